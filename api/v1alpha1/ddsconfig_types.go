@@ -0,0 +1,192 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionTypeReady reports whether the DDSConfig was accepted by DDS.
+	ConditionTypeReady = "Ready"
+
+	// ReasonValid is set when the spec passed validation and is in use.
+	ReasonValid = "Valid"
+	// ReasonInvalidSpec is set when the spec failed validation.
+	ReasonInvalidSpec = "InvalidSpec"
+)
+
+// AttributeMatch is a rule that a device published in a ResourceSlice must
+// satisfy to belong to a model. It checks either an attribute or a capacity.
+type AttributeMatch struct {
+	// Attribute is the name of the device attribute to check.
+	// +optional
+	Attribute string `json:"attribute,omitempty"`
+
+	// Capacity is the name of the device capacity to check. Value is
+	// compared as a resource quantity.
+	// +optional
+	Capacity string `json:"capacity,omitempty"`
+
+	// Type is the value type the attribute must have. Any type is accepted when empty.
+	// +kubebuilder:validation:Enum=string;int;bool;version
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Value is the expected value of the attribute or capacity.
+	Value string `json:"value"`
+}
+
+// DeviceInfo describes a composable device model that DDS can attach to nodes.
+type DeviceInfo struct {
+	// Index identifies the model and is referenced from CannotCoexistWith.
+	// +kubebuilder:validation:Minimum=1
+	Index int `json:"index"`
+
+	// CDIModelName is the model name used in ComposabilityRequests.
+	// +kubebuilder:validation:MinLength=1
+	CDIModelName string `json:"cdiModelName"`
+
+	// DRAAttributes are the DRA device attributes that identify this model in a ResourceSlice.
+	// +optional
+	DRAAttributes map[string]string `json:"draAttributes,omitempty"`
+
+	// Match are additional rules a device must satisfy to be of this model.
+	// +optional
+	Match []AttributeMatch `json:"match,omitempty"`
+
+	// UUIDAttribute is the device attribute that holds the device ID reported
+	// by ComposableResources. Defaults to the uuidAttribute of DriverName.
+	// +optional
+	UUIDAttribute string `json:"uuidAttribute,omitempty"`
+
+	// LabelKeyModel is the node label key used for this model.
+	// +optional
+	LabelKeyModel string `json:"labelKeyModel,omitempty"`
+
+	// DriverName is the DRA driver that publishes devices of this model.
+	// +kubebuilder:validation:MinLength=1
+	DriverName string `json:"driverName"`
+
+	// ResourceType is the CDI resource type used in ComposabilityRequests
+	// for this model. Defaults to the resourceType of DriverName.
+	// +optional
+	ResourceType string `json:"resourceType,omitempty"`
+
+	// K8sDeviceName is the device name used in node labels.
+	// +kubebuilder:validation:MinLength=1
+	K8sDeviceName string `json:"k8sDeviceName"`
+
+	// CannotCoexistWith lists the indexes of models that must not be attached to the same node.
+	// +optional
+	CannotCoexistWith []int `json:"cannotCoexistWith,omitempty"`
+}
+
+// DriverInfo describes how DDS handles the devices of a DRA driver.
+type DriverInfo struct {
+	// Name is the name of the DRA driver.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// ResourceType is the CDI resource type used in ComposabilityRequests
+	// for the devices of the driver. The resourceType of a model takes
+	// precedence.
+	// +optional
+	ResourceType string `json:"resourceType,omitempty"`
+
+	// UUIDAttribute is the device attribute that holds the device ID reported
+	// by ComposableResources. The uuidAttribute of a model takes precedence.
+	// Defaults to "uuid".
+	// +optional
+	UUIDAttribute string `json:"uuidAttribute,omitempty"`
+
+	// ProductNameAttribute is the device attribute matched against the
+	// productName of the draAttributes of a model. Defaults to "productName".
+	// +optional
+	ProductNameAttribute string `json:"productNameAttribute,omitempty"`
+}
+
+// DDSConfigSpec defines the device catalog and labelling used by DDS.
+type DDSConfigSpec struct {
+	// DeviceInfos is the catalog of composable device models.
+	// +kubebuilder:validation:MinItems=1
+	DeviceInfos []DeviceInfo `json:"deviceInfos"`
+
+	// Drivers describe the DRA drivers of the models. gpu.nvidia.com is
+	// known without being listed.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Drivers []DriverInfo `json:"drivers,omitempty"`
+
+	// LabelPrefix is the prefix of node labels and annotations written by DDS.
+	// +kubebuilder:default="composable.fsastech.com"
+	// +kubebuilder:validation:MinLength=1
+	// +optional
+	LabelPrefix string `json:"labelPrefix,omitempty"`
+
+	// FabricIDRange lists the fabric IDs managed by DDS.
+	// +optional
+	FabricIDRange []int `json:"fabricIDRange,omitempty"`
+
+	// DryRun makes DDS only log and record the mutations it would make,
+	// without writing them. It applies to every DDS instance except those
+	// started with --dry-run, whose flag overrides it.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// DDSConfigStatus defines the observed state of DDSConfig.
+type DDSConfigStatus struct {
+	// ObservedGeneration is the generation last processed by DDS.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the spec was accepted.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DDSConfig is the Schema for the ddsconfigs API.
+type DDSConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DDSConfigSpec   `json:"spec,omitempty"`
+	Status DDSConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DDSConfigList contains a list of DDSConfig.
+type DDSConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DDSConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DDSConfig{}, &DDSConfigList{})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DeviceQuotaLimit is the number of devices of a model that the
+// ResourceClaims in the scope of the quota may use.
+type DeviceQuotaLimit struct {
+	// Model is the CDIModelName of a model of the device catalog.
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+
+	// MaxDevices is the number of devices of the model.
+	// +kubebuilder:validation:Minimum=0
+	MaxDevices int `json:"maxDevices"`
+}
+
+// DeviceQuotaSpec defines the device limits of a namespace or of the cluster.
+type DeviceQuotaSpec struct {
+	// Namespace restricts the quota to the ResourceClaims of a namespace.
+	// The quota applies to the whole cluster when empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Limits are the device limits per model.
+	// +listType=map
+	// +listMapKey=model
+	Limits []DeviceQuotaLimit `json:"limits"`
+}
+
+// DeviceQuotaUsage is the number of devices of a model used by the
+// ResourceClaims in the scope of the quota.
+type DeviceQuotaUsage struct {
+	// Model is the CDIModelName of the model.
+	Model string `json:"model"`
+
+	// Used is the number of devices attached or being attached.
+	Used int `json:"used"`
+}
+
+// DeviceQuotaStatus defines the observed state of DeviceQuota.
+type DeviceQuotaStatus struct {
+	// ObservedGeneration is the generation last processed by DDS.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the spec was accepted.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Used are the devices used per model of the limits.
+	// +listType=map
+	// +listMapKey=model
+	// +optional
+	Used []DeviceQuotaUsage `json:"used,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DeviceQuota is the Schema for the devicequotas API.
+type DeviceQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DeviceQuotaSpec   `json:"spec,omitempty"`
+	Status DeviceQuotaStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DeviceQuotaList contains a list of DeviceQuota.
+type DeviceQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DeviceQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DeviceQuota{}, &DeviceQuotaList{})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ModelScalingLimits are the device counts of a model that DDS keeps on the
+// selected nodes.
+type ModelScalingLimits struct {
+	// Model is the CDIModelName of a model of the device catalog.
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+
+	// MinDevice is the number of devices of the model kept attached to
+	// a node, even when no claim uses them.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinDevice *int `json:"minDevice,omitempty"`
+
+	// MaxDevice is the number of devices of the model a node may have.
+	// Claims that would need more are failed.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxDevice *int `json:"maxDevice,omitempty"`
+}
+
+// NodeScalingPolicySpec defines the device limits of the nodes it selects.
+// The `<prefix>/<device>-size-max` and `-size-min` labels of a node override
+// the limits of the policy.
+type NodeScalingPolicySpec struct {
+	// NodeSelector selects the nodes of the policy. An empty selector
+	// selects every node.
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+
+	// Models are the device limits per model.
+	// +listType=map
+	// +listMapKey=model
+	// +optional
+	Models []ModelScalingLimits `json:"models,omitempty"`
+
+	// AllowedModels are the models that may be attached to the selected
+	// nodes. Every model is allowed when empty.
+	// +listType=set
+	// +optional
+	AllowedModels []string `json:"allowedModels,omitempty"`
+
+	// DeviceNoRemoval is how long an attached device is kept after it was
+	// last used. Defaults to DEVICE_NO_REMOVAL_DURATION.
+	// +optional
+	DeviceNoRemoval *metav1.Duration `json:"deviceNoRemoval,omitempty"`
+
+	// DeviceNoAllocation is how long a device is kept after it was attached
+	// without being used. Defaults to DEVICE_NO_ALLOCATION_DURATION.
+	// +optional
+	DeviceNoAllocation *metav1.Duration `json:"deviceNoAllocation,omitempty"`
+}
+
+// NodeScalingPolicyNodeError is an error that keeps DDS from scaling a node
+// selected by the policy.
+type NodeScalingPolicyNodeError struct {
+	// Node is the name of the node.
+	Node string `json:"node"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// NodeScalingPolicyStatus defines the observed state of NodeScalingPolicy.
+type NodeScalingPolicyStatus struct {
+	// ObservedGeneration is the generation last processed by DDS.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the spec was accepted.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// NodeErrors are the selected nodes that DDS does not scale, because
+	// their labels are invalid or they are selected by several policies.
+	// +listType=map
+	// +listMapKey=node
+	// +optional
+	NodeErrors []NodeScalingPolicyNodeError `json:"nodeErrors,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NodeScalingPolicy is the Schema for the nodescalingpolicies API.
+type NodeScalingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeScalingPolicySpec   `json:"spec,omitempty"`
+	Status NodeScalingPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NodeScalingPolicyList contains a list of NodeScalingPolicy.
+type NodeScalingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeScalingPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeScalingPolicy{}, &NodeScalingPolicyList{})
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command dds-sim replays the DDS reconciler offline against a snapshot of
+// ResourceClaims, ResourceSlices, Nodes, ComposabilityRequests,
+// ComposableResources and the DDS configuration, and prints the changes it
+// would make.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/simulator"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+// snapshotFiles collects the repeatable -f flag.
+type snapshotFiles []string
+
+func (f *snapshotFiles) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *snapshotFiles) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func main() {
+	var files snapshotFiles
+	var output string
+	var verbose bool
+	var options simulator.Options
+
+	flag.Var(&files, "f", "Snapshot file with the cluster objects, YAML or JSON. Can be repeated; - reads stdin.")
+	flag.IntVar(&options.Passes, "passes", 1, "Number of reconcile passes over every node.")
+	flag.DurationVar(&options.DeviceNoRemoval, "device-no-removal", 600*time.Second, "DEVICE_NO_REMOVAL_DURATION of the simulated controller.")
+	flag.DurationVar(&options.DeviceNoAllocation, "device-no-allocation", 60*time.Second, "DEVICE_NO_ALLOCATION_DURATION of the simulated controller.")
+	flag.DurationVar(&options.AttachTimeout, "attach-timeout", 600*time.Second, "ATTACH_TIMEOUT of the simulated controller.")
+	flag.DurationVar(&options.ConvergenceTimeout, "convergence-timeout", 300*time.Second, "CONVERGENCE_TIMEOUT of the simulated controller.")
+	flag.DurationVar(&options.ScaleDownCooldown, "scale-down-cooldown", 120*time.Second, "SCALE_DOWN_COOLDOWN of the simulated controller.")
+	flag.StringVar(&output, "o", "text", "Output format: text, yaml or json.")
+	flag.BoolVar(&verbose, "v", false, "Print the log of the reconciler to stderr.")
+	flag.Parse()
+
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one snapshot file is required (-f)")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if verbose {
+		ctrl.SetLogger(zap.New(zap.WriteTo(os.Stderr), zap.UseDevMode(true)))
+	} else {
+		ctrl.SetLogger(logr.Discard())
+	}
+
+	if err := run(files, options, output, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "dds-sim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, options simulator.Options, output string, w io.Writer) error {
+	scheme, err := simulator.NewScheme()
+	if err != nil {
+		return err
+	}
+
+	var objects []client.Object
+	for _, file := range files {
+		loaded, err := loadFile(scheme, file)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, loaded...)
+	}
+
+	report, err := simulator.Run(context.Background(), scheme, objects, options)
+	if err != nil {
+		return err
+	}
+
+	switch output {
+	case "text":
+		return report.WriteText(w)
+	case "yaml":
+		data, err := yaml.Marshal(report)
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %v", err)
+		}
+		_, err = w.Write(data)
+		return err
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+func loadFile(scheme *runtime.Scheme, file string) ([]client.Object, error) {
+	if file == "-" {
+		return simulator.LoadSnapshot(scheme, os.Stdin)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %v", err)
+	}
+	defer f.Close()
+
+	objects, err := simulator.LoadSnapshot(scheme, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	return objects, nil
+}
@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
@@ -31,10 +32,15 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/controller"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	webhookv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -46,23 +52,38 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = cdioperator.AddToScheme(scheme)
+	_ = ddsv1alpha1.AddToScheme(scheme)
 
 	// +kubebuilder:scaffold:scheme
 }
 
 // nolint:gocyclo
 func main() {
-	var enableLeaderElection bool
-	var probeAddr, logLevel string
+	var enableLeaderElection, dryRun bool
+	var probeAddr, logLevel, leaderElectionID string
 
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&logLevel, "log-level", "info", "Set the logging level")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only log and record the mutations DDS would make, without writing them. "+
+			"When given, it overrides the dryRun setting of the configuration.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "2313b367.infra.dds",
+		"The name of the leader election lease. A shadow instance in dry-run mode needs its own.")
 
 	flag.Parse()
 
+	// The dryRun setting of the configuration decides only when --dry-run is
+	// not given, since the configuration is shared with the other instances.
+	var dryRunFlag *bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "dry-run" {
+			dryRunFlag = &dryRun
+		}
+	})
+
 	var level zapcore.Level
 	switch logLevel {
 	case "debug":
@@ -89,7 +110,16 @@ func main() {
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "2313b367.infra.dds",
+		LeaderElectionID:       leaderElectionID,
+		// Only the DDS configuration ConfigMap is read, so avoid caching
+		// ConfigMaps of the whole cluster.
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.ConfigMap{}: {
+					Namespaces: map[string]cache.Config{utils.ConfigMapNamespace: {}},
+				},
+			},
+		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -113,6 +143,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	draVersion, err := utils.DiscoverDRAVersion(clientSet.Discovery())
+	if err != nil {
+		setupLog.Error(err, "unable to discover the resource.k8s.io version")
+		os.Exit(1)
+	}
+	if err := utils.SetDRAVersion(draVersion); err != nil {
+		setupLog.Error(err, "unable to select the resource.k8s.io version")
+		os.Exit(1)
+	}
+	setupLog.Info("Using resource.k8s.io version", "version", draVersion.String())
+
 	scanInterval, err := getEnvAsInt("SCAN_INTERVAL", 60)
 	if err != nil {
 		setupLog.Error(err, "invalid SCAN_INTERVAL")
@@ -131,17 +172,68 @@ func main() {
 		os.Exit(1)
 	}
 
+	attachTimeout, err := getEnvAsInt("ATTACH_TIMEOUT", 0)
+	if err != nil {
+		setupLog.Error(err, "invalid ATTACH_TIMEOUT")
+		os.Exit(1)
+	}
+
+	convergenceTimeout, err := getEnvAsInt("CONVERGENCE_TIMEOUT", 0)
+	if err != nil {
+		setupLog.Error(err, "invalid CONVERGENCE_TIMEOUT")
+		os.Exit(1)
+	}
+
+	scaleDownCooldown, err := getEnvAsInt("SCALE_DOWN_COOLDOWN", 0)
+	if err != nil {
+		setupLog.Error(err, "invalid SCALE_DOWN_COOLDOWN")
+		os.Exit(1)
+	}
+
+	maxConcurrentReconciles, err := getEnvAsInt("MAX_CONCURRENT_RECONCILES", 4)
+	if err != nil {
+		setupLog.Error(err, "invalid MAX_CONCURRENT_RECONCILES")
+		os.Exit(1)
+	}
+
+	var fabricBudgets utils.FabricBudgetConfig
+	for scope, limit := range map[string]*utils.FabricLimit{
+		"GLOBAL": &fabricBudgets.Global,
+		"NODE":   &fabricBudgets.Node,
+		"MODEL":  &fabricBudgets.Model,
+	} {
+		if *limit, err = getFabricLimit(scope); err != nil {
+			setupLog.Error(err, "invalid fabric budget", "scope", scope)
+			os.Exit(1)
+		}
+	}
+
 	if err = (&controller.ResourceMonitorReconciler{
-		Client:             mgr.GetClient(),
-		ClientSet:          clientSet,
-		Scheme:             mgr.GetScheme(),
-		ScanInterval:       time.Duration(scanInterval) * time.Second,
-		DeviceNoRemoval:    time.Duration(deviceNoRemoval) * time.Second,
-		DeviceNoAllocation: time.Duration(deviceNoAllocation) * time.Second,
+		Client:                  mgr.GetClient(),
+		ClientSet:               clientSet,
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("dynamic-device-scaler"),
+		ScanInterval:            time.Duration(scanInterval) * time.Second,
+		DeviceNoRemoval:         time.Duration(deviceNoRemoval) * time.Second,
+		DeviceNoAllocation:      time.Duration(deviceNoAllocation) * time.Second,
+		AttachTimeout:           time.Duration(attachTimeout) * time.Second,
+		ConvergenceTimeout:      time.Duration(convergenceTimeout) * time.Second,
+		ScaleDownCooldown:       time.Duration(scaleDownCooldown) * time.Second,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		DryRun:                  dryRunFlag,
+		FabricBudgets:           fabricBudgets,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResourceMonitor")
 		os.Exit(1)
 	}
+	// Webhooks need serving certificates, so they are only started when
+	// config/webhook is deployed, which sets ENABLE_WEBHOOKS=true.
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = webhookv1alpha1.SetupDDSConfigWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DDSConfig")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -160,6 +252,26 @@ func main() {
 	}
 }
 
+// getFabricLimit reads the budget of fabric operations of a scope from
+// FABRIC_<scope>_OPS_PER_MINUTE, FABRIC_<scope>_BURST and
+// FABRIC_<scope>_MAX_IN_FLIGHT. Unset variables leave the limits disabled.
+func getFabricLimit(scope string) (utils.FabricLimit, error) {
+	var limit utils.FabricLimit
+	var err error
+
+	if limit.OpsPerMinute, err = getEnvAsInt("FABRIC_"+scope+"_OPS_PER_MINUTE", 0); err != nil {
+		return utils.FabricLimit{}, err
+	}
+	if limit.Burst, err = getEnvAsInt("FABRIC_"+scope+"_BURST", 0); err != nil {
+		return utils.FabricLimit{}, err
+	}
+	if limit.MaxInFlight, err = getEnvAsInt("FABRIC_"+scope+"_MAX_IN_FLIGHT", 0); err != nil {
+		return utils.FabricLimit{}, err
+	}
+
+	return limit, nil
+}
+
 func getEnvAsInt(name string, defaultValue int) (int, error) {
 	valueStr := os.Getenv(name)
 	if valueStr == "" {
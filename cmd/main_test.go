@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
 )
 
 func TestGetEnvAsInt(t *testing.T) {
@@ -102,3 +104,52 @@ func TestGetEnvAsInt(t *testing.T) {
 		})
 	}
 }
+
+func TestGetFabricLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantLimit utils.FabricLimit
+		wantErr   bool
+	}{
+		{
+			name:      "Unset",
+			wantLimit: utils.FabricLimit{},
+		},
+		{
+			name: "All set",
+			env: map[string]string{
+				"FABRIC_TEST_OPS_PER_MINUTE": "6",
+				"FABRIC_TEST_BURST":          "2",
+				"FABRIC_TEST_MAX_IN_FLIGHT":  "4",
+			},
+			wantLimit: utils.FabricLimit{OpsPerMinute: 6, Burst: 2, MaxInFlight: 4},
+		},
+		{
+			name:      "Only in flight",
+			env:       map[string]string{"FABRIC_TEST_MAX_IN_FLIGHT": "1"},
+			wantLimit: utils.FabricLimit{MaxInFlight: 1},
+		},
+		{
+			name:    "Invalid burst",
+			env:     map[string]string{"FABRIC_TEST_BURST": "many"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"FABRIC_TEST_OPS_PER_MINUTE", "FABRIC_TEST_BURST", "FABRIC_TEST_MAX_IN_FLIGHT"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			limit, err := getFabricLimit("TEST")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getFabricLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if limit != tt.wantLimit {
+				t.Errorf("getFabricLimit() = %+v, want %+v", limit, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -22,26 +22,72 @@ import (
 	"time"
 
 	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/planner"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
 
-	resourceapi "k8s.io/api/resource/v1beta1"
+	corev1 "k8s.io/api/core/v1"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 // ResourceMonitorReconciler reconciles a ResourceMonitor object
 type ResourceMonitorReconciler struct {
 	client.Client
-	ClientSet          *kubernetes.Clientset
+	ClientSet          kubernetes.Interface
 	Scheme             *runtime.Scheme
+	Recorder           record.EventRecorder
 	ScanInterval       time.Duration
 	DeviceNoRemoval    time.Duration
 	DeviceNoAllocation time.Duration
+	// AttachTimeout is how long the devices of a claim may stay Preparing
+	// before the claim is failed. Zero disables the timeout.
+	AttachTimeout time.Duration
+	// ConvergenceTimeout is how long a resize waits for the previous resize
+	// of its model to settle, and ScaleDownCooldown how long a model is not
+	// scaled down after a scale-up. Zero disables them.
+	ConvergenceTimeout time.Duration
+	ScaleDownCooldown  time.Duration
+	// MaxConcurrentReconciles is the number of nodes reconciled in parallel.
+	MaxConcurrentReconciles int
+	// DryRun makes DDS only log and record its mutations. When nil, the
+	// dry-run setting of the configuration decides at runtime; when set, it
+	// overrides that setting.
+	DryRun *bool
+	// FabricBudgets limits the devices attached and detached per minute and
+	// at once, across the fabric, per node and per model.
+	FabricBudgets utils.FabricBudgetConfig
+
+	configStore    utils.ConfigStore
+	resourceStates utils.ResourceStateTracker
+	// selfWrites records the writes of DDS, so that their watch events do
+	// not trigger another reconcile.
+	selfWrites utils.SelfWriteTracker
+	// fabricBudget holds the budgets of fabric operations and the queue of
+	// the operations they defer.
+	fabricBudget utils.FabricBudget
+	// attachStarts records when the claims of every node started waiting
+	// for their devices.
+	attachStarts utils.AttachStartTracker
+	// resizeHolds records the resizes held on every node, so that a hold is
+	// reported once.
+	resizeHolds utils.ResizeHoldTracker
+	// quotaAdmissions remembers the claims admitted in the DeviceQuotas by
+	// the nodes planned in parallel.
+	quotaAdmissions utils.QuotaAdmissions
 }
 
 //+kubebuilder:rbac:groups=resource.k8s.io,resources=resourceclaims,verbs=get;list;watch;update;patch
@@ -56,207 +102,236 @@ type ResourceMonitorReconciler struct {
 // +kubebuilder:rbac:groups=cro.hpsys.ibm.ie.com,resources=composableresources,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cro.hpsys.ibm.ie.com,resources=composableresources/status,verbs=get;update;patch
 
-//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
-//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;patch;update
+//+kubebuilder:rbac:groups=infra.dds,resources=ddsconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infra.dds,resources=ddsconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infra.dds,resources=nodescalingpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infra.dds,resources=nodescalingpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infra.dds,resources=devicequotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infra.dds,resources=devicequotas/status,verbs=get;update;patch
+
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch;update
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
+// Requests are keyed by node name, so each call only handles the devices of
+// a single node.
 func (r *ResourceMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	reqLogger := ctrl.Log.WithName("DDS")
+	reqLogger := ctrl.Log.WithName("DDS").WithValues("nodeName", req.Name)
 	ctx = ctrl.LoggerInto(ctx, reqLogger)
+	ctx = utils.WithSelfWriteTracker(ctx, &r.selfWrites)
 
 	reqLogger.Info("Start reconcile")
 
-	resourceClaimInfos, resourceSliceInfos, nodeInfos, composableDRASpec, err := r.collectInfo(ctx)
-	if err != nil {
-		return ctrl.Result{}, err
+	node := &corev1.Node{}
+	if err := r.Get(ctx, req.NamespacedName, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			reqLogger.Info("Node not found, deleting its ComposabilityRequests")
+			metrics.DeleteNode(req.Name)
+			r.resourceStates.Forget(req.Name)
+			r.fabricBudget.Forget(req.Name)
+			r.attachStarts.Forget(req.Name)
+			r.resizeHolds.Forget(req.Name)
+			return ctrl.Result{}, r.handleDeletedNode(ctx, req.Name)
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to get Node: %v", err)
 	}
 
-	err = r.updateComposableResourceLastUsedTime(ctx, resourceSliceInfos, composableDRASpec.LabelPrefix)
+	// Loading the configuration writes the DDSConfig status, so the mode is
+	// set from the last configuration until the current one is loaded.
+	lastGood, _ := r.configStore.LastGood()
+	ctx = utils.WithDryRun(ctx, utils.IsDryRunEnabled(lastGood, r.DryRun))
+
+	snapshot, nodeInfo, composableDRASpec, err := r.collectInfo(ctx, node)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	err = r.handleNodes(ctx, nodeInfos, resourceClaimInfos, resourceSliceInfos, composableDRASpec)
+	dryRun := utils.IsDryRunEnabled(composableDRASpec, r.DryRun)
+	metrics.SetDryRun(dryRun)
+	ctx = utils.WithDryRun(ctx, dryRun)
+
+	utils.NotifyComposableResourceStates(ctx, r.Recorder, &r.resourceStates, snapshot)
+
+	retry, err := r.handleNode(ctx, nodeInfo, snapshot, composableDRASpec)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	reqLogger.Info("Reconcile completed successfully", "ScanInterval", r.ScanInterval, "DeviceNoRemoval", r.DeviceNoRemoval, "DeviceNoAllocation", r.DeviceNoAllocation)
+	reqLogger.Info("Reconcile completed successfully", "ScanInterval", r.ScanInterval, "DeviceNoRemoval", r.DeviceNoRemoval, "DeviceNoAllocation", r.DeviceNoAllocation, "AttachTimeout", r.AttachTimeout, "DryRun", dryRun)
 
-	return ctrl.Result{RequeueAfter: r.ScanInterval}, err
+	// Held resizes and operations deferred by the fabric budgets are tried
+	// again as soon as they may go ahead.
+	requeueAfter := r.ScanInterval
+	if retry > 0 && (requeueAfter == 0 || retry < requeueAfter) {
+		requeueAfter = retry
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-func (r *ResourceMonitorReconciler) collectInfo(ctx context.Context) ([]types.ResourceClaimInfo, []types.ResourceSliceInfo, []types.NodeInfo, types.ComposableDRASpec, error) {
+func (r *ResourceMonitorReconciler) collectInfo(ctx context.Context, node *corev1.Node) (*utils.NodeSnapshot, types.NodeInfo, types.ComposableDRASpec, error) {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.Info("Start collecting information")
 
 	var composableDRASpec types.ComposableDRASpec
+	var nodeInfo types.NodeInfo
 
-	composableDRASpec, err := utils.GetConfigMapInfo(ctx, r.ClientSet)
-	if err != nil {
-		return nil, nil, nil, composableDRASpec, err
-	}
-
-	resourceClaimInfos, err := utils.GetResourceClaimInfo(ctx, r.Client, composableDRASpec)
+	composableDRASpec, err := r.configStore.Load(ctx, r.Client)
 	if err != nil {
-		return nil, nil, nil, composableDRASpec, err
+		return nil, nodeInfo, composableDRASpec, err
 	}
 
-	resourceSliceInfos, err := utils.GetResourceSliceInfo(ctx, r.Client)
+	snapshot, err := utils.NewNodeSnapshot(ctx, r.Client, node.Name, composableDRASpec)
 	if err != nil {
-		return nil, nil, nil, composableDRASpec, err
+		return nil, nodeInfo, composableDRASpec, err
 	}
 
-	nodeInfos, err := utils.GetNodeInfo(ctx, r.ClientSet, composableDRASpec)
+	nodeInfo, err = utils.GetNodeInfoWithPolicies(ctx, r.Client, *node, composableDRASpec)
 	if err != nil {
-		return nil, nil, nil, composableDRASpec, err
+		return nil, nodeInfo, composableDRASpec, err
 	}
 
-	return resourceClaimInfos, resourceSliceInfos, nodeInfos, composableDRASpec, nil
+	return snapshot, nodeInfo, composableDRASpec, nil
 }
 
-func (r *ResourceMonitorReconciler) updateComposableResourceLastUsedTime(ctx context.Context, resourceSliceInfos []types.ResourceSliceInfo, labelPrefix string) error {
+// handleNode plans the changes of the node from the snapshot, bounds them by
+// the fabric budgets, then applies them. The plan only depends on the
+// snapshot, so the decisions of a reconcile are consistent even if the
+// cluster changes while it runs. It returns how long to wait before the
+// held resizes and the operations deferred by the budgets are tried again, or
+// zero.
+func (r *ResourceMonitorReconciler) handleNode(ctx context.Context, nodeInfo types.NodeInfo, snapshot *utils.NodeSnapshot, composableDRASpec types.ComposableDRASpec) (time.Duration, error) {
 	logger := ctrl.LoggerFrom(ctx)
-	logger.Info("Start updating ComposableResource last used time")
+	logger.Info("Start handling node")
 
-	resourceList := &cdioperator.ComposableResourceList{}
-	if err := r.List(ctx, resourceList, &client.ListOptions{}); err != nil {
-		return fmt.Errorf("failed to list ComposableResourceList: %v", err)
+	// The NodeScalingPolicy of the node may override the durations.
+	deviceNoRemoval, deviceNoAllocation := r.DeviceNoRemoval, r.DeviceNoAllocation
+	if nodeInfo.DeviceNoRemoval > 0 {
+		deviceNoRemoval = nodeInfo.DeviceNoRemoval
 	}
-
-	for _, resource := range resourceList.Items {
-		if resource.Status.State == "Online" {
-			isRed, resourceSliceInfo, deviceName := utils.IsDeviceResourceSliceRed(resource.Status.DeviceID, resourceSliceInfos)
-			if isRed {
-				isUsed, err := utils.IsDeviceUsedByPod(ctx, r.Client, deviceName, *resourceSliceInfo)
-				if err != nil {
-					return err
-				}
-				if isUsed {
-					currentTime := time.Now().Format(time.RFC3339)
-					if err := utils.PatchComposableResourceAnnotation(ctx, r.Client, resource.Name, labelPrefix+"/last-used-time", currentTime); err != nil {
-						return fmt.Errorf("failed to update ComposableResource: %w", err)
-					}
-				}
-			}
-		}
+	if nodeInfo.DeviceNoAllocation > 0 {
+		deviceNoAllocation = nodeInfo.DeviceNoAllocation
 	}
 
-	return nil
-}
-
-func (r *ResourceMonitorReconciler) handleNodes(ctx context.Context, nodeInfos []types.NodeInfo, resourceClaimInfos []types.ResourceClaimInfo, resourceSliceInfos []types.ResourceSliceInfo, composableDRASpec types.ComposableDRASpec) error {
-	logger := ctrl.LoggerFrom(ctx)
-	logger.Info("Start handling nodes")
+	now := time.Now()
+	r.attachStarts.Observe(snapshot, now)
+
+	input := planner.Input{
+		Snapshot:           snapshot,
+		Node:               nodeInfo,
+		Spec:               composableDRASpec,
+		DeviceNoRemoval:    deviceNoRemoval,
+		DeviceNoAllocation: deviceNoAllocation,
+		AttachTimeout:      r.AttachTimeout,
+		ConvergenceTimeout: r.ConvergenceTimeout,
+		ScaleDownCooldown:  r.ScaleDownCooldown,
+		Now:                now,
+	}
 
-	var err error
-	for _, nodeInfo := range nodeInfos {
-		var nodeResourceClaimInfos []types.ResourceClaimInfo
+	quotas, err := utils.GetDeviceQuotas(ctx, r.Client, composableDRASpec)
+	if err != nil {
+		return 0, err
+	}
 
-		for _, resourceClaimInfo := range resourceClaimInfos {
-			if resourceClaimInfo.NodeName == nodeInfo.Name {
-				nodeResourceClaimInfos = append(nodeResourceClaimInfos, resourceClaimInfo)
+	var plan *types.Plan
+	if len(quotas) == 0 {
+		plan, err = planner.Plan(ctx, input)
+	} else {
+		// The claims of the cluster are collected after the claims admitted
+		// in the DeviceQuotas by the other nodes, so that they include them.
+		plan, err = r.quotaAdmissions.Admit(ctx, r.Client, func(admitted map[k8stypes.UID]bool) (*types.Plan, error) {
+			clusterClaims, err := utils.GetResourceClaimInfo(ctx, r.Client, composableDRASpec)
+			if err != nil {
+				return nil, err
 			}
-		}
-
-		newLogger := logger.WithValues("nodeName", nodeInfo.Name)
-		ctx = ctrl.LoggerInto(ctx, newLogger)
 
-		nodeResourceClaimInfos, err = utils.RescheduleFailedNotification(ctx, r.Client, nodeInfo, nodeResourceClaimInfos, resourceSliceInfos, composableDRASpec)
-		if err != nil {
-			return err
-		}
-
-		nodeResourceClaimInfos, err = utils.RescheduleNotification(ctx, r.Client, nodeResourceClaimInfos, resourceSliceInfos, composableDRASpec.LabelPrefix, r.DeviceNoAllocation)
-		if err != nil {
-			return err
-		}
+			quotaInput := input
+			quotaInput.Quotas, quotaInput.ClusterClaims, quotaInput.AdmittedClaims = quotas, clusterClaims, admitted
+			return planner.Plan(ctx, quotaInput)
+		})
+	}
+	if err != nil {
+		return 0, err
+	}
 
-		err = r.handleDevices(ctx, nodeInfo, nodeResourceClaimInfos, resourceSliceInfos, composableDRASpec)
+	var inFlight utils.FabricInFlight
+	if r.FabricBudgets.InFlightLimited() {
+		inFlight, err = utils.GetFabricInFlight(ctx, r.Client)
 		if err != nil {
-			return err
+			return 0, err
 		}
-
-		err = utils.UpdateNodeLabel(ctx, r.Client, r.ClientSet, nodeInfo.Name, composableDRASpec)
-		if err != nil {
-			return err
+	}
+	retry := r.fabricBudget.Admit(ctx, r.FabricBudgets, plan, inFlight)
+	metrics.SetFabricOperationsQueued(r.fabricBudget.Queued())
+	for _, held := range plan.HeldResizes {
+		if retry == 0 || held.RetryAfter < retry {
+			retry = held.RetryAfter
 		}
 	}
+	r.resizeHolds.Observe(plan)
 
-	return nil
+	return retry, utils.ExecutePlan(ctx, r.Client, r.ClientSet, r.Recorder, plan)
 }
 
-func (r *ResourceMonitorReconciler) handleDevices(ctx context.Context, nodeInfo types.NodeInfo, resourceClaimInfos []types.ResourceClaimInfo, resourceSliceInfos []types.ResourceSliceInfo, composableDRASpec types.ComposableDRASpec) error {
-	logger := ctrl.LoggerFrom(ctx)
-	logger.V(1).Info("Start handling node devices")
-
-	composabilityRequestList := &cdioperator.ComposabilityRequestList{}
-	if err := r.List(ctx, composabilityRequestList, &client.ListOptions{}); err != nil {
-		return err
+// handleDeletedNode deletes the ComposabilityRequests of a node that no longer
+// exists and removes its errors from the NodeScalingPolicies. The node is
+// looked up on the API server first, so that a node missing from a lagging
+// cache does not lose its devices.
+func (r *ResourceMonitorReconciler) handleDeletedNode(ctx context.Context, nodeName string) error {
+	if _, err := r.ClientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{}); err == nil {
+		return fmt.Errorf("node %s is not in the cache yet", nodeName)
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get Node: %v", err)
 	}
 
-	var actualCount int64
-	var requestExit bool
-	for _, device := range composableDRASpec.DeviceInfos {
-		requestExit = false
-
-		newLogger := logger.WithValues("deviceModel", device.CDIModelName)
-		ctx = ctrl.LoggerInto(ctx, newLogger)
-
-		cofiguredDeviceCount, err := utils.GetConfiguredDeviceCount(ctx, r.Client, device.CDIModelName, nodeInfo.Name, resourceClaimInfos, resourceSliceInfos)
-		if err != nil {
-			return err
-		}
-
-		logger.Info("Configured devices count", "count", cofiguredDeviceCount)
+	lastGood, _ := r.configStore.LastGood()
+	ctx = utils.WithDryRun(ctx, utils.IsDryRunEnabled(lastGood, r.DryRun))
 
-		_, minCountLimit := utils.GetModelLimit(nodeInfo, device.CDIModelName)
-		if cofiguredDeviceCount < minCountLimit {
-			cofiguredDeviceCount = minCountLimit
-		}
-
-		logger.Info("Actual cofiguredDeviceCount", "count", cofiguredDeviceCount)
-
-		for _, cr := range composabilityRequestList.Items {
-			if cr.Spec.Resource.Model == device.CDIModelName && cr.Spec.Resource.TargetNode == nodeInfo.Name {
-				actualCount = cr.Spec.Resource.Size
-				if cofiguredDeviceCount > actualCount {
-					err := utils.DynamicAttach(ctx, r.Client, &cr, cofiguredDeviceCount, cr.Spec.Resource.Type, device.CDIModelName, nodeInfo.Name)
-					if err != nil {
-						return err
-					}
-				} else if cofiguredDeviceCount < actualCount {
-					err := utils.DynamicDetach(ctx, r.Client, &cr, cofiguredDeviceCount, nodeInfo.Name, composableDRASpec.LabelPrefix, r.DeviceNoRemoval)
-					if err != nil {
-						return err
-					}
-				}
-				requestExit = true
-				break
-			}
-		}
+	composableDRASpec, err := r.configStore.Load(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+	ctx = utils.WithDryRun(ctx, utils.IsDryRunEnabled(composableDRASpec, r.DryRun))
 
-		if !requestExit && cofiguredDeviceCount > 0 {
-			resourceType := utils.GetDriverType(device.DriverName)
-			err := utils.DynamicAttach(ctx, r.Client, nil, cofiguredDeviceCount, resourceType, device.CDIModelName, nodeInfo.Name)
-			if err != nil {
-				return err
-			}
-		}
+	if err := utils.DeleteOrphanedComposabilityRequests(ctx, r.Client, r.Recorder, nodeName, composableDRASpec.LabelPrefix); err != nil {
+		return err
 	}
 
-	return nil
+	return utils.ClearNodeScalingPolicyErrors(ctx, r.Client, nodeName)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceMonitorReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	eventHandler := handler.EnqueueRequestForObject{}
+	if err := utils.SetupFieldIndexers(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
+	isConfigMap := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == utils.ConfigMapNamespace && obj.GetName() == utils.ConfigMapName
+	})
+	isDDSConfig := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == utils.DDSConfigName
+	})
 
 	return ctrl.NewControllerManagedBy(mgr).
-		Watches(&resourceapi.ResourceClaim{}, &eventHandler).
-		Watches(&resourceapi.ResourceSlice{}, &eventHandler).
+		For(&corev1.Node{}, builder.WithPredicates(watchPredicate("Node", &r.selfWrites,
+			predicate.Or[client.Object](nodeLabelsChangedPredicate(r.configStore.LastGood), nodeLifecycleChangedPredicate())))).
+		Watches(utils.NewResourceClaim(), enqueueNodes(resourceClaimNodeNames),
+			builder.WithPredicates(watchPredicate("ResourceClaim", &r.selfWrites, resourceClaimChangedPredicate()))).
+		Watches(utils.NewResourceSlice(), enqueueNodes(resourceSliceNodeNames),
+			builder.WithPredicates(watchPredicate("ResourceSlice", &r.selfWrites, predicate.GenerationChangedPredicate{}))).
+		Watches(&cdioperator.ComposableResource{}, enqueueNodes(composableResourceNodeNames),
+			builder.WithPredicates(watchPredicate("ComposableResource", &r.selfWrites, composableResourceChangedPredicate()))).
+		Watches(&cdioperator.ComposabilityRequest{}, enqueueNodes(composabilityRequestNodeNames),
+			builder.WithPredicates(watchPredicate("ComposabilityRequest", &r.selfWrites, composabilityRequestChangedPredicate(r.configStore.LastGood)))).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.mapToAllNodes), builder.WithPredicates(isConfigMap)).
+		Watches(&ddsv1alpha1.DDSConfig{}, handler.EnqueueRequestsFromMapFunc(r.mapToAllNodes), builder.WithPredicates(isDDSConfig, predicate.GenerationChangedPredicate{})).
+		Watches(&ddsv1alpha1.NodeScalingPolicy{}, handler.EnqueueRequestsFromMapFunc(r.mapToAllNodes), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&ddsv1alpha1.DeviceQuota{}, handler.EnqueueRequestsFromMapFunc(r.mapToAllNodes), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Named("resourcemonitor").
 		Complete(r)
 }
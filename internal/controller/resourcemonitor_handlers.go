@@ -0,0 +1,305 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// enqueueNodes returns an event handler that enqueues the nodes an object
+// refers to. Updates map both the old and the new object, so that a node
+// losing a claim or a device is reconciled as well as the node gaining it.
+func enqueueNodes(nodeNames func(client.Object) []string) handler.EventHandler {
+	enqueue := func(queue workqueue.TypedRateLimitingInterface[reconcile.Request], objs ...client.Object) {
+		for _, obj := range objs {
+			for _, nodeName := range nodeNames(obj) {
+				queue.Add(reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: nodeName}})
+			}
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(_ context.Context, e event.CreateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(queue, e.Object)
+		},
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(queue, e.ObjectOld, e.ObjectNew)
+		},
+		DeleteFunc: func(_ context.Context, e event.DeleteEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(queue, e.Object)
+		},
+		GenericFunc: func(_ context.Context, e event.GenericEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(queue, e.Object)
+		},
+	}
+}
+
+// resourceClaimNodeNames returns the node a ResourceClaim is allocated on.
+func resourceClaimNodeNames(obj client.Object) []string {
+	rc, err := utils.ToResourceClaim(obj)
+	if err != nil {
+		return nil
+	}
+
+	return nonEmpty(utils.GetResourceClaimNodeName(*rc))
+}
+
+// resourceSliceNodeNames returns the node a ResourceSlice is published for.
+// Slices that are not bound to a node, such as the fabric pool published by
+// the Composable DRA Driver, are ignored: claims allocated from them are
+// mapped through the ResourceClaim watch.
+func resourceSliceNodeNames(obj client.Object) []string {
+	return nonEmpty(utils.GetResourceSliceNodeName(obj))
+}
+
+func composableResourceNodeNames(obj client.Object) []string {
+	resource, ok := obj.(*cdioperator.ComposableResource)
+	if !ok {
+		return nil
+	}
+
+	return nonEmpty(resource.Spec.TargetNode)
+}
+
+func composabilityRequestNodeNames(obj client.Object) []string {
+	cr, ok := obj.(*cdioperator.ComposabilityRequest)
+	if !ok {
+		return nil
+	}
+
+	return nonEmpty(cr.Spec.Resource.TargetNode)
+}
+
+// mapToAllNodes enqueues every node. It is used for configuration changes,
+// which affect the device catalog of the whole cluster.
+func (r *ResourceMonitorReconciler) mapToAllNodes(ctx context.Context, _ client.Object) []reconcile.Request {
+	logger := ctrl.LoggerFrom(ctx)
+
+	nodeList := &corev1.NodeList{}
+	if err := r.List(ctx, nodeList, &client.ListOptions{}); err != nil {
+		logger.Error(err, "Failed to list Nodes for configuration change")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(nodeList.Items))
+	for _, node := range nodeList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: node.Name}})
+	}
+
+	return requests
+}
+
+// nodeLifecycleChangedPredicate passes the updates of a node that cordon,
+// uncordon or delete it, or change whether it is ready.
+func nodeLifecycleChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return false
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return false
+			}
+
+			return utils.IsNodeCordoned(*oldNode) != utils.IsNodeCordoned(*newNode) ||
+				utils.IsNodeNotReady(*oldNode) != utils.IsNodeNotReady(*newNode)
+		},
+	}
+}
+
+// nodeLabelsChangedPredicate passes the updates of a node that change its
+// labels, other than the device labels DDS sets on it. The size-min and
+// size-max labels and the labels selected by the NodeScalingPolicies
+// constrain the devices of the node; the device labels only reflect them.
+// Every label change passes until a configuration is loaded.
+func nodeLabelsChangedPredicate(lastSpec func() (types.ComposableDRASpec, bool)) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			oldLabels, newLabels := e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()
+
+			composableDRASpec, ok := lastSpec()
+			isDeviceLabel := func(key string) bool {
+				return ok && slices.ContainsFunc(composableDRASpec.DeviceInfos, func(deviceInfo types.DeviceInfo) bool {
+					return key == composableDRASpec.LabelPrefix+"/"+deviceInfo.K8sDeviceName
+				})
+			}
+
+			for key, value := range newLabels {
+				if oldValue, exists := oldLabels[key]; (!exists || oldValue != value) && !isDeviceLabel(key) {
+					return true
+				}
+			}
+			for key := range oldLabels {
+				if _, exists := newLabels[key]; !exists && !isDeviceLabel(key) {
+					return true
+				}
+			}
+
+			return false
+		},
+	}
+}
+
+// composableResourceChangedPredicate passes the updates of a
+// ComposableResource that move it to another node or model, change its
+// state, error or device, or start its deletion. The last-used-time
+// annotation DDS writes on every pass is not a transition.
+func composableResourceChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldResource, ok := e.ObjectOld.(*cdioperator.ComposableResource)
+			if !ok {
+				return false
+			}
+			newResource, ok := e.ObjectNew.(*cdioperator.ComposableResource)
+			if !ok {
+				return false
+			}
+
+			return oldResource.Spec.TargetNode != newResource.Spec.TargetNode ||
+				oldResource.Spec.Model != newResource.Spec.Model ||
+				oldResource.Status.State != newResource.Status.State ||
+				oldResource.Status.Error != newResource.Status.Error ||
+				oldResource.Status.DeviceID != newResource.Status.DeviceID ||
+				oldResource.Status.CDIDeviceID != newResource.Status.CDIDeviceID ||
+				(oldResource.DeletionTimestamp == nil) != (newResource.DeletionTimestamp == nil)
+		},
+	}
+}
+
+// composabilityRequestChangedPredicate passes the updates of a
+// ComposabilityRequest that resize it, show the operator progressing on its
+// size, move it to another node or model, change its owner labels or start
+// its deletion. The owner labels are those of the label prefix of the last
+// configuration, and those of earlier DDS versions.
+func composabilityRequestChangedPredicate(lastSpec func() (types.ComposableDRASpec, bool)) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCR, ok := e.ObjectOld.(*cdioperator.ComposabilityRequest)
+			if !ok {
+				return false
+			}
+			newCR, ok := e.ObjectNew.(*cdioperator.ComposabilityRequest)
+			if !ok {
+				return false
+			}
+
+			composableDRASpec, _ := lastSpec()
+			ownerLabelsChanged := slices.ContainsFunc(utils.OwnerLabelKeys(composableDRASpec.LabelPrefix), func(key string) bool {
+				return oldCR.Labels[key] != newCR.Labels[key]
+			})
+
+			return oldCR.Spec.Resource.Size != newCR.Spec.Resource.Size ||
+				oldCR.Status.State != newCR.Status.State ||
+				oldCR.Status.ScalarResource.Size != newCR.Status.ScalarResource.Size ||
+				oldCR.Spec.Resource.TargetNode != newCR.Spec.Resource.TargetNode ||
+				oldCR.Spec.Resource.Model != newCR.Spec.Resource.Model ||
+				ownerLabelsChanged ||
+				(oldCR.DeletionTimestamp == nil) != (newCR.DeletionTimestamp == nil)
+		},
+	}
+}
+
+// resourceClaimChangedPredicate passes the updates of a ResourceClaim that
+// change its spec or the status fields DDS reads, such as its allocation,
+// reservations or device conditions, or start its deletion. Label and
+// annotation changes do not affect its devices.
+func resourceClaimChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRC, err := utils.ToResourceClaim(e.ObjectOld)
+			if err != nil {
+				return true
+			}
+			newRC, err := utils.ToResourceClaim(e.ObjectNew)
+			if err != nil {
+				return true
+			}
+
+			return oldRC.Generation != newRC.Generation ||
+				!equality.Semantic.DeepEqual(oldRC.Status, newRC.Status) ||
+				(oldRC.DeletionTimestamp == nil) != (newRC.DeletionTimestamp == nil)
+		},
+	}
+}
+
+// watchPredicate passes the events of a kind that pass every predicate and
+// do not only show a write of DDS itself, recorded in selfWrites. Every
+// event is counted in dds_watch_events_total, so that a controller at rest
+// shows no processed events caused by its own writes.
+func watchPredicate(kind string, selfWrites *utils.SelfWriteTracker, predicates ...predicate.Predicate) predicate.Predicate {
+	filter := predicate.And[client.Object](predicates...)
+	count := func(obj client.Object, passed bool) bool {
+		switch {
+		case selfWrites.IsSelfWrite(obj):
+			metrics.RecordWatchEvent(kind, metrics.WatchEventSelfWrite)
+			return false
+		case !passed:
+			metrics.RecordWatchEvent(kind, metrics.WatchEventUnchanged)
+			return false
+		default:
+			metrics.RecordWatchEvent(kind, metrics.WatchEventProcessed)
+			return true
+		}
+	}
+
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return count(e.Object, filter.Create(e))
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return count(e.ObjectNew, filter.Update(e))
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return count(nil, filter.Delete(e))
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return count(nil, filter.Generic(e))
+		},
+	}
+}
+
+func nonEmpty(nodeName string) []string {
+	if nodeName == "" {
+		return nil
+	}
+
+	return []string{nodeName}
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestNodeNames(t *testing.T) {
+	testCases := []struct {
+		name      string
+		nodeNames func(client.Object) []string
+		obj       client.Object
+		expected  []string
+	}{
+		{
+			name:      "allocated ResourceClaim",
+			nodeNames: resourceClaimNodeNames,
+			obj: &resourceapi.ResourceClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "rc0", Namespace: "default"},
+				Status: resourceapi.ResourceClaimStatus{
+					Allocation: &resourceapi.AllocationResult{
+						NodeSelector: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{
+								{
+									MatchFields: []corev1.NodeSelectorRequirement{
+										{
+											Key:      "metadata.name",
+											Operator: corev1.NodeSelectorOpIn,
+											Values:   []string{"node1"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []string{"node1"},
+		},
+		{
+			name:      "unallocated ResourceClaim",
+			nodeNames: resourceClaimNodeNames,
+			obj: &resourceapi.ResourceClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "rc0", Namespace: "default"},
+			},
+		},
+		{
+			name:      "node-local ResourceSlice",
+			nodeNames: resourceSliceNodeNames,
+			obj: &resourceapi.ResourceSlice{
+				ObjectMeta: metav1.ObjectMeta{Name: "rs0"},
+				Spec:       resourceapi.ResourceSliceSpec{NodeName: "node1"},
+			},
+			expected: []string{"node1"},
+		},
+		{
+			name:      "fabric ResourceSlice",
+			nodeNames: resourceSliceNodeNames,
+			obj: &resourceapi.ResourceSlice{
+				ObjectMeta: metav1.ObjectMeta{Name: "rs0"},
+			},
+		},
+		{
+			name:      "ComposableResource",
+			nodeNames: composableResourceNodeNames,
+			obj: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{Name: "res0"},
+				Spec:       cdioperator.ComposableResourceSpec{TargetNode: "node2"},
+			},
+			expected: []string{"node2"},
+		},
+		{
+			name:      "ComposabilityRequest",
+			nodeNames: composabilityRequestNodeNames,
+			obj: &cdioperator.ComposabilityRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "cr0"},
+				Spec: cdioperator.ComposabilityRequestSpec{
+					Resource: cdioperator.ScalarResourceDetails{TargetNode: "node3"},
+				},
+			},
+			expected: []string{"node3"},
+		},
+		{
+			name:      "unexpected type",
+			nodeNames: composabilityRequestNodeNames,
+			obj:       &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.nodeNames(tc.obj)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Node names are incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNodeLifecycleChangedPredicate(t *testing.T) {
+	ready := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}}
+	notReady := corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}}
+
+	testCases := []struct {
+		name     string
+		oldNode  *corev1.Node
+		newNode  *corev1.Node
+		expected bool
+	}{
+		{
+			name:     "cordoned",
+			oldNode:  &corev1.Node{Status: ready},
+			newNode:  &corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}, Status: ready},
+			expected: true,
+		},
+		{
+			name:     "became NotReady",
+			oldNode:  &corev1.Node{Status: ready},
+			newNode:  &corev1.Node{Status: notReady},
+			expected: true,
+		},
+		{
+			name:    "heartbeat",
+			oldNode: &corev1.Node{Status: ready},
+			newNode: &corev1.Node{Status: ready, Spec: corev1.NodeSpec{PodCIDR: "10.0.0.0/24"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := nodeLifecycleChangedPredicate().Update(event.UpdateEvent{ObjectOld: tc.oldNode, ObjectNew: tc.newNode})
+			if result != tc.expected {
+				t.Errorf("predicate result is incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNodeLabelsChangedPredicate(t *testing.T) {
+	spec := types.ComposableDRASpec{
+		LabelPrefix: "composable.fsastech.com",
+		DeviceInfos: []types.DeviceInfo{{Index: 1, CDIModelName: "A100 40G", K8sDeviceName: "nvidia-a100-40g"}},
+	}
+	node := func(labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: labels}}
+	}
+
+	testCases := []struct {
+		name     string
+		loaded   bool
+		oldNode  *corev1.Node
+		newNode  *corev1.Node
+		expected bool
+	}{
+		{
+			name:     "size-max label changed",
+			loaded:   true,
+			oldNode:  node(map[string]string{"composable.fsastech.com/nvidia-a100-40g-size-max": "2"}),
+			newNode:  node(map[string]string{"composable.fsastech.com/nvidia-a100-40g-size-max": "4"}),
+			expected: true,
+		},
+		{
+			name:     "policy selector label added",
+			loaded:   true,
+			oldNode:  node(nil),
+			newNode:  node(map[string]string{"pool": "gpu"}),
+			expected: true,
+		},
+		{
+			name:     "label removed",
+			loaded:   true,
+			oldNode:  node(map[string]string{"pool": "gpu"}),
+			newNode:  node(nil),
+			expected: true,
+		},
+		{
+			name:    "device label set by DDS",
+			loaded:  true,
+			oldNode: node(map[string]string{"pool": "gpu"}),
+			newNode: node(map[string]string{"pool": "gpu", "composable.fsastech.com/nvidia-a100-40g": "true"}),
+		},
+		{
+			name:    "device label removed by DDS",
+			loaded:  true,
+			oldNode: node(map[string]string{"composable.fsastech.com/nvidia-a100-40g": "true"}),
+			newNode: node(nil),
+		},
+		{
+			name:     "device label before the configuration is loaded",
+			oldNode:  node(nil),
+			newNode:  node(map[string]string{"composable.fsastech.com/nvidia-a100-40g": "true"}),
+			expected: true,
+		},
+		{
+			name:    "labels unchanged",
+			loaded:  true,
+			oldNode: node(map[string]string{"pool": "gpu"}),
+			newNode: node(map[string]string{"pool": "gpu"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lastSpec := func() (types.ComposableDRASpec, bool) {
+				if !tc.loaded {
+					return types.ComposableDRASpec{}, false
+				}
+				return spec, true
+			}
+
+			result := nodeLabelsChangedPredicate(lastSpec).Update(event.UpdateEvent{ObjectOld: tc.oldNode, ObjectNew: tc.newNode})
+			if result != tc.expected {
+				t.Errorf("predicate result is incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestComposableResourceChangedPredicate(t *testing.T) {
+	resource := func(state, lastUsed string) *cdioperator.ComposableResource {
+		return &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "res0",
+				Annotations: map[string]string{"composable.fsastech.com/last-used-time": lastUsed},
+			},
+			Spec: cdioperator.ComposableResourceSpec{
+				Model:      "A100 40G",
+				TargetNode: "node1",
+			},
+			Status: cdioperator.ComposableResourceStatus{
+				State: state,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		oldResource *cdioperator.ComposableResource
+		newResource *cdioperator.ComposableResource
+		expected    bool
+	}{
+		{
+			name:        "came online",
+			oldResource: resource("Attaching", ""),
+			newResource: resource("Online", ""),
+			expected:    true,
+		},
+		{
+			name:        "last-used time written",
+			oldResource: resource("Online", "2025-01-01T00:00:00Z"),
+			newResource: resource("Online", "2025-01-01T00:01:00Z"),
+		},
+		{
+			name:        "error reported",
+			oldResource: resource("Online", ""),
+			newResource: func() *cdioperator.ComposableResource {
+				r := resource("Online", "")
+				r.Status.Error = "device lost"
+				return r
+			}(),
+			expected: true,
+		},
+		{
+			name:        "deletion started",
+			oldResource: resource("Online", ""),
+			newResource: func() *cdioperator.ComposableResource {
+				r := resource("Online", "")
+				r.DeletionTimestamp = &metav1.Time{}
+				return r
+			}(),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := composableResourceChangedPredicate().Update(event.UpdateEvent{ObjectOld: tc.oldResource, ObjectNew: tc.newResource})
+			if result != tc.expected {
+				t.Errorf("predicate result is incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestComposabilityRequestChangedPredicate(t *testing.T) {
+	lastSpec := func() (types.ComposableDRASpec, bool) {
+		return types.ComposableDRASpec{LabelPrefix: "composable.test"}, true
+	}
+	request := func(size int64, labels map[string]string) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "cr0", Labels: labels},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Model:      "A100 40G",
+					TargetNode: "node1",
+					Size:       size,
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		oldCR    *cdioperator.ComposabilityRequest
+		newCR    *cdioperator.ComposabilityRequest
+		expected bool
+	}{
+		{
+			name:     "resized",
+			oldCR:    request(1, nil),
+			newCR:    request(2, nil),
+			expected: true,
+		},
+		{
+			name:     "adopted",
+			oldCR:    request(1, nil),
+			newCR:    request(1, map[string]string{"composable.test/node": "node1", "composable.test/model": "a100-40g"}),
+			expected: true,
+		},
+		{
+			name:     "labeled by an earlier version",
+			oldCR:    request(1, nil),
+			newCR:    request(1, map[string]string{"infra.dds/node": "node1", "infra.dds/model": "a100-40g"}),
+			expected: true,
+		},
+		{
+			name:  "unrelated label",
+			oldCR: request(1, nil),
+			newCR: request(1, map[string]string{"team": "a"}),
+		},
+		{
+			name:  "size observed by the operator",
+			oldCR: request(2, nil),
+			newCR: func() *cdioperator.ComposabilityRequest {
+				cr := request(2, nil)
+				cr.Status.State = "Updating"
+				cr.Status.ScalarResource.Size = 2
+				return cr
+			}(),
+			expected: true,
+		},
+		{
+			name:  "resize annotations",
+			oldCR: request(2, nil),
+			newCR: func() *cdioperator.ComposabilityRequest {
+				cr := request(2, nil)
+				cr.Annotations = map[string]string{"infra.dds/resized-at": "2025-01-01T00:00:00Z"}
+				return cr
+			}(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := composabilityRequestChangedPredicate(lastSpec).Update(event.UpdateEvent{ObjectOld: tc.oldCR, ObjectNew: tc.newCR})
+			if result != tc.expected {
+				t.Errorf("predicate result is incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestResourceClaimChangedPredicate(t *testing.T) {
+	claim := func(labels map[string]string, reservedFor ...string) *resourceapi.ResourceClaim {
+		rc := &resourceapi.ResourceClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "rc0", Namespace: "default", Generation: 1, Labels: labels},
+		}
+		for _, name := range reservedFor {
+			rc.Status.ReservedFor = append(rc.Status.ReservedFor, resourceapi.ResourceClaimConsumerReference{Resource: "pods", Name: name})
+		}
+		return rc
+	}
+
+	testCases := []struct {
+		name     string
+		oldRC    *resourceapi.ResourceClaim
+		newRC    *resourceapi.ResourceClaim
+		expected bool
+	}{
+		{
+			name:     "reserved for a pod",
+			oldRC:    claim(nil),
+			newRC:    claim(nil, "pod0"),
+			expected: true,
+		},
+		{
+			name:  "label added",
+			oldRC: claim(nil, "pod0"),
+			newRC: claim(map[string]string{"team": "a"}, "pod0"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := resourceClaimChangedPredicate().Update(event.UpdateEvent{ObjectOld: tc.oldRC, ObjectNew: tc.newRC})
+			if result != tc.expected {
+				t.Errorf("predicate result is incorrect. Got: %v, Want: %v", result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestWatchPredicate(t *testing.T) {
+	selfWrites := &utils.SelfWriteTracker{}
+	resource := func(resourceVersion, state string) *cdioperator.ComposableResource {
+		return &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "res0", UID: "uid0", ResourceVersion: resourceVersion},
+			Status:     cdioperator.ComposableResourceStatus{State: state},
+		}
+	}
+	p := watchPredicate("ComposableResource", selfWrites, composableResourceChangedPredicate())
+
+	selfWrites.Record(resource("2", "Online"))
+
+	if p.Update(event.UpdateEvent{ObjectOld: resource("1", "Attaching"), ObjectNew: resource("2", "Online")}) {
+		t.Errorf("an update written by DDS passed")
+	}
+	if !p.Update(event.UpdateEvent{ObjectOld: resource("2", "Online"), ObjectNew: resource("3", "Detaching")}) {
+		t.Errorf("a state change did not pass")
+	}
+	if p.Update(event.UpdateEvent{ObjectOld: resource("3", "Detaching"), ObjectNew: resource("4", "Detaching")}) {
+		t.Errorf("an unchanged resource passed")
+	}
+	if !p.Create(event.CreateEvent{Object: resource("5", "Attaching")}) {
+		t.Errorf("a creation did not pass")
+	}
+	if !p.Delete(event.DeleteEvent{Object: resource("5", "Attaching")}) {
+		t.Errorf("a deletion did not pass")
+	}
+}
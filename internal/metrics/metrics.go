@@ -0,0 +1,301 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the Prometheus metrics exported by DDS. They are
+// registered with the controller-runtime registry and served on the metrics
+// endpoint of the manager.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DecisionAttach and DecisionDetach label scaling decisions.
+	DecisionAttach = "attach"
+	DecisionDetach = "detach"
+)
+
+const (
+	// ActionScaleUpBlocked, ActionReclaim and ActionGarbageCollect label the
+	// actions DDS takes on node lifecycle changes.
+	ActionScaleUpBlocked = "scale_up_blocked"
+	ActionReclaim        = "reclaim"
+	ActionGarbageCollect = "garbage_collect"
+)
+
+const (
+	// WatchEventProcessed, WatchEventSelfWrite and WatchEventUnchanged label
+	// the watch events that trigger a reconcile, and those suppressed because
+	// they only show a write of DDS or change nothing DDS depends on.
+	WatchEventProcessed = "processed"
+	WatchEventSelfWrite = "self_write"
+	WatchEventUnchanged = "unchanged"
+)
+
+var (
+	desiredDevices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "desired_devices",
+		Help:      "Number of devices of a model that DDS wants attached to a node.",
+	}, []string{"node", "model"})
+
+	actualDevices = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "actual_devices",
+		Help:      "Size of the ComposabilityRequest of a model on a node.",
+	}, []string{"node", "model"})
+
+	scalingDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "scaling_decisions_total",
+		Help:      "Number of attach and detach decisions taken by DDS.",
+	}, []string{"node", "model", "decision"})
+
+	claimTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "claim_transitions_total",
+		Help:      "Number of ResourceClaims moved to the Reschedule or Failed state.",
+	}, []string{"state", "reason"})
+
+	attachLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "dds",
+		Name:      "attach_latency_seconds",
+		Help:      "Time from the allocation of a ResourceClaim until its devices are ready and it is rescheduled.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 10),
+	})
+
+	deviceIdle = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "device_idle_seconds",
+		Help:      "Time since an online ComposableResource was last used by a pod, from its last-used-time annotation.",
+	}, []string{"node", "model", "resource"})
+
+	resourceStates = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "composable_resources",
+		Help:      "Number of ComposableResources of a model on a node by state.",
+	}, []string{"node", "model", "state"})
+
+	resourceCycles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "composable_resource_cycles_total",
+		Help:      "Number of times a ComposableResource failed or fell back from Online to attaching.",
+	}, []string{"node", "model"})
+
+	dryRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "dry_run",
+		Help:      "Whether DDS runs in dry-run mode (1) and only records its mutations, or applies them (0).",
+	})
+
+	configStale = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "config_stale",
+		Help:      "Whether the configuration is invalid and DDS runs on the last valid one (1), or runs on the current one (0).",
+	})
+
+	dryRunMutations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "dry_run_mutations_total",
+		Help:      "Number of mutations that DDS skipped in dry-run mode.",
+	}, []string{"mutation"})
+
+	nodeLifecycleActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "node_lifecycle_actions_total",
+		Help:      "Number of scale-ups blocked, devices reclaimed and ComposabilityRequests deleted because a node is cordoned, NotReady or deleted.",
+	}, []string{"node", "action"})
+
+	watchEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "watch_events_total",
+		Help:      "Number of watch events by kind that triggered a reconcile or were suppressed.",
+	}, []string{"kind", "result"})
+
+	fabricOperationsDeferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "fabric_operations_deferred_total",
+		Help:      "Number of attach and detach operations deferred, entirely or in part, by a budget of fabric operations.",
+	}, []string{"node", "model", "scope", "limit"})
+
+	resizesHeld = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "resizes_held_total",
+		Help:      "Number of times a resize started to be held, or was held for another reason, until the previous resize settles or the scale-down cooldown ends.",
+	}, []string{"node", "model", "reason"})
+
+	convergenceTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dds",
+		Name:      "convergence_timeouts_total",
+		Help:      "Number of resizes done although the previous resize did not settle within the convergence timeout.",
+	}, []string{"node", "model"})
+
+	fabricOperationsQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "dds",
+		Name:      "fabric_operations_queued",
+		Help:      "Number of deferred attach and detach operations waiting for a budget of fabric operations.",
+	})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		desiredDevices,
+		actualDevices,
+		scalingDecisions,
+		claimTransitions,
+		attachLatency,
+		deviceIdle,
+		resourceStates,
+		resourceCycles,
+		dryRun,
+		configStale,
+		dryRunMutations,
+		nodeLifecycleActions,
+		watchEvents,
+		fabricOperationsDeferred,
+		fabricOperationsQueued,
+		resizesHeld,
+		convergenceTimeouts,
+	)
+}
+
+// RecordDeviceCounts sets the desired and actual device count of a model on a node.
+func RecordDeviceCounts(nodeName, model string, desired, actual int64) {
+	desiredDevices.WithLabelValues(nodeName, model).Set(float64(desired))
+	actualDevices.WithLabelValues(nodeName, model).Set(float64(actual))
+}
+
+// ResetDeviceCounts drops the device counts of a node, so that models removed
+// from the device catalog do not keep reporting their last value.
+func ResetDeviceCounts(nodeName string) {
+	desiredDevices.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+	actualDevices.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+}
+
+// RecordScalingDecision counts an attach or detach decision.
+func RecordScalingDecision(nodeName, model, decision string) {
+	scalingDecisions.WithLabelValues(nodeName, model, decision).Inc()
+}
+
+// RecordClaimTransition counts a ResourceClaim moved to the given state.
+func RecordClaimTransition(state, reason string) {
+	claimTransitions.WithLabelValues(state, reason).Inc()
+}
+
+// ObserveAttachLatency records how long a ResourceClaim waited for its devices
+// once they started being prepared.
+func ObserveAttachLatency(latency time.Duration) {
+	attachLatency.Observe(latency.Seconds())
+}
+
+// RecordDeviceIdle sets the idle time of a ComposableResource.
+func RecordDeviceIdle(nodeName, model, resourceName string, idle time.Duration) {
+	deviceIdle.WithLabelValues(nodeName, model, resourceName).Set(idle.Seconds())
+}
+
+// ResetDeviceIdle drops the idle times of a node before they are recorded again.
+func ResetDeviceIdle(nodeName string) {
+	deviceIdle.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+}
+
+// RecordResourceState counts a ComposableResource in the given state.
+func RecordResourceState(nodeName, model, state string) {
+	resourceStates.WithLabelValues(nodeName, model, state).Inc()
+}
+
+// ResetResourceStates drops the ComposableResource states of a node before
+// they are recorded again.
+func ResetResourceStates(nodeName string) {
+	resourceStates.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+}
+
+// RecordResourceCycle counts a ComposableResource that failed or re-attached.
+func RecordResourceCycle(nodeName, model string) {
+	resourceCycles.WithLabelValues(nodeName, model).Inc()
+}
+
+// SetDryRun records whether DDS runs in dry-run mode.
+func SetDryRun(enabled bool) {
+	if enabled {
+		dryRun.Set(1)
+		return
+	}
+	dryRun.Set(0)
+}
+
+// SetConfigStale records whether DDS runs on the last valid configuration
+// because the current one is invalid.
+func SetConfigStale(stale bool) {
+	if stale {
+		configStale.Set(1)
+		return
+	}
+	configStale.Set(0)
+}
+
+// RecordDryRunMutation counts a mutation skipped in dry-run mode.
+func RecordDryRunMutation(mutation string) {
+	dryRunMutations.WithLabelValues(mutation).Inc()
+}
+
+// RecordNodeLifecycleAction counts an action taken on a node lifecycle change.
+func RecordNodeLifecycleAction(nodeName, action string) {
+	nodeLifecycleActions.WithLabelValues(nodeName, action).Inc()
+}
+
+// DeleteNode drops every series of a node that no longer exists.
+func DeleteNode(nodeName string) {
+	ResetDeviceCounts(nodeName)
+	ResetDeviceIdle(nodeName)
+	ResetResourceStates(nodeName)
+	scalingDecisions.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+	resourceCycles.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+	fabricOperationsDeferred.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+	resizesHeld.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+	convergenceTimeouts.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+}
+
+// RecordWatchEvent counts a watch event of a kind as processed or suppressed.
+func RecordWatchEvent(kind, result string) {
+	watchEvents.WithLabelValues(kind, result).Inc()
+}
+
+// RecordFabricOperationDeferred counts an operation deferred by the limit of a
+// scope.
+func RecordFabricOperationDeferred(nodeName, model, scope, limit string) {
+	fabricOperationsDeferred.WithLabelValues(nodeName, model, scope, limit).Inc()
+}
+
+// SetFabricOperationsQueued sets the number of deferred operations waiting.
+func SetFabricOperationsQueued(count int) {
+	fabricOperationsQueued.Set(float64(count))
+}
+
+// RecordResizeHeld counts a resize that started to be held for the given
+// reason.
+func RecordResizeHeld(nodeName, model, reason string) {
+	resizesHeld.WithLabelValues(nodeName, model, reason).Inc()
+}
+
+// RecordConvergenceTimeout counts a resize done before the previous one
+// settled.
+func RecordConvergenceTimeout(nodeName, model string) {
+	convergenceTimeouts.WithLabelValues(nodeName, model).Inc()
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordDeviceCounts(t *testing.T) {
+	RecordDeviceCounts("node1", "A100 40G", 3, 2)
+	RecordDeviceCounts("node2", "A100 40G", 1, 1)
+
+	if got := testutil.ToFloat64(desiredDevices.WithLabelValues("node1", "A100 40G")); got != 3 {
+		t.Errorf("desired devices are incorrect. Got: %v, Want: 3", got)
+	}
+	if got := testutil.ToFloat64(actualDevices.WithLabelValues("node1", "A100 40G")); got != 2 {
+		t.Errorf("actual devices are incorrect. Got: %v, Want: 2", got)
+	}
+
+	ResetDeviceCounts("node1")
+
+	if got := testutil.CollectAndCount(desiredDevices); got != 1 {
+		t.Errorf("desired devices series count is incorrect. Got: %d, Want: 1", got)
+	}
+	if got := testutil.CollectAndCount(actualDevices); got != 1 {
+		t.Errorf("actual devices series count is incorrect. Got: %d, Want: 1", got)
+	}
+
+	DeleteNode("node2")
+}
+
+func TestDeleteNode(t *testing.T) {
+	RecordDeviceCounts("node1", "A100 40G", 1, 1)
+	RecordScalingDecision("node1", "A100 40G", DecisionAttach)
+	RecordDeviceIdle("node1", "A100 40G", "res0", time.Minute)
+	RecordScalingDecision("node2", "A100 40G", DecisionDetach)
+
+	DeleteNode("node1")
+
+	if got := testutil.CollectAndCount(desiredDevices); got != 0 {
+		t.Errorf("desired devices series count is incorrect. Got: %d, Want: 0", got)
+	}
+	if got := testutil.CollectAndCount(deviceIdle); got != 0 {
+		t.Errorf("device idle series count is incorrect. Got: %d, Want: 0", got)
+	}
+	if got := testutil.CollectAndCount(scalingDecisions); got != 1 {
+		t.Errorf("scaling decisions series count is incorrect. Got: %d, Want: 1", got)
+	}
+
+	DeleteNode("node2")
+}
+
+func TestRecordClaimTransition(t *testing.T) {
+	before := testutil.ToFloat64(claimTransitions.WithLabelValues("Failed", "MaxDeviceExceeded"))
+
+	RecordClaimTransition("Failed", "MaxDeviceExceeded")
+	RecordClaimTransition("Failed", "MaxDeviceExceeded")
+
+	if got := testutil.ToFloat64(claimTransitions.WithLabelValues("Failed", "MaxDeviceExceeded")); got != before+2 {
+		t.Errorf("claim transitions are incorrect. Got: %v, Want: %v", got, before+2)
+	}
+}
+
+func TestRecordNodeLifecycleAction(t *testing.T) {
+	before := testutil.ToFloat64(nodeLifecycleActions.WithLabelValues("node1", ActionGarbageCollect))
+
+	RecordNodeLifecycleAction("node1", ActionGarbageCollect)
+
+	if got := testutil.ToFloat64(nodeLifecycleActions.WithLabelValues("node1", ActionGarbageCollect)); got != before+1 {
+		t.Errorf("node lifecycle actions are incorrect. Got: %v, Want: %v", got, before+1)
+	}
+}
+
+func TestRecordWatchEvent(t *testing.T) {
+	before := testutil.ToFloat64(watchEvents.WithLabelValues("ResourceClaim", WatchEventSelfWrite))
+
+	RecordWatchEvent("ResourceClaim", WatchEventSelfWrite)
+
+	if got := testutil.ToFloat64(watchEvents.WithLabelValues("ResourceClaim", WatchEventSelfWrite)); got != before+1 {
+		t.Errorf("watch events are incorrect. Got: %v, Want: %v", got, before+1)
+	}
+}
+
+func TestRecordFabricOperationDeferred(t *testing.T) {
+	before := testutil.ToFloat64(fabricOperationsDeferred.WithLabelValues("node1", "A100 40G", "global", "Rate"))
+
+	RecordFabricOperationDeferred("node1", "A100 40G", "global", "Rate")
+	SetFabricOperationsQueued(3)
+
+	if got := testutil.ToFloat64(fabricOperationsDeferred.WithLabelValues("node1", "A100 40G", "global", "Rate")); got != before+1 {
+		t.Errorf("deferred operations are incorrect. Got: %v, Want: %v", got, before+1)
+	}
+	if got := testutil.ToFloat64(fabricOperationsQueued); got != 3 {
+		t.Errorf("queued operations are incorrect. Got: %v, Want: 3", got)
+	}
+
+	DeleteNode("node1")
+	if got := testutil.CollectAndCount(fabricOperationsDeferred); got != 0 {
+		t.Errorf("deferred operations of a deleted node must be dropped. Got %d series", got)
+	}
+}
@@ -0,0 +1,549 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// planLastUsedTime marks the attached devices used by a pod as used now, and
+// records how long the others have been idle.
+func (p *planner) planLastUsedTime(ctx context.Context) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning ComposableResource last used time")
+
+	for _, resource := range p.Snapshot.ComposableResources {
+		if !utils.IsResourceOnline(resource) {
+			continue
+		}
+
+		isRed, resourceSliceInfo, deviceName := utils.IsDeviceResourceSliceRed(resource.Status.DeviceID, p.Snapshot.ResourceSliceInfos)
+		if isRed && p.Snapshot.IsDeviceUsedByPod(deviceName, *resourceSliceInfo) {
+			p.setLastUsedTime(resource.Name)
+			p.plan.IdleDevices = append(p.plan.IdleDevices, types.DeviceIdle{Model: resource.Spec.Model, ResourceName: resource.Name})
+			continue
+		}
+
+		lastUsedTime, exists, err := utils.GetLastUsedTime(resource, p.Spec.LabelPrefix)
+		if err != nil {
+			logger.Error(err, "Failed to read last used time", "name", resource.Name)
+			continue
+		}
+		if exists {
+			p.plan.IdleDevices = append(p.plan.IdleDevices, types.DeviceIdle{
+				Model:        resource.Spec.Model,
+				ResourceName: resource.Name,
+				Idle:         p.Now.Sub(lastUsedTime),
+			})
+		}
+	}
+}
+
+// planDevices sizes the ComposabilityRequests of every model to the number of
+// devices the node needs, within the min_device of the node. Models the node
+// does not allow are never scaled up. The devices of
+// the ComposabilityRequests DDS does not manage are subtracted. The
+// ComposabilityRequests are only shrunk down to the devices used within
+// DeviceNoRemoval, and are not resized again before their last resize
+// settles. The devices a scale-down detaches are chosen and marked for the
+// operator, see detachCandidates.
+func (p *planner) planDevices(ctx context.Context) error {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning node devices")
+
+	for _, device := range p.Spec.DeviceInfos {
+		logger := logger.WithValues("deviceModel", device.CDIModelName)
+		ctx := ctrl.LoggerInto(ctx, logger)
+
+		cofiguredDeviceCount := utils.GetConfiguredDeviceCount(ctx, p.Snapshot, device.CDIModelName, p.claims)
+
+		logger.Info("Configured devices count", "count", cofiguredDeviceCount)
+
+		_, minCountLimit := utils.GetModelLimit(p.Node, device.CDIModelName)
+		if p.Node.Cordoned || !utils.IsModelAllowed(p.Node, device.CDIModelName) {
+			// The idle devices of a cordoned node, and of the models it does
+			// not allow, are reclaimed: it does not keep their minimum.
+			minCountLimit = 0
+		}
+		if cofiguredDeviceCount < minCountLimit {
+			cofiguredDeviceCount = minCountLimit
+		}
+
+		logger.Info("Actual cofiguredDeviceCount", "count", cofiguredDeviceCount)
+
+		managed, unmanagedSize := p.managedComposabilityRequests(device.CDIModelName)
+		var actualCount int64
+		for _, cr := range managed {
+			actualCount += cr.Spec.Resource.Size
+		}
+		p.plan.DeviceCounts = append(p.plan.DeviceCounts, types.DeviceCount{Model: device.CDIModelName, Configured: cofiguredDeviceCount, Actual: actualCount + unmanagedSize})
+
+		targetCount := max(cofiguredDeviceCount-unmanagedSize, 0)
+
+		if blocked := p.scaleUpBlockedReason(); blocked != "" && targetCount > actualCount {
+			logger.Info("Node does not accept new devices, skipping scale up", "reason", blocked, "size", actualCount, "wanted", targetCount)
+			p.plan.BlockedScaleUps = append(p.plan.BlockedScaleUps, types.BlockedScaleUp{
+				Model:  device.CDIModelName,
+				Size:   actualCount,
+				Wanted: targetCount,
+				Reason: blocked,
+			})
+			targetCount = actualCount
+		}
+
+		if !utils.IsModelAllowed(p.Node, device.CDIModelName) && targetCount > actualCount {
+			logger.Info("Model is not allowed on node, skipping scale up", "policy", p.Node.Policy, "size", actualCount, "wanted", targetCount)
+			targetCount = actualCount
+		}
+
+		if len(managed) == 0 {
+			if targetCount > 0 {
+				resourceType := utils.GetResourceType(p.Spec, device)
+				if resourceType == "" {
+					logger.Error(nil, "No resource type for model, skipping attach", "model", device.CDIModelName, "driver", device.DriverName)
+					continue
+				}
+				p.plan.ComposabilityRequests = append(p.plan.ComposabilityRequests, types.ComposabilityRequestChange{
+					ResourceType: resourceType,
+					Model:        device.CDIModelName,
+					Size:         targetCount,
+				})
+				p.createdModels = append(p.createdModels, device.CDIModelName)
+			}
+			continue
+		}
+
+		for _, cr := range managed {
+			if utils.IsLegacyComposabilityRequest(*cr, p.Spec.LabelPrefix) {
+				p.plan.AdoptedRequests = append(p.plan.AdoptedRequests, composabilityRequestRef(cr))
+			}
+		}
+
+		if targetCount > actualCount {
+			// Only the first ComposabilityRequest grows, so that the
+			// duplicates drain.
+			if !p.holdResize(ctx, managed, actualCount, targetCount) {
+				p.resize(managed[0], managed[0].Spec.Resource.Size+targetCount-actualCount)
+			}
+		}
+
+		if targetCount < actualCount {
+			nextSize, err := p.nextSize(device.CDIModelName, cofiguredDeviceCount)
+			if err != nil {
+				return fmt.Errorf("failed to get next size: %v", err)
+			}
+			if nextSize = max(nextSize-unmanagedSize, 0); nextSize < actualCount {
+				victims := p.detachCandidates(ctx, device.CDIModelName, managed, actualCount, actualCount-nextSize)
+				if !p.holdResize(ctx, managed, actualCount, nextSize) {
+					p.shrink(managed, actualCount-nextSize, victims)
+				}
+			}
+		}
+
+		for _, cr := range managed[1:] {
+			if cr.Spec.Resource.Size == 0 && p.sizes[cr.Name] == 0 {
+				p.plan.DeletedRequests = append(p.plan.DeletedRequests, composabilityRequestRef(cr))
+			}
+		}
+	}
+
+	return nil
+}
+
+// managedComposabilityRequests returns the ComposabilityRequests of a model on
+// the node that DDS manages, starting with the one to grow, and the total size
+// of the ComposabilityRequests of the model it does not manage.
+func (p *planner) managedComposabilityRequests(model string) ([]*cdioperator.ComposabilityRequest, int64) {
+	var managed []*cdioperator.ComposabilityRequest
+	var unmanagedSize int64
+	for i := range p.Snapshot.ComposabilityRequests {
+		cr := &p.Snapshot.ComposabilityRequests[i]
+		if cr.Spec.Resource.Model != model || cr.Spec.Resource.TargetNode != p.Snapshot.NodeName {
+			continue
+		}
+		if utils.IsManagedComposabilityRequest(*cr, p.Spec.LabelPrefix) {
+			managed = append(managed, cr)
+		} else {
+			unmanagedSize += cr.Spec.Resource.Size
+		}
+	}
+
+	// The ComposabilityRequest with the name DDS creates comes first, then the
+	// owned ones before the adopted ones, the oldest first.
+	name := utils.ComposabilityRequestName(p.Snapshot.NodeName, model)
+	sort.SliceStable(managed, func(i, j int) bool {
+		if (managed[i].Name == name) != (managed[j].Name == name) {
+			return managed[i].Name == name
+		}
+		if owned := utils.IsOwnedComposabilityRequest(*managed[i], p.Spec.LabelPrefix); owned != utils.IsOwnedComposabilityRequest(*managed[j], p.Spec.LabelPrefix) {
+			return owned
+		}
+		if !managed[i].CreationTimestamp.Equal(&managed[j].CreationTimestamp) {
+			return managed[i].CreationTimestamp.Before(&managed[j].CreationTimestamp)
+		}
+		return managed[i].Name < managed[j].Name
+	})
+
+	return managed, unmanagedSize
+}
+
+// holdResize reports whether the resize of a model from size to wanted
+// devices must wait, and plans the hold. A resize waits until the last resize
+// of every ComposabilityRequest of the model settles, for ConvergenceTimeout
+// at most, and a scale-down waits ScaleDownCooldown after a scale-up. The
+// devices of a cordoned node are reclaimed without cooldown.
+func (p *planner) holdResize(ctx context.Context, managed []*cdioperator.ComposabilityRequest, size, wanted int64) bool {
+	logger := ctrl.LoggerFrom(ctx)
+
+	for _, cr := range managed {
+		resizedAt, direction, resized, err := utils.GetLastResize(*cr, p.Spec.LabelPrefix)
+		if err != nil {
+			logger.Error(err, "Failed to read last resize", "name", cr.Name)
+		}
+		if !resized {
+			resizedAt = cr.CreationTimestamp.Time
+		}
+
+		if p.ConvergenceTimeout > 0 {
+			if state := utils.GetConvergenceState(*cr, p.Snapshot.ComposableResources); state != "" {
+				if remaining := p.ConvergenceTimeout - p.Now.Sub(resizedAt); remaining > 0 {
+					p.hold(ctx, cr, size, wanted, types.HoldNotConverged, state, remaining)
+					return true
+				}
+				logger.Info("ComposabilityRequest did not converge in time, resizing anyway", "name", cr.Name, "state", state)
+				p.plan.UnconvergedRequests = append(p.plan.UnconvergedRequests, composabilityRequestRef(cr))
+			}
+		}
+
+		if p.ScaleDownCooldown > 0 && wanted < size && !p.Node.Cordoned && resized && direction == utils.ResizeDirectionUp {
+			if remaining := p.ScaleDownCooldown - p.Now.Sub(resizedAt); remaining > 0 {
+				p.hold(ctx, cr, size, wanted, types.HoldScaleDownCooldown, "scaled up at "+resizedAt.Format(time.RFC3339), remaining)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hold plans a resize of the model of a ComposabilityRequest that waits.
+func (p *planner) hold(ctx context.Context, cr *cdioperator.ComposabilityRequest, size, wanted int64, reason, message string, retryAfter time.Duration) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.Info("Holding resize", "name", cr.Name, "size", size, "wanted", wanted, "reason", reason, "message", message, "retryAfter", retryAfter)
+
+	p.plan.HeldResizes = append(p.plan.HeldResizes, types.HeldResize{
+		Name:       cr.Name,
+		UID:        cr.UID,
+		Model:      cr.Spec.Resource.Model,
+		Size:       size,
+		Wanted:     wanted,
+		Reason:     reason,
+		Message:    message,
+		RetryAfter: retryAfter,
+	})
+}
+
+// scaleUpBlockedReason returns why the node gets no new devices, or "" when
+// it can be scaled up.
+func (p *planner) scaleUpBlockedReason() string {
+	switch {
+	case p.Node.Cordoned:
+		return types.BlockedNodeCordoned
+	case p.Node.NotReady:
+		return types.BlockedNodeNotReady
+	default:
+		return ""
+	}
+}
+
+// resize plans to change the size of a ComposabilityRequest. Devices removed
+// from a cordoned node are reclaimed.
+func (p *planner) resize(cr *cdioperator.ComposabilityRequest, size int64) {
+	p.plan.ComposabilityRequests = append(p.plan.ComposabilityRequests, types.ComposabilityRequestChange{
+		Name:         cr.Name,
+		UID:          cr.UID,
+		ResourceType: cr.Spec.Resource.Type,
+		Model:        cr.Spec.Resource.Model,
+		PreviousSize: cr.Spec.Resource.Size,
+		Size:         size,
+		Reclaim:      p.Node.Cordoned && size < cr.Spec.Resource.Size,
+	})
+	p.sizes[cr.Name] = size
+}
+
+// shrink plans to remove count devices from the ComposabilityRequests of a
+// model, from the duplicates first. Each change is given the victims its
+// ComposabilityRequest owns, or that have no owner, in order.
+func (p *planner) shrink(managed []*cdioperator.ComposabilityRequest, count int64, victims []string) {
+	owners := make(map[string]string)
+	for _, resource := range p.Snapshot.ComposableResources {
+		if owner := metav1.GetControllerOf(&resource); owner != nil && owner.Kind == "ComposabilityRequest" {
+			owners[resource.Name] = owner.Name
+		}
+	}
+
+	for i := len(managed) - 1; i >= 0 && count > 0; i-- {
+		removed := min(managed[i].Spec.Resource.Size, count)
+		if removed == 0 {
+			continue
+		}
+		p.resize(managed[i], managed[i].Spec.Resource.Size-removed)
+		count -= removed
+
+		change := &p.plan.ComposabilityRequests[len(p.plan.ComposabilityRequests)-1]
+		var rest []string
+		for _, name := range victims {
+			if owner, ok := owners[name]; int64(len(change.Victims)) < removed && (!ok || owner == managed[i].Name) {
+				change.Victims = append(change.Victims, name)
+				if device, ok := p.resourceDevice(name); ok {
+					if change.VictimDevices == nil {
+						change.VictimDevices = make(map[string]types.DeviceRef)
+					}
+					change.VictimDevices[name] = device
+				}
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		victims = rest
+	}
+}
+
+// resourceDevice returns the device of a ComposableResource in the
+// ResourceSlices of the node, if it is published.
+func (p *planner) resourceDevice(name string) (types.DeviceRef, bool) {
+	i := slices.IndexFunc(p.Snapshot.ComposableResources, func(resource cdioperator.ComposableResource) bool { return resource.Name == name })
+	if i < 0 || p.Snapshot.ComposableResources[i].Status.DeviceID == "" {
+		return types.DeviceRef{}, false
+	}
+
+	isRed, resourceSliceInfo, deviceName := utils.IsDeviceResourceSliceRed(p.Snapshot.ComposableResources[i].Status.DeviceID, p.Snapshot.ResourceSliceInfos)
+	if !isRed {
+		return types.DeviceRef{}, false
+	}
+
+	return types.DeviceRef{Driver: resourceSliceInfo.Driver, Pool: resourceSliceInfo.Pool, Name: deviceName}, true
+}
+
+// detachCandidates returns the names of the ComposableResources of a model a
+// scale-down of count devices from size should detach, the first to go
+// first: the ones never used, then the ones idle the longest, then the least
+// healthy. The devices of the size that have no ComposableResource yet go
+// before any of them. Devices already on their way out, devices of
+// ComposabilityRequests DDS does not manage, and the attaching devices the
+// claims being prepared wait for, the oldest first, are not candidates.
+func (p *planner) detachCandidates(ctx context.Context, model string, managed []*cdioperator.ComposabilityRequest, size, count int64) []string {
+	logger := ctrl.LoggerFrom(ctx)
+
+	type candidate struct {
+		name   string
+		used   bool
+		idle   time.Duration
+		health int
+	}
+
+	var resources []cdioperator.ComposableResource
+	for _, resource := range p.Snapshot.ComposableResources {
+		if resource.Spec.Model != model || resource.Spec.TargetNode != p.Snapshot.NodeName || utils.IsResourceDetaching(resource) {
+			continue
+		}
+		if owner := metav1.GetControllerOf(&resource); owner != nil && owner.Kind == "ComposabilityRequest" &&
+			!slices.ContainsFunc(managed, func(cr *cdioperator.ComposabilityRequest) bool { return cr.Name == owner.Name }) {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+
+	awaited := p.awaitedResources(model, resources)
+
+	var candidates []candidate
+	for _, resource := range resources {
+		if awaited[resource.Name] {
+			continue
+		}
+
+		c := candidate{name: resource.Name}
+		lastUsedTime, exists, err := p.lastUsedTime(resource)
+		if err != nil {
+			logger.Error(err, "Failed to read last used time", "name", resource.Name)
+		}
+		if exists {
+			c.used = true
+			c.idle = p.Now.Sub(lastUsedTime)
+		}
+		switch {
+		case utils.IsResourceFailed(resource):
+			c.health = 0
+		case utils.IsResourceOnline(resource):
+			c.health = 2
+		default:
+			c.health = 1
+		}
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].used != candidates[j].used {
+			return !candidates[i].used
+		}
+		if candidates[i].idle != candidates[j].idle {
+			return candidates[i].idle > candidates[j].idle
+		}
+		if candidates[i].health != candidates[j].health {
+			return candidates[i].health < candidates[j].health
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	count -= max(size-int64(len(resources)), 0)
+
+	var victims []string
+	for _, c := range candidates[:max(min(int64(len(candidates)), count), 0)] {
+		victims = append(victims, c.name)
+	}
+
+	return victims
+}
+
+// awaitedResources returns the names of the attaching ComposableResources of a
+// model that the claims of the node being prepared wait for, one per device
+// being prepared, the oldest first.
+func (p *planner) awaitedResources(model string, resources []cdioperator.ComposableResource) map[string]bool {
+	var preparing int
+	for _, rc := range p.claims {
+		if rc.NodeName != p.Snapshot.NodeName {
+			continue
+		}
+		for _, device := range rc.Devices {
+			if device.Model == model && device.State == "Preparing" {
+				preparing++
+			}
+		}
+	}
+
+	var attaching []cdioperator.ComposableResource
+	for _, resource := range resources {
+		if utils.IsResourceAttaching(resource) {
+			attaching = append(attaching, resource)
+		}
+	}
+	sort.SliceStable(attaching, func(i, j int) bool {
+		if !attaching[i].CreationTimestamp.Equal(&attaching[j].CreationTimestamp) {
+			return attaching[i].CreationTimestamp.Before(&attaching[j].CreationTimestamp)
+		}
+		return attaching[i].Name < attaching[j].Name
+	})
+
+	awaited := make(map[string]bool)
+	for _, resource := range attaching[:min(preparing, len(attaching))] {
+		awaited[resource.Name] = true
+	}
+
+	return awaited
+}
+
+// composabilityRequestRef refers to a ComposabilityRequest in a plan.
+func composabilityRequestRef(cr *cdioperator.ComposabilityRequest) types.ComposabilityRequestRef {
+	return types.ComposabilityRequestRef{Name: cr.Name, UID: cr.UID, Model: cr.Spec.Resource.Model}
+}
+
+// nextSize returns the size the ComposabilityRequests of a model can be
+// shrunk to: count, or more while attached devices of the model were used
+// within DeviceNoRemoval.
+func (p *planner) nextSize(model string, count int64) (int64, error) {
+	var resourceCount int64
+	for _, resource := range p.Snapshot.ComposableResources {
+		if resource.Spec.Model != model {
+			continue
+		}
+		// Failed and detaching resources are not kept: their capacity is
+		// not usable, so it does not count as headroom.
+		if utils.IsResourceOnline(resource) || utils.IsResourceAttaching(resource) {
+			over, err := p.isLastUsedOverTime(resource, p.DeviceNoRemoval)
+			if err != nil {
+				return 0, err
+			}
+
+			if !over {
+				resourceCount++
+			}
+		}
+	}
+
+	if count > resourceCount {
+		return count, nil
+	}
+
+	return resourceCount, nil
+}
+
+// planNodeLabels labels the node with the models that can be scheduled on it:
+// the allowed models that can coexist with the models requested or attached on
+// it, taking the planned ComposabilityRequest sizes into account.
+func (p *planner) planNodeLabels() {
+	var installedDevices []string
+
+	for _, cr := range p.Snapshot.ComposabilityRequests {
+		if p.sizes[cr.Name] > 0 && !slices.Contains(installedDevices, cr.Spec.Resource.Model) {
+			installedDevices = append(installedDevices, cr.Spec.Resource.Model)
+		}
+	}
+
+	for _, model := range p.createdModels {
+		if !slices.Contains(installedDevices, model) {
+			installedDevices = append(installedDevices, model)
+		}
+	}
+
+	for _, rs := range p.Snapshot.ComposableResources {
+		// Detaching devices are still attached, so they still restrict the
+		// models that can coexist on the node.
+		if utils.IsResourceOnline(rs) || rs.Status.State == utils.ResourceStateDetaching {
+			if !slices.Contains(installedDevices, rs.Spec.Model) {
+				installedDevices = append(installedDevices, rs.Spec.Model)
+			}
+		}
+	}
+
+	var notCoexistID []int
+	for _, device := range installedDevices {
+		for _, deviceInfo := range p.Spec.DeviceInfos {
+			if device == deviceInfo.CDIModelName {
+				notCoexistID = append(notCoexistID, deviceInfo.CannotCoexistWith...)
+			}
+		}
+	}
+
+	for _, deviceInfo := range p.Spec.DeviceInfos {
+		label := p.Spec.LabelPrefix + "/" + deviceInfo.K8sDeviceName
+		if !slices.Contains(notCoexistID, deviceInfo.Index) && utils.IsModelAllowed(p.Node, deviceInfo.CDIModelName) {
+			p.plan.NodeLabels.AddLabels = append(p.plan.NodeLabels.AddLabels, label)
+		} else {
+			p.plan.NodeLabels.DeleteLabels = append(p.plan.NodeLabels.DeleteLabels, label)
+		}
+	}
+}
@@ -0,0 +1,1083 @@
+package planner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+func TestPlanLastUsedTime(t *testing.T) {
+	now := time.Now()
+
+	usedResourceClaim := &resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "rc0",
+		},
+		Status: resourceapi.ResourceClaimStatus{
+			Devices: []resourceapi.AllocatedDeviceStatus{
+				{
+					Driver: "gpu.nvidia.com",
+					Pool:   "gpu-pool",
+					Device: "gpu0",
+				},
+			},
+			ReservedFor: []resourceapi.ResourceClaimConsumerReference{
+				{
+					Name:     "pod0",
+					Resource: "pods",
+				},
+			},
+			Allocation: &resourceapi.AllocationResult{
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{
+						{
+							Device: "gpu0",
+							Pool:   "gpu-pool",
+							Driver: "gpu.nvidia.com",
+						},
+					},
+				},
+			},
+		},
+	}
+	resourceSliceInfos := []types.ResourceSliceInfo{
+		{
+			Name: "rs0",
+			Devices: []types.ResourceSliceDevice{
+				{
+					Name: "gpu0",
+					UUID: "123",
+				},
+			},
+			Pool:   "gpu-pool",
+			Driver: "gpu.nvidia.com",
+		},
+	}
+
+	testCases := []struct {
+		name                  string
+		existingResource      *cdioperator.ComposableResource
+		existingResourceClaim *resourceapi.ResourceClaim
+		resourceSliceInfoList []types.ResourceSliceInfo
+		expectedAnnotations   []types.AnnotationUpdate
+		expectedIdleDevices   []types.DeviceIdle
+	}{
+		{
+			name: "none Online resource",
+			existingResource: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rs0",
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node0",
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State: "Running",
+				},
+			},
+			existingResourceClaim: usedResourceClaim,
+		},
+		{
+			name: "resource do not match ResourceSliceInfo",
+			existingResource: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rs0",
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node0",
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State:    "Online",
+					DeviceID: "456",
+				},
+			},
+			existingResourceClaim: usedResourceClaim,
+			resourceSliceInfoList: resourceSliceInfos,
+		},
+		{
+			name: "normal case",
+			existingResource: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rs0",
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node0",
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State:    "Online",
+					DeviceID: "123",
+				},
+			},
+			existingResourceClaim: usedResourceClaim,
+			resourceSliceInfoList: resourceSliceInfos,
+			expectedAnnotations: []types.AnnotationUpdate{
+				{ResourceName: "rs0", Key: "test/last-used-time", Value: now.Format(time.RFC3339)},
+			},
+			expectedIdleDevices: []types.DeviceIdle{
+				{Model: "A100 40G", ResourceName: "rs0"},
+			},
+		},
+		{
+			name: "idle resource",
+			existingResource: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rs0",
+					Annotations: map[string]string{
+						"test/last-used-time": now.Add(-10 * time.Minute).Format(time.RFC3339),
+					},
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node0",
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State:    "Online",
+					DeviceID: "123",
+				},
+			},
+			resourceSliceInfoList: resourceSliceInfos,
+			expectedIdleDevices: []types.DeviceIdle{
+				{Model: "A100 40G", ResourceName: "rs0", Idle: now.Sub(now.Add(-10 * time.Minute).Truncate(time.Second))},
+			},
+		},
+		{
+			name: "resource on another node",
+			existingResource: &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "rs0",
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node1",
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State:    "Online",
+					DeviceID: "123",
+				},
+			},
+			existingResourceClaim: usedResourceClaim,
+			resourceSliceInfoList: resourceSliceInfos,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientObjects := []runtime.Object{tc.existingResource}
+			if tc.existingResourceClaim != nil {
+				clientObjects = append(clientObjects, tc.existingResourceClaim.DeepCopy())
+			}
+
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, "node0", nil, tc.resourceSliceInfoList, clientObjects...),
+				Spec:     types.ComposableDRASpec{LabelPrefix: "test"},
+				Now:      now,
+			})
+
+			p.planLastUsedTime(context.Background())
+
+			if !reflect.DeepEqual(p.plan.Annotations, tc.expectedAnnotations) {
+				t.Errorf("annotations are incorrect. Got: %v, Want: %v", p.plan.Annotations, tc.expectedAnnotations)
+			}
+			if !reflect.DeepEqual(p.plan.IdleDevices, tc.expectedIdleDevices) {
+				t.Errorf("idle devices are incorrect. Got: %v, Want: %v", p.plan.IdleDevices, tc.expectedIdleDevices)
+			}
+		})
+	}
+}
+
+func TestNextSize(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name                       string
+		existingComposableResource *cdioperator.ComposableResourceList
+		count                      int64
+		expectedSize               int64
+	}{
+		{
+			name:  "count more than resourceCount",
+			count: 3,
+			existingComposableResource: &cdioperator.ComposableResourceList{
+				Items: []cdioperator.ComposableResource{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "res1",
+							Annotations: map[string]string{
+								"composable.test/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+							},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							TargetNode: "node1",
+							Model:      "A100 40G",
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Online"},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              "res2",
+							DeletionTimestamp: &metav1.Time{Time: now.Add(-30 * time.Second)},
+							Finalizers:        []string{"dummy-finalizer"},
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Attaching"},
+					},
+				},
+			},
+			expectedSize: 3,
+		},
+		{
+			name:  "count less than resourceCount",
+			count: 1,
+			existingComposableResource: &cdioperator.ComposableResourceList{
+				Items: []cdioperator.ComposableResource{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "res1",
+							Annotations: map[string]string{
+								"composable.test/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+							},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							TargetNode: "node1",
+							Model:      "A100 40G",
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Online"},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "res2",
+							Annotations: map[string]string{
+								"composable.test/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+							},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							TargetNode: "node1",
+							Model:      "A100 40G",
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Attaching"},
+					},
+				},
+			},
+			expectedSize: 2,
+		},
+		{
+			name:  "resources of other models",
+			count: 1,
+			existingComposableResource: &cdioperator.ComposableResourceList{
+				Items: []cdioperator.ComposableResource{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "res1",
+							Annotations: map[string]string{
+								"composable.test/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+							},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							TargetNode: "node1",
+							Model:      "H100",
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Online"},
+					},
+				},
+			},
+			expectedSize: 1,
+		},
+		{
+			name:  "resources not used within DeviceNoRemoval",
+			count: 1,
+			existingComposableResource: &cdioperator.ComposableResourceList{
+				Items: []cdioperator.ComposableResource{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "res1",
+							Annotations: map[string]string{
+								"composable.test/last-used-time": now.Add(-10 * time.Minute).Format(time.RFC3339),
+							},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							TargetNode: "node1",
+							Model:      "A100 40G",
+						},
+						Status: cdioperator.ComposableResourceStatus{State: "Online"},
+					},
+				},
+			},
+			expectedSize: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientObjects := []runtime.Object{}
+			for i := range tc.existingComposableResource.Items {
+				clientObjects = append(clientObjects, &tc.existingComposableResource.Items[i])
+			}
+
+			p := newPlanner(Input{
+				Snapshot:        newSnapshot(t, "node1", nil, nil, clientObjects...),
+				Spec:            types.ComposableDRASpec{LabelPrefix: "composable.test"},
+				DeviceNoRemoval: time.Minute,
+				Now:             now,
+			})
+
+			size, err := p.nextSize("A100 40G", tc.count)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size != tc.expectedSize {
+				t.Errorf("Expected Size %d, got %d", tc.expectedSize, size)
+			}
+		})
+	}
+}
+
+func TestPlanDevices(t *testing.T) {
+	now := time.Now()
+
+	composableDRASpec := types.ComposableDRASpec{
+		LabelPrefix: "composable.test",
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:        1,
+				CDIModelName: "A100 40G",
+				DriverName:   "gpu.nvidia.com",
+			},
+		},
+	}
+	preparingClaim := func(count int) []types.ResourceClaimInfo {
+		claim := types.ResourceClaimInfo{
+			Name:      "rc0",
+			Namespace: "default",
+			NodeName:  "node1",
+		}
+		for range count {
+			claim.Devices = append(claim.Devices, types.ResourceClaimDevice{Name: "gpu0", Model: "A100 40G", State: "Preparing"})
+		}
+		return []types.ResourceClaimInfo{claim}
+	}
+	composabilityRequest := func(name string, size int64) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: utils.OwnerLabels(testLabelPrefix, "node1", "A100 40G"),
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Type:       "gpu",
+					Size:       size,
+					Model:      "A100 40G",
+					TargetNode: "node1",
+				},
+			},
+		}
+	}
+	legacyRequest := func(name string, size int64) *cdioperator.ComposabilityRequest {
+		cr := composabilityRequest(name, size)
+		cr.Labels = nil
+		cr.GenerateName = "composability-"
+		return cr
+	}
+	manualRequest := func(name string, size int64) *cdioperator.ComposabilityRequest {
+		cr := composabilityRequest(name, size)
+		cr.Labels = nil
+		return cr
+	}
+	ownedName := utils.ComposabilityRequestName("node1", "A100 40G")
+	usedResource := func(name string) *cdioperator.ComposableResource {
+		return &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					"composable.test/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+				},
+			},
+			Spec: cdioperator.ComposableResourceSpec{
+				Type:       "gpu",
+				Model:      "A100 40G",
+				TargetNode: "node1",
+			},
+			Status: cdioperator.ComposableResourceStatus{State: "Online"},
+		}
+	}
+
+	testCases := []struct {
+		name                  string
+		resourceClaimInfos    []types.ResourceClaimInfo
+		clientObjects         []runtime.Object
+		minDevice             int
+		cordoned              bool
+		notReady              bool
+		allowedModels         []string
+		expectedRequests      []types.ComposabilityRequestChange
+		expectedAdopted       []types.ComposabilityRequestRef
+		expectedDeleted       []types.ComposabilityRequestRef
+		expectedBlocked       []types.BlockedScaleUp
+		expectedDeviceCounts  []types.DeviceCount
+		expectedCreatedModels []string
+	}{
+		{
+			name:               "create ComposabilityRequest",
+			resourceClaimInfos: preparingClaim(2),
+			expectedRequests: []types.ComposabilityRequestChange{
+				{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+			},
+			expectedDeviceCounts:  []types.DeviceCount{{Model: "A100 40G", Configured: 2}},
+			expectedCreatedModels: []string{"A100 40G"},
+		},
+		{
+			name:                 "no devices needed",
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G"}},
+		},
+		{
+			name:      "min_device without claims",
+			minDevice: 1,
+			expectedRequests: []types.ComposabilityRequestChange{
+				{ResourceType: "gpu", Model: "A100 40G", Size: 1},
+			},
+			expectedDeviceCounts:  []types.DeviceCount{{Model: "A100 40G", Configured: 1}},
+			expectedCreatedModels: []string{"A100 40G"},
+		},
+		{
+			name:               "scale up ComposabilityRequest",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{composabilityRequest("test", 2)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 4, Actual: 2}},
+		},
+		{
+			name:               "scale down to the devices used recently",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects:      []runtime.Object{composabilityRequest("test", 4), usedResource("res1"), usedResource("res2")},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 2},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 1, Actual: 4}},
+		},
+		{
+			name:                 "devices used recently are kept",
+			resourceClaimInfos:   preparingClaim(1),
+			clientObjects:        []runtime.Object{composabilityRequest("test", 2), usedResource("res1"), usedResource("res2")},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 1, Actual: 2}},
+		},
+		{
+			name:               "scale up grows the owned ComposabilityRequest",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{legacyRequest("composability-abcde", 1), composabilityRequest(ownedName, 1)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: ownedName, ResourceType: "gpu", Model: "A100 40G", PreviousSize: 1, Size: 3},
+			},
+			expectedAdopted:      []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 4, Actual: 2}},
+		},
+		{
+			name:               "scale down drains the duplicates first",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects: []runtime.Object{
+				composabilityRequest(ownedName, 2), legacyRequest("composability-abcde", 2),
+				usedResource("res1"), usedResource("res2"),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "composability-abcde", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 0},
+			},
+			expectedAdopted:      []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 1, Actual: 4}},
+		},
+		{
+			name:                 "empty duplicate is deleted",
+			resourceClaimInfos:   preparingClaim(2),
+			clientObjects:        []runtime.Object{composabilityRequest(ownedName, 2), composabilityRequest("test", 0)},
+			expectedDeleted:      []types.ComposabilityRequestRef{{Name: "test", Model: "A100 40G"}},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 2, Actual: 2}},
+		},
+		{
+			name:               "manual ComposabilityRequest is left alone",
+			resourceClaimInfos: preparingClaim(3),
+			clientObjects:      []runtime.Object{manualRequest("manual", 2)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{ResourceType: "gpu", Model: "A100 40G", Size: 1},
+			},
+			expectedDeviceCounts:  []types.DeviceCount{{Model: "A100 40G", Configured: 3, Actual: 2}},
+			expectedCreatedModels: []string{"A100 40G"},
+		},
+		{
+			name:                 "manual ComposabilityRequest covers the devices",
+			resourceClaimInfos:   preparingClaim(1),
+			clientObjects:        []runtime.Object{manualRequest("manual", 2)},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 1, Actual: 2}},
+		},
+		{
+			name:               "cordoned node is not scaled up",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{composabilityRequest("test", 2)},
+			cordoned:           true,
+			expectedBlocked: []types.BlockedScaleUp{
+				{Model: "A100 40G", Size: 2, Wanted: 4, Reason: types.BlockedNodeCordoned},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 4, Actual: 2}},
+		},
+		{
+			name:               "NotReady node gets no ComposabilityRequest",
+			resourceClaimInfos: preparingClaim(2),
+			notReady:           true,
+			expectedBlocked: []types.BlockedScaleUp{
+				{Model: "A100 40G", Size: 0, Wanted: 2, Reason: types.BlockedNodeNotReady},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 2, Actual: 0}},
+		},
+		{
+			name:          "cordoned node reclaims idle devices below its minimum",
+			clientObjects: []runtime.Object{composabilityRequest("test", 2)},
+			minDevice:     2,
+			cordoned:      true,
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 0, Reclaim: true},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 0, Actual: 2}},
+		},
+		{
+			name:                 "cordoned node keeps devices used recently",
+			clientObjects:        []runtime.Object{composabilityRequest("test", 2), usedResource("res1"), usedResource("res2")},
+			cordoned:             true,
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 0, Actual: 2}},
+		},
+		{
+			name:          "model not allowed does not keep its minimum",
+			clientObjects: []runtime.Object{composabilityRequest("test", 2)},
+			minDevice:     2,
+			allowedModels: []string{"H100"},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 0},
+			},
+			expectedDeviceCounts: []types.DeviceCount{{Model: "A100 40G", Configured: 0, Actual: 2}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, "node1", tc.resourceClaimInfos, nil, tc.clientObjects...),
+				Node: types.NodeInfo{
+					Name:          "node1",
+					Models:        []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 8, MinDevice: tc.minDevice}},
+					Cordoned:      tc.cordoned,
+					NotReady:      tc.notReady,
+					AllowedModels: tc.allowedModels,
+				},
+				Spec:            composableDRASpec,
+				DeviceNoRemoval: time.Minute,
+				Now:             now,
+			})
+
+			if err := p.planDevices(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(p.plan.ComposabilityRequests, tc.expectedRequests) {
+				t.Errorf("ComposabilityRequest changes are incorrect. Got: %+v, Want: %+v", p.plan.ComposabilityRequests, tc.expectedRequests)
+			}
+			if !reflect.DeepEqual(p.plan.AdoptedRequests, tc.expectedAdopted) {
+				t.Errorf("adopted ComposabilityRequests are incorrect. Got: %+v, Want: %+v", p.plan.AdoptedRequests, tc.expectedAdopted)
+			}
+			if !reflect.DeepEqual(p.plan.DeletedRequests, tc.expectedDeleted) {
+				t.Errorf("deleted ComposabilityRequests are incorrect. Got: %+v, Want: %+v", p.plan.DeletedRequests, tc.expectedDeleted)
+			}
+			if !reflect.DeepEqual(p.plan.BlockedScaleUps, tc.expectedBlocked) {
+				t.Errorf("blocked scale-ups are incorrect. Got: %+v, Want: %+v", p.plan.BlockedScaleUps, tc.expectedBlocked)
+			}
+			if !reflect.DeepEqual(p.plan.DeviceCounts, tc.expectedDeviceCounts) {
+				t.Errorf("device counts are incorrect. Got: %+v, Want: %+v", p.plan.DeviceCounts, tc.expectedDeviceCounts)
+			}
+			if !reflect.DeepEqual(p.createdModels, tc.expectedCreatedModels) {
+				t.Errorf("created models are incorrect. Got: %v, Want: %v", p.createdModels, tc.expectedCreatedModels)
+			}
+		})
+	}
+}
+
+func TestPlanDevicesConvergence(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	composableDRASpec := types.ComposableDRASpec{
+		LabelPrefix: "composable.test",
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:        1,
+				CDIModelName: "A100 40G",
+				DriverName:   "gpu.nvidia.com",
+			},
+		},
+	}
+	preparingClaim := func(count int) []types.ResourceClaimInfo {
+		claim := types.ResourceClaimInfo{Name: "rc0", Namespace: "default", NodeName: "node1"}
+		for range count {
+			claim.Devices = append(claim.Devices, types.ResourceClaimDevice{Name: "gpu0", Model: "A100 40G", State: "Preparing"})
+		}
+		return []types.ResourceClaimInfo{claim}
+	}
+	// request returns a ComposabilityRequest of size devices, of which the
+	// operator has observed observed, resized in direction ago.
+	request := func(size, observed int64, ago time.Duration, direction string) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: utils.OwnerLabels(testLabelPrefix, "node1", "A100 40G"),
+				Annotations: map[string]string{
+					utils.ResizedAtAnnotation("composable.test"):       now.Add(-ago).Format(time.RFC3339),
+					utils.ResizeDirectionAnnotation("composable.test"): direction,
+				},
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Size: size, Model: "A100 40G", TargetNode: "node1"},
+			},
+			Status: cdioperator.ComposabilityRequestStatus{
+				State:          "Running",
+				ScalarResource: cdioperator.ScalarResourceDetails{Type: "gpu", Size: observed, Model: "A100 40G", TargetNode: "node1"},
+			},
+		}
+	}
+	attachingResource := &cdioperator.ComposableResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "res1"},
+		Spec:       cdioperator.ComposableResourceSpec{Type: "gpu", Model: "A100 40G", TargetNode: "node1"},
+		Status:     cdioperator.ComposableResourceStatus{State: "Attaching"},
+	}
+
+	testCases := []struct {
+		name                string
+		resourceClaimInfos  []types.ResourceClaimInfo
+		clientObjects       []runtime.Object
+		cordoned            bool
+		expectedRequests    []types.ComposabilityRequestChange
+		expectedHeld        []types.HeldResize
+		expectedUnconverged []types.ComposabilityRequestRef
+	}{
+		{
+			name:               "scale up waits for the operator to observe the last size",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{request(2, 1, time.Minute, utils.ResizeDirectionUp)},
+			expectedHeld: []types.HeldResize{
+				{Name: "test", Model: "A100 40G", Size: 2, Wanted: 4, Reason: types.HoldNotConverged, Message: "size 2 not observed yet, operator reports 1", RetryAfter: 4 * time.Minute},
+			},
+		},
+		{
+			name:               "scale up waits for the devices being attached",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{request(2, 2, time.Minute, utils.ResizeDirectionUp), attachingResource},
+			expectedHeld: []types.HeldResize{
+				{Name: "test", Model: "A100 40G", Size: 2, Wanted: 4, Reason: types.HoldNotConverged, Message: "1 ComposableResources are attaching or detaching", RetryAfter: 4 * time.Minute},
+			},
+		},
+		{
+			name:               "settled resize is followed by the next one",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{request(2, 2, time.Minute, utils.ResizeDirectionUp)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+			},
+		},
+		{
+			name:               "resize goes ahead after the convergence timeout",
+			resourceClaimInfos: preparingClaim(4),
+			clientObjects:      []runtime.Object{request(2, 1, 6*time.Minute, utils.ResizeDirectionUp)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+			},
+			expectedUnconverged: []types.ComposabilityRequestRef{{Name: "test", Model: "A100 40G"}},
+		},
+		{
+			name:               "scale down waits for the cooldown after a scale up",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects:      []runtime.Object{request(4, 4, time.Minute, utils.ResizeDirectionUp)},
+			expectedHeld: []types.HeldResize{
+				{Name: "test", Model: "A100 40G", Size: 4, Wanted: 1, Reason: types.HoldScaleDownCooldown, Message: "scaled up at 2025-01-01T11:59:00Z", RetryAfter: time.Minute},
+			},
+		},
+		{
+			name:               "scale down after the cooldown",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects:      []runtime.Object{request(4, 4, 3*time.Minute, utils.ResizeDirectionUp)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 1},
+			},
+		},
+		{
+			name:               "scale down after a scale down has no cooldown",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects:      []runtime.Object{request(4, 4, time.Minute, utils.ResizeDirectionDown)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 1},
+			},
+		},
+		{
+			name:          "cordoned node reclaims devices during the cooldown",
+			clientObjects: []runtime.Object{request(2, 2, time.Minute, utils.ResizeDirectionUp)},
+			cordoned:      true,
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 0, Reclaim: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, "node1", tc.resourceClaimInfos, nil, tc.clientObjects...),
+				Node: types.NodeInfo{
+					Name:     "node1",
+					Models:   []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 8}},
+					Cordoned: tc.cordoned,
+				},
+				Spec:               composableDRASpec,
+				DeviceNoRemoval:    time.Minute,
+				ConvergenceTimeout: 5 * time.Minute,
+				ScaleDownCooldown:  2 * time.Minute,
+				Now:                now,
+			})
+
+			if err := p.planDevices(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(p.plan.ComposabilityRequests, tc.expectedRequests) {
+				t.Errorf("ComposabilityRequest changes are incorrect. Got: %+v, Want: %+v", p.plan.ComposabilityRequests, tc.expectedRequests)
+			}
+			if !reflect.DeepEqual(p.plan.HeldResizes, tc.expectedHeld) {
+				t.Errorf("held resizes are incorrect. Got: %+v, Want: %+v", p.plan.HeldResizes, tc.expectedHeld)
+			}
+			if !reflect.DeepEqual(p.plan.UnconvergedRequests, tc.expectedUnconverged) {
+				t.Errorf("unconverged ComposabilityRequests are incorrect. Got: %+v, Want: %+v", p.plan.UnconvergedRequests, tc.expectedUnconverged)
+			}
+		})
+	}
+}
+
+func TestPlanDevicesVictims(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	composableDRASpec := types.ComposableDRASpec{
+		LabelPrefix: "composable.test",
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:        1,
+				CDIModelName: "A100 40G",
+				DriverName:   "gpu.nvidia.com",
+			},
+		},
+	}
+	preparingClaim := func(count int) []types.ResourceClaimInfo {
+		claim := types.ResourceClaimInfo{Name: "rc0", Namespace: "default", NodeName: "node1"}
+		for range count {
+			claim.Devices = append(claim.Devices, types.ResourceClaimDevice{Name: "gpu0", Model: "A100 40G", State: "Preparing"})
+		}
+		return []types.ResourceClaimInfo{claim}
+	}
+	request := func(name string, size int64, owned bool) *cdioperator.ComposabilityRequest {
+		cr := &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Size: size, Model: "A100 40G", TargetNode: "node1"},
+			},
+		}
+		if owned {
+			cr.Labels = utils.OwnerLabels(testLabelPrefix, "node1", "A100 40G")
+		}
+		return cr
+	}
+	// resource returns an Online ComposableResource last used ago, or never
+	// when ago is zero.
+	resource := func(name string, ago time.Duration) *cdioperator.ComposableResource {
+		res := &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}},
+			Spec:       cdioperator.ComposableResourceSpec{Type: "gpu", Model: "A100 40G", TargetNode: "node1"},
+			Status:     cdioperator.ComposableResourceStatus{State: "Online"},
+		}
+		if ago > 0 {
+			res.Annotations["composable.test/last-used-time"] = now.Add(-ago).Format(time.RFC3339)
+		}
+		return res
+	}
+	failed := func(res *cdioperator.ComposableResource) *cdioperator.ComposableResource {
+		res.Status.Error = "device lost"
+		return res
+	}
+	attaching := func(res *cdioperator.ComposableResource) *cdioperator.ComposableResource {
+		res.Status.State = "Attaching"
+		return res
+	}
+	ownedBy := func(res *cdioperator.ComposableResource, owner string) *cdioperator.ComposableResource {
+		res.OwnerReferences = []metav1.OwnerReference{{Kind: "ComposabilityRequest", Name: owner, Controller: ptr.To(true)}}
+		return res
+	}
+
+	published := func(res *cdioperator.ComposableResource, deviceID string) *cdioperator.ComposableResource {
+		res.Status.DeviceID = deviceID
+		return res
+	}
+	resourceSlice := types.ResourceSliceInfo{
+		Name: "rs0", NodeName: "node1", Driver: "gpu.nvidia.com", Pool: "node1",
+		Devices: []types.ResourceSliceDevice{{Name: "gpu2", UUID: "uuid-2"}},
+	}
+
+	testCases := []struct {
+		name               string
+		resourceClaimInfos []types.ResourceClaimInfo
+		resourceSliceInfos []types.ResourceSliceInfo
+		clientObjects      []runtime.Object
+		expectedRequests   []types.ComposabilityRequestChange
+	}{
+		{
+			name:               "never used devices go first, then the longest idle",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects: []runtime.Object{
+				request("test", 3, true),
+				resource("res1", 5*time.Minute), resource("res2", time.Hour), resource("res3", 0),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 3, Size: 1, Victims: []string{"res3", "res2"}},
+			},
+		},
+		{
+			name:               "least healthy devices go first",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects: []runtime.Object{
+				request("test", 3, true),
+				resource("res1", 0), attaching(resource("res2", 0)), failed(resource("res3", 0)), attaching(resource("res4", 0)),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 3, Size: 1, Victims: []string{"res3", "res4"}},
+			},
+		},
+		{
+			name:               "attaching devices the claims wait for are not chosen",
+			resourceClaimInfos: preparingClaim(2),
+			clientObjects: []runtime.Object{
+				request("test", 3, true),
+				attaching(resource("res1", 0)), attaching(resource("res2", 0)), resource("res3", time.Hour),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 3, Size: 2, Victims: []string{"res3"}},
+			},
+		},
+		{
+			name:               "devices without ComposableResource go before any",
+			resourceClaimInfos: preparingClaim(1),
+			clientObjects:      []runtime.Object{request("test", 3, true), resource("res1", 0)},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 3, Size: 1},
+			},
+		},
+		{
+			name:               "published devices of the victims are recorded",
+			resourceClaimInfos: preparingClaim(1),
+			resourceSliceInfos: []types.ResourceSliceInfo{resourceSlice},
+			clientObjects: []runtime.Object{
+				request("test", 3, true),
+				resource("res1", 5*time.Minute), published(resource("res2", time.Hour), "uuid-2"), resource("res3", 0),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{
+					Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 3, Size: 1, Victims: []string{"res3", "res2"},
+					VictimDevices: map[string]types.DeviceRef{"res2": {Driver: "gpu.nvidia.com", Pool: "node1", Name: "gpu2"}},
+				},
+			},
+		},
+		{
+			name:               "devices of a manual ComposabilityRequest are not chosen",
+			resourceClaimInfos: preparingClaim(2),
+			clientObjects: []runtime.Object{
+				request("test", 2, true), request("manual", 1, false),
+				ownedBy(resource("res1", 0), "manual"), ownedBy(resource("res2", time.Hour), "test"), ownedBy(resource("res3", time.Hour), "test"),
+			},
+			expectedRequests: []types.ComposabilityRequestChange{
+				{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 1, Victims: []string{"res2"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, "node1", tc.resourceClaimInfos, tc.resourceSliceInfos, tc.clientObjects...),
+				Node: types.NodeInfo{
+					Name:   "node1",
+					Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 8}},
+				},
+				Spec:            composableDRASpec,
+				DeviceNoRemoval: time.Minute,
+				Now:             now,
+			})
+
+			if err := p.planDevices(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(p.plan.ComposabilityRequests, tc.expectedRequests) {
+				t.Errorf("ComposabilityRequest changes are incorrect. Got: %+v, Want: %+v", p.plan.ComposabilityRequests, tc.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestPlanNodeLabels(t *testing.T) {
+	composableDRASpec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:             1,
+				CDIModelName:      "A100 40G",
+				DriverName:        "gpu.nvidia.com",
+				K8sDeviceName:     "nvidia-a100-40g",
+				CannotCoexistWith: []int{2, 3},
+			},
+			{
+				Index:             2,
+				CDIModelName:      "A100 80G",
+				DriverName:        "gpu.nvidia.com",
+				K8sDeviceName:     "nvidia-a100-80g",
+				CannotCoexistWith: []int{1, 3},
+			},
+			{
+				Index:             3,
+				CDIModelName:      "H100",
+				DriverName:        "gpu.nvidia.com",
+				K8sDeviceName:     "nvidia-h100",
+				CannotCoexistWith: []int{2, 3},
+			},
+			{
+				Index:             4,
+				CDIModelName:      "CXL-mem",
+				DriverName:        "cxl-mem",
+				K8sDeviceName:     "cxl-mem",
+				CannotCoexistWith: []int{2, 3},
+			},
+		},
+		LabelPrefix:   "composable.fsastech.com",
+		FabricIDRange: []int{1, 2, 3},
+	}
+	composabilityRequest := func(name, model, nodeName string, size int64) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Size:       size,
+					Model:      model,
+					TargetNode: nodeName,
+				},
+			},
+		}
+	}
+	composableResource := func(name, model, state string) *cdioperator.ComposableResource {
+		return &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: cdioperator.ComposableResourceSpec{
+				Model:      model,
+				TargetNode: "test",
+			},
+			Status: cdioperator.ComposableResourceStatus{
+				State: state,
+			},
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		clientObjects  []runtime.Object
+		plannedSizes   map[string]int64
+		createdModels  []string
+		allowedModels  []string
+		expectedLabels types.NodeLabelChange
+	}{
+		{
+			name: "update node label successfully",
+			clientObjects: []runtime.Object{
+				composabilityRequest("request1", "A100 40G", "test", 2),
+				composabilityRequest("request2", "A100 80G", "test", 0),
+				composabilityRequest("request3", "H100", "test2", 2),
+				composableResource("resource1", "A100 40G", "Online"),
+				composableResource("resource2", "A100 80G", "Deleting"),
+			},
+			expectedLabels: types.NodeLabelChange{
+				AddLabels:    []string{"composable.fsastech.com/nvidia-a100-40g", "composable.fsastech.com/cxl-mem"},
+				DeleteLabels: []string{"composable.fsastech.com/nvidia-a100-80g", "composable.fsastech.com/nvidia-h100"},
+			},
+		},
+		{
+			name: "planned ComposabilityRequest size",
+			clientObjects: []runtime.Object{
+				composabilityRequest("request1", "A100 80G", "test", 0),
+			},
+			plannedSizes: map[string]int64{"request1": 2},
+			expectedLabels: types.NodeLabelChange{
+				AddLabels:    []string{"composable.fsastech.com/nvidia-a100-80g", "composable.fsastech.com/cxl-mem"},
+				DeleteLabels: []string{"composable.fsastech.com/nvidia-a100-40g", "composable.fsastech.com/nvidia-h100"},
+			},
+		},
+		{
+			name:          "planned new ComposabilityRequest",
+			createdModels: []string{"A100 80G"},
+			expectedLabels: types.NodeLabelChange{
+				AddLabels:    []string{"composable.fsastech.com/nvidia-a100-80g", "composable.fsastech.com/cxl-mem"},
+				DeleteLabels: []string{"composable.fsastech.com/nvidia-a100-40g", "composable.fsastech.com/nvidia-h100"},
+			},
+		},
+		{
+			name: "detaching device still restricts the node",
+			clientObjects: []runtime.Object{
+				composableResource("resource1", "A100 80G", "Detaching"),
+			},
+			expectedLabels: types.NodeLabelChange{
+				AddLabels:    []string{"composable.fsastech.com/nvidia-a100-80g", "composable.fsastech.com/cxl-mem"},
+				DeleteLabels: []string{"composable.fsastech.com/nvidia-a100-40g", "composable.fsastech.com/nvidia-h100"},
+			},
+		},
+		{
+			name:          "models not allowed are not advertised",
+			allowedModels: []string{"A100 40G", "H100"},
+			expectedLabels: types.NodeLabelChange{
+				AddLabels:    []string{"composable.fsastech.com/nvidia-a100-40g", "composable.fsastech.com/nvidia-h100"},
+				DeleteLabels: []string{"composable.fsastech.com/nvidia-a100-80g", "composable.fsastech.com/cxl-mem"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, "test", nil, nil, tc.clientObjects...),
+				Node:     types.NodeInfo{Name: "test", AllowedModels: tc.allowedModels},
+				Spec:     composableDRASpec,
+				Now:      time.Now(),
+			})
+			for name, size := range tc.plannedSizes {
+				p.sizes[name] = size
+			}
+			p.createdModels = tc.createdModels
+
+			p.planNodeLabels()
+
+			if !reflect.DeepEqual(p.plan.NodeLabels, tc.expectedLabels) {
+				t.Errorf("node labels are incorrect. Got: %+v, Want: %+v", p.plan.NodeLabels, tc.expectedLabels)
+			}
+		})
+	}
+}
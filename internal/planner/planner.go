@@ -0,0 +1,234 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package planner decides the changes DDS makes on a node. It works on a
+// snapshot of the node and has no side effects: it does not call the API
+// server, emit events or record metrics. The plan it returns is applied by
+// utils.ExecutePlan.
+package planner
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Input is what a plan is computed from. The snapshot is not modified.
+type Input struct {
+	Snapshot           *utils.NodeSnapshot
+	Node               types.NodeInfo
+	Spec               types.ComposableDRASpec
+	DeviceNoRemoval    time.Duration
+	DeviceNoAllocation time.Duration
+	// AttachTimeout is how long the devices of a claim may stay Preparing
+	// before the claim is failed. Zero disables the timeout.
+	AttachTimeout time.Duration
+	// ConvergenceTimeout is how long a resize of a model waits for the last
+	// one to settle. ScaleDownCooldown is how long after a scale-up a model
+	// is not scaled down. Zero disables them.
+	ConvergenceTimeout time.Duration
+	ScaleDownCooldown  time.Duration
+	// Now is the time the plan is made at. Durations are measured against it
+	// instead of the clock.
+	Now time.Time
+	// Quotas are the valid DeviceQuotas, and ClusterClaims the ResourceClaims
+	// of every node they are enforced against. ClusterClaims is only needed
+	// when there are quotas.
+	Quotas        []ddsv1alpha1.DeviceQuota
+	ClusterClaims []types.ResourceClaimInfo
+	// AdmittedClaims are the UIDs of the ResourceClaims admitted in the
+	// quotas by the plans made before, of this node or of others.
+	AdmittedClaims map[k8stypes.UID]bool
+}
+
+// planner holds the state of the node as the plan being built leaves it, so
+// that every decision sees the previous ones.
+type planner struct {
+	Input
+
+	plan *types.Plan
+	// claims are the ResourceClaims of the snapshot with their planned states.
+	claims []types.ResourceClaimInfo
+	// lastUsed are the last-used times planned for ComposableResources.
+	lastUsed map[string]time.Time
+	// sizes are the planned sizes of the ComposabilityRequests, by name.
+	sizes map[string]int64
+	// createdModels are the models of the planned new ComposabilityRequests.
+	createdModels []string
+}
+
+// Plan decides the changes for the node of the snapshot: the claims to fail
+// or reschedule, the usage of the DeviceQuotas, the last-used times of the
+// ComposableResources, the sizes of the ComposabilityRequests and the device
+// labels of the node.
+func Plan(ctx context.Context, input Input) (*types.Plan, error) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning node")
+
+	p := newPlanner(input)
+
+	p.planLastUsedTime(ctx)
+
+	p.planRescheduleFailed(ctx)
+
+	p.planQuotas(ctx)
+
+	if err := p.planReschedule(ctx); err != nil {
+		return nil, err
+	}
+
+	p.planAttachTimeout(ctx)
+
+	if err := p.planDevices(ctx); err != nil {
+		return nil, err
+	}
+
+	p.planNodeLabels()
+
+	logger.V(1).Info("Finish planning node",
+		"claimTransitions", len(p.plan.ClaimTransitions),
+		"annotations", len(p.plan.Annotations),
+		"composabilityRequests", len(p.plan.ComposabilityRequests))
+
+	return p.plan, nil
+}
+
+func newPlanner(input Input) *planner {
+	p := &planner{
+		Input:    input,
+		plan:     &types.Plan{NodeName: input.Snapshot.NodeName, LabelPrefix: input.Spec.LabelPrefix},
+		claims:   copyClaims(input.Snapshot.ResourceClaimInfos),
+		lastUsed: make(map[string]time.Time),
+		sizes:    make(map[string]int64),
+	}
+	for _, cr := range input.Snapshot.ComposabilityRequests {
+		p.sizes[cr.Name] = cr.Spec.Resource.Size
+	}
+
+	return p
+}
+
+// setDevicesState plans to move the devices of the k-th claim to targetState.
+func (p *planner) setDevicesState(ctx context.Context, k int, targetState, conditionType string, reason types.ConditionReason, message string, related ...corev1.ObjectReference) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Plan devices state",
+		"resourceClaimInfoName", p.claims[k].Name,
+		"conditionType", conditionType,
+		"targetState", targetState,
+		"reason", reason,
+		"message", message)
+
+	p.plan.ClaimTransitions = append(p.plan.ClaimTransitions, types.ClaimTransition{
+		Claim:         copyClaim(p.claims[k]),
+		State:         targetState,
+		ConditionType: conditionType,
+		Reason:        reason,
+		Message:       message,
+		Related:       related,
+	})
+
+	devices := make([]types.ResourceClaimDevice, len(p.claims[k].Devices))
+	for i, device := range p.claims[k].Devices {
+		device.State = targetState
+		devices[i] = device
+	}
+	p.claims[k].Devices = devices
+}
+
+// lastUsedTime returns the last-used time of a ComposableResource, taking the
+// times planned earlier into account.
+func (p *planner) lastUsedTime(resource cdioperator.ComposableResource) (time.Time, bool, error) {
+	if lastUsed, ok := p.lastUsed[resource.Name]; ok {
+		return lastUsed, true, nil
+	}
+
+	return utils.GetLastUsedTime(resource, p.Spec.LabelPrefix)
+}
+
+// isLastUsedOverTime reports whether a ComposableResource has not been used
+// for longer than duration. A resource that was never used is.
+func (p *planner) isLastUsedOverTime(resource cdioperator.ComposableResource, duration time.Duration) (bool, error) {
+	lastUsedTime, exists, err := p.lastUsedTime(resource)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return true, nil
+	}
+
+	return p.Now.Sub(lastUsedTime) > duration, nil
+}
+
+// setLastUsedTime plans to mark a ComposableResource as used now.
+func (p *planner) setLastUsedTime(resourceName string) {
+	if lastUsed, ok := p.lastUsed[resourceName]; ok && lastUsed.Equal(p.Now) {
+		return
+	}
+
+	p.lastUsed[resourceName] = p.Now
+	p.plan.Annotations = append(p.plan.Annotations, types.AnnotationUpdate{
+		ResourceName: resourceName,
+		Key:          p.Spec.LabelPrefix + "/last-used-time",
+		Value:        p.Now.Format(time.RFC3339),
+	})
+}
+
+func sortByTime(resourceClaims []types.ResourceClaimInfo, order string) {
+	sort.SliceStable(resourceClaims, func(i, j int) bool {
+		timeI := resourceClaims[i].CreationTimestamp.Time
+		timeJ := resourceClaims[j].CreationTimestamp.Time
+
+		if order == "Ascending" {
+			return timeI.Before(timeJ)
+		} else {
+			return timeI.After(timeJ)
+		}
+	})
+}
+
+// sortedModels returns the models of a model count map in a stable order, so
+// that the plan does not depend on the map iteration order.
+func sortedModels(modelMap map[string]int) []string {
+	models := make([]string, 0, len(modelMap))
+	for model := range modelMap {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	return models
+}
+
+func copyClaims(resourceClaimInfos []types.ResourceClaimInfo) []types.ResourceClaimInfo {
+	claims := make([]types.ResourceClaimInfo, len(resourceClaimInfos))
+	for i, rc := range resourceClaimInfos {
+		claims[i] = copyClaim(rc)
+	}
+
+	return claims
+}
+
+func copyClaim(resourceClaimInfo types.ResourceClaimInfo) types.ResourceClaimInfo {
+	resourceClaimInfo.Devices = append([]types.ResourceClaimDevice(nil), resourceClaimInfo.Devices...)
+	return resourceClaimInfo
+}
@@ -0,0 +1,566 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testLabelPrefix = "composable.test"
+
+// fakeFieldIndexer registers field indexes on a fake client builder.
+type fakeFieldIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (f fakeFieldIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	f.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// newSnapshot reads the snapshot of a node from an in-memory cluster holding
+// the objects, as the controller does.
+func newSnapshot(t *testing.T, nodeName string, resourceClaimInfos []types.ResourceClaimInfo, resourceSliceInfos []types.ResourceSliceInfo, objects ...runtime.Object) *utils.NodeSnapshot {
+	t.Helper()
+
+	s := scheme.Scheme
+	s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposabilityRequest{}, &cdioperator.ComposabilityRequestList{})
+	s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposableResource{}, &cdioperator.ComposableResourceList{})
+
+	builder := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objects...)
+	if err := utils.SetupFieldIndexers(context.Background(), fakeFieldIndexer{builder: builder}); err != nil {
+		t.Fatalf("failed to set up field indexers: %v", err)
+	}
+
+	snapshot, err := utils.NewNodeSnapshotFromInfos(context.Background(), builder.Build(), nodeName, resourceClaimInfos, resourceSliceInfos)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return snapshot
+}
+
+func TestPlan(t *testing.T) {
+	now := time.Now()
+
+	spec := types.ComposableDRASpec{
+		LabelPrefix: testLabelPrefix,
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:             1,
+				CDIModelName:      "A100 40G",
+				DriverName:        "gpu.nvidia.com",
+				K8sDeviceName:     "nvidia-a100-40g",
+				CannotCoexistWith: []int{2},
+			},
+			{
+				Index:             2,
+				CDIModelName:      "A100 80G",
+				DriverName:        "gpu.nvidia.com",
+				K8sDeviceName:     "nvidia-a100-80g",
+				CannotCoexistWith: []int{1},
+			},
+		},
+	}
+	resourceClaimInfos := []types.ResourceClaimInfo{
+		{
+			Name:              "claim-40g",
+			Namespace:         "default",
+			NodeName:          "node1",
+			CreationTimestamp: metav1.Time{Time: now.Add(-time.Minute)},
+			Devices: []types.ResourceClaimDevice{
+				{Name: "gpu0", Model: "A100 40G", State: "Preparing"},
+				{Name: "gpu1", Model: "A100 40G", State: "Preparing"},
+			},
+		},
+	}
+	snapshot := newSnapshot(t, "node1", resourceClaimInfos, nil)
+
+	plan, err := Plan(context.Background(), Input{
+		Snapshot: snapshot,
+		Node: types.NodeInfo{
+			Name:   "node1",
+			Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 4}},
+		},
+		Spec: spec,
+		Now:  now,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plan.ClaimTransitions) != 0 {
+		t.Errorf("expected no claim transition, got %+v", plan.ClaimTransitions)
+	}
+
+	expectedRequests := []types.ComposabilityRequestChange{
+		{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+	}
+	if !reflect.DeepEqual(plan.ComposabilityRequests, expectedRequests) {
+		t.Errorf("ComposabilityRequest changes are incorrect. Got: %+v, Want: %+v", plan.ComposabilityRequests, expectedRequests)
+	}
+
+	// The request planned for the claim already excludes the other model.
+	expectedLabels := types.NodeLabelChange{
+		AddLabels:    []string{testLabelPrefix + "/nvidia-a100-40g"},
+		DeleteLabels: []string{testLabelPrefix + "/nvidia-a100-80g"},
+	}
+	if !reflect.DeepEqual(plan.NodeLabels, expectedLabels) {
+		t.Errorf("node labels are incorrect. Got: %+v, Want: %+v", plan.NodeLabels, expectedLabels)
+	}
+
+	expectedCounts := []types.DeviceCount{
+		{Model: "A100 40G", Configured: 2},
+		{Model: "A100 80G"},
+	}
+	if !reflect.DeepEqual(plan.DeviceCounts, expectedCounts) {
+		t.Errorf("device counts are incorrect. Got: %+v, Want: %+v", plan.DeviceCounts, expectedCounts)
+	}
+}
+
+// randomInput builds a random node: claims in any state, ComposableResources
+// in any state and ComposabilityRequests of any size and owner.
+func randomInput(t *testing.T, rng *rand.Rand, now time.Time) Input {
+	models := []string{"A100 40G", "A100 80G", "H100"}
+	states := []string{"Preparing", "Reschedule", "Failed", ""}
+	resourceStates := []string{utils.ResourceStateOnline, utils.ResourceStateAttaching, utils.ResourceStateDetaching, ""}
+
+	spec := types.ComposableDRASpec{LabelPrefix: testLabelPrefix}
+	node := types.NodeInfo{Name: "node1", Cordoned: rng.Intn(5) == 0, NotReady: rng.Intn(5) == 0}
+	for i, model := range models {
+		deviceInfo := types.DeviceInfo{
+			Index:         i + 1,
+			CDIModelName:  model,
+			DriverName:    "gpu.nvidia.com",
+			K8sDeviceName: fmt.Sprintf("device-%d", i+1),
+		}
+		for j := range models {
+			if j != i && rng.Intn(3) == 0 {
+				deviceInfo.CannotCoexistWith = append(deviceInfo.CannotCoexistWith, j+1)
+			}
+		}
+		spec.DeviceInfos = append(spec.DeviceInfos, deviceInfo)
+		node.Models = append(node.Models, types.ModelConstraints{Model: model, MaxDevice: rng.Intn(5), MinDevice: rng.Intn(3)})
+	}
+	if rng.Intn(4) == 0 {
+		node.AllowedModels = []string{models[rng.Intn(len(models))]}
+	}
+
+	resourceSliceInfos := []types.ResourceSliceInfo{{Name: "rs0", NodeName: "node1", Driver: "gpu.nvidia.com", Pool: "node1"}}
+	for i := range 4 {
+		resourceSliceInfos[0].Devices = append(resourceSliceInfos[0].Devices, types.ResourceSliceDevice{
+			Name: fmt.Sprintf("gpu%d", i),
+			UUID: fmt.Sprintf("uuid-%d", i),
+		})
+	}
+
+	var resourceClaimInfos []types.ResourceClaimInfo
+	for i := range rng.Intn(5) {
+		claim := types.ResourceClaimInfo{
+			Name:              fmt.Sprintf("claim-%d", i),
+			Namespace:         "default",
+			NodeName:          "node1",
+			CreationTimestamp: metav1.Time{Time: now.Add(-time.Duration(rng.Intn(30)) * time.Minute)},
+		}
+		for j := range rng.Intn(3) + 1 {
+			claim.Devices = append(claim.Devices, types.ResourceClaimDevice{
+				Name:  fmt.Sprintf("gpu%d", j),
+				Model: models[rng.Intn(len(models))],
+				State: states[rng.Intn(len(states))],
+			})
+		}
+		resourceClaimInfos = append(resourceClaimInfos, claim)
+	}
+
+	snapshot := newSnapshot(t, "node1", resourceClaimInfos, resourceSliceInfos)
+
+	for i := range rng.Intn(5) {
+		deviceID := fmt.Sprintf("uuid-%d", rng.Intn(6))
+		resource := cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("res%d", i),
+				CreationTimestamp: metav1.Time{Time: now.Add(-time.Duration(rng.Intn(30)) * time.Minute)},
+			},
+			Spec: cdioperator.ComposableResourceSpec{
+				Type:       "gpu",
+				Model:      models[rng.Intn(len(models))],
+				TargetNode: "node1",
+			},
+			Status: cdioperator.ComposableResourceStatus{
+				State:       resourceStates[rng.Intn(len(resourceStates))],
+				DeviceID:    deviceID,
+				CDIDeviceID: deviceID,
+			},
+		}
+		if rng.Intn(2) == 0 {
+			resource.Annotations = map[string]string{
+				testLabelPrefix + "/last-used-time": now.Add(-time.Duration(rng.Intn(30)) * time.Minute).Format(time.RFC3339),
+			}
+		}
+		snapshot.ComposableResources = append(snapshot.ComposableResources, resource)
+	}
+
+	for i, model := range models {
+		for j := range rng.Intn(4) {
+			cr := cdioperator.ComposabilityRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              fmt.Sprintf("request%d-%d", i, j),
+					CreationTimestamp: metav1.Time{Time: now.Add(-time.Duration(rng.Intn(30)) * time.Minute)},
+				},
+				Spec: cdioperator.ComposabilityRequestSpec{
+					Resource: cdioperator.ScalarResourceDetails{
+						Type:       "gpu",
+						Model:      model,
+						Size:       int64(rng.Intn(5)),
+						TargetNode: "node1",
+					},
+				},
+			}
+			// Owned, created by an earlier version or created by hand.
+			switch rng.Intn(3) {
+			case 0:
+				cr.Labels = utils.OwnerLabels(testLabelPrefix, "node1", model)
+				if j == 0 {
+					cr.Name = utils.ComposabilityRequestName("node1", model)
+				}
+			case 1:
+				cr.GenerateName = "composability-"
+			}
+			snapshot.ComposabilityRequests = append(snapshot.ComposabilityRequests, cr)
+		}
+	}
+
+	return Input{
+		Snapshot:           snapshot,
+		Node:               node,
+		Spec:               spec,
+		DeviceNoRemoval:    10 * time.Minute,
+		DeviceNoAllocation: time.Minute,
+		AttachTimeout:      time.Duration(rng.Intn(20)) * time.Minute,
+		Now:                now,
+	}
+}
+
+// TestPlanProperties checks invariants of the plans of random nodes.
+func TestPlanProperties(t *testing.T) {
+	now := time.Now()
+
+	for seed := int64(1); seed <= 200; seed++ {
+		input := randomInput(t, rand.New(rand.NewSource(seed)), now)
+
+		before := utils.NodeSnapshot{
+			ResourceClaimInfos:    copyClaims(input.Snapshot.ResourceClaimInfos),
+			ComposableResources:   slices.Clone(input.Snapshot.ComposableResources),
+			ComposabilityRequests: slices.Clone(input.Snapshot.ComposabilityRequests),
+		}
+
+		plan, err := Plan(context.Background(), input)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+
+		// The snapshot is not modified.
+		if !reflect.DeepEqual(input.Snapshot.ResourceClaimInfos, before.ResourceClaimInfos) ||
+			!reflect.DeepEqual(input.Snapshot.ComposableResources, before.ComposableResources) ||
+			!reflect.DeepEqual(input.Snapshot.ComposabilityRequests, before.ComposabilityRequests) {
+			t.Errorf("seed %d: the snapshot was modified", seed)
+		}
+
+		// The same input gives the same plan.
+		again, err := Plan(context.Background(), input)
+		if err != nil {
+			t.Fatalf("seed %d: unexpected error: %v", seed, err)
+		}
+		if !reflect.DeepEqual(plan, again) {
+			t.Errorf("seed %d: plans differ:\n%+v\n%+v", seed, plan, again)
+		}
+
+		requests := make(map[string]cdioperator.ComposabilityRequest)
+		totals := make(map[string]int64)
+		for _, cr := range input.Snapshot.ComposabilityRequests {
+			requests[cr.Name] = cr
+			totals[cr.Spec.Resource.Model] += cr.Spec.Resource.Size
+		}
+
+		for _, change := range plan.ComposabilityRequests {
+			// Every change changes something.
+			if change.Size == change.PreviousSize {
+				t.Errorf("seed %d: no-op ComposabilityRequest change %+v", seed, change)
+			}
+			totals[change.Model] += change.Size - change.PreviousSize
+			// A cordoned or NotReady node is never scaled up.
+			if (input.Node.Cordoned || input.Node.NotReady) && change.Size > change.PreviousSize {
+				t.Errorf("seed %d: ComposabilityRequest change %+v on a node that does not accept devices", seed, change)
+			}
+			// A model the node does not allow is never scaled up.
+			if !utils.IsModelAllowed(input.Node, change.Model) && change.Size > change.PreviousSize {
+				t.Errorf("seed %d: ComposabilityRequest change %+v of a model that is not allowed", seed, change)
+			}
+			// A scale-down only chooses devices of its model, and no more
+			// than it removes.
+			if int64(len(change.Victims)) > max(change.PreviousSize-change.Size, 0) {
+				t.Errorf("seed %d: ComposabilityRequest change %+v chooses too many devices", seed, change)
+			}
+			for _, name := range change.Victims {
+				if !slices.ContainsFunc(input.Snapshot.ComposableResources, func(resource cdioperator.ComposableResource) bool {
+					return resource.Name == name && resource.Spec.Model == change.Model
+				}) {
+					t.Errorf("seed %d: ComposabilityRequest change %+v chooses %s of another model", seed, change, name)
+				}
+			}
+			// A request is only created for a model that has no managed one.
+			if change.Name == "" {
+				for _, cr := range input.Snapshot.ComposabilityRequests {
+					if cr.Spec.Resource.Model == change.Model && utils.IsManagedComposabilityRequest(cr, input.Spec.LabelPrefix) {
+						t.Errorf("seed %d: ComposabilityRequest created for model %s which has %s", seed, change.Model, cr.Name)
+					}
+				}
+				continue
+			}
+			// Requests created by hand are never resized.
+			if !utils.IsManagedComposabilityRequest(requests[change.Name], input.Spec.LabelPrefix) {
+				t.Errorf("seed %d: unmanaged ComposabilityRequest %s resized", seed, change.Name)
+			}
+		}
+
+		// No allowed model goes below the min_device of the node, unless the
+		// node is cordoned or cannot be scaled up.
+		for _, device := range input.Spec.DeviceInfos {
+			if input.Node.Cordoned || input.Node.NotReady {
+				break
+			}
+			if !utils.IsModelAllowed(input.Node, device.CDIModelName) {
+				continue
+			}
+			if _, minDevice := utils.GetModelLimit(input.Node, device.CDIModelName); totals[device.CDIModelName] < minDevice {
+				t.Errorf("seed %d: model %s has %d devices, below min_device %d", seed, device.CDIModelName, totals[device.CDIModelName], minDevice)
+			}
+		}
+
+		// Only managed requests are adopted or deleted, and only empty ones
+		// are deleted.
+		for _, ref := range plan.AdoptedRequests {
+			if !utils.IsLegacyComposabilityRequest(requests[ref.Name], input.Spec.LabelPrefix) {
+				t.Errorf("seed %d: ComposabilityRequest %s adopted but not created by an earlier version", seed, ref.Name)
+			}
+		}
+		for _, ref := range plan.DeletedRequests {
+			cr := requests[ref.Name]
+			if !utils.IsManagedComposabilityRequest(cr, input.Spec.LabelPrefix) || cr.Spec.Resource.Size != 0 {
+				t.Errorf("seed %d: ComposabilityRequest %s deleted with size %d", seed, ref.Name, cr.Spec.Resource.Size)
+			}
+		}
+
+		// A claim is only moved to a final state.
+		for _, transition := range plan.ClaimTransitions {
+			if transition.State != "Failed" && transition.State != "Reschedule" {
+				t.Errorf("seed %d: claim %s moved to %q", seed, transition.Claim.Name, transition.State)
+			}
+		}
+
+		// A label is either added or removed, and only allowed models are
+		// advertised.
+		for _, label := range plan.NodeLabels.AddLabels {
+			if slices.Contains(plan.NodeLabels.DeleteLabels, label) {
+				t.Errorf("seed %d: label %s is both added and removed", seed, label)
+			}
+		}
+		for _, device := range input.Spec.DeviceInfos {
+			label := input.Spec.LabelPrefix + "/" + device.K8sDeviceName
+			if !utils.IsModelAllowed(input.Node, device.CDIModelName) && slices.Contains(plan.NodeLabels.AddLabels, label) {
+				t.Errorf("seed %d: label %s of a model that is not allowed is added", seed, label)
+			}
+		}
+	}
+}
+
+func TestSortByTime(t *testing.T) {
+	now := time.Now().UTC()
+	hourAgo := now.Add(-1 * time.Hour)
+	twoHoursAgo := now.Add(-2 * time.Hour)
+	tests := []struct {
+		name  string
+		input []types.ResourceClaimInfo
+		want  []types.ResourceClaimInfo
+		order string
+	}{
+		{
+			name:  "normal descending order",
+			order: "Descending",
+			input: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(twoHoursAgo)},
+				{CreationTimestamp: metav1.NewTime(now)},
+				{CreationTimestamp: metav1.NewTime(hourAgo)},
+			},
+			want: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(now)},
+				{CreationTimestamp: metav1.NewTime(hourAgo)},
+				{CreationTimestamp: metav1.NewTime(twoHoursAgo)},
+			},
+		},
+		{
+			name:  "normal ascending order",
+			order: "Ascending",
+			input: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(twoHoursAgo)},
+				{CreationTimestamp: metav1.NewTime(now)},
+				{CreationTimestamp: metav1.NewTime(hourAgo)},
+			},
+			want: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(twoHoursAgo)},
+				{CreationTimestamp: metav1.NewTime(hourAgo)},
+				{CreationTimestamp: metav1.NewTime(now)},
+			},
+		},
+		{
+			name:  "empty slice",
+			order: "Descending",
+			input: []types.ResourceClaimInfo{},
+			want:  []types.ResourceClaimInfo{},
+		},
+		{
+			name:  "single element",
+			order: "Descending",
+			input: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(now)},
+			},
+			want: []types.ResourceClaimInfo{
+				{CreationTimestamp: metav1.NewTime(now)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]types.ResourceClaimInfo, len(tt.input))
+			copy(got, tt.input)
+
+			sortByTime(got, tt.order)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("length mismatch: got %d, want %d", len(got), len(tt.want))
+			}
+			for i := 0; i < len(got); i++ {
+				if !got[i].CreationTimestamp.Time.Equal(tt.want[i].CreationTimestamp.Time) {
+					t.Errorf("index %d time mismatch:\ngot:  %v\nwant: %v",
+						i, got[i].CreationTimestamp.Time, tt.want[i].CreationTimestamp.Time)
+				}
+			}
+		})
+	}
+}
+
+func TestIsLastUsedOverTime(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		planned            bool
+		deviceNoAllocation time.Duration
+		expectedResult     bool
+		expectedErr        bool
+		errMsg             string
+	}{
+		{
+			name:               "No annotations",
+			annotations:        nil,
+			deviceNoAllocation: time.Minute,
+			expectedResult:     true,
+		},
+		{
+			name:               "Annotation not found",
+			annotations:        map[string]string{},
+			deviceNoAllocation: time.Minute,
+			expectedResult:     true,
+		},
+		{
+			name: "Invalid time format",
+			annotations: map[string]string{
+				testLabelPrefix + "/last-used-time": "invalid-time-format",
+			},
+			deviceNoAllocation: time.Minute,
+			expectedErr:        true,
+			errMsg:             "failed to parse time: parsing time \"invalid-time-format\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"invalid-time-format\" as \"2006\"",
+		},
+		{
+			name: "Time less than deviceNoAllocation",
+			annotations: map[string]string{
+				testLabelPrefix + "/last-used-time": now.Add(-30 * time.Second).Format(time.RFC3339),
+			},
+			deviceNoAllocation: time.Minute,
+			expectedResult:     false,
+		},
+		{
+			name: "Time more than deviceNoAllocation",
+			annotations: map[string]string{
+				testLabelPrefix + "/last-used-time": now.Add(-2 * time.Minute).Format(time.RFC3339),
+			},
+			deviceNoAllocation: time.Minute,
+			expectedResult:     true,
+		},
+		{
+			name: "Last used time planned in the reconcile",
+			annotations: map[string]string{
+				testLabelPrefix + "/last-used-time": now.Add(-2 * time.Minute).Format(time.RFC3339),
+			},
+			planned:            true,
+			deviceNoAllocation: time.Minute,
+			expectedResult:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "res0",
+					Annotations: tt.annotations,
+				},
+				Spec: cdioperator.ComposableResourceSpec{
+					Type:       "gpu",
+					Model:      "A100",
+					TargetNode: "node1",
+				},
+			}
+
+			p := newPlanner(Input{
+				Snapshot: &utils.NodeSnapshot{NodeName: "node1"},
+				Spec:     types.ComposableDRASpec{LabelPrefix: testLabelPrefix},
+				Now:      now,
+			})
+			if tt.planned {
+				p.setLastUsedTime(resource.Name)
+			}
+
+			result, err := p.isLastUsedOverTime(resource, tt.deviceNoAllocation)
+
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got none")
+				} else if err.Error() != tt.errMsg {
+					t.Errorf("Expected error message %q, got %q", tt.errMsg, err.Error())
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			} else if result != tt.expectedResult {
+				t.Errorf("Expected result: %v, got %v", tt.expectedResult, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package planner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// planRescheduleFailed fails the claims that cannot be served on the node: a
+// device matches no model, the claim mixes models that cannot coexist, it
+// conflicts with the ComposabilityRequests or the other claims of the node, its
+// model is not allowed on the node, or the node would need more devices of a
+// model than its max_device.
+func (p *planner) planRescheduleFailed(ctx context.Context) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning reschedule failed")
+
+	sortByTime(p.claims, "Descending")
+
+outerLoop:
+	for k, rc := range p.claims {
+		for _, rcDevice := range rc.Devices {
+			if rcDevice.ResolveError != "" {
+				p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonUnresolvedDevice, rcDevice.ResolveError)
+				continue outerLoop
+			}
+		}
+
+		for i, rcDevice := range rc.Devices {
+			for j, otherDevice := range rc.Devices {
+				if i != j && rcDevice.Model != otherDevice.Model {
+					if !isDeviceCoexistence(rcDevice.Model, otherDevice.Model, p.Spec) {
+						message := fmt.Sprintf("model %s cannot coexist with model %s in the same claim", rcDevice.Model, otherDevice.Model)
+						p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonIncompatibleModelInClaim, message)
+						continue outerLoop
+					}
+				}
+			}
+
+			if rcDevice.State == "Preparing" {
+				for _, composabilityRequest := range p.Snapshot.ComposabilityRequests {
+					if composabilityRequest.Spec.Resource.Size > 0 &&
+						composabilityRequest.Spec.Resource.TargetNode == rc.NodeName {
+						if !isDeviceCoexistence(rcDevice.Model, composabilityRequest.Spec.Resource.Model, p.Spec) {
+							message := fmt.Sprintf("model %s cannot coexist with model %s already requested on node %s by ComposabilityRequest %s",
+								rcDevice.Model, composabilityRequest.Spec.Resource.Model, rc.NodeName, composabilityRequest.Name)
+							p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonIncompatibleModelOnNode, message,
+								*utils.ComposabilityRequestReference(&composabilityRequest))
+							continue outerLoop
+						}
+					}
+				}
+
+				for i, rc2 := range p.claims {
+					if rc.Name != rc2.Name {
+						for _, rc2Device := range rc2.Devices {
+							if rc2Device.State == "Preparing" && rcDevice.Model != rc2Device.Model {
+								if !isDeviceCoexistence(rcDevice.Model, rc2Device.Model, p.Spec) {
+									message := fmt.Sprintf("model %s cannot coexist with model %s requested by ResourceClaim %s/%s on node %s",
+										rcDevice.Model, rc2Device.Model, rc2.Namespace, rc2.Name, rc.NodeName)
+									p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonIncompatibleConcurrentClaim, message)
+									message = fmt.Sprintf("model %s cannot coexist with model %s requested by ResourceClaim %s/%s on node %s",
+										rc2Device.Model, rcDevice.Model, rc.Namespace, rc.Name, rc2.NodeName)
+									p.setDevicesState(ctx, i, "Failed", "FabricDeviceFailed", types.ReasonIncompatibleConcurrentClaim, message)
+									continue outerLoop
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+
+		modelMap := getUniqueModelsWithCounts(rc)
+		for _, model := range sortedModels(modelMap) {
+			if !utils.IsModelAllowed(p.Node, model) {
+				message := fmt.Sprintf("model %s is not allowed on node %s by NodeScalingPolicy %s", model, rc.NodeName, p.Node.Policy)
+				p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonModelNotAllowed, message)
+				continue outerLoop
+			}
+
+			cofiguredDeviceCount := utils.GetConfiguredDeviceCount(ctx, p.Snapshot, model, p.claims)
+			maxDevice, _ := utils.GetModelLimit(p.Node, model)
+			logger.Info("Configured device count", "model", model, "count", cofiguredDeviceCount, "max", maxDevice)
+
+			if cofiguredDeviceCount > maxDevice {
+				message := fmt.Sprintf("model %s requested %d, node limit %d", model, cofiguredDeviceCount, maxDevice)
+				p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonMaxDeviceExceeded, message,
+					p.modelComposabilityRequests(model)...)
+			}
+		}
+	}
+}
+
+// planQuotas fails the claims of the node whose devices would exceed a
+// DeviceQuota, and records the usage of the quotas. The claims of the other
+// nodes are counted as in the cluster, those of the node as planned so far;
+// the claims admitted before keep their devices, and the others are admitted
+// in the same order on every node.
+func (p *planner) planQuotas(ctx context.Context) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning quotas")
+
+	if len(p.Quotas) == 0 {
+		return
+	}
+
+	claims := slices.Clone(p.claims)
+	for _, claim := range p.ClusterClaims {
+		if !slices.ContainsFunc(p.claims, func(rc types.ResourceClaimInfo) bool {
+			return rc.Namespace == claim.Namespace && rc.Name == claim.Name
+		}) {
+			claims = append(claims, claim)
+		}
+	}
+
+	usage, rejections, admitted := utils.AdmitClaims(p.Quotas, claims, p.AdmittedClaims)
+	p.plan.QuotaUsage = usage
+	p.plan.AdmittedClaims = admitted
+
+	for k, rc := range p.claims {
+		if rc.NodeName != p.Snapshot.NodeName {
+			continue
+		}
+		rejection, ok := rejections[k8stypes.NamespacedName{Namespace: rc.Namespace, Name: rc.Name}]
+		if !ok {
+			continue
+		}
+
+		i := slices.IndexFunc(p.Quotas, func(quota ddsv1alpha1.DeviceQuota) bool {
+			return quota.Name == rejection.Quota
+		})
+		message := fmt.Sprintf("model %s requested %d, quota %s allows %d and %d are in use",
+			rejection.Model, rejection.Requested, rejection.Quota, rejection.Limit, rejection.Used)
+		p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonQuotaExceeded, message,
+			*utils.DeviceQuotaReference(&p.Quotas[i]))
+	}
+}
+
+// planReschedule reschedules the claims whose devices are all attached and
+// idle, and marks these devices as used. A claim waiting for a model whose
+// ComposableResource failed is failed instead.
+func (p *planner) planReschedule(ctx context.Context) error {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning reschedule")
+
+	//TODO:
+	if len(p.Snapshot.ComposableResources) == 0 {
+		logger.Info("No ComposableResource found, skipping reschedule notification")
+		return nil
+	}
+
+	sortByTime(p.claims, "Ascending")
+
+OuterLoop:
+	for k, rc := range p.claims {
+		resourceMatched := make(map[string]bool)
+		modelMap := getUniqueModelsWithCounts(rc)
+	MiddleLoop:
+		for _, model := range sortedModels(modelMap) {
+			count := modelMap[model]
+			matchedCount := 0
+			for _, resource := range p.Snapshot.ComposableResources {
+				if resource.Spec.Model == model && resource.Spec.TargetNode == rc.NodeName {
+					if !resourceMatched[resource.Name] && utils.IsResourceOnline(resource) {
+						isRed, resourceSliceInfo, deviceName := utils.IsDeviceResourceSliceRed(resource.Status.CDIDeviceID, p.Snapshot.ResourceSliceInfos)
+						if isRed {
+							if p.Snapshot.IsDeviceUsedByPod(deviceName, *resourceSliceInfo) {
+								continue
+							}
+
+							isOvertime, err := p.isLastUsedOverTime(resource, p.DeviceNoAllocation)
+							if err != nil {
+								return err
+							}
+							if !isOvertime {
+								continue
+							}
+
+							resourceMatched[resource.Name] = true
+							matchedCount++
+
+							if matchedCount >= count {
+								continue MiddleLoop
+							}
+						}
+					}
+				}
+			}
+
+			if failedResource := p.findFailedResource(model, rc.NodeName); failedResource != nil {
+				message := fmt.Sprintf("ComposableResource %s of model %s on node %s failed: %s",
+					failedResource.Name, model, rc.NodeName, failedResource.Status.Error)
+				p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonComposableResourceFailed, message,
+					*utils.ComposableResourceReference(failedResource))
+			}
+			continue OuterLoop
+		}
+
+		message := fmt.Sprintf("%d devices are ready on node %s", len(resourceMatched), rc.NodeName)
+		p.setDevicesState(ctx, k, "Reschedule", "FabricDeviceReschedule", types.ReasonDeviceReady, message)
+
+		resourceNames := make([]string, 0, len(resourceMatched))
+		for resourceName := range resourceMatched {
+			resourceNames = append(resourceNames, resourceName)
+		}
+		sort.Strings(resourceNames)
+		for _, resourceName := range resourceNames {
+			p.setLastUsedTime(resourceName)
+		}
+	}
+
+	return nil
+}
+
+// planAttachTimeout fails the claims of the node whose devices are still
+// being prepared AttachTimeout after their attachment started, see
+// AttachStart, so that the scheduler can retry them elsewhere. The failed devices are no longer counted as configured, so
+// planDevices shrinks the ComposabilityRequest back down.
+func (p *planner) planAttachTimeout(ctx context.Context) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start planning attach timeout")
+
+	if p.AttachTimeout <= 0 {
+		return
+	}
+
+	for k, rc := range p.claims {
+		if rc.NodeName != p.Snapshot.NodeName {
+			continue
+		}
+
+		modelMap := getUniqueModelsWithCounts(rc)
+		if len(modelMap) == 0 {
+			continue
+		}
+
+		attachStart := rc.AttachStart()
+		if attachStart.IsZero() || p.Now.Sub(attachStart) <= p.AttachTimeout {
+			continue
+		}
+
+		models := sortedModels(modelMap)
+		message := fmt.Sprintf("devices of model %v were not attached on node %s within %s", models, rc.NodeName, p.AttachTimeout)
+		p.setDevicesState(ctx, k, "Failed", "FabricDeviceFailed", types.ReasonAttachTimeout, message)
+	}
+}
+
+// findFailedResource returns a ComposableResource of a model on a node that
+// reported an error, or nil.
+func (p *planner) findFailedResource(model, nodeName string) *cdioperator.ComposableResource {
+	for i := range p.Snapshot.ComposableResources {
+		resource := &p.Snapshot.ComposableResources[i]
+		if resource.Spec.Model == model && resource.Spec.TargetNode == nodeName &&
+			utils.IsResourceFailed(*resource) && !utils.IsResourceDetaching(*resource) {
+			return resource
+		}
+	}
+
+	return nil
+}
+
+// modelComposabilityRequests returns references to the ComposabilityRequests
+// of a model in the snapshot.
+func (p *planner) modelComposabilityRequests(model string) []corev1.ObjectReference {
+	var refs []corev1.ObjectReference
+	for i := range p.Snapshot.ComposabilityRequests {
+		if p.Snapshot.ComposabilityRequests[i].Spec.Resource.Model == model {
+			refs = append(refs, *utils.ComposabilityRequestReference(&p.Snapshot.ComposabilityRequests[i]))
+		}
+	}
+
+	return refs
+}
+
+func getUniqueModelsWithCounts(resourceClaimInfo types.ResourceClaimInfo) map[string]int {
+	modelMap := make(map[string]int)
+
+	for _, device := range resourceClaimInfo.Devices {
+		if device.State == "Preparing" {
+			modelMap[device.Model]++
+		}
+	}
+
+	return modelMap
+}
+
+func isDeviceCoexistence(model1, model2 string, composableDRASpec types.ComposableDRASpec) bool {
+	var index1, index2 int
+	var cannotCoexistWith1, cannotCoexistWith2 []int
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		switch deviceInfo.CDIModelName {
+		case model1:
+			index1 = deviceInfo.Index
+			cannotCoexistWith1 = deviceInfo.CannotCoexistWith
+		case model2:
+			index2 = deviceInfo.Index
+			cannotCoexistWith2 = deviceInfo.CannotCoexistWith
+		}
+	}
+
+	if index1 == 0 || index2 == 0 {
+		return true
+	}
+
+	return !slices.Contains(cannotCoexistWith1, index2) && !slices.Contains(cannotCoexistWith2, index1)
+}
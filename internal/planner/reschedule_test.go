@@ -0,0 +1,784 @@
+package planner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestIsDeviceCoexistence(t *testing.T) {
+	tests := []struct {
+		name                string
+		model1              string
+		model2              string
+		composableDRASpec   types.ComposableDRASpec
+		expectedCoexistence bool
+	}{
+		{
+			name:   "Models can coexist",
+			model1: "ModelA",
+			model2: "ModelB",
+			composableDRASpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:             1,
+						CDIModelName:      "ModelA",
+						CannotCoexistWith: []int{},
+					},
+					{
+						Index:             2,
+						CDIModelName:      "ModelB",
+						CannotCoexistWith: []int{},
+					},
+				},
+			},
+			expectedCoexistence: true,
+		},
+		{
+			name:   "Models cannot coexist",
+			model1: "ModelA",
+			model2: "ModelB",
+			composableDRASpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:             1,
+						CDIModelName:      "ModelA",
+						CannotCoexistWith: []int{2},
+					},
+					{
+						Index:             2,
+						CDIModelName:      "ModelB",
+						CannotCoexistWith: []int{1},
+					},
+				},
+			},
+			expectedCoexistence: false,
+		},
+		{
+			name:   "One-sided rule applies to both models",
+			model1: "ModelB",
+			model2: "ModelA",
+			composableDRASpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:             1,
+						CDIModelName:      "ModelA",
+						CannotCoexistWith: []int{2},
+					},
+					{
+						Index:        2,
+						CDIModelName: "ModelB",
+					},
+				},
+			},
+			expectedCoexistence: false,
+		},
+		{
+			name:   "Out of range index",
+			model1: "ModelA",
+			model2: "ModelB",
+			composableDRASpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:             1,
+						CDIModelName:      "ModelA",
+						CannotCoexistWith: []int{5},
+					},
+					{
+						Index:        2,
+						CDIModelName: "ModelB",
+					},
+				},
+			},
+			expectedCoexistence: true,
+		},
+		{
+			name:   "Model not found",
+			model1: "ModelX",
+			model2: "ModelY",
+			composableDRASpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:             1,
+						CDIModelName:      "ModelA",
+						CannotCoexistWith: []int{},
+					},
+					{
+						Index:             2,
+						CDIModelName:      "ModelB",
+						CannotCoexistWith: []int{},
+					},
+				},
+			},
+			expectedCoexistence: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := isDeviceCoexistence(tt.model1, tt.model2, tt.composableDRASpec)
+			if result != tt.expectedCoexistence {
+				t.Errorf("expected %v, got %v", tt.expectedCoexistence, result)
+			}
+		})
+	}
+}
+
+func TestPlanRescheduleFailed(t *testing.T) {
+	now := time.Now()
+	composableDRASpec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:             1,
+				CDIModelName:      "A100 40G",
+				CannotCoexistWith: []int{2},
+			},
+			{
+				Index:             2,
+				CDIModelName:      "A100 80G",
+				CannotCoexistWith: []int{1},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name                   string
+		existingRequestList    *cdioperator.ComposabilityRequestList
+		nodeInfo               types.NodeInfo
+		resourceClaims         []types.ResourceClaimInfo
+		expectedResourceClaims []types.ResourceClaimInfo
+		expectedReasons        []types.ConditionReason
+	}{
+		{
+			name: "Device not coexistence",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+						{Name: "device-2", Model: "A100 80G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 5}},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Failed"},
+						{Name: "device-2", Model: "A100 80G", State: "Failed"},
+					},
+				},
+			},
+			expectedReasons: []types.ConditionReason{types.ReasonIncompatibleModelInClaim},
+		},
+		{
+			name: "ComposabilityRequestList exceed the maximum",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+						{Name: "device-2", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 1}},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Failed"},
+						{Name: "device-2", Model: "A100 40G", State: "Failed"},
+					},
+				},
+			},
+			expectedReasons: []types.ConditionReason{types.ReasonMaxDeviceExceeded},
+		},
+		{
+			name: "ComposabilityRequestList have different model",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+						{Name: "device-2", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 5}},
+			},
+			existingRequestList: &cdioperator.ComposabilityRequestList{
+				Items: []cdioperator.ComposabilityRequest{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "request1",
+						},
+						Spec: cdioperator.ComposabilityRequestSpec{
+							Resource: cdioperator.ScalarResourceDetails{
+								Model:      "A100 80G",
+								Size:       10,
+								TargetNode: "node1",
+							},
+						},
+					},
+				},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Failed"},
+						{Name: "device-2", Model: "A100 40G", State: "Failed"},
+					},
+				},
+			},
+			expectedReasons: []types.ConditionReason{types.ReasonIncompatibleModelOnNode},
+		},
+		{
+			name: "ResourceClaimInfo devices do not coexist",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+						{Name: "device-2", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+				{
+					Name:              "test-claim2",
+					Namespace:         "test-ns",
+					NodeName:          "node2",
+					CreationTimestamp: metav1.Time{Time: now.Add(-time.Minute)},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 80G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 5}},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Failed"},
+						{Name: "device-2", Model: "A100 40G", State: "Failed"},
+					},
+				},
+				{
+					Name:              "test-claim2",
+					Namespace:         "test-ns",
+					NodeName:          "node2",
+					CreationTimestamp: metav1.Time{Time: now.Add(-time.Minute)},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 80G", State: "Failed"},
+					},
+				},
+			},
+			expectedReasons: []types.ConditionReason{types.ReasonIncompatibleConcurrentClaim, types.ReasonIncompatibleConcurrentClaim},
+		},
+		{
+			name: "Model not allowed by the NodeScalingPolicy",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:          "node1",
+				Models:        []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 5}},
+				Policy:        "policy1",
+				AllowedModels: []string{"A100 80G"},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Failed"},
+					},
+				},
+			},
+			expectedReasons: []types.ConditionReason{types.ReasonModelNotAllowed},
+		},
+		{
+			name: "Claims that can be served",
+			resourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+			},
+			nodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "A100 40G", MaxDevice: 5}},
+			},
+			expectedResourceClaims: []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: now},
+					Devices: []types.ResourceClaimDevice{
+						{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientObjects := []runtime.Object{}
+			if tc.existingRequestList != nil {
+				for i := range tc.existingRequestList.Items {
+					clientObjects = append(clientObjects, &tc.existingRequestList.Items[i])
+				}
+			}
+
+			p := newPlanner(Input{
+				Snapshot: newSnapshot(t, tc.nodeInfo.Name, tc.resourceClaims, nil, clientObjects...),
+				Node:     tc.nodeInfo,
+				Spec:     composableDRASpec,
+				Now:      now,
+			})
+
+			p.planRescheduleFailed(context.Background())
+
+			if !reflect.DeepEqual(p.claims, tc.expectedResourceClaims) {
+				t.Errorf("resourceclaim infos are incorrect. Got: %v, Want: %v", p.claims, tc.expectedResourceClaims)
+			}
+
+			var reasons []types.ConditionReason
+			for _, transition := range p.plan.ClaimTransitions {
+				reasons = append(reasons, transition.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tc.expectedReasons) {
+				t.Errorf("transition reasons are incorrect. Got: %v, Want: %v", reasons, tc.expectedReasons)
+			}
+		})
+	}
+}
+
+func TestPlanReschedule(t *testing.T) {
+	now := time.Now()
+
+	onlineResource := func(name, cdiDeviceID, lastUsed string) cdioperator.ComposableResource {
+		return cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					testLabelPrefix + "/last-used-time": lastUsed,
+				},
+			},
+			Spec: cdioperator.ComposableResourceSpec{
+				Type:       "gpu",
+				Model:      "A100 40G",
+				TargetNode: "node1",
+			},
+			Status: cdioperator.ComposableResourceStatus{
+				State:       "Online",
+				CDIDeviceID: cdiDeviceID,
+			},
+		}
+	}
+	resourceClaimInfos := []types.ResourceClaimInfo{
+		{
+			Name:              "test-claim",
+			Namespace:         "test-ns",
+			NodeName:          "node1",
+			CreationTimestamp: metav1.Time{Time: now},
+			Devices: []types.ResourceClaimDevice{
+				{Name: "device-1", Model: "A100 40G", State: "Preparing"},
+				{Name: "device-2", Model: "A100 40G", State: "Preparing"},
+			},
+		},
+	}
+	resourceSliceInfos := []types.ResourceSliceInfo{
+		{
+			Devices: []types.ResourceSliceDevice{
+				{Name: "device-1", UUID: "123"},
+				{Name: "device-2", UUID: "456"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name                string
+		existingResources   []cdioperator.ComposableResource
+		expectedState       string
+		expectedAnnotations []types.AnnotationUpdate
+	}{
+		{
+			name: "normal case",
+			existingResources: []cdioperator.ComposableResource{
+				onlineResource("resource1", "123", now.Add(-30*time.Minute).Format(time.RFC3339)),
+				onlineResource("resource2", "456", now.Add(-30*time.Minute).Format(time.RFC3339)),
+			},
+			expectedState: "Reschedule",
+			expectedAnnotations: []types.AnnotationUpdate{
+				{ResourceName: "resource1", Key: testLabelPrefix + "/last-used-time", Value: now.Format(time.RFC3339)},
+				{ResourceName: "resource2", Key: testLabelPrefix + "/last-used-time", Value: now.Format(time.RFC3339)},
+			},
+		},
+		{
+			name: "device used recently",
+			existingResources: []cdioperator.ComposableResource{
+				onlineResource("resource1", "123", now.Add(-30*time.Minute).Format(time.RFC3339)),
+				onlineResource("resource2", "456", now.Add(-30*time.Second).Format(time.RFC3339)),
+			},
+			expectedState: "Preparing",
+		},
+		{
+			name: "ComposableResource failed",
+			existingResources: []cdioperator.ComposableResource{
+				onlineResource("resource1", "123", now.Add(-30*time.Minute).Format(time.RFC3339)),
+				func() cdioperator.ComposableResource {
+					resource := onlineResource("resource2", "456", now.Add(-30*time.Minute).Format(time.RFC3339))
+					resource.Status.Error = "fabric error"
+					return resource
+				}(),
+			},
+			expectedState: "Failed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clientObjects := []runtime.Object{}
+			for i := range tc.existingResources {
+				clientObjects = append(clientObjects, &tc.existingResources[i])
+			}
+
+			p := newPlanner(Input{
+				Snapshot:           newSnapshot(t, "node1", resourceClaimInfos, resourceSliceInfos, clientObjects...),
+				Spec:               types.ComposableDRASpec{LabelPrefix: testLabelPrefix},
+				DeviceNoAllocation: time.Minute,
+				Now:                now,
+			})
+
+			if err := p.planReschedule(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, device := range p.claims[0].Devices {
+				if device.State != tc.expectedState {
+					t.Errorf("device state is incorrect. Got: %s, Want: %s", device.State, tc.expectedState)
+				}
+			}
+			if !reflect.DeepEqual(p.plan.Annotations, tc.expectedAnnotations) {
+				t.Errorf("annotations are incorrect. Got: %v, Want: %v", p.plan.Annotations, tc.expectedAnnotations)
+			}
+		})
+	}
+}
+
+func TestPlanAttachTimeout(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name                 string
+		claimCreation        time.Time
+		allocatedAt          time.Time
+		preparingSince       time.Time
+		deviceState          string
+		existingResourceList *cdioperator.ComposableResourceList
+		attachTimeout        time.Duration
+		expectedState        string
+	}{
+		{
+			name:          "attach timed out",
+			claimCreation: now.Add(-20 * time.Minute),
+			allocatedAt:   now.Add(-20 * time.Minute),
+			deviceState:   "Preparing",
+			attachTimeout: 10 * time.Minute,
+			expectedState: "Failed",
+		},
+		{
+			name:          "attach within timeout",
+			claimCreation: now.Add(-5 * time.Minute),
+			allocatedAt:   now.Add(-5 * time.Minute),
+			deviceState:   "Preparing",
+			attachTimeout: 10 * time.Minute,
+			expectedState: "Preparing",
+		},
+		{
+			name:           "claim older than the timeout just allocated",
+			claimCreation:  now.Add(-20 * time.Minute),
+			allocatedAt:    now.Add(-time.Minute),
+			preparingSince: now.Add(-time.Minute),
+			deviceState:    "Preparing",
+			attachTimeout:  10 * time.Minute,
+			expectedState:  "Preparing",
+		},
+		{
+			name:           "allocation time survives a restart",
+			claimCreation:  now.Add(-20 * time.Minute),
+			allocatedAt:    now.Add(-20 * time.Minute),
+			preparingSince: now,
+			deviceState:    "Preparing",
+			attachTimeout:  10 * time.Minute,
+			expectedState:  "Failed",
+		},
+		{
+			name:           "first seen without allocation time",
+			claimCreation:  now.Add(-20 * time.Minute),
+			preparingSince: now.Add(-20 * time.Minute),
+			deviceState:    "Preparing",
+			attachTimeout:  10 * time.Minute,
+			expectedState:  "Failed",
+		},
+		{
+			name:          "claim older than the timeout not seen yet",
+			claimCreation: now.Add(-20 * time.Minute),
+			deviceState:   "Preparing",
+			attachTimeout: 10 * time.Minute,
+			expectedState: "Preparing",
+		},
+		{
+			name:          "ComposableResource of another claim attaching recently",
+			claimCreation: now.Add(-20 * time.Minute),
+			allocatedAt:   now.Add(-20 * time.Minute),
+			deviceState:   "Preparing",
+			existingResourceList: &cdioperator.ComposableResourceList{
+				Items: []cdioperator.ComposableResource{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:              "res0",
+							CreationTimestamp: metav1.Time{Time: now.Add(-time.Minute)},
+						},
+						Spec: cdioperator.ComposableResourceSpec{
+							Model:      "A100 40G",
+							TargetNode: "node1",
+						},
+						Status: cdioperator.ComposableResourceStatus{
+							State: "Attaching",
+						},
+					},
+				},
+			},
+			attachTimeout: 10 * time.Minute,
+			expectedState: "Failed",
+		},
+		{
+			name:          "device already rescheduled",
+			claimCreation: now.Add(-20 * time.Minute),
+			allocatedAt:   now.Add(-20 * time.Minute),
+			deviceState:   "Reschedule",
+			attachTimeout: 10 * time.Minute,
+			expectedState: "Reschedule",
+		},
+		{
+			name:          "timeout disabled",
+			claimCreation: now.Add(-20 * time.Minute),
+			allocatedAt:   now.Add(-20 * time.Minute),
+			deviceState:   "Preparing",
+			expectedState: "Preparing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resourceClaimInfos := []types.ResourceClaimInfo{
+				{
+					Name:              "test-claim",
+					Namespace:         "test-ns",
+					NodeName:          "node1",
+					CreationTimestamp: metav1.Time{Time: tc.claimCreation},
+					AllocatedAt:       metav1.Time{Time: tc.allocatedAt},
+					PreparingSince:    metav1.Time{Time: tc.preparingSince},
+					Devices: []types.ResourceClaimDevice{
+						{
+							Name:  "gpu0",
+							Model: "A100 40G",
+							State: tc.deviceState,
+						},
+					},
+				},
+			}
+
+			snapshot := newSnapshot(t, "node1", resourceClaimInfos, nil)
+			// Use the objects of the test case as they are, so that their
+			// creation timestamps do not depend on the fake client.
+			if tc.existingResourceList != nil {
+				snapshot.ComposableResources = tc.existingResourceList.Items
+			}
+
+			p := newPlanner(Input{
+				Snapshot:      snapshot,
+				AttachTimeout: tc.attachTimeout,
+				Now:           now,
+			})
+
+			p.planAttachTimeout(context.Background())
+
+			if p.claims[0].Devices[0].State != tc.expectedState {
+				t.Errorf("device state is incorrect. Got: %s, Want: %s", p.claims[0].Devices[0].State, tc.expectedState)
+			}
+		})
+	}
+}
+
+func TestPlanQuotas(t *testing.T) {
+	now := time.Now()
+	quota := ddsv1alpha1.DeviceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a", UID: "quota-uid"},
+		Spec: ddsv1alpha1.DeviceQuotaSpec{
+			Namespace: "test-ns",
+			Limits:    []ddsv1alpha1.DeviceQuotaLimit{{Model: "A100 40G", MaxDevices: 2}},
+		},
+	}
+	claim := func(name, nodeName string, created time.Time, states ...string) types.ResourceClaimInfo {
+		rc := types.ResourceClaimInfo{
+			Name:              name,
+			Namespace:         "test-ns",
+			NodeName:          nodeName,
+			CreationTimestamp: metav1.Time{Time: created},
+		}
+		for _, state := range states {
+			rc.Devices = append(rc.Devices, types.ResourceClaimDevice{Model: "A100 40G", State: state})
+		}
+		return rc
+	}
+
+	testCases := []struct {
+		name           string
+		quotas         []ddsv1alpha1.DeviceQuota
+		claims         []types.ResourceClaimInfo
+		clusterClaims  []types.ResourceClaimInfo
+		expectedStates []string
+		expectedUsage  []types.DeviceQuotaUsage
+	}{
+		{
+			name:           "no quota",
+			claims:         []types.ResourceClaimInfo{claim("rc1", "node1", now, "Preparing", "Preparing", "Preparing")},
+			expectedStates: []string{"Preparing"},
+		},
+		{
+			name:   "claim within the quota",
+			quotas: []ddsv1alpha1.DeviceQuota{quota},
+			claims: []types.ResourceClaimInfo{claim("rc1", "node1", now, "Preparing")},
+			clusterClaims: []types.ResourceClaimInfo{
+				claim("rc1", "node1", now, "Preparing"),
+				claim("rc2", "node2", now.Add(-time.Hour), ""),
+			},
+			expectedStates: []string{"Preparing"},
+			expectedUsage:  []types.DeviceQuotaUsage{{Quota: "team-a", Model: "A100 40G", Used: 2}},
+		},
+		{
+			name:   "claim over the quota with devices on another node",
+			quotas: []ddsv1alpha1.DeviceQuota{quota},
+			claims: []types.ResourceClaimInfo{claim("rc1", "node1", now, "Preparing")},
+			clusterClaims: []types.ResourceClaimInfo{
+				claim("rc2", "node2", now.Add(-time.Hour), "", ""),
+			},
+			expectedStates: []string{"Failed"},
+			expectedUsage:  []types.DeviceQuotaUsage{{Quota: "team-a", Model: "A100 40G", Used: 2}},
+		},
+		{
+			name:   "older claim on another node is admitted first",
+			quotas: []ddsv1alpha1.DeviceQuota{quota},
+			claims: []types.ResourceClaimInfo{claim("rc1", "node1", now, "Preparing")},
+			clusterClaims: []types.ResourceClaimInfo{
+				claim("rc2", "node2", now.Add(-time.Minute), "Preparing", "Preparing"),
+			},
+			expectedStates: []string{"Failed"},
+			expectedUsage:  []types.DeviceQuotaUsage{{Quota: "team-a", Model: "A100 40G", Used: 2}},
+		},
+		{
+			name:   "claim of the node uses its planned state",
+			quotas: []ddsv1alpha1.DeviceQuota{quota},
+			claims: []types.ResourceClaimInfo{claim("rc1", "node1", now, "Preparing", "Preparing")},
+			clusterClaims: []types.ResourceClaimInfo{
+				claim("rc1", "node1", now, "Preparing", "Preparing"),
+				claim("rc2", "node2", now.Add(-time.Minute), "Failed", "Failed"),
+			},
+			expectedStates: []string{"Preparing"},
+			expectedUsage:  []types.DeviceQuotaUsage{{Quota: "team-a", Model: "A100 40G", Used: 2}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newPlanner(Input{
+				Snapshot:      newSnapshot(t, "node1", tc.claims, nil),
+				Quotas:        tc.quotas,
+				ClusterClaims: tc.clusterClaims,
+				Now:           now,
+			})
+
+			p.planQuotas(context.Background())
+
+			for i, expectedState := range tc.expectedStates {
+				if p.claims[i].Devices[0].State != expectedState {
+					t.Errorf("device state of claim %s is incorrect. Got: %s, Want: %s", p.claims[i].Name, p.claims[i].Devices[0].State, expectedState)
+				}
+			}
+			if !reflect.DeepEqual(p.plan.QuotaUsage, tc.expectedUsage) {
+				t.Errorf("unexpected quota usage. Got: %v, Want: %v", p.plan.QuotaUsage, tc.expectedUsage)
+			}
+
+			for _, transition := range p.plan.ClaimTransitions {
+				if transition.Reason != types.ReasonQuotaExceeded {
+					t.Errorf("unexpected reason %s", transition.Reason)
+				}
+				if len(transition.Related) != 1 || transition.Related[0].Name != "team-a" || transition.Related[0].UID != "quota-uid" {
+					t.Errorf("unexpected related objects %v", transition.Related)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Report lists the changes a simulation made to the cluster.
+type Report struct {
+	Passes                int                          `json:"passes"`
+	DRAVersion            string                       `json:"draVersion"`
+	ComposabilityRequests []ComposabilityRequestChange `json:"composabilityRequests,omitempty"`
+	ClaimConditions       []ClaimConditionChange       `json:"claimConditions,omitempty"`
+	NodeLabels            []LabelChange                `json:"nodeLabels,omitempty"`
+	ResourceAnnotations   []AnnotationChange           `json:"resourceAnnotations,omitempty"`
+	Events                []Event                      `json:"events,omitempty"`
+	Errors                []ReconcileError             `json:"errors,omitempty"`
+}
+
+// ComposabilityRequestChange is a ComposabilityRequest created, resized or
+// deleted. The size before is 0 for created requests, the size after is 0
+// for deleted ones.
+type ComposabilityRequestChange struct {
+	Name       string `json:"name"`
+	Node       string `json:"node"`
+	Model      string `json:"model"`
+	SizeBefore int64  `json:"sizeBefore"`
+	SizeAfter  int64  `json:"sizeAfter"`
+	Created    bool   `json:"created,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+}
+
+// ClaimConditionChange is a device condition of a ResourceClaim that was
+// added or changed.
+type ClaimConditionChange struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Device    string                 `json:"device"`
+	Type      string                 `json:"type"`
+	Status    metav1.ConditionStatus `json:"status"`
+	Reason    string                 `json:"reason"`
+	Message   string                 `json:"message,omitempty"`
+}
+
+// LabelChange is a node label that was added, changed or removed. Before or
+// After is nil when the label is absent.
+type LabelChange struct {
+	Node   string  `json:"node"`
+	Label  string  `json:"label"`
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+// AnnotationChange is an annotation of a ComposableResource that was added,
+// changed or removed.
+type AnnotationChange struct {
+	Resource string  `json:"resource"`
+	Key      string  `json:"key"`
+	Before   *string `json:"before,omitempty"`
+	After    *string `json:"after,omitempty"`
+}
+
+// Event is an event emitted by the reconciler.
+type Event struct {
+	Object  string `json:"object"`
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// ReconcileError is an error returned by the reconcile of a node.
+type ReconcileError struct {
+	Pass  int    `json:"pass"`
+	Node  string `json:"node"`
+	Error string `json:"error"`
+}
+
+type claimDevice struct {
+	namespace, name, device string
+}
+
+// clusterState holds the parts of the cluster that DDS writes.
+type clusterState struct {
+	requests      map[string]cdioperator.ComposabilityRequest
+	conditions    map[claimDevice][]metav1.Condition
+	nodeLabels    map[string]map[string]string
+	annotations   map[string]map[string]string
+	requestNames  []string
+	claimDevices  []claimDevice
+	nodeNames     []string
+	resourceNames []string
+}
+
+func captureState(ctx context.Context, kubeClient client.Client, clientSet kubernetes.Interface) (*clusterState, error) {
+	state := &clusterState{
+		requests:    make(map[string]cdioperator.ComposabilityRequest),
+		conditions:  make(map[claimDevice][]metav1.Condition),
+		nodeLabels:  make(map[string]map[string]string),
+		annotations: make(map[string]map[string]string),
+	}
+
+	requestList := &cdioperator.ComposabilityRequestList{}
+	if err := kubeClient.List(ctx, requestList); err != nil {
+		return nil, fmt.Errorf("failed to list ComposabilityRequests: %v", err)
+	}
+	for _, cr := range requestList.Items {
+		state.requests[cr.Name] = cr
+		state.requestNames = append(state.requestNames, cr.Name)
+	}
+
+	resourceClaimList := utils.NewResourceClaimList()
+	if err := kubeClient.List(ctx, resourceClaimList); err != nil {
+		return nil, fmt.Errorf("failed to list ResourceClaims: %v", err)
+	}
+	resourceClaims, err := utils.ToResourceClaims(resourceClaimList)
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range resourceClaims {
+		for _, device := range rc.Status.Devices {
+			key := claimDevice{namespace: rc.Namespace, name: rc.Name, device: device.Device}
+			state.conditions[key] = device.Conditions
+			state.claimDevices = append(state.claimDevices, key)
+		}
+	}
+
+	nodeList, err := clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Nodes: %v", err)
+	}
+	for _, node := range nodeList.Items {
+		state.nodeLabels[node.Name] = node.Labels
+		state.nodeNames = append(state.nodeNames, node.Name)
+	}
+
+	resourceList := &cdioperator.ComposableResourceList{}
+	if err := kubeClient.List(ctx, resourceList); err != nil {
+		return nil, fmt.Errorf("failed to list ComposableResources: %v", err)
+	}
+	for _, resource := range resourceList.Items {
+		state.annotations[resource.Name] = resource.Annotations
+		state.resourceNames = append(state.resourceNames, resource.Name)
+	}
+
+	sort.Strings(state.requestNames)
+	sort.Slice(state.claimDevices, func(i, j int) bool {
+		a, b := state.claimDevices[i], state.claimDevices[j]
+		return a.namespace+"/"+a.name+"/"+a.device < b.namespace+"/"+b.name+"/"+b.device
+	})
+	sort.Strings(state.nodeNames)
+	sort.Strings(state.resourceNames)
+
+	return state, nil
+}
+
+// diff fills the report with the differences between two cluster states.
+func (r *Report) diff(before, after *clusterState) {
+	for _, name := range after.requestNames {
+		cr := after.requests[name]
+		previous, existed := before.requests[name]
+		if existed && previous.Spec.Resource.Size == cr.Spec.Resource.Size {
+			continue
+		}
+		r.ComposabilityRequests = append(r.ComposabilityRequests, ComposabilityRequestChange{
+			Name:       name,
+			Node:       cr.Spec.Resource.TargetNode,
+			Model:      cr.Spec.Resource.Model,
+			SizeBefore: previous.Spec.Resource.Size,
+			SizeAfter:  cr.Spec.Resource.Size,
+			Created:    !existed,
+		})
+	}
+	for _, name := range before.requestNames {
+		if _, exists := after.requests[name]; exists {
+			continue
+		}
+		cr := before.requests[name]
+		r.ComposabilityRequests = append(r.ComposabilityRequests, ComposabilityRequestChange{
+			Name:       name,
+			Node:       cr.Spec.Resource.TargetNode,
+			Model:      cr.Spec.Resource.Model,
+			SizeBefore: cr.Spec.Resource.Size,
+			Deleted:    true,
+		})
+	}
+
+	for _, key := range after.claimDevices {
+		for _, condition := range after.conditions[key] {
+			previous := meta.FindStatusCondition(before.conditions[key], condition.Type)
+			if previous != nil && previous.Status == condition.Status && previous.Reason == condition.Reason && previous.Message == condition.Message {
+				continue
+			}
+			r.ClaimConditions = append(r.ClaimConditions, ClaimConditionChange{
+				Namespace: key.namespace,
+				Name:      key.name,
+				Device:    key.device,
+				Type:      condition.Type,
+				Status:    condition.Status,
+				Reason:    condition.Reason,
+				Message:   condition.Message,
+			})
+		}
+	}
+
+	for _, nodeName := range after.nodeNames {
+		for _, change := range diffMaps(before.nodeLabels[nodeName], after.nodeLabels[nodeName]) {
+			r.NodeLabels = append(r.NodeLabels, LabelChange{Node: nodeName, Label: change.key, Before: change.before, After: change.after})
+		}
+	}
+
+	for _, resourceName := range after.resourceNames {
+		for _, change := range diffMaps(before.annotations[resourceName], after.annotations[resourceName]) {
+			r.ResourceAnnotations = append(r.ResourceAnnotations, AnnotationChange{Resource: resourceName, Key: change.key, Before: change.before, After: change.after})
+		}
+	}
+}
+
+type mapChange struct {
+	key           string
+	before, after *string
+}
+
+func diffMaps(before, after map[string]string) []mapChange {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []mapChange
+	for key := range keys {
+		beforeValue, inBefore := before[key]
+		afterValue, inAfter := after[key]
+		if inBefore == inAfter && beforeValue == afterValue {
+			continue
+		}
+
+		change := mapChange{key: key}
+		if inBefore {
+			change.before = &beforeValue
+		}
+		if inAfter {
+			change.after = &afterValue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key < changes[j].key })
+
+	return changes
+}
+
+// eventLog is an event recorder that keeps the events in memory.
+type eventLog struct {
+	scheme *runtime.Scheme
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) Event(object runtime.Object, eventType, reason, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, Event{
+		Object:  l.describe(object),
+		Type:    eventType,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func (l *eventLog) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	l.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (l *eventLog) AnnotatedEventf(object runtime.Object, _ map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	l.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+// describe returns the kind and name of the object an event is emitted on.
+func (l *eventLog) describe(object runtime.Object) string {
+	if ref, ok := object.(*corev1.ObjectReference); ok {
+		return objectName(ref.Kind, ref.Namespace, ref.Name)
+	}
+
+	kind := fmt.Sprintf("%T", object)
+	if gvk, err := apiutil.GVKForObject(object, l.scheme); err == nil {
+		kind = gvk.Kind
+	}
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return kind
+	}
+
+	return objectName(kind, accessor.GetNamespace(), accessor.GetName())
+}
+
+func objectName(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + "/" + name
+	}
+
+	return kind + "/" + namespace + "/" + name
+}
+
+// WriteText prints the report in a human readable form.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Simulated %d pass(es) with %s\n", r.Passes, r.DRAVersion)
+
+	writeSection(&b, "ComposabilityRequests", len(r.ComposabilityRequests), func() {
+		for _, change := range r.ComposabilityRequests {
+			switch {
+			case change.Created:
+				fmt.Fprintf(&b, "  created %s: node %s, model %s, size %d\n", change.Name, change.Node, change.Model, change.SizeAfter)
+			case change.Deleted:
+				fmt.Fprintf(&b, "  deleted %s: node %s, model %s, size %d\n", change.Name, change.Node, change.Model, change.SizeBefore)
+			default:
+				fmt.Fprintf(&b, "  resized %s: node %s, model %s, size %d -> %d\n", change.Name, change.Node, change.Model, change.SizeBefore, change.SizeAfter)
+			}
+		}
+	})
+
+	writeSection(&b, "ResourceClaim conditions", len(r.ClaimConditions), func() {
+		for _, change := range r.ClaimConditions {
+			fmt.Fprintf(&b, "  %s/%s device %s: %s=%s reason %s", change.Namespace, change.Name, change.Device, change.Type, change.Status, change.Reason)
+			if change.Message != "" {
+				fmt.Fprintf(&b, ": %s", change.Message)
+			}
+			b.WriteString("\n")
+		}
+	})
+
+	writeSection(&b, "Node labels", len(r.NodeLabels), func() {
+		for _, change := range r.NodeLabels {
+			fmt.Fprintf(&b, "  %s: %s %s\n", change.Node, change.Label, formatChange(change.Before, change.After))
+		}
+	})
+
+	writeSection(&b, "ComposableResource annotations", len(r.ResourceAnnotations), func() {
+		for _, change := range r.ResourceAnnotations {
+			fmt.Fprintf(&b, "  %s: %s %s\n", change.Resource, change.Key, formatChange(change.Before, change.After))
+		}
+	})
+
+	writeSection(&b, "Events", len(r.Events), func() {
+		for _, event := range r.Events {
+			fmt.Fprintf(&b, "  %s %s %s: %s\n", event.Type, event.Reason, event.Object, event.Message)
+		}
+	})
+
+	writeSection(&b, "Errors", len(r.Errors), func() {
+		for _, reconcileError := range r.Errors {
+			fmt.Fprintf(&b, "  pass %d, node %s: %s\n", reconcileError.Pass, reconcileError.Node, reconcileError.Error)
+		}
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSection(b *strings.Builder, title string, count int, writeItems func()) {
+	fmt.Fprintf(b, "\n%s:", title)
+	if count == 0 {
+		b.WriteString(" none\n")
+		return
+	}
+	b.WriteString("\n")
+	writeItems()
+}
+
+func formatChange(before, after *string) string {
+	switch {
+	case before == nil:
+		return fmt.Sprintf("added (%q)", *after)
+	case after == nil:
+		return fmt.Sprintf("removed (was %q)", *before)
+	default:
+		return fmt.Sprintf("%q -> %q", *before, *after)
+	}
+}
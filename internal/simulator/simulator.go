@@ -0,0 +1,190 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/controller"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+	corev1 "k8s.io/api/core/v1"
+	resourcev1 "k8s.io/api/resource/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Options are the settings of the simulated controller, as given to the
+// manager by its environment variables.
+type Options struct {
+	// Passes is the number of times every node is reconciled.
+	Passes             int
+	DeviceNoRemoval    time.Duration
+	DeviceNoAllocation time.Duration
+	AttachTimeout      time.Duration
+	ConvergenceTimeout time.Duration
+	ScaleDownCooldown  time.Duration
+}
+
+// fieldIndexer registers the DDS field indexes on the in-memory client, as
+// the manager cache has them.
+type fieldIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (f fieldIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	f.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// Run loads the snapshot objects into an in-memory cluster, reconciles every
+// node of the snapshot the given number of passes and reports the changes
+// made to the cluster. Reconcile errors do not stop the simulation; they are
+// reported.
+func Run(ctx context.Context, scheme *runtime.Scheme, objects []client.Object, options Options) (*Report, error) {
+	if options.Passes < 1 {
+		options.Passes = 1
+	}
+
+	draVersion, err := snapshotDRAVersion(scheme, objects)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.SetDRAVersion(draVersion); err != nil {
+		return nil, err
+	}
+
+	var clientObjects []client.Object
+	var nodes []runtime.Object
+	var nodeNames []string
+	for _, obj := range objects {
+		clientObjects = append(clientObjects, obj.DeepCopyObject().(client.Object))
+		if node, ok := obj.(*corev1.Node); ok {
+			nodes = append(nodes, node.DeepCopy())
+			nodeNames = append(nodeNames, node.Name)
+		}
+	}
+	sort.Strings(nodeNames)
+
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(clientObjects...).
+		WithStatusSubresource(&ddsv1alpha1.DDSConfig{}, &ddsv1alpha1.NodeScalingPolicy{}, &ddsv1alpha1.DeviceQuota{})
+	if err := utils.SetupFieldIndexers(ctx, fieldIndexer{builder: builder}); err != nil {
+		return nil, err
+	}
+	kubeClient := builder.Build()
+	clientSet := k8sfake.NewClientset(nodes...)
+
+	before, err := captureState(ctx, kubeClient, clientSet)
+	if err != nil {
+		return nil, err
+	}
+
+	events := &eventLog{scheme: scheme}
+	reconciler := &controller.ResourceMonitorReconciler{
+		Client:             kubeClient,
+		ClientSet:          clientSet,
+		Scheme:             scheme,
+		Recorder:           events,
+		DeviceNoRemoval:    options.DeviceNoRemoval,
+		DeviceNoAllocation: options.DeviceNoAllocation,
+		AttachTimeout:      options.AttachTimeout,
+		ConvergenceTimeout: options.ConvergenceTimeout,
+		ScaleDownCooldown:  options.ScaleDownCooldown,
+	}
+
+	report := &Report{Passes: options.Passes, DRAVersion: draVersion.String()}
+	for pass := 1; pass <= options.Passes; pass++ {
+		for _, nodeName := range nodeNames {
+			request := ctrl.Request{NamespacedName: k8stypes.NamespacedName{Name: nodeName}}
+			if _, err := reconciler.Reconcile(ctx, request); err != nil {
+				report.Errors = append(report.Errors, ReconcileError{Pass: pass, Node: nodeName, Error: err.Error()})
+			}
+		}
+
+		if err := syncNodeLabels(ctx, kubeClient, clientSet, nodeNames); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := captureState(ctx, kubeClient, clientSet)
+	if err != nil {
+		return nil, err
+	}
+
+	report.diff(before, after)
+	report.Events = events.events
+
+	return report, nil
+}
+
+// snapshotDRAVersion returns the resource.k8s.io version of the
+// ResourceClaims and ResourceSlices of a snapshot. The in-memory client does
+// not convert between versions, so a snapshot must use a single one.
+func snapshotDRAVersion(scheme *runtime.Scheme, objects []client.Object) (schema.GroupVersion, error) {
+	versions := make(map[schema.GroupVersion]bool)
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return schema.GroupVersion{}, fmt.Errorf("failed to get kind of %s: %v", obj.GetName(), err)
+		}
+		if gvk.Group == resourcev1.GroupName && (gvk.Kind == "ResourceClaim" || gvk.Kind == "ResourceSlice") {
+			versions[gvk.GroupVersion()] = true
+		}
+	}
+
+	if len(versions) > 1 {
+		return schema.GroupVersion{}, fmt.Errorf("the snapshot mixes resource.k8s.io versions %v", versions)
+	}
+	for version := range versions {
+		return version, nil
+	}
+
+	return utils.DRAVersion(), nil
+}
+
+// syncNodeLabels copies the node labels patched through the clientset into
+// the client, so that the next pass reads them.
+func syncNodeLabels(ctx context.Context, kubeClient client.Client, clientSet kubernetes.Interface, nodeNames []string) error {
+	for _, nodeName := range nodeNames {
+		patched, err := clientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get Node %s: %v", nodeName, err)
+		}
+
+		node := &corev1.Node{}
+		if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: nodeName}, node); err != nil {
+			return fmt.Errorf("failed to get Node %s: %v", nodeName, err)
+		}
+		node.Labels = patched.Labels
+		if err := kubeClient.Update(ctx, node); err != nil {
+			return fmt.Errorf("failed to update Node %s: %v", nodeName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const testSnapshot = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: composable-dra-dds
+  namespace: composable-dra
+data:
+  label-prefix: composable.fsastech.com
+  fabric-id-range: "[1]"
+  device-info: |
+    - index: 1
+      cdi-model-name: A100 40G
+      dra-attributes:
+        productName: NVIDIA A100 PCIe 40GB
+      driver-name: gpu.nvidia.com
+      k8s-device-name: nvidia-a100-40g
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node1
+    labels:
+      composable.fsastech.com/nvidia-a100-40g-size-min: "2"
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node2
+`
+
+func TestLoadSnapshot(t *testing.T) {
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	objects, err := LoadSnapshot(scheme, strings.NewReader(testSnapshot))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, obj := range objects {
+		names = append(names, obj.GetName())
+	}
+	if strings.Join(names, ",") != "composable-dra-dds,node1,node2" {
+		t.Errorf("objects are incorrect. Got: %v", names)
+	}
+
+	if _, err := LoadSnapshot(scheme, strings.NewReader("apiVersion: v1\nkind: Unknown\n")); err == nil {
+		t.Error("expected error for unknown kind, got nil")
+	}
+}
+
+func TestRun(t *testing.T) {
+	scheme, err := NewScheme()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	objects, err := LoadSnapshot(scheme, strings.NewReader(testSnapshot))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report, err := Run(context.Background(), scheme, objects, Options{Passes: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Errors) != 0 {
+		t.Fatalf("unexpected reconcile errors: %+v", report.Errors)
+	}
+
+	if len(report.ComposabilityRequests) != 1 {
+		t.Fatalf("expected 1 ComposabilityRequest change, got %+v", report.ComposabilityRequests)
+	}
+	change := report.ComposabilityRequests[0]
+	if !change.Created || change.Node != "node1" || change.Model != "A100 40G" || change.SizeAfter != 2 {
+		t.Errorf("ComposabilityRequest change is incorrect. Got: %+v", change)
+	}
+
+	labels := make(map[string]bool)
+	for _, label := range report.NodeLabels {
+		if label.Before == nil && label.After != nil && *label.After == "true" {
+			labels[label.Node+"="+label.Label] = true
+		}
+	}
+	for _, node := range []string{"node1", "node2"} {
+		if !labels[node+"=composable.fsastech.com/nvidia-a100-40g"] {
+			t.Errorf("device label of %s was not added, got %+v", node, report.NodeLabels)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "created ") || !strings.Contains(out.String(), "model A100 40G, size 2") {
+		t.Errorf("text report is incorrect:\n%s", out.String())
+	}
+}
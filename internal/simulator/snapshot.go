@@ -0,0 +1,125 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package simulator runs the DDS reconciler offline against an in-memory
+// cluster loaded from a snapshot, and reports the changes it makes.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewScheme returns the scheme of the objects a snapshot may contain: the
+// built-in types, ComposabilityRequests, ComposableResources, DDSConfigs,
+// NodeScalingPolicies and DeviceQuotas.
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	for _, addToScheme := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		cdioperator.AddToScheme,
+		ddsv1alpha1.AddToScheme,
+	} {
+		if err := addToScheme(scheme); err != nil {
+			return nil, fmt.Errorf("failed to build scheme: %v", err)
+		}
+	}
+
+	return scheme, nil
+}
+
+// LoadSnapshot decodes the objects of a snapshot: YAML documents or JSON
+// objects, such as the output of `kubectl get -o yaml`. Lists are flattened
+// into their items.
+func LoadSnapshot(scheme *runtime.Scheme, r io.Reader) ([]client.Object, error) {
+	deserializer := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	var objects []client.Object
+	for {
+		var raw runtime.RawExtension
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		decoded, err := decodeObjects(deserializer, raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+
+	return objects, nil
+}
+
+func decodeObjects(deserializer runtime.Decoder, data []byte) ([]client.Object, error) {
+	obj, gvk, err := deserializer.Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode object: %v", err)
+	}
+
+	if list, ok := obj.(*corev1.List); ok {
+		var objects []client.Object
+		for _, item := range list.Items {
+			decoded, err := decodeObjects(deserializer, item.Raw)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, decoded...)
+		}
+		return objects, nil
+	}
+
+	if meta.IsListType(obj) {
+		items, err := meta.ExtractList(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", gvk.Kind, err)
+		}
+		var objects []client.Object
+		for _, item := range items {
+			clientObj, ok := item.(client.Object)
+			if !ok {
+				return nil, fmt.Errorf("unsupported item %T in %s", item, gvk.Kind)
+			}
+			objects = append(objects, clientObj)
+		}
+		return objects, nil
+	}
+
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("unsupported object %s", gvk)
+	}
+
+	return []client.Object{clientObj}, nil
+}
@@ -2,16 +2,40 @@ package types
 
 type ComposableDRASpec struct {
 	DeviceInfos   []DeviceInfo `json:"device-info"`
+	Drivers       []DriverInfo `json:"drivers"`
 	LabelPrefix   string       `json:"label-prefix"`
 	FabricIDRange []int        `json:"fabric-id-range"`
+	DryRun        bool         `json:"dry-run"`
+}
+
+// DriverInfo describes how DDS handles the devices of a DRA driver: the CDI
+// resource type of its devices and the attributes holding their device ID and
+// product name.
+type DriverInfo struct {
+	Name                 string `json:"name"`
+	ResourceType         string `json:"resource-type"`
+	UUIDAttribute        string `json:"uuid-attribute"`
+	ProductNameAttribute string `json:"product-name-attribute"`
 }
 
 type DeviceInfo struct {
 	Index             int               `json:"index"`
 	CDIModelName      string            `json:"cdi-model-name"`
 	DRAAttributes     map[string]string `json:"dra-attributes"`
+	Match             []AttributeMatch  `json:"match"`
+	UUIDAttribute     string            `json:"uuid-attribute"`
 	LabelKeyModel     string            `json:"label-key-model"`
 	DriverName        string            `json:"driver-name"`
+	ResourceType      string            `json:"resource-type"`
 	K8sDeviceName     string            `json:"k8s-device-name"`
 	CannotCoexistWith []int             `json:"cannot-coexist-with"`
 }
+
+// AttributeMatch is a rule that a device must satisfy to belong to a model.
+// It checks either an attribute or a capacity of the device.
+type AttributeMatch struct {
+	Attribute string `json:"attribute"`
+	Capacity  string `json:"capacity"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+}
@@ -1,8 +1,25 @@
 package types
 
+import "time"
+
 type NodeInfo struct {
 	Name   string             `json:"name"`
 	Models []ModelConstraints `json:"models"`
+	// Cordoned is set when the node accepts no new pods: it is cordoned,
+	// drained or being deleted.
+	Cordoned bool `json:"cordoned,omitempty"`
+	// NotReady is set when the kubelet of the node is not ready or not
+	// reachable.
+	NotReady bool `json:"not_ready,omitempty"`
+	// Policy is the NodeScalingPolicy selecting the node, if any.
+	Policy string `json:"policy,omitempty"`
+	// AllowedModels are the models that may be attached to the node. Every
+	// model is allowed when empty.
+	AllowedModels []string `json:"allowed_models,omitempty"`
+	// DeviceNoRemoval and DeviceNoAllocation override the durations of the
+	// manager for the node when they are not zero.
+	DeviceNoRemoval    time.Duration `json:"device_no_removal,omitempty"`
+	DeviceNoAllocation time.Duration `json:"device_no_allocation,omitempty"`
 }
 
 type ModelConstraints struct {
@@ -0,0 +1,204 @@
+package types
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// Plan holds the changes decided for one node in a reconcile. It is computed
+// from a snapshot of the node without side effects, and applied afterwards.
+type Plan struct {
+	NodeName string `json:"node_name"`
+	// LabelPrefix is the prefix of the annotations DDS writes on the
+	// ComposabilityRequests it resizes.
+	LabelPrefix string `json:"label_prefix"`
+	// ClaimTransitions are applied in order: a claim may be updated more than
+	// once, the last update wins.
+	ClaimTransitions      []ClaimTransition            `json:"claim_transitions,omitempty"`
+	Annotations           []AnnotationUpdate           `json:"annotations,omitempty"`
+	ComposabilityRequests []ComposabilityRequestChange `json:"composability_requests,omitempty"`
+	// AdoptedRequests are ComposabilityRequests created by an earlier DDS
+	// version, to be labeled with their owner before they are resized.
+	AdoptedRequests []ComposabilityRequestRef `json:"adopted_requests,omitempty"`
+	// DeletedRequests are empty duplicates of the ComposabilityRequest of a
+	// model, deleted after the resizes.
+	DeletedRequests []ComposabilityRequestRef `json:"deleted_requests,omitempty"`
+	// BlockedScaleUps are the scale-ups not done because the node is
+	// cordoned or NotReady.
+	BlockedScaleUps []BlockedScaleUp `json:"blocked_scale_ups,omitempty"`
+	// HeldResizes are the resizes held until the previous resize of the
+	// model settles, or until the scale-down cooldown ends.
+	HeldResizes []HeldResize `json:"held_resizes,omitempty"`
+	// UnconvergedRequests are the ComposabilityRequests whose last resize
+	// did not settle within the convergence timeout; they are resized anyway.
+	UnconvergedRequests []ComposabilityRequestRef `json:"unconverged_requests,omitempty"`
+	// DeferredOperations are the resizes held back, entirely or in part, by
+	// the budgets of fabric operations. They are set after planning.
+	DeferredOperations []DeferredOperation `json:"deferred_operations,omitempty"`
+	NodeLabels         NodeLabelChange     `json:"node_labels"`
+	DeviceCounts       []DeviceCount       `json:"device_counts,omitempty"`
+	IdleDevices        []DeviceIdle        `json:"idle_devices,omitempty"`
+	// QuotaUsage is the usage of every model limited by a DeviceQuota, once
+	// the claims of the plan are admitted, ordered by quota and model.
+	QuotaUsage []DeviceQuotaUsage `json:"quota_usage,omitempty"`
+	// AdmittedClaims are the UIDs of the ResourceClaims of every node whose
+	// devices being prepared fit in the DeviceQuotas.
+	AdmittedClaims []k8stypes.UID `json:"admitted_claims,omitempty"`
+}
+
+// ClaimTransition moves the devices of a ResourceClaim to State and sets the
+// condition of type ConditionType on them.
+type ClaimTransition struct {
+	// Claim is the ResourceClaim as it was before the transition.
+	Claim         ResourceClaimInfo `json:"claim"`
+	State         string            `json:"state"`
+	ConditionType string            `json:"condition_type"`
+	Reason        ConditionReason   `json:"reason"`
+	Message       string            `json:"message"`
+	// Related are the objects that caused the transition, such as the
+	// ComposabilityRequest the claim conflicts with.
+	Related []corev1.ObjectReference `json:"related,omitempty"`
+}
+
+// Transitioned reports whether a device of the claim changes state.
+func (t ClaimTransition) Transitioned() bool {
+	for _, device := range t.Claim.Devices {
+		if device.State != t.State {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AnnotationUpdate sets an annotation of a ComposableResource.
+type AnnotationUpdate struct {
+	ResourceName string `json:"resource_name"`
+	Key          string `json:"key"`
+	Value        string `json:"value"`
+}
+
+// ComposabilityRequestChange creates a ComposabilityRequest, when Name is
+// empty, or resizes an existing one.
+type ComposabilityRequestChange struct {
+	Name         string       `json:"name,omitempty"`
+	UID          k8stypes.UID `json:"uid,omitempty"`
+	ResourceType string       `json:"resource_type"`
+	Model        string       `json:"model"`
+	PreviousSize int64        `json:"previous_size"`
+	Size         int64        `json:"size"`
+	// Reclaim is set when the devices are detached because the node is
+	// cordoned.
+	Reclaim bool `json:"reclaim,omitempty"`
+	// Victims are the ComposableResources chosen to be detached by a
+	// scale-down, the first to go first. They are deleted once the size goes
+	// down.
+	Victims []string `json:"victims,omitempty"`
+	// VictimDevices are the DRA devices of the Victims published in a
+	// ResourceSlice, by ComposableResource name. A victim whose device was
+	// allocated to a claim since the plan is not deleted.
+	VictimDevices map[string]DeviceRef `json:"victim_devices,omitempty"`
+}
+
+// DeviceRef identifies a device published in a ResourceSlice.
+type DeviceRef struct {
+	Driver string `json:"driver"`
+	Pool   string `json:"pool"`
+	Name   string `json:"name"`
+}
+
+// Reasons of a BlockedScaleUp.
+const (
+	BlockedNodeCordoned = "NodeCordoned"
+	BlockedNodeNotReady = "NodeNotReady"
+)
+
+// BlockedScaleUp is a model that needs more devices on a node that does not
+// get them, because it is cordoned or NotReady.
+type BlockedScaleUp struct {
+	Model  string `json:"model"`
+	Size   int64  `json:"size"`
+	Wanted int64  `json:"wanted"`
+	Reason string `json:"reason"`
+}
+
+// Reasons of a HeldResize.
+const (
+	// HoldNotConverged: the previous resize of the model has not settled.
+	HoldNotConverged = "NotConverged"
+	// HoldScaleDownCooldown: the model was scaled up too recently.
+	HoldScaleDownCooldown = "ScaleDownCooldown"
+)
+
+// HeldResize is a resize of a ComposabilityRequest of a model that is not
+// done yet. It is planned again after RetryAfter at the latest.
+type HeldResize struct {
+	Name       string        `json:"name"`
+	UID        k8stypes.UID  `json:"uid,omitempty"`
+	Model      string        `json:"model"`
+	Size       int64         `json:"size"`
+	Wanted     int64         `json:"wanted"`
+	Reason     string        `json:"reason"`
+	Message    string        `json:"message,omitempty"`
+	RetryAfter time.Duration `json:"retry_after"`
+	// Reported is set after planning when the resize was already held for
+	// the same reason, so that the hold is not reported again.
+	Reported bool `json:"reported,omitempty"`
+}
+
+// Scopes and limits of a DeferredOperation.
+const (
+	FabricScopeGlobal = "global"
+	FabricScopeNode   = "node"
+	FabricScopeModel  = "model"
+
+	// FabricLimitRate: the operations per minute of the scope are used up.
+	FabricLimitRate = "Rate"
+	// FabricLimitInFlight: the scope has too many devices being attached or detached.
+	FabricLimitInFlight = "InFlight"
+	// FabricLimitQueued: an operation deferred earlier in the scope goes first.
+	FabricLimitQueued = "Queued"
+)
+
+// DeferredOperation is a resize of a ComposabilityRequest of a model that a
+// budget of fabric operations holds back: the ComposabilityRequest is resized
+// to Size instead of Wanted, which is left for a later reconcile.
+type DeferredOperation struct {
+	Name   string       `json:"name,omitempty"`
+	UID    k8stypes.UID `json:"uid,omitempty"`
+	Model  string       `json:"model"`
+	Size   int64        `json:"size"`
+	Wanted int64        `json:"wanted"`
+	Scope  string       `json:"scope"`
+	Limit  string       `json:"limit"`
+}
+
+// ComposabilityRequestRef refers to a ComposabilityRequest of a model.
+type ComposabilityRequestRef struct {
+	Name  string       `json:"name"`
+	UID   k8stypes.UID `json:"uid,omitempty"`
+	Model string       `json:"model"`
+}
+
+// NodeLabelChange adds and removes the device labels of a node.
+type NodeLabelChange struct {
+	AddLabels    []string `json:"add_labels,omitempty"`
+	DeleteLabels []string `json:"delete_labels,omitempty"`
+}
+
+// DeviceCount is the number of devices of a model that a node needs, and the
+// total size of its ComposabilityRequests.
+type DeviceCount struct {
+	Model      string `json:"model"`
+	Configured int64  `json:"configured"`
+	Actual     int64  `json:"actual"`
+}
+
+// DeviceIdle is how long an attached device has not been used by a pod.
+type DeviceIdle struct {
+	Model        string        `json:"model"`
+	ResourceName string        `json:"resource_name"`
+	Idle         time.Duration `json:"idle"`
+}
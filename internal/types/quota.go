@@ -0,0 +1,24 @@
+package types
+
+import (
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// DeviceQuotaUsage is the number of devices of a model used by the
+// ResourceClaims in the scope of a DeviceQuota.
+type DeviceQuotaUsage struct {
+	Quota string `json:"quota"`
+	Model string `json:"model"`
+	Used  int    `json:"used"`
+}
+
+// QuotaRejection is a ResourceClaim that does not fit in a DeviceQuota: its
+// Requested devices of Model would bring the Used devices over the Limit.
+type QuotaRejection struct {
+	Claim     k8stypes.NamespacedName `json:"claim"`
+	Quota     string                  `json:"quota"`
+	Model     string                  `json:"model"`
+	Requested int                     `json:"requested"`
+	Used      int                     `json:"used"`
+	Limit     int                     `json:"limit"`
+}
@@ -1,18 +1,70 @@
 package types
 
-import v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+import (
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
 
 type ResourceClaimInfo struct {
 	Name              string                `json:"name"`
+	UID               k8stypes.UID          `json:"uid"`
 	NodeName          string                `json:"node_name"`
 	CreationTimestamp v1.Time               `json:"creation_timestamp"`
 	Namespace         string                `json:"namespace"`
 	ResourceSliceName string                `json:"resource_slice_name"`
 	Devices           []ResourceClaimDevice `json:"devices"`
+	// AllocatedAt is when the scheduler allocated the devices of the claim.
+	// It is zero when the allocation time is not recorded.
+	AllocatedAt v1.Time `json:"allocated_at"`
+	// PreparingSince is when DDS first saw devices of the claim being
+	// prepared on its node. It is zero until then.
+	PreparingSince v1.Time `json:"preparing_since"`
+}
+
+// AttachStart returns when the attachment of the devices of the claim
+// started: its allocation, or when DDS first saw them being prepared if the
+// allocation time is not recorded. It is zero when neither is known.
+func (rc ResourceClaimInfo) AttachStart() time.Time {
+	if !rc.AllocatedAt.IsZero() {
+		return rc.AllocatedAt.Time
+	}
+
+	return rc.PreparingSince.Time
 }
 
 type ResourceClaimDevice struct {
 	Name  string `json:"name"`
 	Model string `json:"model"`
 	State string `json:"state"`
+	// ResolveError is set when the device matches no model of the device catalog.
+	ResolveError string `json:"resolve_error,omitempty"`
 }
+
+// ConditionReason is the reason set on the FabricDeviceFailed and
+// FabricDeviceReschedule conditions of the devices of a ResourceClaim.
+type ConditionReason string
+
+const (
+	// ReasonMaxDeviceExceeded: the node would need more devices of a model than its max_device.
+	ReasonMaxDeviceExceeded ConditionReason = "MaxDeviceExceeded"
+	// ReasonIncompatibleModelInClaim: the claim requests models that cannot coexist.
+	ReasonIncompatibleModelInClaim ConditionReason = "IncompatibleModelInClaim"
+	// ReasonIncompatibleModelOnNode: a ComposabilityRequest of the node has a model that cannot coexist with the claim.
+	ReasonIncompatibleModelOnNode ConditionReason = "IncompatibleModelOnNode"
+	// ReasonIncompatibleConcurrentClaim: another claim being prepared on the node has a model that cannot coexist with the claim.
+	ReasonIncompatibleConcurrentClaim ConditionReason = "IncompatibleConcurrentClaim"
+	// ReasonAttachTimeout: the devices of the claim were not attached in time.
+	ReasonAttachTimeout ConditionReason = "AttachTimeout"
+	// ReasonComposableResourceFailed: a ComposableResource of the claim's model reported an error.
+	ReasonComposableResourceFailed ConditionReason = "ComposableResourceFailed"
+	// ReasonUnresolvedDevice: a device of the claim matches no model of the device catalog.
+	ReasonUnresolvedDevice ConditionReason = "UnresolvedDevice"
+	// ReasonModelNotAllowed: the NodeScalingPolicy of the node does not allow the model of the claim.
+	ReasonModelNotAllowed ConditionReason = "ModelNotAllowed"
+	// ReasonQuotaExceeded: the devices of the claim would exceed a DeviceQuota of its namespace or of the cluster.
+	ReasonQuotaExceeded ConditionReason = "QuotaExceeded"
+	// ReasonDeviceReady: the devices of the claim are attached and the pod can be rescheduled.
+	ReasonDeviceReady ConditionReason = "DeviceReady"
+)
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// AttachStartTracker remembers when DDS first saw the devices of the
+// ResourceClaims of every node being prepared. The attach timeout and latency
+// are measured from it for the claims whose allocation time is not recorded,
+// rather than from the creation of a claim that may have waited in the
+// scheduler. The zero value is ready to use.
+type AttachStartTracker struct {
+	mu    sync.Mutex
+	nodes map[string]map[k8stypes.UID]time.Time
+}
+
+// Observe sets the PreparingSince of the ResourceClaims of the snapshot that
+// have devices being prepared on its node: the first time they were observed
+// so, or now for the ones seen for the first time. Claims that are no longer
+// being prepared on the node are forgotten.
+func (t *AttachStartTracker) Observe(snapshot *NodeSnapshot, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.nodes == nil {
+		t.nodes = make(map[string]map[k8stypes.UID]time.Time)
+	}
+
+	previous := t.nodes[snapshot.NodeName]
+	current := make(map[k8stypes.UID]time.Time)
+
+	for i := range snapshot.ResourceClaimInfos {
+		rc := &snapshot.ResourceClaimInfos[i]
+		if rc.NodeName != snapshot.NodeName || !slices.ContainsFunc(rc.Devices, func(device types.ResourceClaimDevice) bool {
+			return device.State == "Preparing"
+		}) {
+			continue
+		}
+
+		since, ok := previous[rc.UID]
+		if !ok {
+			since = now
+		}
+		current[rc.UID] = since
+		rc.PreparingSince = metav1.Time{Time: since}
+	}
+
+	t.nodes[snapshot.NodeName] = current
+}
+
+// Forget drops the claims of a node that no longer exists.
+func (t *AttachStartTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.nodes, nodeName)
+}
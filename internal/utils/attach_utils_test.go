@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestAttachStartTracker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	claim := func(uid k8stypes.UID, nodeName, state string) types.ResourceClaimInfo {
+		return types.ResourceClaimInfo{
+			Name:              string(uid),
+			UID:               uid,
+			NodeName:          nodeName,
+			CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)},
+			Devices:           []types.ResourceClaimDevice{{Name: "gpu0", Model: "A100 40G", State: state}},
+		}
+	}
+	observe := func(tracker *AttachStartTracker, at time.Time, claims ...types.ResourceClaimInfo) []time.Time {
+		snapshot := &NodeSnapshot{NodeName: "node1", ResourceClaimInfos: claims}
+		tracker.Observe(snapshot, at)
+		var since []time.Time
+		for _, rc := range snapshot.ResourceClaimInfos {
+			since = append(since, rc.PreparingSince.Time)
+		}
+		return since
+	}
+
+	var tracker AttachStartTracker
+
+	// A claim created long ago starts its clock when it is first seen.
+	if since := observe(&tracker, now, claim("rc0", "node1", "Preparing")); !since[0].Equal(now) {
+		t.Errorf("first observation is incorrect. Got: %v, Want: %v", since[0], now)
+	}
+
+	// The clock keeps running while the claim is being prepared.
+	since := observe(&tracker, now.Add(time.Minute), claim("rc0", "node1", "Preparing"), claim("rc1", "node1", "Preparing"))
+	if !since[0].Equal(now) || !since[1].Equal(now.Add(time.Minute)) {
+		t.Errorf("second observation is incorrect. Got: %v", since)
+	}
+
+	// Claims that are not prepared on the node are not tracked.
+	since = observe(&tracker, now.Add(2*time.Minute), claim("rc0", "node1", "Reschedule"), claim("rc1", "node2", "Preparing"))
+	if !since[0].IsZero() || !since[1].IsZero() {
+		t.Errorf("untracked claims are incorrect. Got: %v", since)
+	}
+
+	// A claim prepared again starts over.
+	if since := observe(&tracker, now.Add(3*time.Minute), claim("rc0", "node1", "Preparing")); !since[0].Equal(now.Add(3 * time.Minute)) {
+		t.Errorf("claim prepared again is incorrect. Got: %v, Want: %v", since[0], now.Add(3*time.Minute))
+	}
+
+	tracker.Forget("node1")
+	if since := observe(&tracker, now.Add(4*time.Minute), claim("rc0", "node1", "Preparing")); !since[0].Equal(now.Add(4 * time.Minute)) {
+		t.Errorf("forgotten claim is incorrect. Got: %v, Want: %v", since[0], now.Add(4*time.Minute))
+	}
+}
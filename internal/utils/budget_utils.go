@@ -0,0 +1,375 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// fabricRetryInterval is how long an operation deferred because of the
+	// devices in flight, or queued behind another one, waits before it is
+	// tried again.
+	fabricRetryInterval = 10 * time.Second
+	// fabricGrantSettle is how long granted devices are counted as in
+	// flight, until the ComposableResources of the operation show up.
+	fabricGrantSettle = 30 * time.Second
+	// fabricQueueTTL is how long a deferred operation keeps its place in
+	// the queue when its node is not reconciled again.
+	fabricQueueTTL = 5 * time.Minute
+)
+
+// FabricLimit bounds the hot-plug operations of a scope: at most
+// OpsPerMinute devices attached or detached per minute, in bursts of up to
+// Burst devices, and at most MaxInFlight devices being attached or detached
+// at once. Zero disables a limit; a zero Burst allows one device at a time.
+type FabricLimit struct {
+	OpsPerMinute int
+	Burst        int
+	MaxInFlight  int
+}
+
+func (l FabricLimit) enabled() bool {
+	return l.OpsPerMinute > 0 || l.MaxInFlight > 0
+}
+
+// FabricBudgetConfig holds the limits of the whole fabric, of every node and
+// of every model.
+type FabricBudgetConfig struct {
+	Global FabricLimit
+	Node   FabricLimit
+	Model  FabricLimit
+}
+
+// Enabled reports whether any limit is set.
+func (c FabricBudgetConfig) Enabled() bool {
+	return c.Global.enabled() || c.Node.enabled() || c.Model.enabled()
+}
+
+// InFlightLimited reports whether a limit of devices in flight is set.
+func (c FabricBudgetConfig) InFlightLimited() bool {
+	return c.Global.MaxInFlight > 0 || c.Node.MaxInFlight > 0 || c.Model.MaxInFlight > 0
+}
+
+// FabricInFlight is the number of devices being attached or detached in the
+// whole fabric, per node and per model.
+type FabricInFlight struct {
+	Global int
+	Nodes  map[string]int
+	Models map[string]int
+}
+
+// CountFabricInFlight counts the ComposableResources being attached or
+// detached.
+func CountFabricInFlight(resources []cdioperator.ComposableResource) FabricInFlight {
+	inFlight := FabricInFlight{Nodes: make(map[string]int), Models: make(map[string]int)}
+	for _, resource := range resources {
+		if !IsResourceAttaching(resource) && !IsResourceDetaching(resource) {
+			continue
+		}
+		inFlight.Global++
+		inFlight.Nodes[resource.Spec.TargetNode]++
+		inFlight.Models[resource.Spec.Model]++
+	}
+
+	return inFlight
+}
+
+// GetFabricInFlight counts the ComposableResources of every node being
+// attached or detached.
+func GetFabricInFlight(ctx context.Context, kubeClient client.Client) (FabricInFlight, error) {
+	resourceList := &cdioperator.ComposableResourceList{}
+	if err := kubeClient.List(ctx, resourceList); err != nil {
+		return FabricInFlight{}, fmt.Errorf("failed to list ComposableResourceList: %v", err)
+	}
+
+	return CountFabricInFlight(resourceList.Items), nil
+}
+
+// fabricOp identifies the operations on the devices of a model on a node.
+type fabricOp struct {
+	node  string
+	model string
+}
+
+type queuedFabricOp struct {
+	op   fabricOp
+	seen time.Time
+}
+
+type fabricGrant struct {
+	op    fabricOp
+	count int
+	time  time.Time
+}
+
+type fabricScopeKey struct {
+	scope string
+	name  string
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// FabricBudget holds the token buckets of the fabric, the nodes and the
+// models, and the queue of deferred operations, across reconciles. The zero
+// value is ready to use.
+type FabricBudget struct {
+	mu      sync.Mutex
+	buckets map[fabricScopeKey]*tokenBucket
+	// queue holds the deferred operations in the order they were first
+	// deferred. An operation does not start while an operation ahead of it
+	// in a shared scope waits.
+	queue []queuedFabricOp
+	// grants are the devices granted recently, counted as in flight.
+	grants []fabricGrant
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// Admit bounds the ComposabilityRequest changes of a plan by the budgets.
+// Changes are shrunk to the devices granted, or dropped when none are, and
+// recorded in the DeferredOperations of the plan. A shrunk scale-down keeps
+// only its first victims, one per device granted. It returns how long to
+// wait before the deferred operations are tried again, or zero. In dry-run
+// mode the budgets are checked but not used up.
+func (b *FabricBudget) Admit(ctx context.Context, config FabricBudgetConfig, plan *types.Plan, inFlight FabricInFlight) time.Duration {
+	if !config.Enabled() {
+		return 0
+	}
+
+	logger := ctrl.LoggerFrom(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock()
+	b.expire(now)
+
+	var retry time.Duration
+	deferredModels := make(map[string]bool)
+	changes := make([]types.ComposabilityRequestChange, 0, len(plan.ComposabilityRequests))
+	for _, change := range plan.ComposabilityRequests {
+		op := fabricOp{node: plan.NodeName, model: change.Model}
+		count := int(change.Size - change.PreviousSize)
+		sign := int64(1)
+		if count < 0 {
+			count, sign = -count, -1
+		}
+
+		granted, scope, limit, wait := b.grant(config, op, count, inFlight, now)
+		if granted > 0 && !IsDryRun(ctx) {
+			b.take(config, op, granted, now)
+		}
+
+		wanted := change.Size
+		change.Size = change.PreviousSize + sign*int64(granted)
+		change.Victims = change.Victims[:min(len(change.Victims), granted)]
+		if granted > 0 {
+			changes = append(changes, change)
+		}
+		if granted == count {
+			continue
+		}
+
+		logger.Info("Fabric operation deferred", "model", change.Model, "size", change.Size, "wanted", wanted, "scope", scope, "limit", limit)
+		plan.DeferredOperations = append(plan.DeferredOperations, types.DeferredOperation{
+			Name:   change.Name,
+			UID:    change.UID,
+			Model:  change.Model,
+			Size:   change.Size,
+			Wanted: wanted,
+			Scope:  scope,
+			Limit:  limit,
+		})
+		deferredModels[op.model] = true
+		b.enqueue(op, now)
+		if retry == 0 || wait < retry {
+			retry = wait
+		}
+	}
+	plan.ComposabilityRequests = changes
+
+	b.queue = slices.DeleteFunc(b.queue, func(queued queuedFabricOp) bool {
+		return queued.op.node == plan.NodeName && !deferredModels[queued.op.model]
+	})
+
+	return retry
+}
+
+// Queued returns the number of deferred operations waiting in the queue.
+func (b *FabricBudget) Queued() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.queue)
+}
+
+// Forget drops the deferred operations of a node that no longer exists.
+func (b *FabricBudget) Forget(nodeName string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.queue = slices.DeleteFunc(b.queue, func(queued queuedFabricOp) bool {
+		return queued.op.node == nodeName
+	})
+}
+
+// grant returns how many of count devices an operation may change now, and
+// otherwise the scope and limit holding it back and how long to wait.
+func (b *FabricBudget) grant(config FabricBudgetConfig, op fabricOp, count int, inFlight FabricInFlight, now time.Time) (int, string, string, time.Duration) {
+	for _, queued := range b.queue {
+		if queued.op == op {
+			break
+		}
+		if scope := sharedFabricScope(config, queued.op, op); scope != "" {
+			return 0, scope, types.FabricLimitQueued, fabricRetryInterval
+		}
+	}
+
+	granted, scope, limit := count, "", ""
+	var wait time.Duration
+	for _, s := range fabricScopes(config, op) {
+		if s.limit.MaxInFlight > 0 {
+			used := b.pendingInFlight(s.key, now)
+			switch s.key.scope {
+			case types.FabricScopeGlobal:
+				used += inFlight.Global
+			case types.FabricScopeNode:
+				used += inFlight.Nodes[s.key.name]
+			case types.FabricScopeModel:
+				used += inFlight.Models[s.key.name]
+			}
+			if available := max(s.limit.MaxInFlight-used, 0); available < granted {
+				granted, scope, limit, wait = available, s.key.scope, types.FabricLimitInFlight, fabricRetryInterval
+			}
+		}
+
+		if s.limit.OpsPerMinute > 0 {
+			tokens := b.refill(s.key, s.limit, now)
+			if available := int(math.Floor(tokens)); available < granted {
+				perToken := time.Duration(float64(time.Minute) / float64(s.limit.OpsPerMinute))
+				granted, scope, limit = available, s.key.scope, types.FabricLimitRate
+				wait = time.Duration((1 - (tokens - float64(available))) * float64(perToken))
+			}
+		}
+	}
+
+	return granted, scope, limit, wait
+}
+
+// take uses up the tokens of granted devices and counts them as in flight.
+func (b *FabricBudget) take(config FabricBudgetConfig, op fabricOp, granted int, now time.Time) {
+	for _, s := range fabricScopes(config, op) {
+		if s.limit.OpsPerMinute > 0 {
+			b.buckets[s.key].tokens -= float64(granted)
+		}
+	}
+	b.grants = append(b.grants, fabricGrant{op: op, count: granted, time: now})
+}
+
+// refill adds the tokens earned since the last call to the bucket of a
+// scope, up to its burst, and returns the tokens available.
+func (b *FabricBudget) refill(key fabricScopeKey, limit FabricLimit, now time.Time) float64 {
+	if b.buckets == nil {
+		b.buckets = make(map[fabricScopeKey]*tokenBucket)
+	}
+
+	burst := float64(max(limit.Burst, 1))
+	bucket, ok := b.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		b.buckets[key] = bucket
+	}
+	bucket.tokens = min(burst, bucket.tokens+now.Sub(bucket.last).Minutes()*float64(limit.OpsPerMinute))
+	bucket.last = now
+
+	return bucket.tokens
+}
+
+// pendingInFlight returns the devices granted recently in a scope.
+func (b *FabricBudget) pendingInFlight(key fabricScopeKey, now time.Time) int {
+	var pending int
+	for _, grant := range b.grants {
+		if now.Sub(grant.time) > fabricGrantSettle {
+			continue
+		}
+		switch {
+		case key.scope == types.FabricScopeGlobal,
+			key.scope == types.FabricScopeNode && grant.op.node == key.name,
+			key.scope == types.FabricScopeModel && grant.op.model == key.name:
+			pending += grant.count
+		}
+	}
+
+	return pending
+}
+
+// enqueue adds a deferred operation to the queue, or refreshes it.
+func (b *FabricBudget) enqueue(op fabricOp, now time.Time) {
+	for i := range b.queue {
+		if b.queue[i].op == op {
+			b.queue[i].seen = now
+			return
+		}
+	}
+	b.queue = append(b.queue, queuedFabricOp{op: op, seen: now})
+}
+
+// expire drops the settled grants and the deferred operations that were not
+// tried again for fabricQueueTTL.
+func (b *FabricBudget) expire(now time.Time) {
+	b.grants = slices.DeleteFunc(b.grants, func(grant fabricGrant) bool {
+		return now.Sub(grant.time) > fabricGrantSettle
+	})
+	b.queue = slices.DeleteFunc(b.queue, func(queued queuedFabricOp) bool {
+		return now.Sub(queued.seen) > fabricQueueTTL
+	})
+}
+
+func (b *FabricBudget) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+
+	return time.Now()
+}
+
+type fabricScope struct {
+	key   fabricScopeKey
+	limit FabricLimit
+}
+
+// fabricScopes returns the scopes an operation counts against.
+func fabricScopes(config FabricBudgetConfig, op fabricOp) []fabricScope {
+	return []fabricScope{
+		{key: fabricScopeKey{scope: types.FabricScopeGlobal}, limit: config.Global},
+		{key: fabricScopeKey{scope: types.FabricScopeNode, name: op.node}, limit: config.Node},
+		{key: fabricScopeKey{scope: types.FabricScopeModel, name: op.model}, limit: config.Model},
+	}
+}
+
+// sharedFabricScope returns a limited scope two operations count against, or
+// "".
+func sharedFabricScope(config FabricBudgetConfig, a, b fabricOp) string {
+	switch {
+	case config.Global.enabled():
+		return types.FabricScopeGlobal
+	case config.Node.enabled() && a.node == b.node:
+		return types.FabricScopeNode
+	case config.Model.enabled() && a.model == b.model:
+		return types.FabricScopeModel
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func fabricPlan(nodeName string, changes ...types.ComposabilityRequestChange) *types.Plan {
+	return &types.Plan{NodeName: nodeName, ComposabilityRequests: changes}
+}
+
+func fabricChange(name, model string, previousSize, size int64) types.ComposabilityRequestChange {
+	return types.ComposabilityRequestChange{Name: name, Model: model, PreviousSize: previousSize, Size: size}
+}
+
+func withVictims(change types.ComposabilityRequestChange, victims ...string) types.ComposabilityRequestChange {
+	change.Victims = victims
+	return change
+}
+
+func TestFabricBudgetAdmit(t *testing.T) {
+	type step struct {
+		advance          time.Duration
+		plan             *types.Plan
+		inFlight         FabricInFlight
+		expectedChanges  []types.ComposabilityRequestChange
+		expectedDeferred []types.DeferredOperation
+		expectedRetry    time.Duration
+	}
+
+	testcases := []struct {
+		name   string
+		config FabricBudgetConfig
+		dryRun bool
+		steps  []step
+	}{
+		{
+			name: "no limits",
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 8)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 8)},
+				},
+			},
+		},
+		{
+			name:   "rate limits attach and detach",
+			config: FabricBudgetConfig{Global: FabricLimit{OpsPerMinute: 6, Burst: 2}},
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 5)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 2)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 2, Wanted: 5, Scope: types.FabricScopeGlobal, Limit: types.FabricLimitRate},
+					},
+					expectedRetry: 10 * time.Second,
+				},
+				{
+					advance:         15 * time.Second,
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 2, 5)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 2, 3)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 3, Wanted: 5, Scope: types.FabricScopeGlobal, Limit: types.FabricLimitRate},
+					},
+					expectedRetry: 5 * time.Second,
+				},
+				{
+					advance:         time.Minute,
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 3, 0)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 3, 1)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 1, Wanted: 0, Scope: types.FabricScopeGlobal, Limit: types.FabricLimitRate},
+					},
+					expectedRetry: 10 * time.Second,
+				},
+			},
+		},
+		{
+			name:   "only the victims of the granted detaches are kept",
+			config: FabricBudgetConfig{Global: FabricLimit{OpsPerMinute: 6, Burst: 2}},
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", withVictims(fabricChange("cr1", "A100 40G", 4, 0), "res4", "res3", "res2", "res1")),
+					expectedChanges: []types.ComposabilityRequestChange{withVictims(fabricChange("cr1", "A100 40G", 4, 2), "res4", "res3")},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 2, Wanted: 0, Scope: types.FabricScopeGlobal, Limit: types.FabricLimitRate},
+					},
+					expectedRetry: 10 * time.Second,
+				},
+			},
+		},
+		{
+			name:   "in flight limits count recent grants",
+			config: FabricBudgetConfig{Node: FabricLimit{MaxInFlight: 2}},
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 3)),
+					inFlight:        FabricInFlight{Global: 2, Nodes: map[string]int{"node1": 1, "node2": 1}},
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 1)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 1, Wanted: 3, Scope: types.FabricScopeNode, Limit: types.FabricLimitInFlight},
+					},
+					expectedRetry: fabricRetryInterval,
+				},
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 1, 3)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 1, 2)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 2, Wanted: 3, Scope: types.FabricScopeNode, Limit: types.FabricLimitInFlight},
+					},
+					expectedRetry: fabricRetryInterval,
+				},
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 2, 3)),
+					expectedChanges: []types.ComposabilityRequestChange{},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 2, Wanted: 3, Scope: types.FabricScopeNode, Limit: types.FabricLimitInFlight},
+					},
+					expectedRetry: fabricRetryInterval,
+				},
+				{
+					plan:            fabricPlan("node2", fabricChange("cr2", "A100 40G", 0, 1)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr2", "A100 40G", 0, 1)},
+				},
+				{
+					advance:         fabricGrantSettle + time.Second,
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 2, 3)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 2, 3)},
+				},
+			},
+		},
+		{
+			name:   "deferred operations go first",
+			config: FabricBudgetConfig{Model: FabricLimit{OpsPerMinute: 6}},
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 2)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 1)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr1", Model: "A100 40G", Size: 1, Wanted: 2, Scope: types.FabricScopeModel, Limit: types.FabricLimitRate},
+					},
+					expectedRetry: 10 * time.Second,
+				},
+				{
+					advance: 5 * time.Second,
+					plan: fabricPlan("node2",
+						fabricChange("cr2", "A100 40G", 0, 1),
+						fabricChange("cr3", "H100", 0, 1),
+					),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr3", "H100", 0, 1)},
+					expectedDeferred: []types.DeferredOperation{
+						{Name: "cr2", Model: "A100 40G", Size: 0, Wanted: 1, Scope: types.FabricScopeModel, Limit: types.FabricLimitQueued},
+					},
+					expectedRetry: fabricRetryInterval,
+				},
+				{
+					advance:         10 * time.Second,
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 1, 2)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 1, 2)},
+				},
+				{
+					advance:         15 * time.Second,
+					plan:            fabricPlan("node2", fabricChange("cr2", "A100 40G", 0, 1)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr2", "A100 40G", 0, 1)},
+				},
+			},
+		},
+		{
+			name:   "dry run does not use up the budget",
+			config: FabricBudgetConfig{Global: FabricLimit{OpsPerMinute: 1}},
+			dryRun: true,
+			steps: []step{
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 1)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 1)},
+				},
+				{
+					plan:            fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 1)),
+					expectedChanges: []types.ComposabilityRequestChange{fabricChange("cr1", "A100 40G", 0, 1)},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			budget := &FabricBudget{now: func() time.Time { return now }}
+			ctx := WithDryRun(context.Background(), tc.dryRun)
+
+			for i, s := range tc.steps {
+				now = now.Add(s.advance)
+				retry := budget.Admit(ctx, tc.config, s.plan, s.inFlight)
+
+				if !reflect.DeepEqual(s.plan.ComposabilityRequests, s.expectedChanges) {
+					t.Errorf("step %d: unexpected changes. Got: %+v, Want: %+v", i, s.plan.ComposabilityRequests, s.expectedChanges)
+				}
+				if !reflect.DeepEqual(s.plan.DeferredOperations, s.expectedDeferred) {
+					t.Errorf("step %d: unexpected deferred operations. Got: %+v, Want: %+v", i, s.plan.DeferredOperations, s.expectedDeferred)
+				}
+				if retry != s.expectedRetry {
+					t.Errorf("step %d: unexpected retry. Got: %v, Want: %v", i, retry, s.expectedRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestFabricBudgetQueue(t *testing.T) {
+	now := time.Now()
+	budget := &FabricBudget{now: func() time.Time { return now }}
+	config := FabricBudgetConfig{Global: FabricLimit{MaxInFlight: 1}}
+	full := FabricInFlight{Global: 1}
+
+	budget.Admit(context.Background(), config, fabricPlan("node1", fabricChange("cr1", "A100 40G", 0, 1)), full)
+	budget.Admit(context.Background(), config, fabricPlan("node2", fabricChange("cr2", "A100 40G", 0, 1)), full)
+	if queued := budget.Queued(); queued != 2 {
+		t.Fatalf("unexpected queued operations. Got: %d, Want: 2", queued)
+	}
+
+	budget.Forget("node1")
+	if queued := budget.Queued(); queued != 1 {
+		t.Errorf("the operations of a forgotten node must be dropped. Got: %d queued, Want: 1", queued)
+	}
+
+	budget.Admit(context.Background(), config, fabricPlan("node2"), full)
+	if queued := budget.Queued(); queued != 0 {
+		t.Errorf("an operation no longer planned must be dropped. Got: %d queued, Want: 0", queued)
+	}
+
+	budget.Admit(context.Background(), config, fabricPlan("node3", fabricChange("cr3", "A100 40G", 0, 1)), full)
+	now = now.Add(fabricQueueTTL + time.Second)
+	plan := fabricPlan("node4", fabricChange("cr4", "A100 40G", 0, 1))
+	budget.Admit(context.Background(), config, plan, FabricInFlight{})
+	if len(plan.ComposabilityRequests) != 1 {
+		t.Errorf("an expired operation must not hold back the queue. Got deferred: %+v", plan.DeferredOperations)
+	}
+}
+
+func TestCountFabricInFlight(t *testing.T) {
+	resource := func(node, model, state string) cdioperator.ComposableResource {
+		return cdioperator.ComposableResource{
+			Spec:   cdioperator.ComposableResourceSpec{TargetNode: node, Model: model},
+			Status: cdioperator.ComposableResourceStatus{State: state},
+		}
+	}
+	deleted := resource("node2", "H100", ResourceStateOnline)
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	inFlight := CountFabricInFlight([]cdioperator.ComposableResource{
+		resource("node1", "A100 40G", ResourceStateAttaching),
+		resource("node1", "A100 40G", ResourceStateOnline),
+		resource("node1", "H100", ResourceStateDetaching),
+		resource("node2", "A100 40G", ""),
+		deleted,
+	})
+
+	expected := FabricInFlight{
+		Global: 4,
+		Nodes:  map[string]int{"node1": 2, "node2": 2},
+		Models: map[string]int{"A100 40G": 2, "H100": 2},
+	}
+	if !reflect.DeepEqual(inFlight, expected) {
+		t.Errorf("unexpected devices in flight. Got: %+v, Want: %+v", inFlight, expected)
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigStore serves the DDS configuration and remembers the last revision
+// that loaded successfully. The zero value is ready to use.
+type ConfigStore struct {
+	mu       sync.Mutex
+	lastGood *types.ComposableDRASpec
+}
+
+// InvalidConfigError reports a configuration that exists but cannot be parsed
+// or fails validation.
+type InvalidConfigError struct {
+	Err error
+}
+
+func (e *InvalidConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Load reads the current configuration. When the new revision cannot be
+// parsed or fails validation, the last-known-good spec is returned instead
+// so that reconciliation keeps running on the previous device catalog, and
+// dds_config_stale reports it. Other errors are returned: a deleted
+// configuration also forgets the last-known-good spec, so that DDS stops
+// scaling instead of running on a catalog that no longer exists.
+func (s *ConfigStore) Load(ctx context.Context, kubeClient client.Client) (types.ComposableDRASpec, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	composableDRASpec, err := GetComposableDRASpec(ctx, kubeClient)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		var invalidErr *InvalidConfigError
+		switch {
+		case apierrors.IsNotFound(err):
+			if s.lastGood != nil {
+				logger.Info("Configuration not found, forgetting last-known-good spec")
+			}
+			s.lastGood = nil
+			metrics.SetConfigStale(false)
+		case errors.As(err, &invalidErr) && s.lastGood != nil:
+			logger.Error(err, "Invalid configuration, keeping last-known-good spec")
+			metrics.SetConfigStale(true)
+			return *s.lastGood, nil
+		}
+		return composableDRASpec, err
+	}
+	metrics.SetConfigStale(false)
+
+	if s.lastGood != nil {
+		if changes := diffComposableDRASpec(*s.lastGood, composableDRASpec); len(changes) > 0 {
+			logger.Info("Configuration changed", "changes", changes)
+		}
+	}
+	s.lastGood = &composableDRASpec
+
+	return composableDRASpec, nil
+}
+
+// LastGood returns the last configuration that loaded successfully, and false
+// when none has loaded yet.
+func (s *ConfigStore) LastGood() (types.ComposableDRASpec, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastGood == nil {
+		return types.ComposableDRASpec{}, false
+	}
+
+	return *s.lastGood, true
+}
+
+func diffComposableDRASpec(oldSpec, newSpec types.ComposableDRASpec) []string {
+	var changes []string
+
+	if oldSpec.LabelPrefix != newSpec.LabelPrefix {
+		changes = append(changes, fmt.Sprintf("label-prefix: %q -> %q", oldSpec.LabelPrefix, newSpec.LabelPrefix))
+	}
+
+	if !reflect.DeepEqual(oldSpec.FabricIDRange, newSpec.FabricIDRange) {
+		changes = append(changes, fmt.Sprintf("fabric-id-range: %v -> %v", oldSpec.FabricIDRange, newSpec.FabricIDRange))
+	}
+
+	if !reflect.DeepEqual(oldSpec.Drivers, newSpec.Drivers) {
+		changes = append(changes, fmt.Sprintf("drivers: %+v -> %+v", oldSpec.Drivers, newSpec.Drivers))
+	}
+
+	if oldSpec.DryRun != newSpec.DryRun {
+		changes = append(changes, fmt.Sprintf("dry-run: %t -> %t", oldSpec.DryRun, newSpec.DryRun))
+	}
+
+	oldDevices := make(map[string]types.DeviceInfo, len(oldSpec.DeviceInfos))
+	for _, deviceInfo := range oldSpec.DeviceInfos {
+		oldDevices[deviceInfo.CDIModelName] = deviceInfo
+	}
+
+	for _, deviceInfo := range newSpec.DeviceInfos {
+		oldDeviceInfo, exists := oldDevices[deviceInfo.CDIModelName]
+		if !exists {
+			changes = append(changes, fmt.Sprintf("device-info %q added", deviceInfo.CDIModelName))
+			continue
+		}
+		if !reflect.DeepEqual(oldDeviceInfo, deviceInfo) {
+			changes = append(changes, fmt.Sprintf("device-info %q: %+v -> %+v", deviceInfo.CDIModelName, oldDeviceInfo, deviceInfo))
+		}
+		delete(oldDevices, deviceInfo.CDIModelName)
+	}
+
+	for _, deviceInfo := range oldSpec.DeviceInfos {
+		if _, removed := oldDevices[deviceInfo.CDIModelName]; removed {
+			changes = append(changes, fmt.Sprintf("device-info %q removed", deviceInfo.CDIModelName))
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+)
+
+func TestConfigStoreLoad(t *testing.T) {
+	validDeviceInfo := `
+- index: 1
+  cdi-model-name: "A100 40G"
+  driver-name: "gpu.nvidia.com"
+  k8s-device-name: "nvidia-a100-40"
+`
+	validSpec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:         1,
+				CDIModelName:  "A100 40G",
+				DriverName:    "gpu.nvidia.com",
+				K8sDeviceName: "nvidia-a100-40",
+			},
+		},
+		LabelPrefix:   "composable.fsastech.com",
+		FabricIDRange: []int{1},
+	}
+
+	tests := []struct {
+		name           string
+		lastGood       *types.ComposableDRASpec
+		deviceInfo     string
+		deleted        bool
+		wantSpec       types.ComposableDRASpec
+		wantErr        bool
+		expectedErrMsg string
+	}{
+		{
+			name:       "valid revision is returned",
+			deviceInfo: validDeviceInfo,
+			wantSpec:   validSpec,
+		},
+		{
+			name:           "invalid revision without last-known-good",
+			deviceInfo:     "invalid yaml",
+			wantErr:        true,
+			expectedErrMsg: "failed to parse device-info",
+		},
+		{
+			name:       "invalid revision keeps last-known-good",
+			lastGood:   &validSpec,
+			deviceInfo: "invalid yaml",
+			wantSpec:   validSpec,
+		},
+		{
+			name:           "deleted configuration forgets last-known-good",
+			lastGood:       &validSpec,
+			deleted:        true,
+			wantErr:        true,
+			expectedErrMsg: "failed to get ConfigMap",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := scheme.Scheme
+			if err := ddsv1alpha1.AddToScheme(s); err != nil {
+				t.Fatalf("failed to add scheme: %v", err)
+			}
+
+			builder := fake.NewClientBuilder().WithScheme(s)
+			if !tc.deleted {
+				builder = builder.WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      ConfigMapName,
+						Namespace: ConfigMapNamespace,
+					},
+					Data: map[string]string{
+						"device-info":     tc.deviceInfo,
+						"label-prefix":    "composable.fsastech.com",
+						"fabric-id-range": "[1]",
+					},
+				})
+			}
+			fakeClient := builder.Build()
+
+			store := &ConfigStore{lastGood: tc.lastGood}
+			result, err := store.Load(context.Background(), fakeClient)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+					t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+				}
+				if tc.deleted && store.lastGood != nil {
+					t.Errorf("last-known-good not forgotten: %+v", store.lastGood)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.wantSpec) {
+				t.Errorf("got %+v, want %+v", result, tc.wantSpec)
+			}
+			if store.lastGood == nil || !reflect.DeepEqual(*store.lastGood, tc.wantSpec) {
+				t.Errorf("last-known-good not updated: %+v", store.lastGood)
+			}
+		})
+	}
+}
+
+func TestConfigStoreLoadDrivers(t *testing.T) {
+	s := scheme.Scheme
+	if err := ddsv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName,
+			Namespace: ConfigMapNamespace,
+		},
+		Data: map[string]string{
+			"device-info": `
+- index: 1
+  cdi-model-name: "Agilex 7"
+  driver-name: "fpga.example.com"
+  k8s-device-name: "agilex-7"
+`,
+			"drivers": `
+- name: "fpga.example.com"
+  resource-type: "fpga"
+`,
+			"label-prefix":    "composable.fsastech.com",
+			"fabric-id-range": "[1]",
+		},
+	}).Build()
+
+	store := &ConfigStore{}
+	spec, err := store.Load(context.Background(), fakeClient)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := GetResourceType(spec, types.DeviceInfo{DriverName: "fpga.example.com"}); got != "fpga" {
+		t.Errorf("resource type of the configured driver is incorrect. Got: %q, Want: %q", got, "fpga")
+	}
+	if got := GetResourceType(spec, types.DeviceInfo{DriverName: "gpu.nvidia.com"}); got != "gpu" {
+		t.Errorf("resource type of the built-in driver is incorrect. Got: %q, Want: %q", got, "gpu")
+	}
+}
+
+func TestDiffComposableDRASpec(t *testing.T) {
+	oldSpec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{Index: 1, CDIModelName: "A100 40G", K8sDeviceName: "nvidia-a100-40"},
+			{Index: 2, CDIModelName: "H100", K8sDeviceName: "nvidia-h100"},
+		},
+		LabelPrefix:   "composable.fsastech.com",
+		FabricIDRange: []int{1},
+	}
+	newSpec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{Index: 1, CDIModelName: "A100 40G", K8sDeviceName: "nvidia-a100"},
+			{Index: 3, CDIModelName: "L40S", K8sDeviceName: "nvidia-l40s"},
+		},
+		LabelPrefix:   "composable.fsastech.com",
+		FabricIDRange: []int{1, 2},
+	}
+
+	changes := diffComposableDRASpec(oldSpec, newSpec)
+
+	expected := []string{
+		"fabric-id-range: [1] -> [1 2]",
+		`device-info "A100 40G": `,
+		`device-info "L40S" added`,
+		`device-info "H100" removed`,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("got %d changes %q, want %d", len(changes), changes, len(expected))
+	}
+	for i := range expected {
+		if !strings.HasPrefix(changes[i], expected[i]) {
+			t.Errorf("change %d = %q, want prefix %q", i, changes[i], expected[i])
+		}
+	}
+
+	if changes := diffComposableDRASpec(oldSpec, oldSpec); len(changes) != 0 {
+		t.Errorf("expected no changes, got %q", changes)
+	}
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+)
+
+// Directions of a resize, recorded in the ResizeDirectionAnnotation.
+const (
+	ResizeDirectionUp   = "up"
+	ResizeDirectionDown = "down"
+)
+
+// States of a ComposabilityRequest in which the operator is still applying
+// its size.
+const (
+	RequestStateNodeAllocating = "NodeAllocating"
+	RequestStateUpdating       = "Updating"
+)
+
+// ResizedAtAnnotation returns the key of the annotation recording when DDS
+// last resized a ComposabilityRequest.
+func ResizedAtAnnotation(labelPrefix string) string {
+	return labelPrefix + "/resized-at"
+}
+
+// ResizeDirectionAnnotation returns the key of the annotation recording
+// whether the last resize DDS made to a ComposabilityRequest grew or shrank
+// it.
+func ResizeDirectionAnnotation(labelPrefix string) string {
+	return labelPrefix + "/resize-direction"
+}
+
+// resizeAnnotations returns the annotations recording a resize of a
+// ComposabilityRequest from previousSize to size at now.
+func resizeAnnotations(previousSize, size int64, now time.Time, labelPrefix string) map[string]string {
+	direction := ResizeDirectionUp
+	if size < previousSize {
+		direction = ResizeDirectionDown
+	}
+
+	return map[string]string{
+		ResizedAtAnnotation(labelPrefix):       now.UTC().Format(time.RFC3339),
+		ResizeDirectionAnnotation(labelPrefix): direction,
+	}
+}
+
+// GetLastResize returns the time and direction of the last resize DDS made to
+// a ComposabilityRequest. The boolean is false when it was not annotated.
+func GetLastResize(cr cdioperator.ComposabilityRequest, labelPrefix string) (time.Time, string, bool, error) {
+	resizedAtStr, exists := cr.GetAnnotations()[ResizedAtAnnotation(labelPrefix)]
+	if !exists {
+		return time.Time{}, "", false, nil
+	}
+
+	resizedAt, err := time.Parse(time.RFC3339, resizedAtStr)
+	if err != nil {
+		return time.Time{}, "", false, fmt.Errorf("failed to parse time: %v", err)
+	}
+
+	return resizedAt, cr.GetAnnotations()[ResizeDirectionAnnotation(labelPrefix)], true, nil
+}
+
+// GetConvergenceState returns why the last size of a ComposabilityRequest has
+// not settled yet, or "" when it has. The operator copies the spec it has
+// taken in to status.scalarResource, which plays the part of an observed
+// generation; the size settles once the operator has taken it in, is no
+// longer allocating or updating, and no ComposableResource of the model on
+// the node is being attached or detached.
+func GetConvergenceState(cr cdioperator.ComposabilityRequest, resources []cdioperator.ComposableResource) string {
+	if cr.Status.ScalarResource.Size != cr.Spec.Resource.Size {
+		return fmt.Sprintf("size %d not observed yet, operator reports %d", cr.Spec.Resource.Size, cr.Status.ScalarResource.Size)
+	}
+
+	switch cr.Status.State {
+	case RequestStateNodeAllocating, RequestStateUpdating:
+		return fmt.Sprintf("ComposabilityRequest is %s", cr.Status.State)
+	}
+
+	var inFlight int
+	for _, resource := range resources {
+		if resource.Spec.Model != cr.Spec.Resource.Model || resource.Spec.TargetNode != cr.Spec.Resource.TargetNode {
+			continue
+		}
+		if IsResourceAttaching(resource) || IsResourceDetaching(resource) {
+			inFlight++
+		}
+	}
+	if inFlight > 0 {
+		return fmt.Sprintf("%d ComposableResources are attaching or detaching", inFlight)
+	}
+
+	return ""
+}
+
+// ResizeHoldTracker remembers the reason the resize of every model of every
+// node is held for, so that a hold is reported when it starts or its reason
+// changes rather than on every reconcile. The zero value is ready to use.
+type ResizeHoldTracker struct {
+	mu    sync.Mutex
+	nodes map[string]map[string]string
+}
+
+// Observe records the resizes held by the plan of a node and marks as
+// Reported the ones held for the same reason at the last call. Holds that
+// ended are forgotten.
+func (t *ResizeHoldTracker) Observe(plan *types.Plan) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.nodes == nil {
+		t.nodes = make(map[string]map[string]string)
+	}
+
+	previous := t.nodes[plan.NodeName]
+	current := make(map[string]string, len(plan.HeldResizes))
+
+	for i := range plan.HeldResizes {
+		held := &plan.HeldResizes[i]
+		if reason, ok := previous[held.Model]; ok && reason == held.Reason {
+			held.Reported = true
+		}
+		current[held.Model] = held.Reason
+	}
+
+	t.nodes[plan.NodeName] = current
+}
+
+// Forget drops the holds of a node that no longer exists.
+func (t *ResizeHoldTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.nodes, nodeName)
+}
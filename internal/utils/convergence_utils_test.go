@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetConvergenceState(t *testing.T) {
+	request := func(size, observed int64, state string) cdioperator.ComposabilityRequest {
+		return cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{Model: "A100 40G", TargetNode: "node1", Size: size},
+			},
+			Status: cdioperator.ComposabilityRequestStatus{
+				State:          state,
+				ScalarResource: cdioperator.ScalarResourceDetails{Model: "A100 40G", TargetNode: "node1", Size: observed},
+			},
+		}
+	}
+	resource := func(node, model, state string) cdioperator.ComposableResource {
+		return cdioperator.ComposableResource{
+			Spec:   cdioperator.ComposableResourceSpec{TargetNode: node, Model: model},
+			Status: cdioperator.ComposableResourceStatus{State: state},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		request   cdioperator.ComposabilityRequest
+		resources []cdioperator.ComposableResource
+		expected  string
+	}{
+		{
+			name:     "settled",
+			request:  request(2, 2, "Running"),
+			expected: "",
+		},
+		{
+			name:     "size not observed",
+			request:  request(3, 2, "Running"),
+			expected: "size 3 not observed yet, operator reports 2",
+		},
+		{
+			name:     "operator updating",
+			request:  request(3, 3, RequestStateUpdating),
+			expected: "ComposabilityRequest is Updating",
+		},
+		{
+			name:    "devices in flight",
+			request: request(2, 2, "Running"),
+			resources: []cdioperator.ComposableResource{
+				resource("node1", "A100 40G", ResourceStateOnline),
+				resource("node1", "A100 40G", ResourceStateAttaching),
+				resource("node1", "A100 40G", ResourceStateDetaching),
+			},
+			expected: "2 ComposableResources are attaching or detaching",
+		},
+		{
+			name:    "devices of other models and nodes",
+			request: request(2, 2, "Running"),
+			resources: []cdioperator.ComposableResource{
+				resource("node1", "H100", ResourceStateAttaching),
+				resource("node2", "A100 40G", ResourceStateAttaching),
+			},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetConvergenceState(tc.request, tc.resources); got != tc.expected {
+				t.Errorf("convergence state is incorrect. Got: %q, Want: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestGetLastResize(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cr := cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Annotations: resizeAnnotations(4, 2, now, "composable.test")}}
+	resizedAt, direction, exists, err := GetLastResize(cr, "composable.test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists || !resizedAt.Equal(now) || direction != ResizeDirectionDown {
+		t.Errorf("last resize is incorrect. Got: %v %q %v, Want: %v %q true", resizedAt, direction, exists, now, ResizeDirectionDown)
+	}
+
+	if _, _, exists, err := GetLastResize(cdioperator.ComposabilityRequest{}, "composable.test"); exists || err != nil {
+		t.Errorf("a ComposabilityRequest without annotations has no last resize. Got: %v, %v", exists, err)
+	}
+
+	if _, _, exists, err := GetLastResize(cr, "other.test"); exists || err != nil {
+		t.Errorf("annotations of another prefix are not read. Got: %v, %v", exists, err)
+	}
+
+	cr.Annotations[ResizedAtAnnotation("composable.test")] = "yesterday"
+	if _, _, _, err := GetLastResize(cr, "composable.test"); err == nil {
+		t.Errorf("expected an error for an invalid time")
+	}
+}
+
+func TestResizeHoldTracker(t *testing.T) {
+	observe := func(tracker *ResizeHoldTracker, nodeName string, held ...types.HeldResize) []bool {
+		plan := &types.Plan{NodeName: nodeName, HeldResizes: held}
+		tracker.Observe(plan)
+		var reported []bool
+		for _, h := range plan.HeldResizes {
+			reported = append(reported, h.Reported)
+		}
+		return reported
+	}
+	notConverged := types.HeldResize{Name: "test", Model: "A100 40G", Reason: types.HoldNotConverged}
+	cooldown := types.HeldResize{Name: "test", Model: "A100 40G", Reason: types.HoldScaleDownCooldown}
+	other := types.HeldResize{Name: "other", Model: "H100", Reason: types.HoldNotConverged}
+
+	var tracker ResizeHoldTracker
+
+	if reported := observe(&tracker, "node1", notConverged); reported[0] {
+		t.Errorf("a new hold is reported")
+	}
+	if reported := observe(&tracker, "node1", notConverged, other); !reported[0] || reported[1] {
+		t.Errorf("only the hold of the same reason is reported. Got: %v", reported)
+	}
+	if reported := observe(&tracker, "node2", notConverged); reported[0] {
+		t.Errorf("holds of another node are reported")
+	}
+	if reported := observe(&tracker, "node1", cooldown, other); reported[0] || !reported[1] {
+		t.Errorf("only the hold of the same reason is reported after a change. Got: %v", reported)
+	}
+
+	// A hold that ended is reported again when it starts over.
+	observe(&tracker, "node1")
+	if reported := observe(&tracker, "node1", cooldown); reported[0] {
+		t.Errorf("a hold that started over is reported")
+	}
+
+	tracker.Forget("node1")
+	if reported := observe(&tracker, "node1", cooldown); reported[0] {
+		t.Errorf("a hold of a forgotten node is reported")
+	}
+}
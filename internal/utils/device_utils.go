@@ -7,28 +7,30 @@ import (
 
 	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
-	resourceapi "k8s.io/api/resource/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func GetConfiguredDeviceCount(ctx context.Context, kubeClient client.Client, model, nodeName string, resourceClaimInfos []types.ResourceClaimInfo, resourceSliceInfos []types.ResourceSliceInfo) (int64, error) {
+// GetConfiguredDeviceCount returns the number of devices of a model that the
+// node of the snapshot needs: devices being prepared or rescheduled for
+// claims, and attached devices still used by a pod.
+func GetConfiguredDeviceCount(ctx context.Context, snapshot *NodeSnapshot, model string, resourceClaimInfos []types.ResourceClaimInfo) int64 {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.V(1).Info("Start getting configured device count")
 
-	preparingDeviceCount := getPreparingDevicesCount(resourceClaimInfos, model, nodeName)
+	preparingDeviceCount := getPreparingDevicesCount(resourceClaimInfos, model, snapshot.NodeName)
 
-	podAllocatedDevicesCount, err := getPodAllocatedDevicesCount(ctx, kubeClient, model, nodeName, resourceSliceInfos)
-	if err != nil {
-		return 0, err
-	}
+	podAllocatedDevicesCount := getPodAllocatedDevicesCount(snapshot, model)
 
-	rescheduleDeviceCount := getRescheduleDevicesCount(resourceClaimInfos, model, nodeName)
+	rescheduleDeviceCount := getRescheduleDevicesCount(resourceClaimInfos, model, snapshot.NodeName)
 
 	logger.V(1).Info("Finish getting configured device count", "preparingDeviceCount", preparingDeviceCount, "podAllocatedDevicesCount", podAllocatedDevicesCount, "rescheduleDeviceCount", rescheduleDeviceCount)
 
-	return preparingDeviceCount + podAllocatedDevicesCount + rescheduleDeviceCount, nil
+	return preparingDeviceCount + podAllocatedDevicesCount + rescheduleDeviceCount
 }
 
 func getPreparingDevicesCount(resourceClaimInfos []types.ResourceClaimInfo, model, nodeName string) int64 {
@@ -48,35 +50,21 @@ func getPreparingDevicesCount(resourceClaimInfos []types.ResourceClaimInfo, mode
 	return count
 }
 
-func getPodAllocatedDevicesCount(ctx context.Context, kubeClient client.Client, model, nodeName string, resourceSliceInfos []types.ResourceSliceInfo) (int64, error) {
+func getPodAllocatedDevicesCount(snapshot *NodeSnapshot, model string) int64 {
 	var count int64
 
-	composableResourceList := &cdioperator.ComposableResourceList{}
-	if err := kubeClient.List(ctx, composableResourceList, &client.ListOptions{}); err != nil {
-		return count, fmt.Errorf("failed to list composableResourceList: %v", err)
-	}
-
-	for _, resource := range composableResourceList.Items {
-		if resource.Spec.TargetNode != nodeName {
-			continue
-		}
+	for _, resource := range snapshot.ComposableResources {
 		if resource.Spec.Model == model {
-			if resource.Status.State == "Online" {
-				isRed, resourceSliceInfo, deviceName := IsDeviceResourceSliceRed(resource.Status.DeviceID, resourceSliceInfos)
-				if isRed {
-					isUsed, err := IsDeviceUsedByPod(ctx, kubeClient, deviceName, *resourceSliceInfo)
-					if err != nil {
-						return count, err
-					}
-					if isUsed {
-						count++
-					}
+			if IsResourceOnline(resource) {
+				isRed, resourceSliceInfo, deviceName := IsDeviceResourceSliceRed(resource.Status.DeviceID, snapshot.ResourceSliceInfos)
+				if isRed && snapshot.IsDeviceUsedByPod(deviceName, *resourceSliceInfo) {
+					count++
 				}
 			}
 		}
 	}
 
-	return count, nil
+	return count
 }
 
 func getRescheduleDevicesCount(resourceClaimInfos []types.ResourceClaimInfo, model, nodeName string) int64 {
@@ -96,25 +84,17 @@ func getRescheduleDevicesCount(resourceClaimInfos []types.ResourceClaimInfo, mod
 	return count
 }
 
+// IsDeviceUsedByPod reports whether a device of a ResourceSlice is allocated
+// to any ResourceClaim. It is an indexed lookup, see ResourceClaimDeviceIndex.
 func IsDeviceUsedByPod(ctx context.Context, kubeClient client.Client, deviceName string, resourceSliceInfo types.ResourceSliceInfo) (bool, error) {
-	resourceClaimList := &resourceapi.ResourceClaimList{}
-	if err := kubeClient.List(ctx, resourceClaimList, &client.ListOptions{}); err != nil {
+	resourceClaimList := NewResourceClaimList()
+	if err := kubeClient.List(ctx, resourceClaimList, client.MatchingFields{
+		ResourceClaimDeviceIndex: DeviceKey(resourceSliceInfo.Driver, resourceSliceInfo.Pool, deviceName),
+	}); err != nil {
 		return false, fmt.Errorf("failed to list ResourceClaims: %v", err)
 	}
 
-	for _, resourceClaim := range resourceClaimList.Items {
-		if resourceClaim.Status.Allocation != nil {
-			for _, resourceClaimDevice := range resourceClaim.Status.Allocation.Devices.Results {
-				if resourceSliceInfo.Pool == resourceClaimDevice.Pool &&
-					resourceSliceInfo.Driver == resourceClaimDevice.Driver &&
-					deviceName == resourceClaimDevice.Device {
-					return true, nil
-				}
-			}
-		}
-	}
-
-	return false, nil
+	return meta.LenList(resourceClaimList) > 0, nil
 }
 
 func IsDeviceResourceSliceRed(deviceID string, resourceSliceInfos []types.ResourceSliceInfo) (bool, *types.ResourceSliceInfo, string) {
@@ -129,18 +109,11 @@ func IsDeviceResourceSliceRed(deviceID string, resourceSliceInfos []types.Resour
 	return false, nil, ""
 }
 
-func DynamicAttach(ctx context.Context, kubeClient client.Client, cr *cdioperator.ComposabilityRequest, count int64, resourceType, model, nodeName string) error {
-	logger := ctrl.LoggerFrom(ctx)
-	logger.Info("Start dynamic attach")
-
-	if cr == nil {
-		return createNewComposabilityRequestCR(ctx, kubeClient, count, resourceType, model, nodeName)
-	}
-
-	return PatchComposabilityRequestSize(ctx, kubeClient, cr.Name, count)
-}
-
-func createNewComposabilityRequestCR(ctx context.Context, kubeClient client.Client, count int64, resourceType, model, node string) error {
+// createNewComposabilityRequestCR creates the ComposabilityRequest of a model
+// on a node, named with ComposabilityRequestName and stamped with the owner
+// labels. If it already exists, for example because DDS restarted before it
+// listed it, the existing one is resized instead.
+func createNewComposabilityRequestCR(ctx context.Context, kubeClient client.Client, count int64, resourceType, model, node, labelPrefix string) (*cdioperator.ComposabilityRequest, error) {
 	logger := ctrl.LoggerFrom(ctx)
 
 	logger.Info("Create new ComposabilityRequestCR",
@@ -149,7 +122,9 @@ func createNewComposabilityRequestCR(ctx context.Context, kubeClient client.Clie
 
 	newCR := &cdioperator.ComposabilityRequest{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: "composability-",
+			Name:        ComposabilityRequestName(node, model),
+			Labels:      OwnerLabels(labelPrefix, node, model),
+			Annotations: resizeAnnotations(0, count, time.Now(), labelPrefix),
 		},
 		Spec: cdioperator.ComposabilityRequestSpec{
 			Resource: cdioperator.ScalarResourceDetails{
@@ -161,62 +136,102 @@ func createNewComposabilityRequestCR(ctx context.Context, kubeClient client.Clie
 		},
 	}
 
-	if err := kubeClient.Create(ctx, newCR); err != nil {
-		return fmt.Errorf("failed to create ComposabilityRequest: %v", err)
+	if skipInDryRun(ctx, MutationCreateComposabilityRequest, "name", newCR.Name, "model", model, "node", node, "size", count) {
+		return nil, nil
 	}
 
-	return nil
-}
-
-func DynamicDetach(ctx context.Context, kubeClient client.Client, cr *cdioperator.ComposabilityRequest, count int64, nodeName, labelPrefix string, deviceNoRemoval time.Duration) error {
-	logger := ctrl.LoggerFrom(ctx)
-	logger.Info("Start dynamic detach")
+	err := kubeClient.Create(ctx, newCR)
+	if err == nil {
+		recordSelfWrite(ctx, newCR)
+		return newCR, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to create ComposabilityRequest: %v", err)
+	}
 
-	nextSize, err := getNextSize(ctx, kubeClient, count, nodeName, labelPrefix, deviceNoRemoval)
-	if err != nil {
-		return fmt.Errorf("failed to get next size: %v", err)
+	existingCR := &cdioperator.ComposabilityRequest{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: newCR.Name}, existingCR); err != nil {
+		return nil, fmt.Errorf("failed to get ComposabilityRequest: %v", err)
+	}
+	if !IsOwnedComposabilityRequest(*existingCR, labelPrefix) || existingCR.Spec.Resource.TargetNode != node || existingCR.Spec.Resource.Model != model {
+		return nil, fmt.Errorf("ComposabilityRequest %s already exists and is not owned for model %s on node %s", newCR.Name, model, node)
 	}
 
-	if nextSize < cr.Spec.Resource.Size {
-		return PatchComposabilityRequestSize(ctx, kubeClient, cr.Name, nextSize)
+	logger.Info("ComposabilityRequest already exists", "name", existingCR.Name, "size", existingCR.Spec.Resource.Size)
+	if existingCR.Spec.Resource.Size != count {
+		if err := PatchComposabilityRequestSize(ctx, kubeClient, existingCR.Name, count, labelPrefix); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return existingCR, nil
 }
 
-func getNextSize(ctx context.Context, kubeClient client.Client, count int64, nodeName, labelPrefix string, deviceNoRemoval time.Duration) (int64, error) {
-	resourceList := &cdioperator.ComposableResourceList{}
-	if err := kubeClient.List(ctx, resourceList, &client.ListOptions{}); err != nil {
-		return 0, fmt.Errorf("failed to list ComposableResourceList: %v", err)
+// deleteComposableResource deletes a ComposableResource chosen to be detached
+// from a node. A ComposableResource that is already gone or being deleted,
+// that no longer holds a device of the model on the node, or whose device is
+// now allocated to a ResourceClaim, is left alone. device is the device of
+// the ComposableResource in its ResourceSlice, if it was published.
+func deleteComposableResource(ctx context.Context, kubeClient client.Client, name, node, model string, device types.DeviceRef) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	resource := &cdioperator.ComposableResource{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: name}, resource); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get ComposableResource: %v", err)
+	}
+	if resource.DeletionTimestamp != nil || resource.Spec.TargetNode != node || resource.Spec.Model != model {
+		return nil
+	}
+	if device.Name != "" {
+		isUsed, err := IsDeviceUsedByPod(ctx, kubeClient, device.Name, types.ResourceSliceInfo{Driver: device.Driver, Pool: device.Pool})
+		if err != nil {
+			return err
+		}
+		if isUsed {
+			logger.Info("Keeping ComposableResource allocated since the plan", "name", name, "device", device.Name)
+			return nil
+		}
 	}
 
-	var resourceCount int64
-	for _, resource := range resourceList.Items {
-		if (resource.Status.State == "Online" || resource.Status.State == "Attaching") &&
-			resource.Spec.TargetNode == nodeName && resource.DeletionTimestamp == nil {
-			over, err := isLastUsedOverTime(resource, labelPrefix, deviceNoRemoval)
-			if err != nil {
-				return 0, err
-			}
+	logger.Info("Delete ComposableResource", "name", name)
 
-			if !over {
-				resourceCount++
-			}
-		}
+	if skipInDryRun(ctx, MutationDeleteComposableResource, "name", name, "node", node, "model", model) {
+		return nil
 	}
 
-	if count > resourceCount {
-		return count, nil
+	// The UID precondition keeps a ComposableResource recreated under the
+	// same name from being deleted.
+	if err := kubeClient.Delete(ctx, resource, client.Preconditions{UID: &resource.UID}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ComposableResource: %v", err)
 	}
 
-	return resourceCount, nil
+	return nil
 }
 
-func GetDriverType(model string) string {
-	switch model {
-	case "gpu.nvidia.com":
-		return "gpu"
-	default:
-		return ""
+// deleteComposabilityRequest deletes a ComposabilityRequest. A
+// ComposabilityRequest that is already gone is not an error.
+func deleteComposabilityRequest(ctx context.Context, kubeClient client.Client, name string, uid k8stypes.UID) error {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.Info("Delete ComposabilityRequest", "name", name)
+
+	if skipInDryRun(ctx, MutationDeleteComposabilityRequest, "name", name) {
+		return nil
 	}
+
+	// The UID precondition keeps a ComposabilityRequest recreated under the
+	// same name from being deleted.
+	var opts []client.DeleteOption
+	if uid != "" {
+		opts = append(opts, client.Preconditions{UID: &uid})
+	}
+
+	cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := kubeClient.Delete(ctx, cr, opts...); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete ComposabilityRequest: %v", err)
+	}
+
+	return nil
 }
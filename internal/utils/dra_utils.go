@@ -0,0 +1,399 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	resourcev1 "k8s.io/api/resource/v1"
+	resourcev1beta1 "k8s.io/api/resource/v1beta1"
+	resourcev1beta2 "k8s.io/api/resource/v1beta2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DRAVersions are the resource.k8s.io versions supported by DDS, newest
+// first. DDS works on resource.k8s.io/v1 internally; objects of the other
+// versions are converted when they are read, see ToResourceClaim and
+// ToResourceSlice.
+var DRAVersions = []schema.GroupVersion{
+	resourcev1.SchemeGroupVersion,
+	resourcev1beta2.SchemeGroupVersion,
+	resourcev1beta1.SchemeGroupVersion,
+}
+
+var (
+	draVersionMu sync.RWMutex
+	draVersion   = resourcev1beta1.SchemeGroupVersion
+)
+
+// DiscoverDRAVersion returns the newest resource.k8s.io version in which the
+// API server serves both ResourceClaims and ResourceSlices.
+func DiscoverDRAVersion(discoveryClient discovery.DiscoveryInterface) (schema.GroupVersion, error) {
+	for _, gv := range DRAVersions {
+		resourceList, err := discoveryClient.ServerResourcesForGroupVersion(gv.String())
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return schema.GroupVersion{}, fmt.Errorf("failed to discover %s: %v", gv, err)
+		}
+
+		if servesResources(resourceList, "resourceclaims", "resourceslices") {
+			return gv, nil
+		}
+	}
+
+	return schema.GroupVersion{}, fmt.Errorf("the API server serves none of the supported versions %v", DRAVersions)
+}
+
+func servesResources(resourceList *metav1.APIResourceList, names ...string) bool {
+	served := make(map[string]bool, len(resourceList.APIResources))
+	for _, apiResource := range resourceList.APIResources {
+		served[apiResource.Name] = true
+	}
+
+	for _, name := range names {
+		if !served[name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetDRAVersion selects the resource.k8s.io version used to read and write
+// ResourceClaims and ResourceSlices. It is called once at startup, before
+// the manager starts; the default is v1beta1.
+func SetDRAVersion(gv schema.GroupVersion) error {
+	supported := false
+	for _, version := range DRAVersions {
+		if version == gv {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("unsupported version %s, supported versions are %v", gv, DRAVersions)
+	}
+
+	draVersionMu.Lock()
+	defer draVersionMu.Unlock()
+
+	draVersion = gv
+
+	return nil
+}
+
+// DRAVersion returns the resource.k8s.io version selected with SetDRAVersion.
+func DRAVersion() schema.GroupVersion {
+	draVersionMu.RLock()
+	defer draVersionMu.RUnlock()
+
+	return draVersion
+}
+
+// NewResourceClaim returns an empty ResourceClaim of the selected version.
+func NewResourceClaim() client.Object {
+	switch DRAVersion() {
+	case resourcev1.SchemeGroupVersion:
+		return &resourcev1.ResourceClaim{}
+	case resourcev1beta2.SchemeGroupVersion:
+		return &resourcev1beta2.ResourceClaim{}
+	default:
+		return &resourcev1beta1.ResourceClaim{}
+	}
+}
+
+// NewResourceClaimList returns an empty ResourceClaimList of the selected version.
+func NewResourceClaimList() client.ObjectList {
+	switch DRAVersion() {
+	case resourcev1.SchemeGroupVersion:
+		return &resourcev1.ResourceClaimList{}
+	case resourcev1beta2.SchemeGroupVersion:
+		return &resourcev1beta2.ResourceClaimList{}
+	default:
+		return &resourcev1beta1.ResourceClaimList{}
+	}
+}
+
+// NewResourceSlice returns an empty ResourceSlice of the selected version.
+func NewResourceSlice() client.Object {
+	switch DRAVersion() {
+	case resourcev1.SchemeGroupVersion:
+		return &resourcev1.ResourceSlice{}
+	case resourcev1beta2.SchemeGroupVersion:
+		return &resourcev1beta2.ResourceSlice{}
+	default:
+		return &resourcev1beta1.ResourceSlice{}
+	}
+}
+
+// NewResourceSliceList returns an empty ResourceSliceList of the selected version.
+func NewResourceSliceList() client.ObjectList {
+	switch DRAVersion() {
+	case resourcev1.SchemeGroupVersion:
+		return &resourcev1.ResourceSliceList{}
+	case resourcev1beta2.SchemeGroupVersion:
+		return &resourcev1beta2.ResourceSliceList{}
+	default:
+		return &resourcev1beta1.ResourceSliceList{}
+	}
+}
+
+// ToResourceClaim converts a ResourceClaim of any supported version to v1.
+// Only the metadata and the fields of the status DDS reads are converted:
+// the reservations, the allocated devices with their binding conditions, the
+// node selector and time of the allocation, and the conditions of the
+// devices. The spec is not converted.
+func ToResourceClaim(obj runtime.Object) (*resourcev1.ResourceClaim, error) {
+	switch rc := obj.(type) {
+	case *resourcev1.ResourceClaim:
+		return rc, nil
+	case *resourcev1beta2.ResourceClaim:
+		converted := &resourcev1.ResourceClaim{ObjectMeta: rc.ObjectMeta}
+		for _, ref := range rc.Status.ReservedFor {
+			converted.Status.ReservedFor = append(converted.Status.ReservedFor, resourcev1.ResourceClaimConsumerReference{
+				APIGroup: ref.APIGroup,
+				Resource: ref.Resource,
+				Name:     ref.Name,
+				UID:      ref.UID,
+			})
+		}
+		if allocation := rc.Status.Allocation; allocation != nil {
+			converted.Status.Allocation = &resourcev1.AllocationResult{
+				NodeSelector:        allocation.NodeSelector,
+				AllocationTimestamp: allocation.AllocationTimestamp,
+			}
+			for _, result := range allocation.Devices.Results {
+				converted.Status.Allocation.Devices.Results = append(converted.Status.Allocation.Devices.Results, resourcev1.DeviceRequestAllocationResult{
+					Request:                  result.Request,
+					Driver:                   result.Driver,
+					Pool:                     result.Pool,
+					Device:                   result.Device,
+					BindingConditions:        result.BindingConditions,
+					BindingFailureConditions: result.BindingFailureConditions,
+				})
+			}
+		}
+		for _, device := range rc.Status.Devices {
+			converted.Status.Devices = append(converted.Status.Devices, resourcev1.AllocatedDeviceStatus{
+				Driver:     device.Driver,
+				Pool:       device.Pool,
+				Device:     device.Device,
+				Conditions: device.Conditions,
+			})
+		}
+		return converted, nil
+	case *resourcev1beta1.ResourceClaim:
+		converted := &resourcev1.ResourceClaim{ObjectMeta: rc.ObjectMeta}
+		for _, ref := range rc.Status.ReservedFor {
+			converted.Status.ReservedFor = append(converted.Status.ReservedFor, resourcev1.ResourceClaimConsumerReference{
+				APIGroup: ref.APIGroup,
+				Resource: ref.Resource,
+				Name:     ref.Name,
+				UID:      ref.UID,
+			})
+		}
+		if allocation := rc.Status.Allocation; allocation != nil {
+			converted.Status.Allocation = &resourcev1.AllocationResult{
+				NodeSelector:        allocation.NodeSelector,
+				AllocationTimestamp: allocation.AllocationTimestamp,
+			}
+			for _, result := range allocation.Devices.Results {
+				converted.Status.Allocation.Devices.Results = append(converted.Status.Allocation.Devices.Results, resourcev1.DeviceRequestAllocationResult{
+					Request:                  result.Request,
+					Driver:                   result.Driver,
+					Pool:                     result.Pool,
+					Device:                   result.Device,
+					BindingConditions:        result.BindingConditions,
+					BindingFailureConditions: result.BindingFailureConditions,
+				})
+			}
+		}
+		for _, device := range rc.Status.Devices {
+			converted.Status.Devices = append(converted.Status.Devices, resourcev1.AllocatedDeviceStatus{
+				Driver:     device.Driver,
+				Pool:       device.Pool,
+				Device:     device.Device,
+				Conditions: device.Conditions,
+			})
+		}
+		return converted, nil
+	default:
+		return nil, fmt.Errorf("unsupported ResourceClaim type %T", obj)
+	}
+}
+
+// ToResourceClaims converts a list returned by NewResourceClaimList to v1.
+func ToResourceClaims(list client.ObjectList) ([]resourcev1.ResourceClaim, error) {
+	var resourceClaims []resourcev1.ResourceClaim
+
+	switch l := list.(type) {
+	case *resourcev1.ResourceClaimList:
+		return l.Items, nil
+	case *resourcev1beta2.ResourceClaimList:
+		for i := range l.Items {
+			rc, err := ToResourceClaim(&l.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			resourceClaims = append(resourceClaims, *rc)
+		}
+	case *resourcev1beta1.ResourceClaimList:
+		for i := range l.Items {
+			rc, err := ToResourceClaim(&l.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			resourceClaims = append(resourceClaims, *rc)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ResourceClaimList type %T", list)
+	}
+
+	return resourceClaims, nil
+}
+
+// ToResourceSlice converts a ResourceSlice of any supported version to v1.
+// Only the metadata and the fields of the spec DDS reads are converted: the
+// driver, pool and node, and the name, attributes, capacity values and
+// binding conditions of the devices. The devices of v1beta1 wrap their
+// fields in Basic, which is unwrapped.
+func ToResourceSlice(obj runtime.Object) (*resourcev1.ResourceSlice, error) {
+	switch rs := obj.(type) {
+	case *resourcev1.ResourceSlice:
+		return rs, nil
+	case *resourcev1beta2.ResourceSlice:
+		converted := &resourcev1.ResourceSlice{
+			ObjectMeta: rs.ObjectMeta,
+			Spec: resourcev1.ResourceSliceSpec{
+				Driver: rs.Spec.Driver,
+				Pool: resourcev1.ResourcePool{
+					Name:               rs.Spec.Pool.Name,
+					Generation:         rs.Spec.Pool.Generation,
+					ResourceSliceCount: rs.Spec.Pool.ResourceSliceCount,
+				},
+				NodeName: rs.Spec.NodeName,
+			},
+		}
+		for _, device := range rs.Spec.Devices {
+			convertedDevice := resourcev1.Device{
+				Name:                     device.Name,
+				BindingConditions:        device.BindingConditions,
+				BindingFailureConditions: device.BindingFailureConditions,
+			}
+			for name, attribute := range device.Attributes {
+				if convertedDevice.Attributes == nil {
+					convertedDevice.Attributes = make(map[resourcev1.QualifiedName]resourcev1.DeviceAttribute, len(device.Attributes))
+				}
+				convertedDevice.Attributes[resourcev1.QualifiedName(name)] = resourcev1.DeviceAttribute{
+					IntValue:     attribute.IntValue,
+					BoolValue:    attribute.BoolValue,
+					StringValue:  attribute.StringValue,
+					VersionValue: attribute.VersionValue,
+				}
+			}
+			for name, capacity := range device.Capacity {
+				if convertedDevice.Capacity == nil {
+					convertedDevice.Capacity = make(map[resourcev1.QualifiedName]resourcev1.DeviceCapacity, len(device.Capacity))
+				}
+				convertedDevice.Capacity[resourcev1.QualifiedName(name)] = resourcev1.DeviceCapacity{Value: capacity.Value}
+			}
+			converted.Spec.Devices = append(converted.Spec.Devices, convertedDevice)
+		}
+		return converted, nil
+	case *resourcev1beta1.ResourceSlice:
+		converted := &resourcev1.ResourceSlice{
+			ObjectMeta: rs.ObjectMeta,
+			Spec: resourcev1.ResourceSliceSpec{
+				Driver: rs.Spec.Driver,
+				Pool: resourcev1.ResourcePool{
+					Name:               rs.Spec.Pool.Name,
+					Generation:         rs.Spec.Pool.Generation,
+					ResourceSliceCount: rs.Spec.Pool.ResourceSliceCount,
+				},
+			},
+		}
+		if rs.Spec.NodeName != "" {
+			converted.Spec.NodeName = ptr.To(rs.Spec.NodeName)
+		}
+		for _, device := range rs.Spec.Devices {
+			convertedDevice := resourcev1.Device{Name: device.Name}
+			if basic := device.Basic; basic != nil {
+				convertedDevice.BindingConditions = basic.BindingConditions
+				convertedDevice.BindingFailureConditions = basic.BindingFailureConditions
+				for name, attribute := range basic.Attributes {
+					if convertedDevice.Attributes == nil {
+						convertedDevice.Attributes = make(map[resourcev1.QualifiedName]resourcev1.DeviceAttribute, len(basic.Attributes))
+					}
+					convertedDevice.Attributes[resourcev1.QualifiedName(name)] = resourcev1.DeviceAttribute{
+						IntValue:     attribute.IntValue,
+						BoolValue:    attribute.BoolValue,
+						StringValue:  attribute.StringValue,
+						VersionValue: attribute.VersionValue,
+					}
+				}
+				for name, capacity := range basic.Capacity {
+					if convertedDevice.Capacity == nil {
+						convertedDevice.Capacity = make(map[resourcev1.QualifiedName]resourcev1.DeviceCapacity, len(basic.Capacity))
+					}
+					convertedDevice.Capacity[resourcev1.QualifiedName(name)] = resourcev1.DeviceCapacity{Value: capacity.Value}
+				}
+			}
+			converted.Spec.Devices = append(converted.Spec.Devices, convertedDevice)
+		}
+		return converted, nil
+	default:
+		return nil, fmt.Errorf("unsupported ResourceSlice type %T", obj)
+	}
+}
+
+// ToResourceSlices converts a list returned by NewResourceSliceList to v1.
+func ToResourceSlices(list client.ObjectList) ([]resourcev1.ResourceSlice, error) {
+	var resourceSlices []resourcev1.ResourceSlice
+
+	switch l := list.(type) {
+	case *resourcev1.ResourceSliceList:
+		return l.Items, nil
+	case *resourcev1beta2.ResourceSliceList:
+		for i := range l.Items {
+			rs, err := ToResourceSlice(&l.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			resourceSlices = append(resourceSlices, *rs)
+		}
+	case *resourcev1beta1.ResourceSliceList:
+		for i := range l.Items {
+			rs, err := ToResourceSlice(&l.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			resourceSlices = append(resourceSlices, *rs)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ResourceSliceList type %T", list)
+	}
+
+	return resourceSlices, nil
+}
+
+// GetResourceSliceNodeName returns the node a ResourceSlice of any supported
+// version is published for, without converting the whole slice.
+func GetResourceSliceNodeName(obj runtime.Object) string {
+	switch rs := obj.(type) {
+	case *resourcev1.ResourceSlice:
+		return ptr.Deref(rs.Spec.NodeName, "")
+	case *resourcev1beta2.ResourceSlice:
+		return ptr.Deref(rs.Spec.NodeName, "")
+	case *resourcev1beta1.ResourceSlice:
+		return rs.Spec.NodeName
+	default:
+		return ""
+	}
+}
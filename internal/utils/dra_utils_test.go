@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	resourcev1 "k8s.io/api/resource/v1"
+	resourcev1beta1 "k8s.io/api/resource/v1beta1"
+	resourcev1beta2 "k8s.io/api/resource/v1beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func draResourceList(gv schema.GroupVersion, names ...string) *metav1.APIResourceList {
+	resourceList := &metav1.APIResourceList{GroupVersion: gv.String()}
+	for _, name := range names {
+		resourceList.APIResources = append(resourceList.APIResources, metav1.APIResource{Name: name})
+	}
+
+	return resourceList
+}
+
+func TestDiscoverDRAVersion(t *testing.T) {
+	testCases := []struct {
+		name            string
+		resources       []*metav1.APIResourceList
+		expectedVersion schema.GroupVersion
+		wantErr         bool
+	}{
+		{
+			name: "all versions served",
+			resources: []*metav1.APIResourceList{
+				draResourceList(resourcev1beta1.SchemeGroupVersion, "resourceclaims", "resourceslices"),
+				draResourceList(resourcev1beta2.SchemeGroupVersion, "resourceclaims", "resourceslices"),
+				draResourceList(resourcev1.SchemeGroupVersion, "resourceclaims", "resourceslices"),
+			},
+			expectedVersion: resourcev1.SchemeGroupVersion,
+		},
+		{
+			name: "only v1beta1 served",
+			resources: []*metav1.APIResourceList{
+				draResourceList(resourcev1beta1.SchemeGroupVersion, "resourceclaims", "resourceslices"),
+			},
+			expectedVersion: resourcev1beta1.SchemeGroupVersion,
+		},
+		{
+			name: "newest version without ResourceSlices",
+			resources: []*metav1.APIResourceList{
+				draResourceList(resourcev1beta2.SchemeGroupVersion, "resourceclaims", "resourceslices"),
+				draResourceList(resourcev1.SchemeGroupVersion, "resourceclaims"),
+			},
+			expectedVersion: resourcev1beta2.SchemeGroupVersion,
+		},
+		{
+			name:    "no version served",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			discoveryClient := &discoveryfake.FakeDiscovery{Fake: &clienttesting.Fake{Resources: tc.resources}}
+
+			version, err := DiscoverDRAVersion(discoveryClient)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if version != tc.expectedVersion {
+				t.Errorf("version is incorrect. Got: %v, Want: %v", version, tc.expectedVersion)
+			}
+		})
+	}
+}
+
+func TestSetDRAVersion(t *testing.T) {
+	t.Cleanup(func() {
+		_ = SetDRAVersion(resourcev1beta1.SchemeGroupVersion)
+	})
+
+	if err := SetDRAVersion(schema.GroupVersion{Group: "resource.k8s.io", Version: "v1alpha3"}); err == nil {
+		t.Error("expected error for unsupported version, got nil")
+	}
+
+	if err := SetDRAVersion(resourcev1.SchemeGroupVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := NewResourceClaim().(*resourcev1.ResourceClaim); !ok {
+		t.Errorf("NewResourceClaim returned %T, want *v1.ResourceClaim", NewResourceClaim())
+	}
+	if _, ok := NewResourceSliceList().(*resourcev1.ResourceSliceList); !ok {
+		t.Errorf("NewResourceSliceList returned %T, want *v1.ResourceSliceList", NewResourceSliceList())
+	}
+}
+
+func TestToResourceSlice(t *testing.T) {
+	expected := &resourcev1.ResourceSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "slice"},
+		Spec: resourcev1.ResourceSliceSpec{
+			Driver:   "gpu.nvidia.com",
+			NodeName: ptr.To("node1"),
+			Pool:     resourcev1.ResourcePool{Name: "pool"},
+			Devices: []resourcev1.Device{
+				{
+					Name: "gpu-0",
+					Attributes: map[resourcev1.QualifiedName]resourcev1.DeviceAttribute{
+						"uuid": {StringValue: ptr.To("uuid-0")},
+					},
+					Capacity: map[resourcev1.QualifiedName]resourcev1.DeviceCapacity{
+						"memory": {Value: resource.MustParse("40Gi")},
+					},
+					BindingConditions: []string{"FabricDeviceReady"},
+				},
+				{Name: "gpu-1"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name string
+		obj  runtime.Object
+	}{
+		{
+			name: "v1",
+			obj:  expected,
+		},
+		{
+			name: "v1beta2",
+			obj: &resourcev1beta2.ResourceSlice{
+				ObjectMeta: metav1.ObjectMeta{Name: "slice"},
+				Spec: resourcev1beta2.ResourceSliceSpec{
+					Driver:   "gpu.nvidia.com",
+					NodeName: ptr.To("node1"),
+					Pool:     resourcev1beta2.ResourcePool{Name: "pool"},
+					Devices: []resourcev1beta2.Device{
+						{
+							Name: "gpu-0",
+							Attributes: map[resourcev1beta2.QualifiedName]resourcev1beta2.DeviceAttribute{
+								"uuid": {StringValue: ptr.To("uuid-0")},
+							},
+							Capacity: map[resourcev1beta2.QualifiedName]resourcev1beta2.DeviceCapacity{
+								"memory": {Value: resource.MustParse("40Gi")},
+							},
+							BindingConditions: []string{"FabricDeviceReady"},
+						},
+						{Name: "gpu-1"},
+					},
+				},
+			},
+		},
+		{
+			name: "v1beta1",
+			obj: &resourcev1beta1.ResourceSlice{
+				ObjectMeta: metav1.ObjectMeta{Name: "slice"},
+				Spec: resourcev1beta1.ResourceSliceSpec{
+					Driver:   "gpu.nvidia.com",
+					NodeName: "node1",
+					Pool:     resourcev1beta1.ResourcePool{Name: "pool"},
+					Devices: []resourcev1beta1.Device{
+						{
+							Name: "gpu-0",
+							Basic: &resourcev1beta1.BasicDevice{
+								Attributes: map[resourcev1beta1.QualifiedName]resourcev1beta1.DeviceAttribute{
+									"uuid": {StringValue: ptr.To("uuid-0")},
+								},
+								Capacity: map[resourcev1beta1.QualifiedName]resourcev1beta1.DeviceCapacity{
+									"memory": {Value: resource.MustParse("40Gi")},
+								},
+								BindingConditions: []string{"FabricDeviceReady"},
+							},
+						},
+						{Name: "gpu-1"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, err := ToResourceSlice(tc.obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rs, expected) {
+				t.Errorf("ResourceSlice is incorrect. Got: %+v, Want: %+v", rs, expected)
+			}
+		})
+	}
+}
+
+func TestToResourceClaim(t *testing.T) {
+	allocatedAt := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	rc := &resourcev1beta1.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "default", UID: "uid-1"},
+		Status: resourcev1beta1.ResourceClaimStatus{
+			ReservedFor: []resourcev1beta1.ResourceClaimConsumerReference{{Resource: "pods", Name: "pod", UID: "uid-2"}},
+			Allocation: &resourcev1beta1.AllocationResult{
+				AllocationTimestamp: &allocatedAt,
+				Devices: resourcev1beta1.DeviceAllocationResult{
+					Results: []resourcev1beta1.DeviceRequestAllocationResult{
+						{Request: "gpu", Driver: "gpu.nvidia.com", Pool: "pool", Device: "gpu-0", BindingConditions: []string{"FabricDeviceReady"}},
+					},
+				},
+			},
+			Devices: []resourcev1beta1.AllocatedDeviceStatus{
+				{Driver: "gpu.nvidia.com", Pool: "pool", Device: "gpu-0", Conditions: []metav1.Condition{{Type: "FabricDeviceReady", Status: metav1.ConditionTrue}}},
+			},
+		},
+	}
+
+	converted, err := ToResourceClaim(rc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if converted.Name != "claim" || converted.Namespace != "default" || converted.UID != "uid-1" {
+		t.Errorf("metadata is incorrect. Got: %+v", converted.ObjectMeta)
+	}
+	if len(converted.Status.ReservedFor) != 1 || converted.Status.ReservedFor[0].UID != "uid-2" {
+		t.Errorf("reservations are incorrect. Got: %+v", converted.Status.ReservedFor)
+	}
+	if !converted.Status.Allocation.AllocationTimestamp.Equal(&allocatedAt) {
+		t.Errorf("allocation time is incorrect. Got: %v, Want: %v", converted.Status.Allocation.AllocationTimestamp, allocatedAt)
+	}
+	results := converted.Status.Allocation.Devices.Results
+	if len(results) != 1 || results[0].Device != "gpu-0" || !reflect.DeepEqual(results[0].BindingConditions, []string{"FabricDeviceReady"}) {
+		t.Errorf("allocation is incorrect. Got: %+v", results)
+	}
+	if len(converted.Status.Devices) != 1 || converted.Status.Devices[0].Conditions[0].Type != "FabricDeviceReady" {
+		t.Errorf("device status is incorrect. Got: %+v", converted.Status.Devices)
+	}
+}
+
+func TestGetResourceSliceInfoV1(t *testing.T) {
+	if err := SetDRAVersion(resourcev1.SchemeGroupVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = SetDRAVersion(resourcev1beta1.SchemeGroupVersion)
+	})
+
+	rs := &resourcev1.ResourceSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: "slice"},
+		Spec: resourcev1.ResourceSliceSpec{
+			Driver:   "gpu.nvidia.com",
+			NodeName: ptr.To("node1"),
+			Pool:     resourcev1.ResourcePool{Name: "pool"},
+			Devices: []resourcev1.Device{
+				{
+					Name: "gpu-0",
+					Attributes: map[resourcev1.QualifiedName]resourcev1.DeviceAttribute{
+						"uuid": {StringValue: ptr.To("uuid-0")},
+					},
+				},
+			},
+		},
+	}
+
+	kubeClient := newIndexedClientBuilder(t).WithObjects(rs).Build()
+
+	resourceSliceInfos, err := GetResourceSliceInfo(context.Background(), kubeClient, types.ComposableDRASpec{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(resourceSliceInfos) != 1 {
+		t.Fatalf("expected 1 ResourceSliceInfo, got %d", len(resourceSliceInfos))
+	}
+	info := resourceSliceInfos[0]
+	if info.NodeName != "node1" || info.Pool != "pool" || len(info.Devices) != 1 || info.Devices[0].UUID != "uuid-0" {
+		t.Errorf("ResourceSliceInfo is incorrect. Got: %+v", info)
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	resourceapi "k8s.io/api/resource/v1"
+)
+
+// AttributeFunc extracts a value from the attributes of a DRA device. The
+// boolean is false when the device does not publish the value.
+type AttributeFunc func(attributes map[resourceapi.QualifiedName]resourceapi.DeviceAttribute) (string, bool)
+
+// DriverHooks describe how DDS handles the devices of a DRA driver.
+type DriverHooks struct {
+	// ResourceType is the CDI resource type used in ComposabilityRequests
+	// for the devices of the driver. The resource-type of a model in the
+	// device catalog takes precedence.
+	ResourceType string
+	// UUID returns the device ID that ComposableResources report for the
+	// device. Defaults to the string attribute "uuid".
+	UUID AttributeFunc
+	// ProductName returns the product name matched against the
+	// draAttributes of the device catalog. Defaults to the string attribute
+	// "productName".
+	ProductName AttributeFunc
+}
+
+// builtinDrivers are the drivers known without being listed in the drivers
+// of the device catalog.
+var builtinDrivers = []types.DriverInfo{
+	{Name: "gpu.nvidia.com", ResourceType: "gpu"},
+}
+
+// newDriverRegistry returns the hooks of the built-in drivers and of the
+// drivers of the device catalog, which replace the built-in ones of the same
+// name.
+func newDriverRegistry(driverInfos []types.DriverInfo) map[string]DriverHooks {
+	registry := make(map[string]DriverHooks, len(builtinDrivers)+len(driverInfos))
+	for _, list := range [][]types.DriverInfo{builtinDrivers, driverInfos} {
+		for _, driverInfo := range list {
+			hooks := DriverHooks{ResourceType: driverInfo.ResourceType}
+			if driverInfo.UUIDAttribute != "" {
+				hooks.UUID = StringAttribute(resourceapi.QualifiedName(driverInfo.UUIDAttribute))
+			}
+			if driverInfo.ProductNameAttribute != "" {
+				hooks.ProductName = StringAttribute(resourceapi.QualifiedName(driverInfo.ProductNameAttribute))
+			}
+			registry[driverInfo.Name] = hooks
+		}
+	}
+
+	return registry
+}
+
+// getDriverHooks returns the hooks of a DRA driver among the drivers of the
+// device catalog, with the defaults filled in for drivers and hooks that are
+// not configured.
+func getDriverHooks(driverInfos []types.DriverInfo, driverName string) DriverHooks {
+	hooks := newDriverRegistry(driverInfos)[driverName]
+
+	if hooks.UUID == nil {
+		hooks.UUID = StringAttribute("uuid")
+	}
+	if hooks.ProductName == nil {
+		hooks.ProductName = StringAttribute("productName")
+	}
+
+	return hooks
+}
+
+// GetResourceType returns the CDI resource type of a model of the device
+// catalog: its resource-type, or the type of its driver. It is empty when
+// neither is known.
+func GetResourceType(composableDRASpec types.ComposableDRASpec, deviceInfo types.DeviceInfo) string {
+	if deviceInfo.ResourceType != "" {
+		return deviceInfo.ResourceType
+	}
+
+	return newDriverRegistry(composableDRASpec.Drivers)[deviceInfo.DriverName].ResourceType
+}
+
+// StringAttribute returns an AttributeFunc reading a string or version
+// attribute.
+func StringAttribute(name resourceapi.QualifiedName) AttributeFunc {
+	return func(attributes map[resourceapi.QualifiedName]resourceapi.DeviceAttribute) (string, bool) {
+		attribute, ok := attributes[name]
+		if !ok {
+			return "", false
+		}
+
+		switch {
+		case attribute.StringValue != nil:
+			return *attribute.StringValue, true
+		case attribute.VersionValue != nil:
+			return *attribute.VersionValue, true
+		default:
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	resourceapi "k8s.io/api/resource/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetResourceType(t *testing.T) {
+	spec := types.ComposableDRASpec{
+		Drivers: []types.DriverInfo{{Name: "memory.cxl.example.com", ResourceType: "cxlmemory"}},
+	}
+
+	testCases := []struct {
+		name         string
+		deviceInfo   types.DeviceInfo
+		expectedType string
+	}{
+		{
+			name:         "built-in driver",
+			deviceInfo:   types.DeviceInfo{DriverName: "gpu.nvidia.com"},
+			expectedType: "gpu",
+		},
+		{
+			name:         "configured driver",
+			deviceInfo:   types.DeviceInfo{DriverName: "memory.cxl.example.com"},
+			expectedType: "cxlmemory",
+		},
+		{
+			name:         "resource type in the catalog",
+			deviceInfo:   types.DeviceInfo{DriverName: "gpu.nvidia.com", ResourceType: "gpu-shared"},
+			expectedType: "gpu-shared",
+		},
+		{
+			name:       "unknown driver",
+			deviceInfo: types.DeviceInfo{DriverName: "fpga.example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GetResourceType(spec, tc.deviceInfo); got != tc.expectedType {
+				t.Errorf("resource type is incorrect. Got: %q, Want: %q", got, tc.expectedType)
+			}
+		})
+	}
+}
+
+func TestDriverHooks(t *testing.T) {
+	driverInfos := []types.DriverInfo{
+		{Name: "nic.example.com", ResourceType: "smartnic", UUIDAttribute: "serialNumber"},
+		{Name: "gpu.example.com", ResourceType: "gpu", ProductNameAttribute: "model"},
+	}
+
+	attributes := map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+		"uuid":         {StringValue: ptr.To("uuid-0")},
+		"serialNumber": {StringValue: ptr.To("sn-0")},
+		"productName":  {VersionValue: ptr.To("1.0.0")},
+		"model":        {StringValue: ptr.To("A100")},
+		"memory":       {IntValue: ptr.To[int64](16)},
+	}
+
+	testCases := []struct {
+		name                string
+		driverName          string
+		expectedUUID        string
+		expectedProductName string
+	}{
+		{
+			name:                "default hooks",
+			driverName:          "gpu.nvidia.com",
+			expectedUUID:        "uuid-0",
+			expectedProductName: "1.0.0",
+		},
+		{
+			name:                "configured UUID attribute",
+			driverName:          "nic.example.com",
+			expectedUUID:        "sn-0",
+			expectedProductName: "1.0.0",
+		},
+		{
+			name:                "configured product name attribute",
+			driverName:          "gpu.example.com",
+			expectedUUID:        "uuid-0",
+			expectedProductName: "A100",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hooks := getDriverHooks(driverInfos, tc.driverName)
+
+			if uuid, _ := hooks.UUID(attributes); uuid != tc.expectedUUID {
+				t.Errorf("UUID is incorrect. Got: %q, Want: %q", uuid, tc.expectedUUID)
+			}
+			if productName, _ := hooks.ProductName(attributes); productName != tc.expectedProductName {
+				t.Errorf("product name is incorrect. Got: %q, Want: %q", productName, tc.expectedProductName)
+			}
+		})
+	}
+
+	if _, ok := StringAttribute("memory")(attributes); ok {
+		t.Errorf("Expected int attribute not to be read as a string")
+	}
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Mutations that are skipped in dry-run mode, as counted in
+// dds_dry_run_mutations_total.
+const (
+	MutationCreateComposabilityRequest        = "CreateComposabilityRequest"
+	MutationResizeComposabilityRequest        = "ResizeComposabilityRequest"
+	MutationLabelComposabilityRequest         = "LabelComposabilityRequest"
+	MutationDeleteComposabilityRequest        = "DeleteComposabilityRequest"
+	MutationDeleteComposableResource          = "DeleteComposableResource"
+	MutationPatchResourceClaimConditions      = "PatchResourceClaimConditions"
+	MutationPatchComposableResourceAnnotation = "PatchComposableResourceAnnotation"
+	MutationPatchNodeLabels                   = "PatchNodeLabels"
+	MutationPatchDeviceQuotaStatus            = "PatchDeviceQuotaStatus"
+	MutationPatchDDSConfigStatus              = "PatchDDSConfigStatus"
+	MutationPatchNodeScalingPolicyStatus      = "PatchNodeScalingPolicyStatus"
+)
+
+// dryRunReasonPrefix is prepended to the reason of the events emitted in
+// dry-run mode, so that the events of a shadow instance can be told apart
+// from those of the active one.
+const dryRunReasonPrefix = "DryRun"
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context in which DDS only logs and records the
+// mutations it would make, without writing them.
+func WithDryRun(ctx context.Context, dryRun bool) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun)
+}
+
+// IsDryRun reports whether ctx is in dry-run mode.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// IsDryRunEnabled reports whether dry-run mode is enabled. The --dry-run flag,
+// when given, overrides the dry-run setting of the configuration, which is
+// shared by every instance: a shadow instance started with --dry-run never
+// writes, and an instance started with --dry-run=false is not stopped from
+// writing by the configuration. dryRunFlag is nil when the flag is not given.
+func IsDryRunEnabled(composableDRASpec types.ComposableDRASpec, dryRunFlag *bool) bool {
+	if dryRunFlag != nil {
+		return *dryRunFlag
+	}
+
+	return composableDRASpec.DryRun
+}
+
+// skipInDryRun reports whether a mutation must be skipped. In dry-run mode
+// the intended mutation is logged with its details and counted.
+func skipInDryRun(ctx context.Context, mutation string, keysAndValues ...any) bool {
+	if !IsDryRun(ctx) {
+		return false
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Dry run, skipping "+mutation, keysAndValues...)
+	metrics.RecordDryRunMutation(mutation)
+
+	return true
+}
+
+// eventReason returns the reason of an event, prefixed in dry-run mode.
+func eventReason(ctx context.Context, reason string) string {
+	if IsDryRun(ctx) {
+		return dryRunReasonPrefix + reason
+	}
+
+	return reason
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsDryRunEnabled(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flag     *bool
+		config   bool
+		expected bool
+	}{
+		{name: "disabled"},
+		{name: "enabled by flag", flag: ptr.To(true), expected: true},
+		{name: "enabled by config", config: true, expected: true},
+		{name: "enabled by both", flag: ptr.To(true), config: true, expected: true},
+		{name: "flag overrides config", flag: ptr.To(false), config: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsDryRunEnabled(types.ComposableDRASpec{DryRun: tc.config}, tc.flag); got != tc.expected {
+				t.Errorf("dry run is incorrect. Got: %v, Want: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDryRunSkipsMutations(t *testing.T) {
+	ctx := WithDryRun(context.Background(), true)
+
+	cr := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Model: "A100 40G", Size: 2, TargetNode: "node1"},
+		},
+	}
+	legacyCR := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "composability-abcde", GenerateName: "composability-"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Model: "A100 40G", TargetNode: "node1"},
+		},
+	}
+	resource := &cdioperator.ComposableResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "res0"},
+	}
+	rc := &resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "claim", Namespace: "default"},
+		Status: resourceapi.ResourceClaimStatus{
+			Devices: []resourceapi.AllocatedDeviceStatus{{Driver: "gpu.nvidia.com", Pool: "pool", Device: "gpu-0"}},
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"existing": "true"}}}
+
+	fakeClient := newIndexedClientBuilder(t).WithObjects(cr, legacyCR, resource, rc).Build()
+	clientSet := k8sfake.NewClientset(node)
+	recorder := record.NewFakeRecorder(100)
+
+	plan := &types.Plan{
+		NodeName: "node1",
+		ComposabilityRequests: []types.ComposabilityRequestChange{
+			{ResourceType: "gpu", Model: "A100 80G", Size: 1},
+			{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+		},
+		AdoptedRequests: []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+		DeletedRequests: []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+	}
+	if err := ExecutePlan(ctx, fakeClient, clientSet, recorder, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := PatchComposableResourceAnnotation(ctx, fakeClient, "res0", "composable.fsastech.com/last-used-time", "2025-01-01T00:00:00Z"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := PatchResourceClaimDeviceConditions(ctx, fakeClient, "claim", "default", "FabricDeviceFailed", types.ReasonAttachTimeout, "timeout"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := patchNodeLabel(ctx, clientSet, "node1", []string{"added"}, []string{"existing"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	crList := &cdioperator.ComposabilityRequestList{}
+	if err := fakeClient.List(context.Background(), crList); err != nil {
+		t.Fatalf("failed to list ComposabilityRequests: %v", err)
+	}
+	if len(crList.Items) != 2 {
+		t.Errorf("ComposabilityRequests were created or deleted: %+v", crList.Items)
+	}
+	for _, item := range crList.Items {
+		if item.Labels != nil || item.Name == "test" && item.Spec.Resource.Size != 2 {
+			t.Errorf("ComposabilityRequest was modified: %+v", item)
+		}
+	}
+
+	updatedResource := &cdioperator.ComposableResource{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "res0"}, updatedResource); err != nil {
+		t.Fatalf("failed to get ComposableResource: %v", err)
+	}
+	if len(updatedResource.Annotations) != 0 {
+		t.Errorf("ComposableResource annotations were modified: %v", updatedResource.Annotations)
+	}
+
+	updatedRC := &resourceapi.ResourceClaim{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "claim", Namespace: "default"}, updatedRC); err != nil {
+		t.Fatalf("failed to get ResourceClaim: %v", err)
+	}
+	if len(updatedRC.Status.Devices[0].Conditions) != 0 {
+		t.Errorf("ResourceClaim conditions were modified: %v", updatedRC.Status.Devices[0].Conditions)
+	}
+
+	updatedNode, err := clientSet.CoreV1().Nodes().Get(context.Background(), "node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get Node: %v", err)
+	}
+	if len(updatedNode.Labels) != 1 || updatedNode.Labels["existing"] != "true" {
+		t.Errorf("Node labels were modified: %v", updatedNode.Labels)
+	}
+
+	var tracker ResourceStateTracker
+	for _, state := range []string{"Attaching", "Online", "Attaching"} {
+		snapshot := &NodeSnapshot{
+			NodeName: "node1",
+			ComposableResources: []cdioperator.ComposableResource{{
+				ObjectMeta: metav1.ObjectMeta{Name: "res0", UID: k8stypes.UID("uid0")},
+				Status:     cdioperator.ComposableResourceStatus{State: state},
+			}},
+		}
+		NotifyComposableResourceStates(ctx, recorder, &tracker, snapshot)
+	}
+
+	close(recorder.Events)
+	cyclingEvents := 0
+	for event := range recorder.Events {
+		if !strings.Contains(event, " "+dryRunReasonPrefix) {
+			t.Errorf("event reason is not marked as dry run: %s", event)
+		}
+		if strings.Contains(event, " "+dryRunReasonPrefix+ReasonComposableResourceCycling+" ") {
+			cyclingEvents++
+		}
+	}
+	if cyclingEvents != 2 {
+		t.Errorf("Expected 2 dry-run ComposableResourceCycling events, got %d", cyclingEvents)
+	}
+}
+
+func TestDryRunSkipsStatusPatches(t *testing.T) {
+	ctx := WithDryRun(context.Background(), true)
+
+	s := scheme.Scheme
+	if err := ddsv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	ddsConfig := &ddsv1alpha1.DDSConfig{ObjectMeta: metav1.ObjectMeta{Name: "default", Generation: 1}}
+	policy := nodeScalingPolicy("policy1", nil)
+	policy.Status.NodeErrors = []ddsv1alpha1.NodeScalingPolicyNodeError{{Node: "node1", Message: "error1"}}
+	quota := deviceQuota("quota1", "default")
+
+	fakeClient := fake.NewClientBuilder().WithScheme(s).
+		WithStatusSubresource(&ddsv1alpha1.DDSConfig{}, &ddsv1alpha1.NodeScalingPolicy{}, &ddsv1alpha1.DeviceQuota{}).
+		WithObjects(ddsConfig, policy, quota).
+		Build()
+
+	if err := PatchDDSConfigStatus(ctx, fakeClient, ddsConfig, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := PatchNodeScalingPolicyStatus(ctx, fakeClient, policy, nil, "node2", errors.New("error2")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ClearNodeScalingPolicyErrors(ctx, fakeClient, "node1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := PatchDeviceQuotaStatus(ctx, fakeClient, quota, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updatedDDSConfig := &ddsv1alpha1.DDSConfig{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "default"}, updatedDDSConfig); err != nil {
+		t.Fatalf("failed to get DDSConfig: %v", err)
+	}
+	if updatedDDSConfig.Status.ObservedGeneration != 0 || len(updatedDDSConfig.Status.Conditions) != 0 {
+		t.Errorf("DDSConfig status was modified: %+v", updatedDDSConfig.Status)
+	}
+
+	updatedPolicy := &ddsv1alpha1.NodeScalingPolicy{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "policy1"}, updatedPolicy); err != nil {
+		t.Fatalf("failed to get NodeScalingPolicy: %v", err)
+	}
+	if len(updatedPolicy.Status.Conditions) != 0 || len(updatedPolicy.Status.NodeErrors) != 1 || updatedPolicy.Status.NodeErrors[0].Node != "node1" {
+		t.Errorf("NodeScalingPolicy status was modified: %+v", updatedPolicy.Status)
+	}
+
+	updatedQuota := &ddsv1alpha1.DeviceQuota{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "quota1"}, updatedQuota); err != nil {
+		t.Fatalf("failed to get DeviceQuota: %v", err)
+	}
+	if updatedQuota.Status.ObservedGeneration != 0 || len(updatedQuota.Status.Conditions) != 0 {
+		t.Errorf("DeviceQuota status was modified: %+v", updatedQuota.Status)
+	}
+}
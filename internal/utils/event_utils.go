@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the events emitted for attach and detach decisions.
+const (
+	ReasonDeviceAttach = "DeviceAttach"
+	ReasonDeviceDetach = "DeviceDetach"
+)
+
+// Reasons of the events emitted when DDS adopts a ComposabilityRequest created
+// by an earlier version, or deletes an empty duplicate.
+const (
+	ReasonRequestAdopt  = "ComposabilityRequestAdopt"
+	ReasonRequestDelete = "ComposabilityRequestDelete"
+)
+
+// Reasons of the events emitted when DDS reacts to the lifecycle of a node:
+// it does not scale up a cordoned or NotReady node, reclaims the idle devices
+// of a cordoned node and deletes the ComposabilityRequests of a deleted node.
+const (
+	ReasonScaleUpBlocked = "ScaleUpBlocked"
+	ReasonDeviceReclaim  = "DeviceReclaim"
+	ReasonNodeDeleted    = "NodeDeleted"
+)
+
+// ReasonFabricOperationDeferred is the reason of the events emitted when a
+// budget of fabric operations defers an attach or detach.
+const ReasonFabricOperationDeferred = "FabricOperationDeferred"
+
+// Reasons of the events emitted when a resize waits for the previous one to
+// settle or for the scale-down cooldown, and when the previous one did not
+// settle in time.
+const (
+	ReasonResizeHeld         = "ResizeHeld"
+	ReasonConvergenceTimeout = "ConvergenceTimeout"
+)
+
+// resourceClaimReference refers to the ResourceClaim of a ResourceClaimInfo.
+func resourceClaimReference(resourceClaimInfo types.ResourceClaimInfo) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: DRAVersion().String(),
+		Kind:       "ResourceClaim",
+		Namespace:  resourceClaimInfo.Namespace,
+		Name:       resourceClaimInfo.Name,
+		UID:        resourceClaimInfo.UID,
+	}
+}
+
+// nodeReference refers to a Node. Like the kubelet, it uses the node name as
+// UID, so that the events show up in `kubectl describe node`.
+func nodeReference(nodeName string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: corev1.SchemeGroupVersion.String(),
+		Kind:       "Node",
+		Name:       nodeName,
+		UID:        k8stypes.UID(nodeName),
+	}
+}
+
+// ComposabilityRequestReference refers to a ComposabilityRequest.
+func ComposabilityRequestReference(cr *cdioperator.ComposabilityRequest) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: cdioperator.GroupVersion.String(),
+		Kind:       "ComposabilityRequest",
+		Name:       cr.Name,
+		UID:        cr.UID,
+	}
+}
+
+// ComposableResourceReference refers to a ComposableResource.
+func ComposableResourceReference(resource *cdioperator.ComposableResource) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: cdioperator.GroupVersion.String(),
+		Kind:       "ComposableResource",
+		Name:       resource.Name,
+		UID:        resource.UID,
+	}
+}
+
+// DeviceQuotaReference refers to a DeviceQuota.
+func DeviceQuotaReference(quota *ddsv1alpha1.DeviceQuota) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: ddsv1alpha1.GroupVersion.String(),
+		Kind:       "DeviceQuota",
+		Name:       quota.Name,
+		UID:        quota.UID,
+	}
+}
+
+// recordClaimEvent emits an event on a ResourceClaim, on the node it is
+// allocated on and on the related objects, such as the ComposabilityRequest
+// it conflicts with.
+func recordClaimEvent(recorder record.EventRecorder, resourceClaimInfo types.ResourceClaimInfo, eventType, reason, message string, related ...runtime.Object) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.Event(resourceClaimReference(resourceClaimInfo), eventType, reason, message)
+
+	claimMessage := fmt.Sprintf("ResourceClaim %s/%s: %s", resourceClaimInfo.Namespace, resourceClaimInfo.Name, message)
+	if resourceClaimInfo.NodeName != "" {
+		recorder.Event(nodeReference(resourceClaimInfo.NodeName), eventType, reason, claimMessage)
+	}
+	for _, obj := range related {
+		recorder.Event(obj, eventType, reason, claimMessage)
+	}
+}
+
+// recordScalingEvent emits an event for an attach or detach decision on the
+// node and on its ComposabilityRequest.
+func recordScalingEvent(recorder record.EventRecorder, nodeName string, cr *cdioperator.ComposabilityRequest, reason, message string) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.Event(nodeReference(nodeName), corev1.EventTypeNormal, reason, message)
+	if cr != nil {
+		recorder.Event(ComposabilityRequestReference(cr), corev1.EventTypeNormal, reason, message)
+	}
+}
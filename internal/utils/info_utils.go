@@ -3,44 +3,86 @@ package utils
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
 	v1 "k8s.io/api/core/v1"
-	resourceapi "k8s.io/api/resource/v1beta1"
+	resourceapi "k8s.io/api/resource/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func GetResourceClaimInfo(ctx context.Context, kubeClient client.Client, composableDRASpec types.ComposableDRASpec) ([]types.ResourceClaimInfo, error) {
+const (
+	// ConfigMapNamespace and ConfigMapName locate the legacy configuration ConfigMap.
+	ConfigMapNamespace = "composable-dra"
+	ConfigMapName      = "composable-dra-dds"
+	// DDSConfigName is the name of the cluster-scoped DDSConfig read by DDS.
+	DDSConfigName = "composable-dra-dds"
+)
+
+// GetResourceClaimInfo collects the allocated ResourceClaims that wait for
+// composable devices. The list options restrict which claims are read. The
+// ResourceSlices of the allocated devices are read through the pool index,
+// only for the pools the claims use.
+func GetResourceClaimInfo(ctx context.Context, kubeClient client.Client, composableDRASpec types.ComposableDRASpec, opts ...client.ListOption) ([]types.ResourceClaimInfo, error) {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.V(1).Info("Start collecting ResourceClaim info")
 
 	var resourceClaimInfoList []types.ResourceClaimInfo
 
-	resourceClaimList := &resourceapi.ResourceClaimList{}
-	if err := kubeClient.List(ctx, resourceClaimList, &client.ListOptions{}); err != nil {
+	resourceClaimList := NewResourceClaimList()
+	if err := kubeClient.List(ctx, resourceClaimList, opts...); err != nil {
 		return nil, fmt.Errorf("failed to list ResourceClaims: %v", err)
 	}
+	resourceClaims, err := ToResourceClaims(resourceClaimList)
+	if err != nil {
+		return nil, err
+	}
 
-	resourceSliceList := &resourceapi.ResourceSliceList{}
-	if err := kubeClient.List(ctx, resourceSliceList, &client.ListOptions{}); err != nil {
-		return nil, fmt.Errorf("failed to list ResourceClaims: %v", err)
+	// The ResourceSlices of every pool are listed once.
+	poolSlices := make(map[string][]resourceapi.ResourceSlice)
+	getPoolSlices := func(driver, pool string) ([]resourceapi.ResourceSlice, error) {
+		key := PoolKey(driver, pool)
+		if resourceSlices, ok := poolSlices[key]; ok {
+			return resourceSlices, nil
+		}
+
+		resourceSliceList := NewResourceSliceList()
+		if err := kubeClient.List(ctx, resourceSliceList, client.MatchingFields{ResourceSlicePoolIndex: key}); err != nil {
+			return nil, fmt.Errorf("failed to list ResourceSlices: %v", err)
+		}
+		resourceSlices, err := ToResourceSlices(resourceSliceList)
+		if err != nil {
+			return nil, err
+		}
+		poolSlices[key] = resourceSlices
+
+		return resourceSlices, nil
 	}
 
-	for _, rc := range resourceClaimList.Items {
+	for _, rc := range resourceClaims {
 		if len(rc.Status.ReservedFor) == 0 || rc.Status.Allocation == nil {
 			continue
 		}
 
 		var resourceClaimInfo types.ResourceClaimInfo
 		resourceClaimInfo.Name = rc.Name
+		resourceClaimInfo.UID = rc.UID
 		resourceClaimInfo.Namespace = rc.Namespace
 		resourceClaimInfo.CreationTimestamp = rc.ObjectMeta.CreationTimestamp
+		if rc.Status.Allocation.AllocationTimestamp != nil {
+			resourceClaimInfo.AllocatedAt = *rc.Status.Allocation.AllocationTimestamp
+		}
 		if rc.Status.Allocation.NodeSelector != nil {
 			resourceClaimInfo.NodeName = getNodeName(*rc.Status.Allocation.NodeSelector)
 		}
@@ -52,17 +94,25 @@ func GetResourceClaimInfo(ctx context.Context, kubeClient client.Client, composa
 			var deviceInfo types.ResourceClaimDevice
 			deviceInfo.Name = device.Device
 
+			resourceSlices, err := getPoolSlices(device.Driver, device.Pool)
+			if err != nil {
+				return nil, err
+			}
+
 		ResourceSliceLoop:
-			for _, rs := range resourceSliceList.Items {
+			for _, rs := range resourceSlices {
 				if rs.Spec.Driver == device.Driver && rs.Spec.Pool.Name == device.Pool {
 					for _, resourceSliceDevice := range rs.Spec.Devices {
 						if resourceSliceDevice.Name == device.Device {
-							model, err := getModelName(composableDRASpec, "", *resourceSliceDevice.Basic.Attributes["productName"].StringValue)
-							logger.Info("Found model name for device", "device", device.Device, "model", model)
+							resourceClaimInfo.ResourceSliceName = rs.Name
+							matched, err := matchDeviceInfo(composableDRASpec, rs.Spec.Driver, resourceSliceDevice)
 							if err != nil {
-								return nil, err
+								logger.Error(err, "Failed to resolve model of device", "device", device.Device)
+								deviceInfo.ResolveError = err.Error()
+								break ResourceSliceLoop
 							}
-							deviceInfo.Model = model
+							logger.Info("Found model name for device", "device", device.Device, "model", matched.CDIModelName)
+							deviceInfo.Model = matched.CDIModelName
 							break ResourceSliceLoop
 						}
 					}
@@ -103,18 +153,24 @@ func GetResourceClaimInfo(ctx context.Context, kubeClient client.Client, composa
 	return resourceClaimInfoList, nil
 }
 
-func GetResourceSliceInfo(ctx context.Context, kubeClient client.Client) ([]types.ResourceSliceInfo, error) {
+// GetResourceSliceInfo collects the ResourceSlices of attached devices. The
+// list options restrict which slices are read.
+func GetResourceSliceInfo(ctx context.Context, kubeClient client.Client, composableDRASpec types.ComposableDRASpec, opts ...client.ListOption) ([]types.ResourceSliceInfo, error) {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.V(1).Info("Start collecting ResourceSlice info")
 
 	var resourceSliceInfoList []types.ResourceSliceInfo
 
-	resourceSliceList := &resourceapi.ResourceSliceList{}
-	if err := kubeClient.List(ctx, resourceSliceList, &client.ListOptions{}); err != nil {
-		return nil, fmt.Errorf("failed to list ResourceClaims: %v", err)
+	resourceSliceList := NewResourceSliceList()
+	if err := kubeClient.List(ctx, resourceSliceList, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list ResourceSlices: %v", err)
+	}
+	resourceSlices, err := ToResourceSlices(resourceSliceList)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, rs := range resourceSliceList.Items {
+	for _, rs := range resourceSlices {
 		if hasBindingConditions(rs) {
 			continue
 		}
@@ -124,20 +180,16 @@ func GetResourceSliceInfo(ctx context.Context, kubeClient client.Client) ([]type
 		resourceSliceInfo.Name = rs.Name
 		resourceSliceInfo.CreationTimestamp = rs.CreationTimestamp
 		resourceSliceInfo.Driver = rs.Spec.Driver
-		resourceSliceInfo.NodeName = rs.Spec.NodeName
+		resourceSliceInfo.NodeName = ptr.Deref(rs.Spec.NodeName, "")
 		resourceSliceInfo.Pool = rs.Spec.Pool.Name
 
 		for _, device := range rs.Spec.Devices {
-			if device.Basic != nil {
-				var deviceInfo types.ResourceSliceDevice
-				deviceInfo.Name = device.Name
-				for attrName, attrValue := range device.Basic.Attributes {
-					if attrName == "uuid" {
-						deviceInfo.UUID = *attrValue.StringValue
-					}
-				}
-				resourceSliceInfo.Devices = append(resourceSliceInfo.Devices, deviceInfo)
+			var deviceInfo types.ResourceSliceDevice
+			deviceInfo.Name = device.Name
+			if uuid, ok := getDeviceUUID(composableDRASpec, rs.Spec.Driver, device); ok {
+				deviceInfo.UUID = uuid
 			}
+			resourceSliceInfo.Devices = append(resourceSliceInfo.Devices, deviceInfo)
 		}
 
 		resourceSliceInfoList = append(resourceSliceInfoList, resourceSliceInfo)
@@ -166,132 +218,249 @@ func GetNodeInfo(ctx context.Context, clientSet kubernetes.Interface, composable
 	return nodeInfos, nil
 }
 
+// GetNodeInfoFromNode reads the model constraints of a single Node from its labels.
+func GetNodeInfoFromNode(node v1.Node, composableDRASpec types.ComposableDRASpec) (types.NodeInfo, error) {
+	return newNodeInfo(node, nil, composableDRASpec)
+}
+
 func processNodeInfo(nodes *v1.NodeList, composableDRASpec types.ComposableDRASpec) ([]types.NodeInfo, error) {
 	var nodeInfoList []types.NodeInfo
 
 	for _, node := range nodes.Items {
-		var nodeInfo types.NodeInfo
+		nodeInfo, err := newNodeInfo(node, nil, composableDRASpec)
+		if err != nil {
+			return nil, err
+		}
+
+		nodeInfoList = append(nodeInfoList, nodeInfo)
+	}
 
-		nodeInfo.Name = node.Name
+	return nodeInfoList, nil
+}
 
-		labels := node.Labels
-		for key, val := range labels {
-			if !strings.HasPrefix(key, composableDRASpec.LabelPrefix+"/") {
-				continue
+// newNodeInfo reads the model constraints of a node from the NodeScalingPolicy
+// selecting it, if any, then from its labels. A label overrides the limit of
+// the policy it names.
+func newNodeInfo(node v1.Node, policy *ddsv1alpha1.NodeScalingPolicy, composableDRASpec types.ComposableDRASpec) (types.NodeInfo, error) {
+	var nodeInfo types.NodeInfo
+
+	nodeInfo.Name = node.Name
+	nodeInfo.Cordoned = IsNodeCordoned(node)
+	nodeInfo.NotReady = IsNodeNotReady(node)
+
+	if policy != nil {
+		if err := applyNodeScalingPolicy(&nodeInfo, policy, composableDRASpec); err != nil {
+			return types.NodeInfo{}, err
+		}
+	}
+
+	labels := node.Labels
+	for key, val := range labels {
+		if !strings.HasPrefix(key, composableDRASpec.LabelPrefix+"/") {
+			continue
+		}
+
+		suffix := key[len(composableDRASpec.LabelPrefix+"/"):]
+		var exit bool
+		if strings.HasSuffix(suffix, "-size-max") {
+			max, err := strconv.Atoi(val)
+			if err != nil {
+				return types.NodeInfo{}, fmt.Errorf("invalid integer in %s: %v", val, err)
 			}
 
-			suffix := key[len(composableDRASpec.LabelPrefix+"/"):]
-			var exit bool
-			if strings.HasSuffix(suffix, "-size-max") {
-				max, err := strconv.Atoi(val)
-				if err != nil {
-					return nil, fmt.Errorf("invalid integer in %s: %v", val, err)
-				}
+			deviceName := suffix[:len(suffix)-9]
+			model, err := getModelName(composableDRASpec, deviceName)
+			if err != nil {
+				return types.NodeInfo{}, err
+			}
 
-				deviceName := suffix[:len(suffix)-9]
-				model, err := getModelName(composableDRASpec, deviceName, "")
-				if err != nil {
-					return nil, err
+			exit = false
+			for i := range nodeInfo.Models {
+				if nodeInfo.Models[i].DeviceName == deviceName {
+					nodeInfo.Models[i].MaxDevice = max
+					nodeInfo.Models[i].Model = model
+					exit = true
+					break
 				}
+			}
 
-				exit = false
-				for i := range nodeInfo.Models {
-					if nodeInfo.Models[i].DeviceName == deviceName {
-						nodeInfo.Models[i].MaxDevice = max
-						nodeInfo.Models[i].Model = model
-						exit = true
-						break
-					}
+			if !exit {
+				newModelConstraint := types.ModelConstraints{
+					DeviceName: deviceName,
+					Model:      model,
+					MaxDevice:  max,
 				}
 
-				if !exit {
-					newModelConstraint := types.ModelConstraints{
-						DeviceName: deviceName,
-						Model:      model,
-						MaxDevice:  max,
-					}
+				nodeInfo.Models = append(nodeInfo.Models, newModelConstraint)
+			}
+		} else if strings.HasSuffix(suffix, "-size-min") {
+			min, err := strconv.Atoi(val)
+			if err != nil {
+				return types.NodeInfo{}, fmt.Errorf("invalid integer in %s: %v", val, err)
+			}
 
-					nodeInfo.Models = append(nodeInfo.Models, newModelConstraint)
-				}
-			} else if strings.HasSuffix(suffix, "-size-min") {
-				min, err := strconv.Atoi(val)
-				if err != nil {
-					return nil, fmt.Errorf("invalid integer in %s: %v", val, err)
-				}
+			deviceName := suffix[:len(suffix)-9]
+			model, err := getModelName(composableDRASpec, deviceName)
+			if err != nil {
+				return types.NodeInfo{}, err
+			}
 
-				deviceName := suffix[:len(suffix)-9]
-				model, err := getModelName(composableDRASpec, deviceName, "")
-				if err != nil {
-					return nil, err
+			exit = false
+			for i := range nodeInfo.Models {
+				if nodeInfo.Models[i].DeviceName == deviceName {
+					nodeInfo.Models[i].MinDevice = min
+					nodeInfo.Models[i].Model = model
+					exit = true
+					break
 				}
+			}
 
-				exit = false
-				for i := range nodeInfo.Models {
-					if nodeInfo.Models[i].DeviceName == deviceName {
-						nodeInfo.Models[i].MinDevice = min
-						nodeInfo.Models[i].Model = model
-						exit = true
-						break
-					}
+			if !exit {
+				newModelConstraint := types.ModelConstraints{
+					DeviceName: deviceName,
+					Model:      model,
+					MinDevice:  min,
 				}
 
-				if !exit {
-					newModelConstraint := types.ModelConstraints{
-						DeviceName: deviceName,
-						Model:      model,
-						MinDevice:  min,
-					}
-
-					nodeInfo.Models = append(nodeInfo.Models, newModelConstraint)
-				}
+				nodeInfo.Models = append(nodeInfo.Models, newModelConstraint)
 			}
 		}
-
-		nodeInfoList = append(nodeInfoList, nodeInfo)
 	}
 
-	return nodeInfoList, nil
+	return nodeInfo, nil
 }
 
-func getModelName(composableDRASpec types.ComposableDRASpec, deviceName, productName string) (string, error) {
-	if deviceName != "" {
-		for _, deviceInfo := range composableDRASpec.DeviceInfos {
-			if deviceInfo.K8sDeviceName == deviceName {
-				return deviceInfo.CDIModelName, nil
-			}
+func getModelName(composableDRASpec types.ComposableDRASpec, deviceName string) (string, error) {
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		if deviceInfo.K8sDeviceName == deviceName {
+			return deviceInfo.CDIModelName, nil
 		}
 	}
 
-	if productName != "" {
-		for _, deviceInfo := range composableDRASpec.DeviceInfos {
-			if deviceInfo.DRAAttributes["productName"] == productName {
-				return deviceInfo.CDIModelName, nil
+	return "", fmt.Errorf("unknown device name: %s", deviceName)
+}
+
+// GetComposableDRASpec reads the DDSConfig custom resource and reports its
+// validation result in the resource status. The ConfigMap is used as a
+// fallback when no DDSConfig exists.
+func GetComposableDRASpec(ctx context.Context, kubeClient client.Client) (types.ComposableDRASpec, error) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start collecting DDSConfig info")
+
+	var composableDRASpec types.ComposableDRASpec
+
+	ddsConfig := &ddsv1alpha1.DDSConfig{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: DDSConfigName}, ddsConfig); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			logger.V(1).Info("DDSConfig not found, falling back to ConfigMap", "name", DDSConfigName)
+			composableDRASpec, err = GetConfigMapInfo(ctx, kubeClient)
+			if err != nil {
+				return composableDRASpec, err
 			}
+			if err := ValidateComposableDRASpec(composableDRASpec); err != nil {
+				return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("invalid ConfigMap %s/%s: %v", ConfigMapNamespace, ConfigMapName, err)}
+			}
+			return composableDRASpec, nil
 		}
+		return composableDRASpec, fmt.Errorf("failed to get DDSConfig: %v", err)
 	}
 
-	return "", fmt.Errorf("unknown device name: %s", deviceName)
+	composableDRASpec = ConvertDDSConfigSpec(ddsConfig.Spec)
+
+	validationErr := ValidateComposableDRASpec(composableDRASpec)
+	if err := PatchDDSConfigStatus(ctx, kubeClient, ddsConfig, validationErr); err != nil {
+		return composableDRASpec, err
+	}
+	if validationErr != nil {
+		return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("invalid DDSConfig %s: %v", DDSConfigName, validationErr)}
+	}
+
+	logger.V(1).Info("Finish collecting DDSConfig info", "composableDRASpec", composableDRASpec)
+
+	return composableDRASpec, nil
+}
+
+// ConvertDDSConfigSpec converts the DDSConfig API spec into the internal
+// representation shared with the ConfigMap source.
+func ConvertDDSConfigSpec(spec ddsv1alpha1.DDSConfigSpec) types.ComposableDRASpec {
+	composableDRASpec := types.ComposableDRASpec{
+		LabelPrefix:   spec.LabelPrefix,
+		FabricIDRange: spec.FabricIDRange,
+		DryRun:        spec.DryRun,
+	}
+
+	for _, device := range spec.DeviceInfos {
+		composableDRASpec.DeviceInfos = append(composableDRASpec.DeviceInfos, types.DeviceInfo{
+			Index:             device.Index,
+			CDIModelName:      device.CDIModelName,
+			DRAAttributes:     device.DRAAttributes,
+			Match:             convertAttributeMatches(device.Match),
+			UUIDAttribute:     device.UUIDAttribute,
+			LabelKeyModel:     device.LabelKeyModel,
+			DriverName:        device.DriverName,
+			ResourceType:      device.ResourceType,
+			K8sDeviceName:     device.K8sDeviceName,
+			CannotCoexistWith: device.CannotCoexistWith,
+		})
+	}
+
+	for _, driver := range spec.Drivers {
+		composableDRASpec.Drivers = append(composableDRASpec.Drivers, types.DriverInfo{
+			Name:                 driver.Name,
+			ResourceType:         driver.ResourceType,
+			UUIDAttribute:        driver.UUIDAttribute,
+			ProductNameAttribute: driver.ProductNameAttribute,
+		})
+	}
+
+	return composableDRASpec
 }
 
-func GetConfigMapInfo(ctx context.Context, clientSet kubernetes.Interface) (types.ComposableDRASpec, error) {
+func convertAttributeMatches(matches []ddsv1alpha1.AttributeMatch) []types.AttributeMatch {
+	var result []types.AttributeMatch
+	for _, match := range matches {
+		result = append(result, types.AttributeMatch{
+			Attribute: match.Attribute,
+			Capacity:  match.Capacity,
+			Type:      match.Type,
+			Value:     match.Value,
+		})
+	}
+
+	return result
+}
+
+func GetConfigMapInfo(ctx context.Context, kubeClient client.Reader) (types.ComposableDRASpec, error) {
 	logger := ctrl.LoggerFrom(ctx)
 	logger.V(1).Info("Start collecting ConfigMap info")
 
 	var composableDRASpec types.ComposableDRASpec
 
-	configMap, err := clientSet.CoreV1().ConfigMaps("composable-dra").Get(ctx, "composable-dra-dds", metav1.GetOptions{})
-	if err != nil {
-		return composableDRASpec, fmt.Errorf("failed to get ConfigMap: %v", err)
+	configMap := &v1.ConfigMap{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: ConfigMapName, Namespace: ConfigMapNamespace}, configMap); err != nil {
+		return composableDRASpec, fmt.Errorf("failed to get ConfigMap: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(configMap.Data["device-info"]), &composableDRASpec.DeviceInfos); err != nil {
+		return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("failed to parse device-info: %v", err)}
 	}
 
-	if err = yaml.Unmarshal([]byte(configMap.Data["device-info"]), &composableDRASpec.DeviceInfos); err != nil {
-		return composableDRASpec, fmt.Errorf("failed to parse device-info: %v", err)
+	if err := yaml.Unmarshal([]byte(configMap.Data["drivers"]), &composableDRASpec.Drivers); err != nil {
+		return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("failed to parse drivers: %v", err)}
 	}
 
 	composableDRASpec.LabelPrefix = configMap.Data["label-prefix"]
 
 	if err := yaml.Unmarshal([]byte(configMap.Data["fabric-id-range"]), &composableDRASpec.FabricIDRange); err != nil {
-		return composableDRASpec, fmt.Errorf("failed to parse fabric-id-range: %v", err)
+		return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("failed to parse fabric-id-range: %v", err)}
+	}
+
+	if value, ok := configMap.Data["dry-run"]; ok {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return composableDRASpec, &InvalidConfigError{Err: fmt.Errorf("failed to parse dry-run: %v", err)}
+		}
+		composableDRASpec.DryRun = dryRun
 	}
 
 	logger.V(1).Info("Finish collecting ConfigMap info", "composableDRASpec", composableDRASpec)
@@ -344,7 +513,7 @@ func hasMatchingBindingCondition(
 
 func hasBindingConditions(rs resourceapi.ResourceSlice) bool {
 	for _, device := range rs.Spec.Devices {
-		if len(device.Basic.BindingConditions) > 0 {
+		if len(device.BindingConditions) > 0 {
 			return true
 		}
 	}
@@ -352,6 +521,16 @@ func hasBindingConditions(rs resourceapi.ResourceSlice) bool {
 	return false
 }
 
+// GetResourceClaimNodeName returns the node a ResourceClaim is allocated on,
+// or an empty string when it is not allocated to a single node.
+func GetResourceClaimNodeName(rc resourceapi.ResourceClaim) string {
+	if rc.Status.Allocation == nil || rc.Status.Allocation.NodeSelector == nil {
+		return ""
+	}
+
+	return getNodeName(*rc.Status.Allocation.NodeSelector)
+}
+
 func getNodeName(selector v1.NodeSelector) string {
 	for _, term := range selector.NodeSelectorTerms {
 		for _, field := range term.MatchFields {
@@ -362,3 +541,21 @@ func getNodeName(selector v1.NodeSelector) string {
 	}
 	return ""
 }
+
+// IsModelAllowed reports whether a model may be attached to a node. Every
+// model is allowed when the node has no allowed models.
+func IsModelAllowed(node types.NodeInfo, model string) bool {
+	return len(node.AllowedModels) == 0 || slices.Contains(node.AllowedModels, model)
+}
+
+// GetModelLimit returns the max_device and min_device of a model on a node.
+func GetModelLimit(node types.NodeInfo, model string) (max int64, min int64) {
+	for _, modelConstraint := range node.Models {
+		if modelConstraint.Model == model {
+			max = int64(modelConstraint.MaxDevice)
+			min = int64(modelConstraint.MinDevice)
+		}
+	}
+
+	return
+}
@@ -14,9 +14,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/ptr"
 
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"k8s.io/apimachinery/pkg/api/meta"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -98,6 +101,7 @@ func TestGetResourceClaimInfo(t *testing.T) {
 								},
 							},
 							Allocation: &resourceapi.AllocationResult{
+								AllocationTimestamp: &metav1.Time{Time: now.Add(-time.Minute)},
 								Devices: resourceapi.DeviceAllocationResult{
 									Results: []resourceapi.DeviceRequestAllocationResult{
 										{
@@ -182,6 +186,7 @@ func TestGetResourceClaimInfo(t *testing.T) {
 					NodeName:          "node1",
 					ResourceSliceName: "test-resourceslice-1",
 					CreationTimestamp: metav1.Time{Time: now.Truncate(time.Second)},
+					AllocatedAt:       metav1.Time{Time: now.Add(-time.Minute).Truncate(time.Second)},
 					Devices: []types.ResourceClaimDevice{
 						{
 							Name:  "gpu-1",
@@ -218,9 +223,7 @@ func TestGetResourceClaimInfo(t *testing.T) {
 				}
 			}
 
-			s := scheme.Scheme
-
-			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clientObjects...).Build()
+			fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects(clientObjects...).Build()
 
 			result, err := GetResourceClaimInfo(context.Background(), fakeClient, tc.composableDRASpec)
 
@@ -317,7 +320,7 @@ func TestGetResourceSliceInfo(t *testing.T) {
 
 			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clientObjects...).Build()
 
-			result, err := GetResourceSliceInfo(context.Background(), fakeClient)
+			result, err := GetResourceSliceInfo(context.Background(), fakeClient, types.ComposableDRASpec{})
 
 			if tc.wantErr {
 				if err == nil {
@@ -477,6 +480,126 @@ func TestGetNodeInfo(t *testing.T) {
 	}
 }
 
+func TestGetNodeInfoFromNode(t *testing.T) {
+	composableDRASpec := types.ComposableDRASpec{
+		LabelPrefix: "composable.fsastech.com",
+		DeviceInfos: []types.DeviceInfo{
+			{
+				Index:         1,
+				CDIModelName:  "A100 80G",
+				K8sDeviceName: "nvidia-a100-80g",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		node             corev1.Node
+		expectedNodeInfo types.NodeInfo
+		wantErr          bool
+		expectedErrMsg   string
+	}{
+		{
+			name: "normal case",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node1",
+					Labels: map[string]string{
+						"composable.fsastech.com/nvidia-a100-80g-size-min": "1",
+						"composable.fsastech.com/nvidia-a100-80g-size-max": "4",
+					},
+				},
+			},
+			expectedNodeInfo: types.NodeInfo{
+				Name: "node1",
+				Models: []types.ModelConstraints{
+					{
+						Model:      "A100 80G",
+						DeviceName: "nvidia-a100-80g",
+						MinDevice:  1,
+						MaxDevice:  4,
+					},
+				},
+			},
+		},
+		{
+			name: "node without constraints",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node2",
+				},
+			},
+			expectedNodeInfo: types.NodeInfo{
+				Name: "node2",
+			},
+		},
+		{
+			name: "cordoned node",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node2",
+				},
+				Spec: corev1.NodeSpec{Unschedulable: true},
+			},
+			expectedNodeInfo: types.NodeInfo{
+				Name:     "node2",
+				Cordoned: true,
+			},
+		},
+		{
+			name: "NotReady node",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node2",
+				},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionUnknown}},
+				},
+			},
+			expectedNodeInfo: types.NodeInfo{
+				Name:     "node2",
+				NotReady: true,
+			},
+		},
+		{
+			name: "invalid integer",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "node3",
+					Labels: map[string]string{
+						"composable.fsastech.com/nvidia-a100-80g-size-max": "many",
+					},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "invalid integer in many: strconv.Atoi: parsing \"many\": invalid syntax",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := GetNodeInfoFromNode(tc.node, composableDRASpec)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				if err.Error() != tc.expectedErrMsg {
+					t.Errorf("Error message is incorrect. Got: %q, Want: %q", err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(result, tc.expectedNodeInfo) {
+				t.Errorf("NodeInfo is incorrect. Got: %v, Want: %v", result, tc.expectedNodeInfo)
+			}
+		})
+	}
+}
+
 func TestGetModelName(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -519,7 +642,7 @@ func TestGetModelName(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			result, err := getModelName(tc.composableDRASpec, tc.deviceName, "")
+			result, err := getModelName(tc.composableDRASpec, tc.deviceName)
 			if tc.wantErr {
 				if err == nil {
 					t.Fatal("expected error but got nil")
@@ -624,20 +747,236 @@ func TestGetConfigMapInfo(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			var clientSet *k8sfake.Clientset
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
 			if tc.createConfigMap {
-				clientSet = k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+				builder = builder.WithObjects(&corev1.ConfigMap{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      "composable-dra-dds",
 						Namespace: "composable-dra",
 					},
 					Data: tc.configMapData,
 				})
-			} else {
-				clientSet = k8sfake.NewSimpleClientset()
 			}
+			fakeClient := builder.Build()
+
+			result, err := GetConfigMapInfo(context.Background(), fakeClient)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+					t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tc.wantSpec) {
+				t.Errorf("got %+v, want %+v", result, tc.wantSpec)
+			}
+		})
+	}
+}
+
+func TestGetComposableDRASpec(t *testing.T) {
+	tests := []struct {
+		name                string
+		ddsConfig           *ddsv1alpha1.DDSConfig
+		createConfigMap     bool
+		configMapDeviceInfo string
+		configMapDrivers    string
+		wantSpec            types.ComposableDRASpec
+		wantErr             bool
+		expectedErrMsg      string
+		wantCondition       metav1.ConditionStatus
+	}{
+		{
+			name: "valid DDSConfig",
+			ddsConfig: &ddsv1alpha1.DDSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "composable-dra-dds",
+					Generation: 2,
+				},
+				Spec: ddsv1alpha1.DDSConfigSpec{
+					DeviceInfos: []ddsv1alpha1.DeviceInfo{
+						{
+							Index:        1,
+							CDIModelName: "A100 40G",
+							DRAAttributes: map[string]string{
+								"productName": "NVIDIA A100 40GB PCIe",
+							},
+							LabelKeyModel: "composable-a100-40G",
+							DriverName:    "gpu.nvidia.com",
+							K8sDeviceName: "nvidia-a100-40",
+						},
+					},
+					Drivers: []ddsv1alpha1.DriverInfo{
+						{Name: "gpu.nvidia.com", ResourceType: "gpu", UUIDAttribute: "uuid", ProductNameAttribute: "productName"},
+					},
+					LabelPrefix:   "composable.fsastech.com",
+					FabricIDRange: []int{1, 2},
+				},
+			},
+			wantSpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:        1,
+						CDIModelName: "A100 40G",
+						DRAAttributes: map[string]string{
+							"productName": "NVIDIA A100 40GB PCIe",
+						},
+						LabelKeyModel: "composable-a100-40G",
+						DriverName:    "gpu.nvidia.com",
+						K8sDeviceName: "nvidia-a100-40",
+					},
+				},
+				Drivers: []types.DriverInfo{
+					{Name: "gpu.nvidia.com", ResourceType: "gpu", UUIDAttribute: "uuid", ProductNameAttribute: "productName"},
+				},
+				LabelPrefix:   "composable.fsastech.com",
+				FabricIDRange: []int{1, 2},
+			},
+			wantCondition: metav1.ConditionTrue,
+		},
+		{
+			name: "invalid DDSConfig",
+			ddsConfig: &ddsv1alpha1.DDSConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "composable-dra-dds",
+					Generation: 1,
+				},
+				Spec: ddsv1alpha1.DDSConfigSpec{
+					DeviceInfos: []ddsv1alpha1.DeviceInfo{
+						{
+							Index:         1,
+							CDIModelName:  "A100 40G",
+							DriverName:    "gpu.nvidia.com",
+							K8sDeviceName: "nvidia-a100-40",
+						},
+					},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "label-prefix must not be empty",
+			wantCondition:  metav1.ConditionFalse,
+		},
+		{
+			name:            "fallback to ConfigMap",
+			createConfigMap: true,
+			configMapDeviceInfo: `
+- index: 1
+  cdi-model-name: "A100 40G"
+  driver-name: "gpu.nvidia.com"
+  k8s-device-name: "nvidia-a100-40"
+`,
+			wantSpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:         1,
+						CDIModelName:  "A100 40G",
+						DriverName:    "gpu.nvidia.com",
+						K8sDeviceName: "nvidia-a100-40",
+					},
+				},
+				LabelPrefix:   "composable.fsastech.com",
+				FabricIDRange: []int{1},
+			},
+		},
+		{
+			name:            "ConfigMap with drivers",
+			createConfigMap: true,
+			configMapDeviceInfo: `
+- index: 1
+  cdi-model-name: "Agilex 7"
+  driver-name: "fpga.example.com"
+  k8s-device-name: "agilex-7"
+`,
+			configMapDrivers: `
+- name: "fpga.example.com"
+  resource-type: "fpga"
+  uuid-attribute: "serial"
+  product-name-attribute: "board"
+`,
+			wantSpec: types.ComposableDRASpec{
+				DeviceInfos: []types.DeviceInfo{
+					{
+						Index:         1,
+						CDIModelName:  "Agilex 7",
+						DriverName:    "fpga.example.com",
+						K8sDeviceName: "agilex-7",
+					},
+				},
+				Drivers: []types.DriverInfo{
+					{Name: "fpga.example.com", ResourceType: "fpga", UUIDAttribute: "serial", ProductNameAttribute: "board"},
+				},
+				LabelPrefix:   "composable.fsastech.com",
+				FabricIDRange: []int{1},
+			},
+		},
+		{
+			name:            "invalid ConfigMap",
+			createConfigMap: true,
+			configMapDeviceInfo: `
+- index: 1
+  cdi-model-name: "A100 40G"
+  driver-name: "gpu.nvidia.com"
+  k8s-device-name: "nvidia-a100-40"
+  cannot-coexist-with: [3]
+`,
+			wantErr:        true,
+			expectedErrMsg: "cannot-coexist-with refers to unknown index 3",
+		},
+		{
+			name:           "neither DDSConfig nor ConfigMap",
+			wantErr:        true,
+			expectedErrMsg: "failed to get ConfigMap",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := scheme.Scheme
+			if err := ddsv1alpha1.AddToScheme(s); err != nil {
+				t.Fatalf("failed to add scheme: %v", err)
+			}
+
+			builder := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&ddsv1alpha1.DDSConfig{})
+			if tc.ddsConfig != nil {
+				builder = builder.WithObjects(tc.ddsConfig)
+			}
+			if tc.createConfigMap {
+				builder = builder.WithObjects(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "composable-dra-dds",
+						Namespace: "composable-dra",
+					},
+					Data: map[string]string{
+						"device-info":     tc.configMapDeviceInfo,
+						"label-prefix":    "composable.fsastech.com",
+						"fabric-id-range": "[1]",
+						"drivers":         tc.configMapDrivers,
+					},
+				})
+			}
+			fakeClient := builder.Build()
 
-			result, err := GetConfigMapInfo(context.Background(), clientSet)
+			result, err := GetComposableDRASpec(context.Background(), fakeClient)
+
+			if tc.ddsConfig != nil {
+				ddsConfig := &ddsv1alpha1.DDSConfig{}
+				if err := fakeClient.Get(context.Background(), client.ObjectKey{Name: tc.ddsConfig.Name}, ddsConfig); err != nil {
+					t.Fatalf("failed to get DDSConfig: %v", err)
+				}
+				condition := meta.FindStatusCondition(ddsConfig.Status.Conditions, ddsv1alpha1.ConditionTypeReady)
+				if condition == nil || condition.Status != tc.wantCondition {
+					t.Errorf("unexpected Ready condition: %+v, want status %s", condition, tc.wantCondition)
+				}
+				if ddsConfig.Status.ObservedGeneration != tc.ddsConfig.Generation {
+					t.Errorf("observedGeneration = %d, want %d", ddsConfig.Status.ObservedGeneration, tc.ddsConfig.Generation)
+				}
+			}
 
 			if tc.wantErr {
 				if err == nil {
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	resourceapi "k8s.io/api/resource/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Value types of AttributeMatch.
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeInt     = "int"
+	AttributeTypeBool    = "bool"
+	AttributeTypeVersion = "version"
+)
+
+// matchDeviceInfo returns the model of the device catalog that a device
+// published by a DRA driver belongs to. A device belongs to a model when it
+// has all the draAttributes of the model and satisfies all its match rules.
+// Models without any rule never match, so that they cannot claim every device.
+func matchDeviceInfo(composableDRASpec types.ComposableDRASpec, driverName string, device resourceapi.Device) (types.DeviceInfo, error) {
+	if len(device.Attributes) == 0 && len(device.Capacity) == 0 {
+		return types.DeviceInfo{}, fmt.Errorf("device %s of driver %s publishes no attributes", device.Name, driverName)
+	}
+
+	hooks := getDriverHooks(composableDRASpec.Drivers, driverName)
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		if deviceInfo.DriverName != "" && deviceInfo.DriverName != driverName {
+			continue
+		}
+		if len(deviceInfo.DRAAttributes) == 0 && len(deviceInfo.Match) == 0 {
+			continue
+		}
+		if matchesDeviceInfo(deviceInfo, hooks, device) {
+			return deviceInfo, nil
+		}
+	}
+
+	return types.DeviceInfo{}, fmt.Errorf("device %s of driver %s matches no device-info", device.Name, driverName)
+}
+
+func matchesDeviceInfo(deviceInfo types.DeviceInfo, hooks DriverHooks, device resourceapi.Device) bool {
+	for name, value := range deviceInfo.DRAAttributes {
+		var actual string
+		var ok bool
+		if name == "productName" {
+			actual, ok = hooks.ProductName(device.Attributes)
+		} else {
+			actual, _, ok = formatAttribute(device.Attributes, resourceapi.QualifiedName(name))
+		}
+		if !ok || actual != value {
+			return false
+		}
+	}
+
+	for _, match := range deviceInfo.Match {
+		if !matchesRule(match, device) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesRule(match types.AttributeMatch, device resourceapi.Device) bool {
+	if match.Capacity != "" {
+		capacity, ok := device.Capacity[resourceapi.QualifiedName(match.Capacity)]
+		if !ok {
+			return false
+		}
+		expected, err := resource.ParseQuantity(match.Value)
+		if err != nil {
+			return false
+		}
+		return capacity.Value.Cmp(expected) == 0
+	}
+
+	actual, attributeType, ok := formatAttribute(device.Attributes, resourceapi.QualifiedName(match.Attribute))
+	if !ok {
+		return false
+	}
+	if match.Type != "" && match.Type != attributeType {
+		return false
+	}
+
+	if attributeType == AttributeTypeInt {
+		expected, err := strconv.ParseInt(match.Value, 10, 64)
+		if err != nil {
+			return false
+		}
+		return *device.Attributes[resourceapi.QualifiedName(match.Attribute)].IntValue == expected
+	}
+
+	return actual == match.Value
+}
+
+// formatAttribute returns the value of a device attribute as a string,
+// together with its value type.
+func formatAttribute(attributes map[resourceapi.QualifiedName]resourceapi.DeviceAttribute, name resourceapi.QualifiedName) (string, string, bool) {
+	attribute, ok := attributes[name]
+	if !ok {
+		return "", "", false
+	}
+
+	switch {
+	case attribute.StringValue != nil:
+		return *attribute.StringValue, AttributeTypeString, true
+	case attribute.IntValue != nil:
+		return strconv.FormatInt(*attribute.IntValue, 10), AttributeTypeInt, true
+	case attribute.BoolValue != nil:
+		return strconv.FormatBool(*attribute.BoolValue), AttributeTypeBool, true
+	case attribute.VersionValue != nil:
+		return *attribute.VersionValue, AttributeTypeVersion, true
+	default:
+		return "", "", false
+	}
+}
+
+// getDeviceUUID returns the device ID of a device, read from the
+// uuid-attribute of its model or with the UUID hook of its driver.
+func getDeviceUUID(composableDRASpec types.ComposableDRASpec, driverName string, device resourceapi.Device) (string, bool) {
+	if deviceInfo, err := matchDeviceInfo(composableDRASpec, driverName, device); err == nil && deviceInfo.UUIDAttribute != "" {
+		uuid, _, ok := formatAttribute(device.Attributes, resourceapi.QualifiedName(deviceInfo.UUIDAttribute))
+		return uuid, ok
+	}
+
+	return getDriverHooks(composableDRASpec.Drivers, driverName).UUID(device.Attributes)
+}
+
+// validateAttributeMatch checks that a match rule can be evaluated.
+func validateAttributeMatch(match types.AttributeMatch) error {
+	if (match.Attribute == "") == (match.Capacity == "") {
+		return fmt.Errorf("exactly one of attribute and capacity must be set")
+	}
+
+	if match.Capacity != "" {
+		if _, err := resource.ParseQuantity(match.Value); err != nil {
+			return fmt.Errorf("capacity %s: invalid quantity %q: %v", match.Capacity, match.Value, err)
+		}
+		return nil
+	}
+
+	switch match.Type {
+	case "", AttributeTypeString, AttributeTypeVersion:
+	case AttributeTypeInt:
+		if _, err := strconv.ParseInt(match.Value, 10, 64); err != nil {
+			return fmt.Errorf("attribute %s: invalid int %q", match.Attribute, match.Value)
+		}
+	case AttributeTypeBool:
+		if _, err := strconv.ParseBool(match.Value); err != nil {
+			return fmt.Errorf("attribute %s: invalid bool %q", match.Attribute, match.Value)
+		}
+	default:
+		return fmt.Errorf("attribute %s: unknown type %q", match.Attribute, match.Type)
+	}
+
+	return nil
+}
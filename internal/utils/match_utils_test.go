@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	resourceapi "k8s.io/api/resource/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+)
+
+func TestMatchDeviceInfo(t *testing.T) {
+	device := resourceapi.Device{
+		Name: "gpu-0",
+		Attributes: map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"productName":   {StringValue: ptr.To("NVIDIA A100 80GB")},
+			"architecture":  {StringValue: ptr.To("Ampere")},
+			"driverVersion": {VersionValue: ptr.To("550.54.15")},
+			"cudaCores":     {IntValue: ptr.To[int64](6912)},
+			"mig":           {BoolValue: ptr.To(true)},
+		},
+		Capacity: map[resourceapi.QualifiedName]resourceapi.DeviceCapacity{
+			"memory": {Value: resource.MustParse("80Gi")},
+		},
+	}
+
+	testCases := []struct {
+		name          string
+		deviceInfos   []types.DeviceInfo
+		device        resourceapi.Device
+		expectedModel string
+		wantErr       bool
+	}{
+		{
+			name: "all dra attributes",
+			deviceInfos: []types.DeviceInfo{
+				{
+					CDIModelName:  "A100 40G",
+					DRAAttributes: map[string]string{"productName": "NVIDIA A100 80GB", "architecture": "Hopper"},
+				},
+				{
+					CDIModelName:  "A100 80G",
+					DRAAttributes: map[string]string{"productName": "NVIDIA A100 80GB", "architecture": "Ampere"},
+				},
+			},
+			device:        device,
+			expectedModel: "A100 80G",
+		},
+		{
+			name: "typed attribute and capacity rules",
+			deviceInfos: []types.DeviceInfo{
+				{
+					CDIModelName: "A100 40G",
+					Match:        []types.AttributeMatch{{Capacity: "memory", Value: "40Gi"}},
+				},
+				{
+					CDIModelName: "A100 80G",
+					Match: []types.AttributeMatch{
+						{Capacity: "memory", Value: "80Gi"},
+						{Attribute: "cudaCores", Type: "int", Value: "6912"},
+						{Attribute: "mig", Type: "bool", Value: "true"},
+						{Attribute: "driverVersion", Type: "version", Value: "550.54.15"},
+					},
+				},
+			},
+			device:        device,
+			expectedModel: "A100 80G",
+		},
+		{
+			name: "attribute type mismatch",
+			deviceInfos: []types.DeviceInfo{
+				{
+					CDIModelName: "A100 80G",
+					Match:        []types.AttributeMatch{{Attribute: "driverVersion", Type: "string", Value: "550.54.15"}},
+				},
+			},
+			device:  device,
+			wantErr: true,
+		},
+		{
+			name: "other driver",
+			deviceInfos: []types.DeviceInfo{
+				{
+					CDIModelName:  "A100 80G",
+					DriverName:    "gpu.amd.com",
+					DRAAttributes: map[string]string{"productName": "NVIDIA A100 80GB"},
+				},
+			},
+			device:  device,
+			wantErr: true,
+		},
+		{
+			name:        "model without rules",
+			deviceInfos: []types.DeviceInfo{{CDIModelName: "A100 80G"}},
+			device:      device,
+			wantErr:     true,
+		},
+		{
+			name: "device without attributes",
+			deviceInfos: []types.DeviceInfo{
+				{
+					CDIModelName:  "A100 80G",
+					DRAAttributes: map[string]string{"productName": "NVIDIA A100 80GB"},
+				},
+			},
+			device:  resourceapi.Device{Name: "gpu-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := types.ComposableDRASpec{DeviceInfos: tc.deviceInfos}
+
+			result, err := matchDeviceInfo(spec, "gpu.nvidia.com", tc.device)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, but got model %q", result.CDIModelName)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.CDIModelName != tc.expectedModel {
+				t.Errorf("model is incorrect. Got: %q, Want: %q", result.CDIModelName, tc.expectedModel)
+			}
+		})
+	}
+}
+
+func TestGetDeviceUUID(t *testing.T) {
+	device := resourceapi.Device{
+		Name: "gpu-0",
+		Attributes: map[resourceapi.QualifiedName]resourceapi.DeviceAttribute{
+			"productName": {StringValue: ptr.To("NVIDIA A100 80GB")},
+			"uuid":        {StringValue: ptr.To("uuid-0")},
+			"serial":      {IntValue: ptr.To[int64](1234)},
+		},
+	}
+
+	spec := types.ComposableDRASpec{
+		DeviceInfos: []types.DeviceInfo{
+			{
+				CDIModelName:  "A100 80G",
+				DRAAttributes: map[string]string{"productName": "NVIDIA A100 80GB"},
+			},
+		},
+	}
+
+	if uuid, _ := getDeviceUUID(spec, "gpu.nvidia.com", device); uuid != "uuid-0" {
+		t.Errorf("UUID is incorrect. Got: %q, Want: %q", uuid, "uuid-0")
+	}
+
+	spec.DeviceInfos[0].UUIDAttribute = "serial"
+	if uuid, _ := getDeviceUUID(spec, "gpu.nvidia.com", device); uuid != "1234" {
+		t.Errorf("UUID is incorrect. Got: %q, Want: %q", uuid, "1234")
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TaintToBeDeletedByClusterAutoscaler is set by the cluster autoscaler on the
+// nodes it drains before removing them.
+const TaintToBeDeletedByClusterAutoscaler = "ToBeDeletedByClusterAutoscaler"
+
+// IsNodeCordoned reports whether a node accepts no new pods because it is
+// cordoned, drained by the cluster autoscaler or being deleted.
+func IsNodeCordoned(node corev1.Node) bool {
+	if node.Spec.Unschedulable || node.DeletionTimestamp != nil {
+		return true
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == corev1.TaintNodeUnschedulable || taint.Key == TaintToBeDeletedByClusterAutoscaler {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsNodeNotReady reports whether the kubelet of a node is not ready or not
+// reachable. A node that does not report a Ready condition yet is not
+// considered NotReady unless it is tainted so.
+func IsNodeNotReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue {
+			return true
+		}
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == corev1.TaintNodeNotReady || taint.Key == corev1.TaintNodeUnreachable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DeleteOrphanedComposabilityRequests deletes the ComposabilityRequests DDS
+// manages for a node that no longer exists, so that their devices are
+// detached. ComposabilityRequests created by hand are only logged.
+func DeleteOrphanedComposabilityRequests(ctx context.Context, kubeClient client.Client, recorder record.EventRecorder, nodeName, labelPrefix string) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	crList := &cdioperator.ComposabilityRequestList{}
+	if err := kubeClient.List(ctx, crList, client.MatchingFields{ComposabilityRequestNodeIndex: nodeName}); err != nil {
+		return fmt.Errorf("failed to list ComposabilityRequests: %v", err)
+	}
+
+	for i := range crList.Items {
+		cr := &crList.Items[i]
+		if !IsManagedComposabilityRequest(*cr, labelPrefix) {
+			logger.Info("ComposabilityRequest of a deleted node is not managed by DDS, skipping", "name", cr.Name)
+			continue
+		}
+
+		if err := deleteComposabilityRequest(ctx, kubeClient, cr.Name, cr.UID); err != nil {
+			return err
+		}
+		metrics.RecordNodeLifecycleAction(nodeName, metrics.ActionGarbageCollect)
+		if recorder != nil {
+			recorder.Event(ComposabilityRequestReference(cr), corev1.EventTypeNormal, eventReason(ctx, ReasonNodeDeleted),
+				fmt.Sprintf("deleted ComposabilityRequest %s of model %s with %d devices: node %s no longer exists",
+					cr.Name, cr.Spec.Resource.Model, cr.Spec.Resource.Size, nodeName))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestNodeLifecycle(t *testing.T) {
+	now := metav1.Now()
+
+	testCases := []struct {
+		name             string
+		node             corev1.Node
+		expectedCordoned bool
+		expectedNotReady bool
+	}{
+		{
+			name: "ready node",
+			node: corev1.Node{
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+				},
+			},
+		},
+		{
+			name: "node without conditions",
+		},
+		{
+			name:             "cordoned node",
+			node:             corev1.Node{Spec: corev1.NodeSpec{Unschedulable: true}},
+			expectedCordoned: true,
+		},
+		{
+			name: "node drained by the cluster autoscaler",
+			node: corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+				{Key: TaintToBeDeletedByClusterAutoscaler, Effect: corev1.TaintEffectNoSchedule},
+			}}},
+			expectedCordoned: true,
+		},
+		{
+			name:             "node being deleted",
+			node:             corev1.Node{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now}},
+			expectedCordoned: true,
+		},
+		{
+			name: "node with a device taint",
+			node: corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+				{Key: "nvidia.com/gpu", Effect: corev1.TaintEffectNoSchedule},
+			}}},
+		},
+		{
+			name: "NotReady node",
+			node: corev1.Node{
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}},
+				},
+			},
+			expectedNotReady: true,
+		},
+		{
+			name: "unreachable node",
+			node: corev1.Node{Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+				{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute},
+			}}},
+			expectedNotReady: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cordoned := IsNodeCordoned(tc.node); cordoned != tc.expectedCordoned {
+				t.Errorf("IsNodeCordoned is incorrect. Got: %v, Want: %v", cordoned, tc.expectedCordoned)
+			}
+			if notReady := IsNodeNotReady(tc.node); notReady != tc.expectedNotReady {
+				t.Errorf("IsNodeNotReady is incorrect. Got: %v, Want: %v", notReady, tc.expectedNotReady)
+			}
+		})
+	}
+}
+
+func TestDeleteOrphanedComposabilityRequests(t *testing.T) {
+	request := func(name, nodeName string, labels map[string]string) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Type:       "gpu",
+					Size:       2,
+					Model:      "A100 40G",
+					TargetNode: nodeName,
+				},
+			},
+		}
+	}
+	legacy := request("composability-abcde", "node1", nil)
+	legacy.GenerateName = "composability-"
+
+	testCases := []struct {
+		name             string
+		dryRun           bool
+		expectedRequests []string
+		expectedEvents   int
+	}{
+		{
+			name:             "delete managed ComposabilityRequests",
+			expectedRequests: []string{"manual", "other-node"},
+			expectedEvents:   2,
+		},
+		{
+			name:             "dry run",
+			dryRun:           true,
+			expectedRequests: []string{"composability-abcde", "manual", "other-node", "owned"},
+			expectedEvents:   2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects([]runtime.Object{
+				request("owned", "node1", OwnerLabels("composable.test", "node1", "A100 40G")),
+				legacy.DeepCopy(),
+				request("manual", "node1", nil),
+				request("other-node", "node2", OwnerLabels("composable.test", "node2", "A100 40G")),
+			}...).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			ctx := WithDryRun(context.Background(), tc.dryRun)
+			if err := DeleteOrphanedComposabilityRequests(ctx, fakeClient, recorder, "node1", "composable.test"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			crList := &cdioperator.ComposabilityRequestList{}
+			if err := fakeClient.List(context.Background(), crList); err != nil {
+				t.Fatalf("Failed to list ComposabilityRequests: %v", err)
+			}
+			var names []string
+			for _, cr := range crList.Items {
+				names = append(names, cr.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.expectedRequests) {
+				t.Errorf("ComposabilityRequests are incorrect. Got: %v, Want: %v", names, tc.expectedRequests)
+			}
+			if len(recorder.Events) != tc.expectedEvents {
+				t.Errorf("event count is incorrect. Got: %d, Want: %d", len(recorder.Events), tc.expectedEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// legacyOwnerLabelPrefix prefixes the owner labels set by DDS versions that
+// did not derive them from the label-prefix of the configuration.
+const legacyOwnerLabelPrefix = "infra.dds"
+
+// composabilityRequestPrefix prefixes the names of the ComposabilityRequests
+// created by DDS. Earlier versions used it as generateName.
+const composabilityRequestPrefix = "composability-"
+
+// ComposabilityRequestName returns the name of the ComposabilityRequest DDS
+// manages for a model on a node. The name is deterministic, so that creating
+// it twice, for example after a restart between the creation and the next
+// list, does not create a second one.
+func ComposabilityRequestName(nodeName, model string) string {
+	return composabilityRequestPrefix + hashedName(validation.DNS1123LabelMaxLength-len(composabilityRequestPrefix), nodeName, model)
+}
+
+// OwnerNodeLabel and OwnerModelLabel return the keys of the labels
+// identifying the node and model a ComposabilityRequest created by DDS is
+// managed for. DDS manages at most one ComposabilityRequest per node and
+// model; ComposabilityRequests without these labels are left alone.
+func OwnerNodeLabel(labelPrefix string) string {
+	return labelPrefix + "/node"
+}
+
+func OwnerModelLabel(labelPrefix string) string {
+	return labelPrefix + "/model"
+}
+
+// OwnerLabelKeys returns the keys of the owner labels DDS reads: the ones of
+// the label prefix and the ones of earlier DDS versions.
+func OwnerLabelKeys(labelPrefix string) []string {
+	keys := []string{OwnerNodeLabel(legacyOwnerLabelPrefix), OwnerModelLabel(legacyOwnerLabelPrefix)}
+	if labelPrefix != "" && labelPrefix != legacyOwnerLabelPrefix {
+		keys = append(keys, OwnerNodeLabel(labelPrefix), OwnerModelLabel(labelPrefix))
+	}
+
+	return keys
+}
+
+// OwnerLabels returns the owner labels of the ComposabilityRequest of a model
+// on a node.
+func OwnerLabels(labelPrefix, nodeName, model string) map[string]string {
+	return map[string]string{
+		OwnerNodeLabel(labelPrefix):  ownerLabelValue(nodeName),
+		OwnerModelLabel(labelPrefix): ownerLabelValue(model),
+	}
+}
+
+// IsOwnedComposabilityRequest reports whether a ComposabilityRequest carries
+// the owner labels of the node and model of its spec.
+func IsOwnedComposabilityRequest(cr cdioperator.ComposabilityRequest, labelPrefix string) bool {
+	return cr.Labels[OwnerNodeLabel(labelPrefix)] == ownerLabelValue(cr.Spec.Resource.TargetNode) &&
+		cr.Labels[OwnerModelLabel(labelPrefix)] == ownerLabelValue(cr.Spec.Resource.Model)
+}
+
+// IsLegacyComposabilityRequest reports whether a ComposabilityRequest was
+// created by an earlier DDS version and is to be adopted: it carries the
+// owner labels of the node and model of its spec with the infra.dds/ prefix,
+// or no owner label at all and the generateName DDS used.
+func IsLegacyComposabilityRequest(cr cdioperator.ComposabilityRequest, labelPrefix string) bool {
+	if IsOwnedComposabilityRequest(cr, labelPrefix) {
+		return false
+	}
+	if IsOwnedComposabilityRequest(cr, legacyOwnerLabelPrefix) {
+		return true
+	}
+
+	for _, key := range OwnerLabelKeys(labelPrefix) {
+		if _, ok := cr.Labels[key]; ok {
+			return false
+		}
+	}
+
+	return cr.GenerateName == composabilityRequestPrefix
+}
+
+// IsManagedComposabilityRequest reports whether DDS manages a
+// ComposabilityRequest: it is owned, or was created by an earlier DDS version
+// and is adopted.
+func IsManagedComposabilityRequest(cr cdioperator.ComposabilityRequest, labelPrefix string) bool {
+	return IsOwnedComposabilityRequest(cr, labelPrefix) || IsLegacyComposabilityRequest(cr, labelPrefix)
+}
+
+// ownerLabelValue returns value when it is a valid label value. Other values,
+// such as models with spaces, are replaced by a readable prefix and a hash.
+func ownerLabelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+
+	return hashedName(validation.LabelValueMaxLength, value)
+}
+
+// hashedName returns a DNS label of at most maxLength characters made of the
+// lowercased alphanumeric characters of parts and a hash of parts, so that
+// different parts give different names.
+func hashedName(maxLength int, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	hash := hex.EncodeToString(sum[:])[:8]
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.Join(parts, "-")) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+
+	prefix := strings.Trim(b.String(), "-")
+	if maxPrefix := maxLength - len(hash) - 1; len(prefix) > maxPrefix {
+		prefix = strings.TrimRight(prefix[:maxPrefix], "-")
+	}
+	if prefix == "" {
+		return hash
+	}
+
+	return prefix + "-" + hash
+}
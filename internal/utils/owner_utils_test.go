@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func TestComposabilityRequestName(t *testing.T) {
+	testCases := []struct {
+		name         string
+		nodeName     string
+		model        string
+		expectedName string
+	}{
+		{
+			name:         "readable name",
+			nodeName:     "node1",
+			model:        "A100 40G",
+			expectedName: "composability-node1-a100-40g-9f29c311",
+		},
+		{
+			name:     "long node name",
+			nodeName: strings.Repeat("node", 30),
+			model:    "A100 40G",
+		},
+		{
+			name:     "no alphanumeric characters",
+			nodeName: "...",
+			model:    "_",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name := ComposabilityRequestName(tc.nodeName, tc.model)
+			if tc.expectedName != "" && name != tc.expectedName {
+				t.Errorf("name is incorrect. Got: %q, Want: %q", name, tc.expectedName)
+			}
+			if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+				t.Errorf("name %q is not a DNS label: %v", name, errs)
+			}
+			if again := ComposabilityRequestName(tc.nodeName, tc.model); again != name {
+				t.Errorf("name is not deterministic. Got: %q and %q", name, again)
+			}
+		})
+	}
+
+	if ComposabilityRequestName("a-b", "c") == ComposabilityRequestName("a", "b-c") {
+		t.Errorf("different nodes and models give the same name %q", ComposabilityRequestName("a-b", "c"))
+	}
+}
+
+func TestOwnerLabels(t *testing.T) {
+	expected := map[string]string{
+		"composable.test/node":  "node1",
+		"composable.test/model": "a100-40g-67b6719f",
+	}
+	if labels := OwnerLabels("composable.test", "node1", "A100 40G"); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("labels are incorrect. Got: %v, Want: %v", labels, expected)
+	}
+
+	for _, value := range OwnerLabels("composable.test", strings.Repeat("node", 30), "NVIDIA A100-SXM4-40GB (PCIe)") {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			t.Errorf("label value %q is invalid: %v", value, errs)
+		}
+	}
+}
+
+func TestIsManagedComposabilityRequest(t *testing.T) {
+	labelPrefix := "composable.test"
+	request := func(name, generateName string, labels map[string]string) cdioperator.ComposabilityRequest {
+		return cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:         name,
+				GenerateName: generateName,
+				Labels:       labels,
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Type:       "gpu",
+					Model:      "A100 40G",
+					TargetNode: "node1",
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		request         cdioperator.ComposabilityRequest
+		expectedOwned   bool
+		expectedLegacy  bool
+		expectedManaged bool
+	}{
+		{
+			name:            "owned",
+			request:         request(ComposabilityRequestName("node1", "A100 40G"), "", OwnerLabels(labelPrefix, "node1", "A100 40G")),
+			expectedOwned:   true,
+			expectedManaged: true,
+		},
+		{
+			name:            "owned duplicate",
+			request:         request("composability-abcde", "composability-", OwnerLabels(labelPrefix, "node1", "A100 40G")),
+			expectedOwned:   true,
+			expectedManaged: true,
+		},
+		{
+			name:            "created by an earlier version",
+			request:         request("composability-abcde", "composability-", nil),
+			expectedLegacy:  true,
+			expectedManaged: true,
+		},
+		{
+			name:            "labeled by an earlier version",
+			request:         request(ComposabilityRequestName("node1", "A100 40G"), "", OwnerLabels(legacyOwnerLabelPrefix, "node1", "A100 40G")),
+			expectedLegacy:  true,
+			expectedManaged: true,
+		},
+		{
+			name:    "labeled by an earlier version for another node",
+			request: request("composability-abcde", "composability-", OwnerLabels(legacyOwnerLabelPrefix, "node2", "A100 40G")),
+		},
+		{
+			name:    "created by hand",
+			request: request("manual", "", nil),
+		},
+		{
+			name:    "labeled for another node",
+			request: request("composability-abcde", "composability-", OwnerLabels(labelPrefix, "node2", "A100 40G")),
+		},
+		{
+			name:    "labeled for another model",
+			request: request("composability-abcde", "", OwnerLabels(labelPrefix, "node1", "H100")),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if owned := IsOwnedComposabilityRequest(tc.request, labelPrefix); owned != tc.expectedOwned {
+				t.Errorf("IsOwnedComposabilityRequest is incorrect. Got: %v, Want: %v", owned, tc.expectedOwned)
+			}
+			if legacy := IsLegacyComposabilityRequest(tc.request, labelPrefix); legacy != tc.expectedLegacy {
+				t.Errorf("IsLegacyComposabilityRequest is incorrect. Got: %v, Want: %v", legacy, tc.expectedLegacy)
+			}
+			if managed := IsManagedComposabilityRequest(tc.request, labelPrefix); managed != tc.expectedManaged {
+				t.Errorf("IsManagedComposabilityRequest is incorrect. Got: %v, Want: %v", managed, tc.expectedManaged)
+			}
+		})
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
-	resourceapi "k8s.io/api/resource/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
@@ -19,7 +22,11 @@ import (
 
 const maxRetries = 2
 
-func patchNodeLabel(clientset kubernetes.Interface, nodeName string, addLabels, deleteLabels []string) error {
+func patchNodeLabel(ctx context.Context, clientset kubernetes.Interface, nodeName string, addLabels, deleteLabels []string) error {
+	if skipInDryRun(ctx, MutationPatchNodeLabels, "node", nodeName, "addLabels", addLabels, "deleteLabels", deleteLabels) {
+		return nil
+	}
+
 	var lastErr error
 
 	labelsPatch := make(map[string]interface{})
@@ -42,8 +49,8 @@ func patchNodeLabel(clientset kubernetes.Interface, nodeName string, addLabels,
 	}
 
 	for range maxRetries {
-		_, err = clientset.CoreV1().Nodes().Patch(
-			context.TODO(),
+		node, err := clientset.CoreV1().Nodes().Patch(
+			ctx,
 			nodeName,
 			k8stypes.StrategicMergePatchType,
 			patchBytes,
@@ -51,6 +58,7 @@ func patchNodeLabel(clientset kubernetes.Interface, nodeName string, addLabels,
 		)
 
 		if err == nil {
+			recordSelfWrite(ctx, node)
 			return nil
 		}
 
@@ -99,17 +107,23 @@ func PatchComposableResourceAnnotation(ctx context.Context, kubeClient client.Cl
 			}
 		}
 
+		if skipInDryRun(ctx, MutationPatchComposableResourceAnnotation, "name", resourceName, "key", key, "value", value) {
+			return nil
+		}
+
+		patchedCR := &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: resourceName,
+			},
+		}
 		err := kubeClient.Patch(
 			ctx,
-			&cdioperator.ComposableResource{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: resourceName,
-				},
-			},
+			patchedCR,
 			client.RawPatch(k8stypes.StrategicMergePatchType, patchBytes),
 		)
 
 		if err == nil {
+			recordSelfWrite(ctx, patchedCR)
 			return nil
 		}
 
@@ -123,7 +137,9 @@ func PatchComposableResourceAnnotation(ctx context.Context, kubeClient client.Cl
 	return fmt.Errorf("max retries (%d) reached, last error: %v", maxRetries, lastErr)
 }
 
-func PatchComposabilityRequestSize(ctx context.Context, kubeClient client.Client, requestName string, count int64) error {
+// PatchComposabilityRequestSize sets the size of a ComposabilityRequest and
+// records the resize in its annotations, whose keys start with labelPrefix.
+func PatchComposabilityRequestSize(ctx context.Context, kubeClient client.Client, requestName string, count int64, labelPrefix string) error {
 	logger := ctrl.LoggerFrom(ctx)
 
 	logger.Info("Start patch ComposabilityRequest size",
@@ -143,6 +159,10 @@ func PatchComposabilityRequestSize(ctx context.Context, kubeClient client.Client
 			return fmt.Errorf("failed to get ComposabilityRequest: %v", err)
 		}
 
+		if skipInDryRun(ctx, MutationResizeComposabilityRequest, "name", requestName, "size", existingCR.Spec.Resource.Size, "patchSize", count) {
+			return nil
+		}
+
 		patchOpts := []map[string]interface{}{
 			{
 				"op":    "replace",
@@ -150,6 +170,24 @@ func PatchComposabilityRequestSize(ctx context.Context, kubeClient client.Client
 				"value": count,
 			},
 		}
+		// The resize is recorded in the annotations, so that the next
+		// resizes wait for it to settle.
+		annotations := resizeAnnotations(existingCR.Spec.Resource.Size, count, time.Now(), labelPrefix)
+		if existingCR.Annotations == nil {
+			patchOpts = append(patchOpts, map[string]interface{}{
+				"op":    "add",
+				"path":  "/metadata/annotations",
+				"value": annotations,
+			})
+		} else {
+			for _, key := range []string{ResizedAtAnnotation(labelPrefix), ResizeDirectionAnnotation(labelPrefix)} {
+				patchOpts = append(patchOpts, map[string]interface{}{
+					"op":    "add",
+					"path":  "/metadata/annotations/" + strings.ReplaceAll(key, "/", "~1"),
+					"value": annotations[key],
+				})
+			}
+		}
 
 		patchBytes, err := json.Marshal(patchOpts)
 		if err != nil {
@@ -163,23 +201,76 @@ func PatchComposabilityRequestSize(ctx context.Context, kubeClient client.Client
 			}
 			return fmt.Errorf("failed to patch ComposabilityRequest: %v", err)
 		}
+		recordSelfWrite(ctx, existingCR)
 		return nil
 	}
 	return fmt.Errorf("max retries (%d) reached, last error: %v", maxRetries, lastErr)
 }
 
-func PatchResourceClaimDeviceConditions(ctx context.Context, kubeClient client.Client, name, namespace, conditionType string) error {
+// PatchComposabilityRequestLabels sets labels on a ComposabilityRequest,
+// keeping its other labels.
+func PatchComposabilityRequestLabels(ctx context.Context, kubeClient client.Client, requestName string, labels map[string]string) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	logger.Info("Start patch ComposabilityRequest labels",
+		"name", requestName,
+		"labels", labels)
+
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"labels": labels,
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("patch marshal error: %w", err)
+	}
+
+	existingCR := &cdioperator.ComposabilityRequest{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: requestName}, existingCR); err != nil {
+		return fmt.Errorf("failed to get ComposabilityRequest: %v", err)
+	}
+
+	current := true
+	for key, value := range labels {
+		if existingCR.Labels[key] != value {
+			current = false
+		}
+	}
+	if current {
+		return nil
+	}
+
+	if skipInDryRun(ctx, MutationLabelComposabilityRequest, "name", requestName, "labels", labels) {
+		return nil
+	}
+
+	// A merge patch only touches the given labels, so it cannot conflict.
+	if err := kubeClient.Patch(ctx, existingCR, client.RawPatch(k8stypes.MergePatchType, patchBytes)); err != nil {
+		return fmt.Errorf("failed to patch ComposabilityRequest: %v", err)
+	}
+	recordSelfWrite(ctx, existingCR)
+
+	return nil
+}
+
+// PatchResourceClaimDeviceConditions sets the condition conditionType on every
+// device of a ResourceClaim. Reason, message and the observed generation are
+// updated even when the status of the condition does not change; the claim is
+// not patched when the conditions are already current.
+func PatchResourceClaimDeviceConditions(ctx context.Context, kubeClient client.Client, name, namespace, conditionType string, reason types.ConditionReason, message string) error {
 	logger := ctrl.LoggerFrom(ctx)
 
 	logger.Info("Start patch ResourceClaim DeviceConditions",
 		"name", name,
 		"namespace", namespace,
-		"conditionType", conditionType)
+		"conditionType", conditionType,
+		"reason", reason)
 
 	var lastErr error
 
 	for range maxRetries {
-		existingRC := &resourceapi.ResourceClaim{}
+		existingRC := NewResourceClaim()
 		err := kubeClient.Get(
 			ctx,
 			k8stypes.NamespacedName{Name: name, Namespace: namespace},
@@ -189,102 +280,269 @@ func PatchResourceClaimDeviceConditions(ctx context.Context, kubeClient client.C
 			return fmt.Errorf("failed to get ResourceClaim: %v", err)
 		}
 
-		modifiedRC := existingRC.DeepCopy()
-
-		for i := range modifiedRC.Status.Devices {
-			device := &modifiedRC.Status.Devices[i]
+		rc, err := ToResourceClaim(existingRC)
+		if err != nil {
+			return err
+		}
 
-			newCondition := metav1.Condition{
+		// Only the conditions of the devices are patched, so the other
+		// fields of the device statuses need not be converted. The resource
+		// version turns a concurrent update into a conflict.
+		operations := []map[string]any{
+			{"op": "replace", "path": "/metadata/resourceVersion", "value": rc.ResourceVersion},
+		}
+		for i, device := range rc.Status.Devices {
+			conditions := slices.Clone(device.Conditions)
+			if meta.SetStatusCondition(&conditions, metav1.Condition{
 				Type:               conditionType,
 				Status:             metav1.ConditionTrue,
-				LastTransitionTime: metav1.NewTime(time.Now()),
+				Reason:             string(reason),
+				Message:            message,
+				ObservedGeneration: rc.Generation,
+			}) {
+				operations = append(operations, map[string]any{
+					"op":    "add",
+					"path":  fmt.Sprintf("/status/devices/%d/conditions", i),
+					"value": conditions,
+				})
 			}
+		}
 
-			conditionExists := false
-			for j, existingCond := range device.Conditions {
-				if existingCond.Type == conditionType {
-					if existingCond.Status != newCondition.Status {
-						device.Conditions[j] = newCondition
-					}
-					conditionExists = true
-					break
-				}
-			}
+		if len(operations) == 1 {
+			return nil
+		}
 
-			if !conditionExists {
-				device.Conditions = append(device.Conditions, newCondition)
-			}
+		if skipInDryRun(ctx, MutationPatchResourceClaimConditions, "name", name, "namespace", namespace, "conditionType", conditionType, "reason", reason, "message", message) {
+			return nil
+		}
+
+		// The device statuses are at the same path in all resource.k8s.io
+		// versions, so the same patch applies to the version read.
+		data, err := json.Marshal(operations)
+		if err != nil {
+			return fmt.Errorf("failed to build ResourceClaim patch: %v", err)
 		}
 
-		patch := client.StrategicMergeFrom(existingRC.DeepCopy())
-		if err := kubeClient.Patch(ctx, modifiedRC, patch); err != nil {
+		if err := kubeClient.Patch(ctx, existingRC, client.RawPatch(k8stypes.JSONPatchType, data)); err != nil {
 			if apierrors.IsConflict(err) {
 				lastErr = err
 				continue
 			}
 			return fmt.Errorf("failed to patch ResourceClaim status: %v", err)
 		}
+		recordSelfWrite(ctx, existingRC)
 		return nil
 	}
 	return fmt.Errorf("max retries (%d) reached, last error: %v", maxRetries, lastErr)
 }
 
-func UpdateNodeLabel(ctx context.Context, kubeClient client.Client, clientSet kubernetes.Interface, nodeName string, composableDRASpec types.ComposableDRASpec) error {
+// PatchDDSConfigStatus records the validation result of the DDSConfig in its
+// Ready condition. Nothing is written when the condition is already current.
+func PatchDDSConfigStatus(ctx context.Context, kubeClient client.Client, ddsConfig *ddsv1alpha1.DDSConfig, validationErr error) error {
 	logger := ctrl.LoggerFrom(ctx)
-	logger.V(1).Info("Start updating Node label")
 
-	var installedDevices []string
+	condition := metav1.Condition{
+		Type:               ddsv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ddsv1alpha1.ReasonValid,
+		Message:            "DDSConfig is valid",
+		ObservedGeneration: ddsConfig.Generation,
+	}
+	if validationErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ddsv1alpha1.ReasonInvalidSpec
+		condition.Message = validationErr.Error()
+	}
 
-	composabilityRequestList := &cdioperator.ComposabilityRequestList{}
-	if err := kubeClient.List(ctx, composabilityRequestList, &client.ListOptions{}); err != nil {
-		return fmt.Errorf("failed to list composabilityRequestList: %v", err)
+	modified := ddsConfig.DeepCopy()
+	changed := meta.SetStatusCondition(&modified.Status.Conditions, condition)
+	if !changed && modified.Status.ObservedGeneration == ddsConfig.Generation {
+		return nil
 	}
+	modified.Status.ObservedGeneration = ddsConfig.Generation
 
-	for _, cr := range composabilityRequestList.Items {
-		if cr.Spec.Resource.TargetNode == nodeName {
-			if cr.Spec.Resource.Size > 0 {
-				if notIn(cr.Spec.Resource.Model, installedDevices) {
-					installedDevices = append(installedDevices, cr.Spec.Resource.Model)
-				}
-			}
-		}
+	if skipInDryRun(ctx, MutationPatchDDSConfigStatus, "name", ddsConfig.Name, "reason", condition.Reason) {
+		return nil
 	}
 
-	resourceList := &cdioperator.ComposableResourceList{}
-	if err := kubeClient.List(ctx, resourceList, &client.ListOptions{}); err != nil {
-		return fmt.Errorf("failed to list ComposableResourceList: %v", err)
+	logger.Info("Start patch DDSConfig status",
+		"name", ddsConfig.Name,
+		"reason", condition.Reason)
+
+	if err := kubeClient.Status().Patch(ctx, modified, client.MergeFrom(ddsConfig)); err != nil {
+		return fmt.Errorf("failed to patch DDSConfig status: %v", err)
 	}
 
-	for _, rs := range resourceList.Items {
-		if rs.Spec.TargetNode == nodeName {
-			if rs.Status.State == "Online" {
-				if notIn(rs.Spec.Model, installedDevices) {
-					installedDevices = append(installedDevices, rs.Spec.Model)
-				}
-			}
+	return nil
+}
+
+// PatchNodeScalingPolicyStatus records the validation result of a
+// NodeScalingPolicy in its Ready condition, and the error that keeps a node
+// selected by it from being scaled, or removes the error of the node when
+// nodeErr is nil. Nothing is written when the status is already current.
+func PatchNodeScalingPolicyStatus(ctx context.Context, kubeClient client.Client, policy *ddsv1alpha1.NodeScalingPolicy, validationErr error, nodeName string, nodeErr error) error {
+	return patchNodeScalingPolicyStatus(ctx, kubeClient, policy, func(modified *ddsv1alpha1.NodeScalingPolicy) bool {
+		condition := metav1.Condition{
+			Type:               ddsv1alpha1.ConditionTypeReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             ddsv1alpha1.ReasonValid,
+			Message:            "NodeScalingPolicy is valid",
+			ObservedGeneration: modified.Generation,
+		}
+		if validationErr != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = ddsv1alpha1.ReasonInvalidSpec
+			condition.Message = validationErr.Error()
 		}
-	}
 
-	var addLabels, deleteLabels []string
-	var notCoexistID []int
+		changed := meta.SetStatusCondition(&modified.Status.Conditions, condition)
+		if modified.Status.ObservedGeneration != modified.Generation {
+			modified.Status.ObservedGeneration = modified.Generation
+			changed = true
+		}
+		if setNodeScalingPolicyNodeError(&modified.Status, nodeName, nodeErr) {
+			changed = true
+		}
 
-	for _, device := range installedDevices {
-		for _, deviceInfo := range composableDRASpec.DeviceInfos {
-			if device == deviceInfo.CDIModelName {
-				notCoexistID = append(notCoexistID, deviceInfo.CannotCoexistWith...)
-			}
+		return changed
+	})
+}
+
+// patchNodeScalingPolicyStatus patches the status of a NodeScalingPolicy when
+// update changes it. The nodes selected by a policy are reconciled in
+// parallel and patch the same status, so the patch carries the resource
+// version and is retried on the current policy after a conflict.
+func patchNodeScalingPolicyStatus(ctx context.Context, kubeClient client.Client, policy *ddsv1alpha1.NodeScalingPolicy, update func(*ddsv1alpha1.NodeScalingPolicy) bool) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	var lastErr error
+
+	for range maxRetries {
+		modified := policy.DeepCopy()
+		if !update(modified) {
+			return nil
+		}
+
+		if skipInDryRun(ctx, MutationPatchNodeScalingPolicyStatus, "name", policy.Name, "nodeErrors", len(modified.Status.NodeErrors)) {
+			return nil
+		}
+
+		logger.Info("Start patch NodeScalingPolicy status",
+			"name", policy.Name,
+			"nodeErrors", len(modified.Status.NodeErrors))
+
+		err := kubeClient.Status().Patch(ctx, modified, client.MergeFromWithOptions(policy, client.MergeFromWithOptimisticLock{}))
+		if err == nil {
+			return nil
+		}
+		if !apierrors.IsConflict(err) {
+			return fmt.Errorf("failed to patch NodeScalingPolicy status: %v", err)
+		}
+		lastErr = err
+
+		policy = &ddsv1alpha1.NodeScalingPolicy{}
+		if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: modified.Name}, policy); err != nil {
+			return fmt.Errorf("failed to get NodeScalingPolicy: %v", err)
 		}
 	}
+	return fmt.Errorf("max retries (%d) reached, last error: %v", maxRetries, lastErr)
+}
 
-	for _, deviceInfo := range composableDRASpec.DeviceInfos {
-		if notIn(deviceInfo.Index, notCoexistID) {
-			label := composableDRASpec.LabelPrefix + "/" + deviceInfo.K8sDeviceName
-			addLabels = append(addLabels, label)
-		} else {
-			label := composableDRASpec.LabelPrefix + "/" + deviceInfo.K8sDeviceName
-			deleteLabels = append(deleteLabels, label)
+// setNodeScalingPolicyNodeError sets the error of a node in the status of a
+// NodeScalingPolicy, or removes it when err is nil, and reports whether the
+// status changed. The errors are kept sorted by node.
+func setNodeScalingPolicyNodeError(status *ddsv1alpha1.NodeScalingPolicyStatus, nodeName string, err error) bool {
+	i, found := slices.BinarySearchFunc(status.NodeErrors, nodeName, func(nodeError ddsv1alpha1.NodeScalingPolicyNodeError, name string) int {
+		return strings.Compare(nodeError.Node, name)
+	})
+
+	switch {
+	case err == nil && found:
+		status.NodeErrors = slices.Delete(status.NodeErrors, i, i+1)
+	case err == nil:
+		return false
+	case found && status.NodeErrors[i].Message == err.Error():
+		return false
+	case found:
+		status.NodeErrors[i].Message = err.Error()
+	default:
+		status.NodeErrors = slices.Insert(status.NodeErrors, i, ddsv1alpha1.NodeScalingPolicyNodeError{Node: nodeName, Message: err.Error()})
+	}
+
+	return true
+}
+
+// PatchDeviceQuotaStatus records the validation result of a DeviceQuota in
+// its Ready condition. Nothing is written when the condition is already
+// current.
+func PatchDeviceQuotaStatus(ctx context.Context, kubeClient client.Client, quota *ddsv1alpha1.DeviceQuota, validationErr error) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	condition := metav1.Condition{
+		Type:               ddsv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ddsv1alpha1.ReasonValid,
+		Message:            "DeviceQuota is valid",
+		ObservedGeneration: quota.Generation,
+	}
+	if validationErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ddsv1alpha1.ReasonInvalidSpec
+		condition.Message = validationErr.Error()
+	}
+
+	modified := quota.DeepCopy()
+	changed := meta.SetStatusCondition(&modified.Status.Conditions, condition)
+	if !changed && modified.Status.ObservedGeneration == quota.Generation {
+		return nil
+	}
+	modified.Status.ObservedGeneration = quota.Generation
+
+	if skipInDryRun(ctx, MutationPatchDeviceQuotaStatus, "name", quota.Name, "reason", condition.Reason) {
+		return nil
+	}
+
+	logger.Info("Start patch DeviceQuota status",
+		"name", quota.Name,
+		"reason", condition.Reason)
+
+	if err := kubeClient.Status().Patch(ctx, modified, client.MergeFrom(quota)); err != nil {
+		return fmt.Errorf("failed to patch DeviceQuota status: %v", err)
+	}
+
+	return nil
+}
+
+// PatchDeviceQuotaUsage records the devices used per model in the status of
+// a DeviceQuota. Nothing is written when the usage is already current.
+func PatchDeviceQuotaUsage(ctx context.Context, kubeClient client.Client, quotaName string, used []ddsv1alpha1.DeviceQuotaUsage) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	quota := &ddsv1alpha1.DeviceQuota{}
+	if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: quotaName}, quota); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
 		}
+		return fmt.Errorf("failed to get DeviceQuota: %v", err)
+	}
+
+	if slices.Equal(quota.Status.Used, used) {
+		return nil
+	}
+
+	if skipInDryRun(ctx, MutationPatchDeviceQuotaStatus, "name", quotaName, "used", used) {
+		return nil
+	}
+
+	logger.Info("Start patch DeviceQuota usage",
+		"name", quotaName,
+		"used", used)
+
+	modified := quota.DeepCopy()
+	modified.Status.Used = used
+	if err := kubeClient.Status().Patch(ctx, modified, client.MergeFrom(quota)); err != nil {
+		return fmt.Errorf("failed to patch DeviceQuota status: %v", err)
 	}
 
-	return patchNodeLabel(clientSet, nodeName, addLabels, deleteLabels)
+	return nil
 }
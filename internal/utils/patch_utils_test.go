@@ -7,224 +7,15 @@ import (
 
 	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
 	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
-	corev1 "k8s.io/api/core/v1"
 	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stypes "k8s.io/apimachinery/pkg/types"
-	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
-func TestUpdateNodeLabel(t *testing.T) {
-	testCases := []struct {
-		name                 string
-		existingRequestList  *cdioperator.ComposabilityRequestList
-		existingResourceList *cdioperator.ComposableResourceList
-		existingNode         *corev1.Node
-		nodeName             string
-		composableDRASpec    types.ComposableDRASpec
-		expectedNodeLabels   map[string]string
-		wantErr              bool
-		expectedErrMsg       string
-	}{
-		{
-			name:           "node not exist",
-			nodeName:       "test",
-			wantErr:        true,
-			expectedErrMsg: "patch failed: nodes \"test\" not found",
-		},
-		{
-			name: "update node label successfully",
-			existingRequestList: &cdioperator.ComposabilityRequestList{
-				Items: []cdioperator.ComposabilityRequest{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "request1",
-						},
-						Spec: cdioperator.ComposabilityRequestSpec{
-							Resource: cdioperator.ScalarResourceDetails{
-								Size:       2,
-								Model:      "A100 40G",
-								TargetNode: "test",
-							},
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "request2",
-						},
-						Spec: cdioperator.ComposabilityRequestSpec{
-							Resource: cdioperator.ScalarResourceDetails{
-								Size:       0,
-								Model:      "A100 80G",
-								TargetNode: "test",
-							},
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "request3",
-						},
-						Spec: cdioperator.ComposabilityRequestSpec{
-							Resource: cdioperator.ScalarResourceDetails{
-								Size:       2,
-								Model:      "H100",
-								TargetNode: "test2",
-							},
-						},
-					},
-				},
-			},
-			existingResourceList: &cdioperator.ComposableResourceList{
-				Items: []cdioperator.ComposableResource{
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "resource1",
-						},
-						Spec: cdioperator.ComposableResourceSpec{
-							Model:      "A100 40G",
-							TargetNode: "test",
-						},
-						Status: cdioperator.ComposableResourceStatus{
-							State: "Online",
-						},
-					},
-					{
-						ObjectMeta: metav1.ObjectMeta{
-							Name: "resource2",
-						},
-						Spec: cdioperator.ComposableResourceSpec{
-							Model:      "A100 80G",
-							TargetNode: "test",
-						},
-						Status: cdioperator.ComposableResourceStatus{
-							State: "Deleting",
-						},
-					},
-				},
-			},
-			existingNode: &corev1.Node{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "test",
-					Labels: map[string]string{
-						"composable.fsastech.com/nvidia-a100-80g": "true",
-					},
-				},
-			},
-			expectedNodeLabels: map[string]string{
-				"composable.fsastech.com/nvidia-a100-40g": "true",
-				"composable.fsastech.com/cxl-mem":         "true",
-			},
-			composableDRASpec: types.ComposableDRASpec{
-				DeviceInfos: []types.DeviceInfo{
-					{
-						Index:        1,
-						CDIModelName: "A100 40G",
-						DRAAttributes: map[string]string{
-							"productName": "NVIDIA A100 40GB PCIe",
-						},
-						LabelKeyModel:     "composable-a100-40G",
-						DriverName:        "gpu.nvidia.com",
-						K8sDeviceName:     "nvidia-a100-40g",
-						CannotCoexistWith: []int{2, 3},
-					},
-					{
-						Index:        2,
-						CDIModelName: "A100 80G",
-						DRAAttributes: map[string]string{
-							"productName": "NVIDIA A100 80GB PCIe",
-						},
-						LabelKeyModel:     "composable-a100-80G",
-						DriverName:        "gpu.nvidia.com",
-						K8sDeviceName:     "nvidia-a100-80g",
-						CannotCoexistWith: []int{1, 3},
-					},
-					{
-						Index:        3,
-						CDIModelName: "H100",
-						DRAAttributes: map[string]string{
-							"productName": "NVIDIA H100 PCIe",
-						},
-						LabelKeyModel:     "composable-h100",
-						DriverName:        "gpu.nvidia.com",
-						K8sDeviceName:     "nvidia-h100",
-						CannotCoexistWith: []int{2, 3},
-					},
-					{
-						Index:        4,
-						CDIModelName: "CXL-mem",
-						DRAAttributes: map[string]string{
-							"productName": "CXL mem",
-						},
-						DriverName:        "cxl-mem",
-						LabelKeyModel:     "cxl-mem",
-						K8sDeviceName:     "cxl-mem",
-						CannotCoexistWith: []int{2, 3},
-					},
-				},
-				LabelPrefix:   "composable.fsastech.com",
-				FabricIDRange: []int{1, 2, 3},
-			},
-			nodeName: "test",
-			wantErr:  false,
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			kubeObjects := []runtime.Object{}
-			if tc.existingNode != nil {
-				kubeObjects = append(kubeObjects, tc.existingNode)
-			}
-
-			kubeClient := k8sfake.NewClientset(kubeObjects...)
-
-			clientObjects := []runtime.Object{}
-			if tc.existingRequestList != nil {
-				for i := range tc.existingRequestList.Items {
-					clientObjects = append(clientObjects, &tc.existingRequestList.Items[i])
-				}
-			}
-			if tc.existingResourceList != nil {
-				for i := range tc.existingResourceList.Items {
-					clientObjects = append(clientObjects, &tc.existingResourceList.Items[i])
-				}
-			}
-
-			s := scheme.Scheme
-			s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposabilityRequest{}, &cdioperator.ComposabilityRequestList{})
-			s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposableResource{}, &cdioperator.ComposableResourceList{})
-
-			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clientObjects...).Build()
-
-			err := UpdateNodeLabel(context.Background(), fakeClient, kubeClient, tc.nodeName, tc.composableDRASpec)
-
-			if tc.wantErr {
-				if err == nil {
-					t.Fatalf("Expected error, but got nil")
-				}
-				if err.Error() != tc.expectedErrMsg {
-					t.Errorf("Error message is incorrect. Got: %q, Want: %q", err.Error(), tc.expectedErrMsg)
-				}
-				return
-			}
-			if err != nil {
-				t.Fatalf("Unexpected error: %v", err)
-			}
-			updatedNode, err := kubeClient.CoreV1().Nodes().Get(context.Background(), tc.nodeName, metav1.GetOptions{})
-			if err != nil {
-				t.Fatalf("Failed to get updated node: %v", err)
-			}
-
-			if !reflect.DeepEqual(updatedNode.Labels, tc.expectedNodeLabels) {
-				t.Errorf("Node labels are incorrect. Got: %v, Want: %v", updatedNode.Labels, tc.expectedNodeLabels)
-			}
-		})
-	}
-}
-
 func TestPatchComposabilityRequestSize(t *testing.T) {
 	testCases := []struct {
 		name                string
@@ -273,7 +64,7 @@ func TestPatchComposabilityRequestSize(t *testing.T) {
 
 			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clientObjects...).Build()
 
-			err := PatchComposabilityRequestSize(context.Background(), fakeClient, tc.requestName, tc.count)
+			err := PatchComposabilityRequestSize(context.Background(), fakeClient, tc.requestName, tc.count, "composable.test")
 
 			if tc.wantErr {
 				if err == nil {
@@ -301,6 +92,9 @@ func TestPatchComposabilityRequestSize(t *testing.T) {
 			if updatedRequest.Spec.Resource.Size != tc.expectedSize {
 				t.Errorf("Unexpected ComposabilityRequest size. Got: %v, Want: %v", updatedRequest.Spec.Resource.Size, tc.expectedSize)
 			}
+			if _, direction, exists, err := GetLastResize(*updatedRequest, "composable.test"); err != nil || !exists || direction != ResizeDirectionUp {
+				t.Errorf("Unexpected resize annotations: %v", updatedRequest.Annotations)
+			}
 		})
 	}
 }
@@ -312,6 +106,8 @@ func TestPatchResourceClaimDeviceConditions(t *testing.T) {
 		resourceClaimName         string
 		namespace                 string
 		conditionType             string
+		reason                    types.ConditionReason
+		message                   string
 		wantErr                   bool
 		expectedErrMsg            string
 	}{
@@ -334,12 +130,49 @@ func TestPatchResourceClaimDeviceConditions(t *testing.T) {
 								},
 							},
 						},
+						Status: resourceapi.ResourceClaimStatus{
+							Devices: []resourceapi.AllocatedDeviceStatus{
+								{
+									Device:      "gpu-0",
+									Driver:      "gpu.nvidia.com",
+									Pool:        "k8s-dra-driver",
+									NetworkData: &resourceapi.NetworkDeviceData{InterfaceName: "eth0"},
+								},
+							},
+						},
+					},
+				},
+			},
+			resourceClaimName: "resource1",
+			namespace:         "default",
+			conditionType:     "FabricDeviceReschedule",
+			reason:            types.ReasonDeviceReady,
+			message:           "1 devices are ready on node node1",
+		},
+		{
+			name: "reason changed while status is unchanged",
+			existingResourceClaimList: &resourceapi.ResourceClaimList{
+				Items: []resourceapi.ResourceClaim{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:       "resource1",
+							Namespace:  "default",
+							Generation: 2,
+						},
 						Status: resourceapi.ResourceClaimStatus{
 							Devices: []resourceapi.AllocatedDeviceStatus{
 								{
 									Device: "gpu-0",
 									Driver: "gpu.nvidia.com",
 									Pool:   "k8s-dra-driver",
+									Conditions: []metav1.Condition{
+										{
+											Type:    "FabricDeviceFailed",
+											Status:  metav1.ConditionTrue,
+											Reason:  string(types.ReasonIncompatibleModelOnNode),
+											Message: "model A100 40G cannot coexist with model A100 80G",
+										},
+									},
 								},
 							},
 						},
@@ -348,7 +181,18 @@ func TestPatchResourceClaimDeviceConditions(t *testing.T) {
 			},
 			resourceClaimName: "resource1",
 			namespace:         "default",
-			conditionType:     "FabricDeviceReschedule",
+			conditionType:     "FabricDeviceFailed",
+			reason:            types.ReasonMaxDeviceExceeded,
+			message:           "model A100 40G requested 3, node limit 2",
+		},
+		{
+			name:              "resource claim not found",
+			resourceClaimName: "resource1",
+			namespace:         "default",
+			conditionType:     "FabricDeviceFailed",
+			reason:            types.ReasonMaxDeviceExceeded,
+			wantErr:           true,
+			expectedErrMsg:    "failed to get ResourceClaim: resourceclaims.resource.k8s.io \"resource1\" not found",
 		},
 	}
 
@@ -365,7 +209,7 @@ func TestPatchResourceClaimDeviceConditions(t *testing.T) {
 
 			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(clientObjects...).Build()
 
-			err := PatchResourceClaimDeviceConditions(context.Background(), fakeClient, tc.resourceClaimName, tc.namespace, tc.conditionType)
+			err := PatchResourceClaimDeviceConditions(context.Background(), fakeClient, tc.resourceClaimName, tc.namespace, tc.conditionType, tc.reason, tc.message)
 
 			if tc.wantErr {
 				if err == nil {
@@ -390,15 +234,18 @@ func TestPatchResourceClaimDeviceConditions(t *testing.T) {
 				t.Fatalf("Failed to get updated node: %v", err)
 			}
 
-			found := false
-			for _, cond := range updatedRequest.Status.Devices[0].Conditions {
-				if cond.Type == tc.conditionType && cond.Status == metav1.ConditionTrue {
-					found = true
-					break
-				}
+			cond := meta.FindStatusCondition(updatedRequest.Status.Devices[0].Conditions, tc.conditionType)
+			if cond == nil || cond.Status != metav1.ConditionTrue {
+				t.Fatalf("Expected condition %s not found in Device Conditions", tc.conditionType)
+			}
+			if cond.Reason != string(tc.reason) || cond.Message != tc.message {
+				t.Errorf("Condition reason or message is incorrect. Got: %s %q, Want: %s %q", cond.Reason, cond.Message, tc.reason, tc.message)
+			}
+			if cond.ObservedGeneration != updatedRequest.Generation {
+				t.Errorf("Condition observed generation is incorrect. Got: %d, Want: %d", cond.ObservedGeneration, updatedRequest.Generation)
 			}
-			if !found {
-				t.Errorf("Expected condition %s not found in Device Conditions", tc.conditionType)
+			if networkData := tc.existingResourceClaimList.Items[0].Status.Devices[0].NetworkData; !reflect.DeepEqual(updatedRequest.Status.Devices[0].NetworkData, networkData) {
+				t.Errorf("Device network data was not kept. Got: %+v, Want: %+v", updatedRequest.Status.Devices[0].NetworkData, networkData)
 			}
 		})
 	}
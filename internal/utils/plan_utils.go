@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExecutePlan applies the plan of a node: it patches the annotations of the
+// ComposableResources, the conditions of the ResourceClaims, the
+// ComposabilityRequests and the node labels, and records the events and
+// metrics of the decisions, of the resizes held and of the operations
+// deferred by the fabric budgets. Adopted ComposabilityRequests are labeled
+// before the resizes, the devices chosen by a scale-down are deleted right
+// after it, and duplicates are deleted after them. It stops at the first
+// error; the next reconcile plans again from the new state.
+func ExecutePlan(ctx context.Context, kubeClient client.Client, clientSet kubernetes.Interface, recorder record.EventRecorder, plan *types.Plan) error {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start executing plan",
+		"claimTransitions", len(plan.ClaimTransitions),
+		"annotations", len(plan.Annotations),
+		"composabilityRequests", len(plan.ComposabilityRequests))
+
+	metrics.ResetDeviceIdle(plan.NodeName)
+	for _, idle := range plan.IdleDevices {
+		metrics.RecordDeviceIdle(plan.NodeName, idle.Model, idle.ResourceName, idle.Idle)
+	}
+
+	metrics.ResetDeviceCounts(plan.NodeName)
+	for _, count := range plan.DeviceCounts {
+		metrics.RecordDeviceCounts(plan.NodeName, count.Model, count.Configured, count.Actual)
+	}
+
+	for _, blocked := range plan.BlockedScaleUps {
+		metrics.RecordNodeLifecycleAction(plan.NodeName, metrics.ActionScaleUpBlocked)
+		if recorder != nil {
+			recorder.Event(nodeReference(plan.NodeName), corev1.EventTypeWarning, eventReason(ctx, ReasonScaleUpBlocked),
+				fmt.Sprintf("not scaling model %s on node %s from %d to %d devices: %s", blocked.Model, plan.NodeName, blocked.Size, blocked.Wanted, blocked.Reason))
+		}
+	}
+
+	for _, held := range plan.HeldResizes {
+		if held.Reported {
+			continue
+		}
+		metrics.RecordResizeHeld(plan.NodeName, held.Model, held.Reason)
+		cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: held.Name, UID: held.UID}}
+		recordScalingEvent(recorder, plan.NodeName, cr, eventReason(ctx, ReasonResizeHeld),
+			fmt.Sprintf("holding scaling model %s on node %s from %d to %d devices: %s: %s", held.Model, plan.NodeName, held.Size, held.Wanted, held.Reason, held.Message))
+	}
+
+	for _, ref := range plan.UnconvergedRequests {
+		metrics.RecordConvergenceTimeout(plan.NodeName, ref.Model)
+		if recorder != nil {
+			message := fmt.Sprintf("ComposabilityRequest %s of model %s on node %s did not settle in time, resizing anyway", ref.Name, ref.Model, plan.NodeName)
+			cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: ref.Name, UID: ref.UID}}
+			recorder.Event(nodeReference(plan.NodeName), corev1.EventTypeWarning, eventReason(ctx, ReasonConvergenceTimeout), message)
+			recorder.Event(ComposabilityRequestReference(cr), corev1.EventTypeWarning, eventReason(ctx, ReasonConvergenceTimeout), message)
+		}
+	}
+
+	for _, deferred := range plan.DeferredOperations {
+		metrics.RecordFabricOperationDeferred(plan.NodeName, deferred.Model, deferred.Scope, deferred.Limit)
+		recordDeferredEvent(ctx, recorder, plan.NodeName, deferred)
+	}
+
+	for _, annotation := range plan.Annotations {
+		if err := PatchComposableResourceAnnotation(ctx, kubeClient, annotation.ResourceName, annotation.Key, annotation.Value); err != nil {
+			return fmt.Errorf("failed to update ComposableResource: %w", err)
+		}
+	}
+
+	for _, transition := range plan.ClaimTransitions {
+		if err := applyClaimTransition(ctx, kubeClient, recorder, transition); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range plan.AdoptedRequests {
+		if err := PatchComposabilityRequestLabels(ctx, kubeClient, ref.Name, OwnerLabels(plan.LabelPrefix, plan.NodeName, ref.Model)); err != nil {
+			return err
+		}
+		cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: ref.Name, UID: ref.UID}}
+		recordScalingEvent(recorder, plan.NodeName, cr, eventReason(ctx, ReasonRequestAdopt),
+			fmt.Sprintf("adopted ComposabilityRequest %s of model %s on node %s", ref.Name, ref.Model, plan.NodeName))
+	}
+
+	for _, change := range plan.ComposabilityRequests {
+		if err := applyComposabilityRequestChange(ctx, kubeClient, recorder, plan.NodeName, plan.LabelPrefix, change); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range plan.DeletedRequests {
+		if err := deleteComposabilityRequest(ctx, kubeClient, ref.Name, ref.UID); err != nil {
+			return err
+		}
+		recordScalingEvent(recorder, plan.NodeName, nil, eventReason(ctx, ReasonRequestDelete),
+			fmt.Sprintf("deleted empty duplicate ComposabilityRequest %s of model %s on node %s", ref.Name, ref.Model, plan.NodeName))
+	}
+
+	return patchNodeLabel(ctx, clientSet, plan.NodeName, plan.NodeLabels.AddLabels, plan.NodeLabels.DeleteLabels)
+}
+
+// recordDeferredEvent emits an event for an operation deferred by a budget of
+// fabric operations on the node and on its ComposabilityRequest.
+func recordDeferredEvent(ctx context.Context, recorder record.EventRecorder, nodeName string, deferred types.DeferredOperation) {
+	if recorder == nil {
+		return
+	}
+
+	message := fmt.Sprintf("deferred scaling model %s on node %s to %d devices, scaling to %d: %s limit of the %s budget",
+		deferred.Model, nodeName, deferred.Wanted, deferred.Size, deferred.Limit, deferred.Scope)
+	recorder.Event(nodeReference(nodeName), corev1.EventTypeWarning, eventReason(ctx, ReasonFabricOperationDeferred), message)
+	if deferred.Name != "" {
+		cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: deferred.Name, UID: deferred.UID}}
+		recorder.Event(ComposabilityRequestReference(cr), corev1.EventTypeWarning, eventReason(ctx, ReasonFabricOperationDeferred), message)
+	}
+}
+
+// applyClaimTransition sets the condition of a transition on the devices of
+// its ResourceClaim. When the state changes, an event with the reason and
+// message is emitted on the claim, its node and the related objects.
+func applyClaimTransition(ctx context.Context, kubeClient client.Client, recorder record.EventRecorder, transition types.ClaimTransition) error {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start applying claim transition",
+		"resourceClaimInfoName", transition.Claim.Name,
+		"conditionType", transition.ConditionType,
+		"targetState", transition.State,
+		"reason", transition.Reason,
+		"message", transition.Message)
+
+	claim := transition.Claim
+	if err := PatchResourceClaimDeviceConditions(ctx, kubeClient, claim.Name, claim.Namespace, transition.ConditionType, transition.Reason, transition.Message); err != nil {
+		return err
+	}
+
+	if !transition.Transitioned() {
+		return nil
+	}
+
+	eventType := corev1.EventTypeNormal
+	if transition.State == "Failed" {
+		eventType = corev1.EventTypeWarning
+	}
+	related := make([]runtime.Object, 0, len(transition.Related))
+	for i := range transition.Related {
+		related = append(related, &transition.Related[i])
+	}
+	recordClaimEvent(recorder, claim, eventType, eventReason(ctx, string(transition.Reason)), transition.Message, related...)
+
+	// In dry-run mode the claim is not moved, so the transition would be
+	// counted again on every reconcile.
+	if !IsDryRun(ctx) {
+		metrics.RecordClaimTransition(transition.State, string(transition.Reason))
+		if attachStart := claim.AttachStart(); transition.State == "Reschedule" && !attachStart.IsZero() {
+			metrics.ObserveAttachLatency(time.Since(attachStart))
+		}
+	}
+
+	return nil
+}
+
+// applyQuotaUsage records the planned usage in the status of the
+// DeviceQuotas. It is called by QuotaAdmissions.Admit rather than
+// ExecutePlan, so that the usage is written in the order of the plans.
+func applyQuotaUsage(ctx context.Context, kubeClient client.Client, usage []types.DeviceQuotaUsage) error {
+	for start := 0; start < len(usage); {
+		end := start
+		var used []ddsv1alpha1.DeviceQuotaUsage
+		for ; end < len(usage) && usage[end].Quota == usage[start].Quota; end++ {
+			used = append(used, ddsv1alpha1.DeviceQuotaUsage{Model: usage[end].Model, Used: usage[end].Used})
+		}
+
+		if err := PatchDeviceQuotaUsage(ctx, kubeClient, usage[start].Quota, used); err != nil {
+			return err
+		}
+		start = end
+	}
+
+	return nil
+}
+
+// applyComposabilityRequestChange creates or resizes a ComposabilityRequest
+// and reports the attach or detach decision.
+func applyComposabilityRequestChange(ctx context.Context, kubeClient client.Client, recorder record.EventRecorder, nodeName, labelPrefix string, change types.ComposabilityRequestChange) error {
+	logger := ctrl.LoggerFrom(ctx)
+
+	if change.Name == "" {
+		logger.Info("Start dynamic attach")
+		metrics.RecordScalingDecision(nodeName, change.Model, metrics.DecisionAttach)
+
+		newCR, err := createNewComposabilityRequestCR(ctx, kubeClient, change.Size, change.ResourceType, change.Model, nodeName, labelPrefix)
+		if err != nil {
+			return err
+		}
+		recordScalingEvent(recorder, nodeName, newCR, eventReason(ctx, ReasonDeviceAttach),
+			fmt.Sprintf("requested %d devices of model %s on node %s", change.Size, change.Model, nodeName))
+		return nil
+	}
+
+	decision, reason := metrics.DecisionAttach, ReasonDeviceAttach
+	if change.Size < change.PreviousSize {
+		decision, reason = metrics.DecisionDetach, ReasonDeviceDetach
+	}
+	if change.Reclaim {
+		reason = ReasonDeviceReclaim
+		metrics.RecordNodeLifecycleAction(nodeName, metrics.ActionReclaim)
+	}
+	logger.Info("Start dynamic "+decision, "name", change.Name, "previousSize", change.PreviousSize, "size", change.Size, "victims", change.Victims)
+	metrics.RecordScalingDecision(nodeName, change.Model, decision)
+
+	if err := PatchComposabilityRequestSize(ctx, kubeClient, change.Name, change.Size, labelPrefix); err != nil {
+		return err
+	}
+
+	// The victims are deleted once the size went down, so that the operator
+	// does not attach devices to replace them. They are deleted right away,
+	// before the operator chooses devices of its own to remove.
+	for _, name := range change.Victims {
+		if err := deleteComposableResource(ctx, kubeClient, name, nodeName, change.Model, change.VictimDevices[name]); err != nil {
+			return err
+		}
+	}
+
+	message := fmt.Sprintf("scaled model %s on node %s from %d to %d devices", change.Model, nodeName, change.PreviousSize, change.Size)
+	if len(change.Victims) > 0 {
+		message += ", detaching " + strings.Join(change.Victims, ", ")
+	}
+	cr := &cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: change.Name, UID: change.UID}}
+	recordScalingEvent(recorder, nodeName, cr, eventReason(ctx, reason), message)
+
+	return nil
+}
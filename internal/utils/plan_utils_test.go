@@ -0,0 +1,714 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestExecutePlan(t *testing.T) {
+	existingCR := func() *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test",
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Type:       "gpu",
+					Size:       2,
+					Model:      "A100 40G",
+					TargetNode: "node1",
+				},
+			},
+		}
+	}
+	existingNode := func() *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "node1",
+				Labels: map[string]string{
+					"composable.test/nvidia-a100-80g": "true",
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name               string
+		existingObjects    []runtime.Object
+		existingNode       *corev1.Node
+		plan               *types.Plan
+		expectedSizes      map[string]int64
+		expectedNodeLabels map[string]string
+		wantErr            bool
+		expectedErrMsg     string
+	}{
+		{
+			name:         "create ComposabilityRequest",
+			existingNode: existingNode(),
+			plan: &types.Plan{
+				NodeName: "node1",
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+				},
+				NodeLabels: types.NodeLabelChange{
+					AddLabels:    []string{"composable.test/nvidia-a100-40g"},
+					DeleteLabels: []string{"composable.test/nvidia-a100-80g"},
+				},
+			},
+			expectedSizes: map[string]int64{"A100 40G": 2},
+			expectedNodeLabels: map[string]string{
+				"composable.test/nvidia-a100-40g": "true",
+			},
+		},
+		{
+			name:            "resize ComposabilityRequest",
+			existingObjects: []runtime.Object{existingCR()},
+			existingNode:    existingNode(),
+			plan: &types.Plan{
+				NodeName: "node1",
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+				},
+			},
+			expectedSizes: map[string]int64{"A100 40G": 4},
+			expectedNodeLabels: map[string]string{
+				"composable.test/nvidia-a100-80g": "true",
+			},
+		},
+		{
+			name:         "ComposabilityRequest not exist",
+			existingNode: existingNode(),
+			plan: &types.Plan{
+				NodeName: "node1",
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 4},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "failed to get ComposabilityRequest: composabilityrequests.meta.k8s.io \"test\" not found",
+		},
+		{
+			name:         "ResourceClaim not exist",
+			existingNode: existingNode(),
+			plan: &types.Plan{
+				NodeName: "node1",
+				ClaimTransitions: []types.ClaimTransition{
+					{
+						Claim: types.ResourceClaimInfo{
+							Name:      "test-claim",
+							Namespace: "test-ns",
+							NodeName:  "node1",
+							Devices:   []types.ResourceClaimDevice{{Name: "gpu0", Model: "A100 40G", State: "Preparing"}},
+						},
+						State:         "Failed",
+						ConditionType: "FabricDeviceFailed",
+						Reason:        types.ReasonMaxDeviceExceeded,
+						Message:       "model A100 40G requested 3, node limit 2",
+					},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "failed to get ResourceClaim: resourceclaims.resource.k8s.io \"test-claim\" not found",
+		},
+		{
+			name:         "ComposableResource not exist",
+			existingNode: existingNode(),
+			plan: &types.Plan{
+				NodeName: "node1",
+				Annotations: []types.AnnotationUpdate{
+					{ResourceName: "res0", Key: "composable.test/last-used-time", Value: "2025-01-01T00:00:00Z"},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "failed to update ComposableResource: failed to get latest ComposableResource: composableresources.meta.k8s.io \"res0\" not found",
+		},
+		{
+			name: "node not exist",
+			plan: &types.Plan{
+				NodeName: "test",
+			},
+			wantErr:        true,
+			expectedErrMsg: "patch failed: nodes \"test\" not found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeObjects := []runtime.Object{}
+			if tc.existingNode != nil {
+				kubeObjects = append(kubeObjects, tc.existingNode)
+			}
+			clientSet := k8sfake.NewClientset(kubeObjects...)
+
+			fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects(tc.existingObjects...).Build()
+
+			err := ExecutePlan(context.Background(), fakeClient, clientSet, record.NewFakeRecorder(100), tc.plan)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				if err.Error() != tc.expectedErrMsg {
+					t.Errorf("Error message is incorrect. Got: %q, Want: %q", err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			crList := &cdioperator.ComposabilityRequestList{}
+			if err := fakeClient.List(context.Background(), crList); err != nil {
+				t.Fatalf("Failed to list ComposabilityRequests: %v", err)
+			}
+			sizes := make(map[string]int64)
+			for _, cr := range crList.Items {
+				if cr.Spec.Resource.TargetNode != "node1" {
+					t.Errorf("Expected TargetNode %q, got %q", "node1", cr.Spec.Resource.TargetNode)
+				}
+				sizes[cr.Spec.Resource.Model] = cr.Spec.Resource.Size
+			}
+			if !reflect.DeepEqual(sizes, tc.expectedSizes) {
+				t.Errorf("ComposabilityRequest sizes are incorrect. Got: %v, Want: %v", sizes, tc.expectedSizes)
+			}
+
+			updatedNode, err := clientSet.CoreV1().Nodes().Get(context.Background(), tc.plan.NodeName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get updated node: %v", err)
+			}
+			if !reflect.DeepEqual(updatedNode.Labels, tc.expectedNodeLabels) {
+				t.Errorf("Node labels are incorrect. Got: %v, Want: %v", updatedNode.Labels, tc.expectedNodeLabels)
+			}
+		})
+	}
+}
+
+func TestExecutePlanOwnership(t *testing.T) {
+	labelPrefix := "composable.test"
+	ownedName := ComposabilityRequestName("node1", "A100 40G")
+	request := func(name string, size int64, labels map[string]string) *cdioperator.ComposabilityRequest {
+		return &cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: labels,
+			},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{
+					Type:       "gpu",
+					Size:       size,
+					Model:      "A100 40G",
+					TargetNode: "node1",
+				},
+			},
+		}
+	}
+	type requestState struct {
+		Size   int64
+		Labels map[string]string
+	}
+
+	testCases := []struct {
+		name             string
+		existingObjects  []runtime.Object
+		plan             *types.Plan
+		expectedRequests map[string]requestState
+		wantErr          bool
+		expectedErrMsg   string
+	}{
+		{
+			name: "create owned ComposabilityRequest",
+			plan: &types.Plan{
+				NodeName:    "node1",
+				LabelPrefix: labelPrefix,
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+				},
+			},
+			expectedRequests: map[string]requestState{
+				ownedName: {Size: 2, Labels: OwnerLabels(labelPrefix, "node1", "A100 40G")},
+			},
+		},
+		{
+			name:            "create ComposabilityRequest that already exists",
+			existingObjects: []runtime.Object{request(ownedName, 1, OwnerLabels(labelPrefix, "node1", "A100 40G"))},
+			plan: &types.Plan{
+				NodeName:    "node1",
+				LabelPrefix: labelPrefix,
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+				},
+			},
+			expectedRequests: map[string]requestState{
+				ownedName: {Size: 2, Labels: OwnerLabels(labelPrefix, "node1", "A100 40G")},
+			},
+		},
+		{
+			name:            "create ComposabilityRequest over one not owned",
+			existingObjects: []runtime.Object{request(ownedName, 1, nil)},
+			plan: &types.Plan{
+				NodeName:    "node1",
+				LabelPrefix: labelPrefix,
+				ComposabilityRequests: []types.ComposabilityRequestChange{
+					{ResourceType: "gpu", Model: "A100 40G", Size: 2},
+				},
+			},
+			wantErr:        true,
+			expectedErrMsg: "ComposabilityRequest " + ownedName + " already exists and is not owned for model A100 40G on node node1",
+		},
+		{
+			name:            "adopt ComposabilityRequest",
+			existingObjects: []runtime.Object{request("composability-abcde", 2, map[string]string{"team": "ml"})},
+			plan: &types.Plan{
+				NodeName:        "node1",
+				LabelPrefix:     labelPrefix,
+				AdoptedRequests: []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+			},
+			expectedRequests: map[string]requestState{
+				"composability-abcde": {Size: 2, Labels: map[string]string{
+					"team":                       "ml",
+					OwnerNodeLabel(labelPrefix):  "node1",
+					OwnerModelLabel(labelPrefix): OwnerLabels(labelPrefix, "node1", "A100 40G")[OwnerModelLabel(labelPrefix)],
+				}},
+			},
+		},
+		{
+			name: "delete duplicate ComposabilityRequest",
+			existingObjects: []runtime.Object{
+				request(ownedName, 2, OwnerLabels(labelPrefix, "node1", "A100 40G")),
+				request("composability-abcde", 0, OwnerLabels(labelPrefix, "node1", "A100 40G")),
+			},
+			plan: &types.Plan{
+				NodeName:        "node1",
+				LabelPrefix:     labelPrefix,
+				DeletedRequests: []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+			},
+			expectedRequests: map[string]requestState{
+				ownedName: {Size: 2, Labels: OwnerLabels(labelPrefix, "node1", "A100 40G")},
+			},
+		},
+		{
+			name: "delete ComposabilityRequest already gone",
+			plan: &types.Plan{
+				NodeName:        "node1",
+				LabelPrefix:     labelPrefix,
+				DeletedRequests: []types.ComposabilityRequestRef{{Name: "composability-abcde", Model: "A100 40G"}},
+			},
+			expectedRequests: map[string]requestState{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+			fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects(tc.existingObjects...).Build()
+
+			err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), record.NewFakeRecorder(100), tc.plan)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				if err.Error() != tc.expectedErrMsg {
+					t.Errorf("Error message is incorrect. Got: %q, Want: %q", err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			crList := &cdioperator.ComposabilityRequestList{}
+			if err := fakeClient.List(context.Background(), crList); err != nil {
+				t.Fatalf("Failed to list ComposabilityRequests: %v", err)
+			}
+			requests := make(map[string]requestState)
+			for _, cr := range crList.Items {
+				requests[cr.Name] = requestState{Size: cr.Spec.Resource.Size, Labels: cr.Labels}
+			}
+			if !reflect.DeepEqual(requests, tc.expectedRequests) {
+				t.Errorf("ComposabilityRequests are incorrect. Got: %+v, Want: %+v", requests, tc.expectedRequests)
+			}
+		})
+	}
+}
+
+func TestExecutePlanNodeLifecycle(t *testing.T) {
+	cr := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Model: "A100 40G", Size: 2, TargetNode: "node1"},
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+
+	fakeClient := newIndexedClientBuilder(t).WithObjects(cr).Build()
+	recorder := record.NewFakeRecorder(10)
+	plan := &types.Plan{
+		NodeName: "node1",
+		BlockedScaleUps: []types.BlockedScaleUp{
+			{Model: "H100", Size: 0, Wanted: 1, Reason: types.BlockedNodeCordoned},
+		},
+		ComposabilityRequests: []types.ComposabilityRequestChange{
+			{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 2, Size: 0, Reclaim: true},
+		},
+	}
+
+	if err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), recorder, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	expectedEvents := []string{
+		"Warning ScaleUpBlocked not scaling model H100 on node node1 from 0 to 1 devices: NodeCordoned",
+		"Normal DeviceReclaim scaled model A100 40G on node node1 from 2 to 0 devices",
+		"Normal DeviceReclaim scaled model A100 40G on node node1 from 2 to 0 devices",
+	}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("events are incorrect. Got: %v, Want: %v", events, expectedEvents)
+	}
+}
+
+func TestExecutePlanDeferredOperations(t *testing.T) {
+	cr := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Model: "A100 40G", Size: 1, TargetNode: "node1"},
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+
+	fakeClient := newIndexedClientBuilder(t).WithObjects(cr).Build()
+	recorder := record.NewFakeRecorder(10)
+	plan := &types.Plan{
+		NodeName: "node1",
+		ComposabilityRequests: []types.ComposabilityRequestChange{
+			{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 1, Size: 2},
+		},
+		DeferredOperations: []types.DeferredOperation{
+			{Name: "test", Model: "A100 40G", Size: 2, Wanted: 4, Scope: types.FabricScopeNode, Limit: types.FabricLimitRate},
+			{Model: "H100", Size: 0, Wanted: 1, Scope: types.FabricScopeGlobal, Limit: types.FabricLimitQueued},
+		},
+	}
+
+	if err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), recorder, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	expectedEvents := []string{
+		"Warning FabricOperationDeferred deferred scaling model A100 40G on node node1 to 4 devices, scaling to 2: Rate limit of the node budget",
+		"Warning FabricOperationDeferred deferred scaling model A100 40G on node node1 to 4 devices, scaling to 2: Rate limit of the node budget",
+		"Warning FabricOperationDeferred deferred scaling model H100 on node node1 to 1 devices, scaling to 0: Queued limit of the global budget",
+		"Normal DeviceAttach scaled model A100 40G on node node1 from 1 to 2 devices",
+		"Normal DeviceAttach scaled model A100 40G on node node1 from 1 to 2 devices",
+	}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("events are incorrect. Got: %v, Want: %v", events, expectedEvents)
+	}
+
+	updated := &cdioperator.ComposabilityRequest{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "test"}, updated); err != nil {
+		t.Fatalf("failed to get ComposabilityRequest: %v", err)
+	}
+	if updated.Spec.Resource.Size != 2 {
+		t.Errorf("ComposabilityRequest size is incorrect. Got: %d, Want: 2", updated.Spec.Resource.Size)
+	}
+}
+
+func TestExecutePlanHeldResizes(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+
+	fakeClient := newIndexedClientBuilder(t).Build()
+	recorder := record.NewFakeRecorder(10)
+	plan := &types.Plan{
+		NodeName: "node1",
+		HeldResizes: []types.HeldResize{
+			{Name: "test", Model: "A100 40G", Size: 4, Wanted: 1, Reason: types.HoldScaleDownCooldown, Message: "scaled up at 2025-01-01T11:59:00Z", RetryAfter: time.Minute},
+			{Name: "reported", Model: "H100", Size: 2, Wanted: 1, Reason: types.HoldNotConverged, RetryAfter: time.Minute, Reported: true},
+		},
+		UnconvergedRequests: []types.ComposabilityRequestRef{{Name: "other", Model: "H100"}},
+	}
+
+	if err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), recorder, plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	expectedEvents := []string{
+		"Normal ResizeHeld holding scaling model A100 40G on node node1 from 4 to 1 devices: ScaleDownCooldown: scaled up at 2025-01-01T11:59:00Z",
+		"Normal ResizeHeld holding scaling model A100 40G on node node1 from 4 to 1 devices: ScaleDownCooldown: scaled up at 2025-01-01T11:59:00Z",
+		"Warning ConvergenceTimeout ComposabilityRequest other of model H100 on node node1 did not settle in time, resizing anyway",
+		"Warning ConvergenceTimeout ComposabilityRequest other of model H100 on node node1 did not settle in time, resizing anyway",
+	}
+	if !reflect.DeepEqual(events, expectedEvents) {
+		t.Errorf("events are incorrect. Got: %v, Want: %v", events, expectedEvents)
+	}
+}
+
+func TestExecutePlanAnnotations(t *testing.T) {
+	resource := &cdioperator.ComposableResource{
+		ObjectMeta: metav1.ObjectMeta{Name: "res0"},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+
+	fakeClient := newIndexedClientBuilder(t).WithObjects(resource).Build()
+	plan := &types.Plan{
+		NodeName: "node1",
+		Annotations: []types.AnnotationUpdate{
+			{ResourceName: "res0", Key: "composable.test/last-used-time", Value: "2025-01-01T00:00:00Z"},
+		},
+	}
+
+	if err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), record.NewFakeRecorder(10), plan); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	updatedResource := &cdioperator.ComposableResource{}
+	if err := fakeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "res0"}, updatedResource); err != nil {
+		t.Fatalf("failed to get ComposableResource: %v", err)
+	}
+	if got := updatedResource.Annotations["composable.test/last-used-time"]; got != "2025-01-01T00:00:00Z" {
+		t.Errorf("last used time is incorrect. Got: %q, Want: %q", got, "2025-01-01T00:00:00Z")
+	}
+}
+
+func TestExecutePlanVictims(t *testing.T) {
+	cr := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Type: "gpu", Model: "A100 40G", Size: 4, TargetNode: "node1"},
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	resource := func(name, model, node string) *cdioperator.ComposableResource {
+		return &cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       cdioperator.ComposableResourceSpec{Type: "gpu", Model: model, TargetNode: node},
+		}
+	}
+	resources := []client.Object{
+		resource("res1", "A100 40G", "node1"), resource("res2", "A100 40G", "node1"),
+		resource("res3", "A100 40G", "node1"), resource("res4", "A100 40G", "node2"),
+		resource("res5", "H100", "node1"),
+	}
+	// The device of res2 was allocated to a claim since the plan.
+	claim := allocatedResourceClaim("rc0", "node1", "gpu.nvidia.com", "node1", "gpu2")
+
+	testCases := []struct {
+		name            string
+		change          types.ComposabilityRequestChange
+		expectedEvent   string
+		expectedDeleted []string
+	}{
+		{
+			name:            "chosen devices are deleted",
+			change:          types.ComposabilityRequestChange{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 2, Victims: []string{"res3", "res1"}},
+			expectedEvent:   "Normal DeviceDetach scaled model A100 40G on node node1 from 4 to 2 devices, detaching res3, res1",
+			expectedDeleted: []string{"res1", "res3"},
+		},
+		{
+			name: "devices allocated since the plan are not deleted",
+			change: types.ComposabilityRequestChange{
+				Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 2, Victims: []string{"res2", "res1"},
+				VictimDevices: map[string]types.DeviceRef{
+					"res1": {Driver: "gpu.nvidia.com", Pool: "node1", Name: "gpu1"},
+					"res2": {Driver: "gpu.nvidia.com", Pool: "node1", Name: "gpu2"},
+				},
+			},
+			expectedEvent:   "Normal DeviceDetach scaled model A100 40G on node node1 from 4 to 2 devices, detaching res2, res1",
+			expectedDeleted: []string{"res1"},
+		},
+		{
+			name:          "devices of another node or model are not deleted",
+			change:        types.ComposabilityRequestChange{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 1, Victims: []string{"res4", "res5", "res6"}},
+			expectedEvent: "Normal DeviceDetach scaled model A100 40G on node node1 from 4 to 1 devices, detaching res4, res5, res6",
+		},
+		{
+			name:          "no devices chosen",
+			change:        types.ComposabilityRequestChange{Name: "test", ResourceType: "gpu", Model: "A100 40G", PreviousSize: 4, Size: 2},
+			expectedEvent: "Normal DeviceDetach scaled model A100 40G on node node1 from 4 to 2 devices",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := []client.Object{cr.DeepCopy(), claim.DeepCopy()}
+			for _, res := range resources {
+				objects = append(objects, res.DeepCopyObject().(client.Object))
+			}
+			fakeClient := newIndexedClientBuilder(t).WithObjects(objects...).Build()
+			recorder := record.NewFakeRecorder(10)
+			plan := &types.Plan{
+				NodeName:              "node1",
+				ComposabilityRequests: []types.ComposabilityRequestChange{tc.change},
+			}
+
+			if err := ExecutePlan(context.Background(), fakeClient, k8sfake.NewClientset(node), recorder, plan); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			close(recorder.Events)
+			for event := range recorder.Events {
+				if event != tc.expectedEvent {
+					t.Errorf("event is incorrect. Got: %q, Want: %q", event, tc.expectedEvent)
+				}
+			}
+
+			resourceList := &cdioperator.ComposableResourceList{}
+			if err := fakeClient.List(context.Background(), resourceList); err != nil {
+				t.Fatalf("failed to list ComposableResources: %v", err)
+			}
+			remaining := make(map[string]bool, len(resourceList.Items))
+			for _, res := range resourceList.Items {
+				remaining[res.Name] = true
+			}
+			var deleted []string
+			for _, res := range resources {
+				if !remaining[res.GetName()] {
+					deleted = append(deleted, res.GetName())
+				}
+			}
+			if !reflect.DeepEqual(deleted, tc.expectedDeleted) {
+				t.Errorf("deleted ComposableResources are incorrect. Got: %v, Want: %v", deleted, tc.expectedDeleted)
+			}
+		})
+	}
+}
+
+func TestApplyClaimTransitionEvents(t *testing.T) {
+	testCases := []struct {
+		name           string
+		devices        []types.ResourceClaimDevice
+		targetState    string
+		reason         types.ConditionReason
+		message        string
+		related        []corev1.ObjectReference
+		expectedEvents []string
+	}{
+		{
+			name:        "transition to Failed",
+			devices:     []types.ResourceClaimDevice{{Name: "gpu0", Model: "A100 40G", State: "Preparing"}},
+			targetState: "Failed",
+			reason:      types.ReasonMaxDeviceExceeded,
+			message:     "model A100 40G requested 3, node limit 2",
+			related: []corev1.ObjectReference{
+				*ComposabilityRequestReference(&cdioperator.ComposabilityRequest{ObjectMeta: metav1.ObjectMeta{Name: "cr0"}}),
+			},
+			expectedEvents: []string{
+				"Warning MaxDeviceExceeded model A100 40G requested 3, node limit 2",
+				"Warning MaxDeviceExceeded ResourceClaim default/rc0: model A100 40G requested 3, node limit 2",
+				"Warning MaxDeviceExceeded ResourceClaim default/rc0: model A100 40G requested 3, node limit 2",
+			},
+		},
+		{
+			name:        "transition to Reschedule",
+			devices:     []types.ResourceClaimDevice{{Name: "gpu0", Model: "A100 40G", State: "Preparing"}},
+			targetState: "Reschedule",
+			reason:      types.ReasonDeviceReady,
+			message:     "1 devices are ready on node node1",
+			expectedEvents: []string{
+				"Normal DeviceReady 1 devices are ready on node node1",
+				"Normal DeviceReady ResourceClaim default/rc0: 1 devices are ready on node node1",
+			},
+		},
+		{
+			name:        "no transition",
+			devices:     []types.ResourceClaimDevice{{Name: "gpu0", Model: "A100 40G", State: "Failed"}},
+			targetState: "Failed",
+			reason:      types.ReasonMaxDeviceExceeded,
+			message:     "model A100 40G requested 3, node limit 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects(&resourceapi.ResourceClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "rc0", Namespace: "default"},
+			}).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			transition := types.ClaimTransition{
+				Claim: types.ResourceClaimInfo{
+					Name:      "rc0",
+					Namespace: "default",
+					NodeName:  "node1",
+					Devices:   tc.devices,
+				},
+				State:         tc.targetState,
+				ConditionType: "FabricDeviceFailed",
+				Reason:        tc.reason,
+				Message:       tc.message,
+				Related:       tc.related,
+			}
+
+			if err := applyClaimTransition(context.Background(), fakeClient, recorder, transition); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			if !reflect.DeepEqual(events, tc.expectedEvents) {
+				t.Errorf("events are incorrect. Got: %v, Want: %v", events, tc.expectedEvents)
+			}
+		})
+	}
+}
+
+func TestApplyQuotaUsage(t *testing.T) {
+	kubeClient := newQuotaClient(t,
+		deviceQuota("cluster", "", ddsv1alpha1.DeviceQuotaLimit{Model: "A100 80G", MaxDevices: 4}, ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 4}),
+		deviceQuota("team-a", "team-a", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 2}),
+	)
+
+	usage := []types.DeviceQuotaUsage{
+		{Quota: "cluster", Model: "A100 80G", Used: 1},
+		{Quota: "cluster", Model: "H100", Used: 3},
+		{Quota: "team-a", Model: "H100", Used: 2},
+	}
+	if err := applyQuotaUsage(context.Background(), kubeClient, usage); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string][]ddsv1alpha1.DeviceQuotaUsage{
+		"cluster": {{Model: "A100 80G", Used: 1}, {Model: "H100", Used: 3}},
+		"team-a":  {{Model: "H100", Used: 2}},
+	}
+	for name, expectedUsed := range expected {
+		quota := &ddsv1alpha1.DeviceQuota{}
+		if err := kubeClient.Get(context.Background(), k8stypes.NamespacedName{Name: name}, quota); err != nil {
+			t.Fatalf("failed to get DeviceQuota: %v", err)
+		}
+		if !reflect.DeepEqual(quota.Status.Used, expectedUsed) {
+			t.Errorf("DeviceQuota %s: unexpected usage. Got: %v, Want: %v", name, quota.Status.Used, expectedUsed)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateNodeScalingPolicy checks a NodeScalingPolicy against the device
+// catalog. All problems found are returned together.
+func ValidateNodeScalingPolicy(policy ddsv1alpha1.NodeScalingPolicy, composableDRASpec types.ComposableDRASpec) error {
+	var errs []error
+
+	if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.NodeSelector); err != nil {
+		errs = append(errs, fmt.Errorf("invalid nodeSelector: %v", err))
+	}
+
+	for _, model := range policy.Spec.AllowedModels {
+		if _, err := getDeviceName(composableDRASpec, model); err != nil {
+			errs = append(errs, fmt.Errorf("allowedModels: %v", err))
+		}
+	}
+
+	models := make(map[string]struct{})
+	for _, limits := range policy.Spec.Models {
+		if _, err := getDeviceName(composableDRASpec, limits.Model); err != nil {
+			errs = append(errs, fmt.Errorf("models: %v", err))
+		}
+		if _, exists := models[limits.Model]; exists {
+			errs = append(errs, fmt.Errorf("models: duplicate model %q", limits.Model))
+		}
+		models[limits.Model] = struct{}{}
+
+		minDevice, maxDevice := ptr.Deref(limits.MinDevice, 0), ptr.Deref(limits.MaxDevice, 0)
+		if minDevice < 0 || maxDevice < 0 {
+			errs = append(errs, fmt.Errorf("models %q: minDevice and maxDevice must not be negative", limits.Model))
+		}
+		if limits.MaxDevice != nil && minDevice > maxDevice {
+			errs = append(errs, fmt.Errorf("models %q: minDevice %d exceeds maxDevice %d", limits.Model, minDevice, maxDevice))
+		}
+		if len(policy.Spec.AllowedModels) > 0 && !slices.Contains(policy.Spec.AllowedModels, limits.Model) {
+			errs = append(errs, fmt.Errorf("models %q: model is not in allowedModels", limits.Model))
+		}
+	}
+
+	if policy.Spec.DeviceNoRemoval != nil && policy.Spec.DeviceNoRemoval.Duration < 0 {
+		errs = append(errs, fmt.Errorf("deviceNoRemoval must not be negative, got %s", policy.Spec.DeviceNoRemoval.Duration))
+	}
+	if policy.Spec.DeviceNoAllocation != nil && policy.Spec.DeviceNoAllocation.Duration < 0 {
+		errs = append(errs, fmt.Errorf("deviceNoAllocation must not be negative, got %s", policy.Spec.DeviceNoAllocation.Duration))
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetNodeInfoWithPolicies reads the model constraints of a node from the
+// NodeScalingPolicy selecting it and from its labels. Every NodeScalingPolicy
+// is validated against the device catalog, and the result is reported in its
+// Ready condition. A node is not scaled when its labels are invalid, the
+// policy selecting it is invalid or several policies select it; the error is
+// returned for this node only and recorded in the status of the policies
+// selecting it.
+func GetNodeInfoWithPolicies(ctx context.Context, kubeClient client.Client, node v1.Node, composableDRASpec types.ComposableDRASpec) (types.NodeInfo, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	policyList := &ddsv1alpha1.NodeScalingPolicyList{}
+	if err := kubeClient.List(ctx, policyList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return GetNodeInfoFromNode(node, composableDRASpec)
+		}
+		return types.NodeInfo{}, fmt.Errorf("failed to list NodeScalingPolicies: %v", err)
+	}
+
+	validationErrs := make(map[string]error, len(policyList.Items))
+	var selected []string
+	for _, policy := range policyList.Items {
+		validationErrs[policy.Name] = ValidateNodeScalingPolicy(policy, composableDRASpec)
+
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.NodeSelector)
+		if err == nil && selector.Matches(labels.Set(node.Labels)) {
+			selected = append(selected, policy.Name)
+		}
+	}
+
+	slices.Sort(selected)
+
+	var nodeInfo types.NodeInfo
+	var nodeErr, recordedErr error
+	switch {
+	case len(selected) > 1:
+		nodeErr = fmt.Errorf("node %s is selected by several NodeScalingPolicies: %s", node.Name, strings.Join(selected, ", "))
+		recordedErr = nodeErr
+	case len(selected) == 1 && validationErrs[selected[0]] != nil:
+		// The error is reported in the Ready condition of the policy.
+		nodeErr = fmt.Errorf("invalid NodeScalingPolicy %s: %v", selected[0], validationErrs[selected[0]])
+	default:
+		var policy *ddsv1alpha1.NodeScalingPolicy
+		if len(selected) == 1 {
+			policy = &policyList.Items[slices.IndexFunc(policyList.Items, func(p ddsv1alpha1.NodeScalingPolicy) bool {
+				return p.Name == selected[0]
+			})]
+			logger.V(1).Info("NodeScalingPolicy selects node", "policy", policy.Name)
+		}
+		nodeInfo, nodeErr = newNodeInfo(node, policy, composableDRASpec)
+		recordedErr = nodeErr
+	}
+
+	for i := range policyList.Items {
+		policy := &policyList.Items[i]
+		var policyNodeErr error
+		if slices.Contains(selected, policy.Name) {
+			policyNodeErr = recordedErr
+		}
+		if err := PatchNodeScalingPolicyStatus(ctx, kubeClient, policy, validationErrs[policy.Name], node.Name, policyNodeErr); err != nil {
+			return types.NodeInfo{}, err
+		}
+	}
+
+	return nodeInfo, nodeErr
+}
+
+// ClearNodeScalingPolicyErrors removes the errors of a deleted node from the
+// status of the NodeScalingPolicies.
+func ClearNodeScalingPolicyErrors(ctx context.Context, kubeClient client.Client, nodeName string) error {
+	policyList := &ddsv1alpha1.NodeScalingPolicyList{}
+	if err := kubeClient.List(ctx, policyList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list NodeScalingPolicies: %v", err)
+	}
+
+	for i := range policyList.Items {
+		err := patchNodeScalingPolicyStatus(ctx, kubeClient, &policyList.Items[i], func(policy *ddsv1alpha1.NodeScalingPolicy) bool {
+			return setNodeScalingPolicyNodeError(&policy.Status, nodeName, nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyNodeScalingPolicy sets the limits, allowed models and durations of a
+// NodeScalingPolicy on a node.
+func applyNodeScalingPolicy(nodeInfo *types.NodeInfo, policy *ddsv1alpha1.NodeScalingPolicy, composableDRASpec types.ComposableDRASpec) error {
+	nodeInfo.Policy = policy.Name
+	nodeInfo.AllowedModels = slices.Clone(policy.Spec.AllowedModels)
+	if policy.Spec.DeviceNoRemoval != nil {
+		nodeInfo.DeviceNoRemoval = policy.Spec.DeviceNoRemoval.Duration
+	}
+	if policy.Spec.DeviceNoAllocation != nil {
+		nodeInfo.DeviceNoAllocation = policy.Spec.DeviceNoAllocation.Duration
+	}
+
+	for _, limits := range policy.Spec.Models {
+		deviceName, err := getDeviceName(composableDRASpec, limits.Model)
+		if err != nil {
+			return err
+		}
+
+		nodeInfo.Models = append(nodeInfo.Models, types.ModelConstraints{
+			Model:      limits.Model,
+			DeviceName: deviceName,
+			MaxDevice:  ptr.Deref(limits.MaxDevice, 0),
+			MinDevice:  ptr.Deref(limits.MinDevice, 0),
+		})
+	}
+
+	return nil
+}
+
+func getDeviceName(composableDRASpec types.ComposableDRASpec, model string) (string, error) {
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		if deviceInfo.CDIModelName == model {
+			return deviceInfo.K8sDeviceName, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown model: %s", model)
+}
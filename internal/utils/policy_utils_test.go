@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var policyTestSpec = types.ComposableDRASpec{
+	LabelPrefix: "composable.fsastech.com",
+	DeviceInfos: []types.DeviceInfo{
+		{
+			Index:         1,
+			CDIModelName:  "A100 80G",
+			K8sDeviceName: "nvidia-a100-80g",
+		},
+		{
+			Index:         2,
+			CDIModelName:  "H100",
+			K8sDeviceName: "nvidia-h100",
+		},
+	},
+}
+
+func nodeScalingPolicy(name string, matchLabels map[string]string, models ...ddsv1alpha1.ModelScalingLimits) *ddsv1alpha1.NodeScalingPolicy {
+	return &ddsv1alpha1.NodeScalingPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: ddsv1alpha1.NodeScalingPolicySpec{
+			NodeSelector: metav1.LabelSelector{MatchLabels: matchLabels},
+			Models:       models,
+		},
+	}
+}
+
+func newPolicyClient(t *testing.T, policies ...*ddsv1alpha1.NodeScalingPolicy) client.Client {
+	s := scheme.Scheme
+	if err := ddsv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	builder := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&ddsv1alpha1.NodeScalingPolicy{})
+	for _, policy := range policies {
+		builder = builder.WithObjects(policy)
+	}
+
+	return builder.Build()
+}
+
+func TestValidateNodeScalingPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		spec           ddsv1alpha1.NodeScalingPolicySpec
+		expectedErrMsg string
+	}{
+		{
+			name: "valid policy",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				NodeSelector:    metav1.LabelSelector{MatchLabels: map[string]string{"pool": "gpu"}},
+				Models:          []ddsv1alpha1.ModelScalingLimits{{Model: "A100 80G", MinDevice: ptr.To(1), MaxDevice: ptr.To(4)}},
+				AllowedModels:   []string{"A100 80G"},
+				DeviceNoRemoval: &metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "invalid selector",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				NodeSelector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "pool", Operator: "Near"},
+				}},
+			},
+			expectedErrMsg: "invalid nodeSelector",
+		},
+		{
+			name: "unknown model",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				Models: []ddsv1alpha1.ModelScalingLimits{{Model: "A100 40G", MaxDevice: ptr.To(1)}},
+			},
+			expectedErrMsg: "models: unknown model: A100 40G",
+		},
+		{
+			name: "unknown allowed model",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				AllowedModels: []string{"A100 40G"},
+			},
+			expectedErrMsg: "allowedModels: unknown model: A100 40G",
+		},
+		{
+			name: "duplicate model",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				Models: []ddsv1alpha1.ModelScalingLimits{{Model: "H100"}, {Model: "H100"}},
+			},
+			expectedErrMsg: `models: duplicate model "H100"`,
+		},
+		{
+			name: "min above max",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				Models: []ddsv1alpha1.ModelScalingLimits{{Model: "H100", MinDevice: ptr.To(3), MaxDevice: ptr.To(2)}},
+			},
+			expectedErrMsg: `models "H100": minDevice 3 exceeds maxDevice 2`,
+		},
+		{
+			name: "limits of a model that is not allowed",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				Models:        []ddsv1alpha1.ModelScalingLimits{{Model: "H100", MaxDevice: ptr.To(2)}},
+				AllowedModels: []string{"A100 80G"},
+			},
+			expectedErrMsg: `models "H100": model is not in allowedModels`,
+		},
+		{
+			name: "negative duration",
+			spec: ddsv1alpha1.NodeScalingPolicySpec{
+				DeviceNoAllocation: &metav1.Duration{Duration: -time.Minute},
+			},
+			expectedErrMsg: "deviceNoAllocation must not be negative, got -1m0s",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateNodeScalingPolicy(ddsv1alpha1.NodeScalingPolicy{Spec: tc.spec}, policyTestSpec)
+
+			if tc.expectedErrMsg == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected error, but got nil")
+			}
+			if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+				t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+			}
+		})
+	}
+}
+
+func TestGetNodeInfoWithPolicies(t *testing.T) {
+	gpuPool := map[string]string{"pool": "gpu"}
+	policyWithLimits := func() *ddsv1alpha1.NodeScalingPolicy {
+		policy := nodeScalingPolicy("policy1", gpuPool,
+			ddsv1alpha1.ModelScalingLimits{Model: "A100 80G", MinDevice: ptr.To(1), MaxDevice: ptr.To(4)},
+			ddsv1alpha1.ModelScalingLimits{Model: "H100", MaxDevice: ptr.To(2)},
+		)
+		policy.Spec.AllowedModels = []string{"A100 80G", "H100"}
+		policy.Spec.DeviceNoRemoval = &metav1.Duration{Duration: 10 * time.Minute}
+		return policy
+	}
+	staleError := func(policy *ddsv1alpha1.NodeScalingPolicy) *ddsv1alpha1.NodeScalingPolicy {
+		policy.Status.NodeErrors = []ddsv1alpha1.NodeScalingPolicyNodeError{{Node: "node1", Message: "old error"}}
+		return policy
+	}
+
+	testCases := []struct {
+		name               string
+		policies           []*ddsv1alpha1.NodeScalingPolicy
+		nodeLabels         map[string]string
+		expectedNodeInfo   types.NodeInfo
+		expectedErrMsg     string
+		expectedReady      map[string]metav1.ConditionStatus
+		expectedNodeErrors map[string][]ddsv1alpha1.NodeScalingPolicyNodeError
+	}{
+		{
+			name:       "no policy",
+			nodeLabels: map[string]string{"composable.fsastech.com/nvidia-h100-size-max": "3"},
+			expectedNodeInfo: types.NodeInfo{
+				Name:   "node1",
+				Models: []types.ModelConstraints{{Model: "H100", DeviceName: "nvidia-h100", MaxDevice: 3}},
+			},
+		},
+		{
+			name:     "labels override the policy",
+			policies: []*ddsv1alpha1.NodeScalingPolicy{policyWithLimits()},
+			nodeLabels: map[string]string{
+				"pool": "gpu",
+				"composable.fsastech.com/nvidia-a100-80g-size-max": "8",
+			},
+			expectedNodeInfo: types.NodeInfo{
+				Name: "node1",
+				Models: []types.ModelConstraints{
+					{Model: "A100 80G", DeviceName: "nvidia-a100-80g", MaxDevice: 8, MinDevice: 1},
+					{Model: "H100", DeviceName: "nvidia-h100", MaxDevice: 2},
+				},
+				Policy:          "policy1",
+				AllowedModels:   []string{"A100 80G", "H100"},
+				DeviceNoRemoval: 10 * time.Minute,
+			},
+			expectedReady: map[string]metav1.ConditionStatus{"policy1": metav1.ConditionTrue},
+		},
+		{
+			name:       "policy not selecting the node",
+			policies:   []*ddsv1alpha1.NodeScalingPolicy{staleError(policyWithLimits())},
+			nodeLabels: map[string]string{"pool": "cpu"},
+			expectedNodeInfo: types.NodeInfo{
+				Name: "node1",
+			},
+			expectedReady: map[string]metav1.ConditionStatus{"policy1": metav1.ConditionTrue},
+		},
+		{
+			name:     "invalid label on a selected node",
+			policies: []*ddsv1alpha1.NodeScalingPolicy{policyWithLimits()},
+			nodeLabels: map[string]string{
+				"pool": "gpu",
+				"composable.fsastech.com/nvidia-h100-size-max": "many",
+			},
+			expectedErrMsg: "invalid integer in many",
+			expectedReady:  map[string]metav1.ConditionStatus{"policy1": metav1.ConditionTrue},
+			expectedNodeErrors: map[string][]ddsv1alpha1.NodeScalingPolicyNodeError{
+				"policy1": {{Node: "node1", Message: `invalid integer in many: strconv.Atoi: parsing "many": invalid syntax`}},
+			},
+		},
+		{
+			name: "node selected by several policies",
+			policies: []*ddsv1alpha1.NodeScalingPolicy{
+				nodeScalingPolicy("policy1", gpuPool),
+				nodeScalingPolicy("policy2", nil),
+			},
+			nodeLabels:     gpuPool,
+			expectedErrMsg: "node node1 is selected by several NodeScalingPolicies: policy1, policy2",
+			expectedReady: map[string]metav1.ConditionStatus{
+				"policy1": metav1.ConditionTrue,
+				"policy2": metav1.ConditionTrue,
+			},
+			expectedNodeErrors: map[string][]ddsv1alpha1.NodeScalingPolicyNodeError{
+				"policy1": {{Node: "node1", Message: "node node1 is selected by several NodeScalingPolicies: policy1, policy2"}},
+				"policy2": {{Node: "node1", Message: "node node1 is selected by several NodeScalingPolicies: policy1, policy2"}},
+			},
+		},
+		{
+			name: "invalid policy",
+			policies: []*ddsv1alpha1.NodeScalingPolicy{staleError(nodeScalingPolicy("policy1", gpuPool,
+				ddsv1alpha1.ModelScalingLimits{Model: "H100", MinDevice: ptr.To(3), MaxDevice: ptr.To(2)},
+			))},
+			nodeLabels:     gpuPool,
+			expectedErrMsg: `invalid NodeScalingPolicy policy1: models "H100": minDevice 3 exceeds maxDevice 2`,
+			expectedReady:  map[string]metav1.ConditionStatus{"policy1": metav1.ConditionFalse},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient := newPolicyClient(t, tc.policies...)
+			node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: tc.nodeLabels}}
+
+			result, err := GetNodeInfoWithPolicies(context.Background(), fakeClient, node, policyTestSpec)
+
+			if tc.expectedErrMsg != "" {
+				if err == nil {
+					t.Fatalf("Expected error, but got nil")
+				}
+				if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+					t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(result, tc.expectedNodeInfo) {
+					t.Errorf("NodeInfo is incorrect. Got: %+v, Want: %+v", result, tc.expectedNodeInfo)
+				}
+			}
+
+			for _, expected := range tc.policies {
+				policy := &ddsv1alpha1.NodeScalingPolicy{}
+				if err := fakeClient.Get(context.Background(), client.ObjectKey{Name: expected.Name}, policy); err != nil {
+					t.Fatalf("failed to get NodeScalingPolicy: %v", err)
+				}
+				condition := meta.FindStatusCondition(policy.Status.Conditions, ddsv1alpha1.ConditionTypeReady)
+				if condition == nil || condition.Status != tc.expectedReady[policy.Name] {
+					t.Errorf("unexpected Ready condition of %s: %+v, want status %s", policy.Name, condition, tc.expectedReady[policy.Name])
+				}
+				if policy.Status.ObservedGeneration != policy.Generation {
+					t.Errorf("observedGeneration of %s = %d, want %d", policy.Name, policy.Status.ObservedGeneration, policy.Generation)
+				}
+				if !reflect.DeepEqual(policy.Status.NodeErrors, tc.expectedNodeErrors[policy.Name]) {
+					t.Errorf("node errors of %s are incorrect. Got: %+v, Want: %+v", policy.Name, policy.Status.NodeErrors, tc.expectedNodeErrors[policy.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestPatchNodeScalingPolicyStatus(t *testing.T) {
+	policy := nodeScalingPolicy("policy1", nil)
+	policy.Status.NodeErrors = []ddsv1alpha1.NodeScalingPolicyNodeError{{Node: "node1", Message: "error1"}}
+	fakeClient := newPolicyClient(t, policy)
+
+	stale := &ddsv1alpha1.NodeScalingPolicy{}
+	if err := fakeClient.Get(context.Background(), client.ObjectKey{Name: "policy1"}, stale); err != nil {
+		t.Fatalf("failed to get NodeScalingPolicy: %v", err)
+	}
+
+	// Another node records its error first; the stale copy conflicts and is
+	// refreshed, so that both errors are kept.
+	if err := PatchNodeScalingPolicyStatus(context.Background(), fakeClient, stale.DeepCopy(), nil, "node3", errors.New("error3")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := PatchNodeScalingPolicyStatus(context.Background(), fakeClient, stale.DeepCopy(), nil, "node2", errors.New("error2")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ClearNodeScalingPolicyErrors(context.Background(), fakeClient, "node1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := &ddsv1alpha1.NodeScalingPolicy{}
+	if err := fakeClient.Get(context.Background(), client.ObjectKey{Name: "policy1"}, result); err != nil {
+		t.Fatalf("failed to get NodeScalingPolicy: %v", err)
+	}
+	expected := []ddsv1alpha1.NodeScalingPolicyNodeError{
+		{Node: "node2", Message: "error2"},
+		{Node: "node3", Message: "error3"},
+	}
+	if !reflect.DeepEqual(result.Status.NodeErrors, expected) {
+		t.Errorf("node errors are incorrect. Got: %+v, Want: %+v", result.Status.NodeErrors, expected)
+	}
+}
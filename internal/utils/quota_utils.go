@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ValidateDeviceQuota checks a DeviceQuota against the device catalog. All
+// problems found are returned together.
+func ValidateDeviceQuota(quota ddsv1alpha1.DeviceQuota, composableDRASpec types.ComposableDRASpec) error {
+	var errs []error
+
+	if quota.Spec.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(quota.Spec.Namespace) {
+			errs = append(errs, fmt.Errorf("invalid namespace %q: %s", quota.Spec.Namespace, msg))
+		}
+	}
+
+	models := make(map[string]struct{})
+	for _, limit := range quota.Spec.Limits {
+		if _, err := getDeviceName(composableDRASpec, limit.Model); err != nil {
+			errs = append(errs, fmt.Errorf("limits: %v", err))
+		}
+		if _, exists := models[limit.Model]; exists {
+			errs = append(errs, fmt.Errorf("limits: duplicate model %q", limit.Model))
+		}
+		models[limit.Model] = struct{}{}
+
+		if limit.MaxDevices < 0 {
+			errs = append(errs, fmt.Errorf("limits %q: maxDevices must not be negative", limit.Model))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetDeviceQuotas returns the valid DeviceQuotas, ordered by name. Every
+// DeviceQuota is validated against the device catalog and the result is
+// reported in its Ready condition; invalid quotas are not enforced.
+func GetDeviceQuotas(ctx context.Context, kubeClient client.Client, composableDRASpec types.ComposableDRASpec) ([]ddsv1alpha1.DeviceQuota, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	quotaList := &ddsv1alpha1.DeviceQuotaList{}
+	if err := kubeClient.List(ctx, quotaList); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list DeviceQuotas: %v", err)
+	}
+
+	var quotas []ddsv1alpha1.DeviceQuota
+	for i := range quotaList.Items {
+		quota := &quotaList.Items[i]
+		validationErr := ValidateDeviceQuota(*quota, composableDRASpec)
+		if err := PatchDeviceQuotaStatus(ctx, kubeClient, quota, validationErr); err != nil {
+			return nil, err
+		}
+		if validationErr != nil {
+			logger.Error(validationErr, "Ignoring invalid DeviceQuota", "name", quota.Name)
+			continue
+		}
+		quotas = append(quotas, *quota)
+	}
+
+	slices.SortFunc(quotas, func(a, b ddsv1alpha1.DeviceQuota) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return quotas, nil
+}
+
+// AdmitClaims decides which ResourceClaims fit in the DeviceQuotas. The
+// devices held by the claims, attached or being rescheduled, are counted
+// first, then the devices being prepared for the claims admitted before;
+// the other claims whose devices are being prepared are then admitted
+// oldest first while they fit in every quota that applies to their
+// namespace. Every node makes the same decisions from the same claims.
+// AdmitClaims returns the usage of every limited model once the claims are
+// admitted, the rejected claims, and the UIDs of the claims admitted with
+// devices being prepared.
+func AdmitClaims(quotas []ddsv1alpha1.DeviceQuota, claims []types.ResourceClaimInfo, admitted map[k8stypes.UID]bool) ([]types.DeviceQuotaUsage, map[k8stypes.NamespacedName]types.QuotaRejection, []k8stypes.UID) {
+	used := make([]map[string]int, len(quotas))
+	for i := range quotas {
+		used[i] = make(map[string]int)
+	}
+
+	var preparing []types.ResourceClaimInfo
+	var admittedUIDs []k8stypes.UID
+	for _, claim := range claims {
+		states := []string{"", "Reschedule"}
+		isPreparing := len(countClaimDevices(claim, "Preparing")) > 0
+		switch {
+		case isPreparing && admitted[claim.UID]:
+			states = append(states, "Preparing")
+			admittedUIDs = append(admittedUIDs, claim.UID)
+		case isPreparing:
+			preparing = append(preparing, claim)
+		}
+
+		for i, quota := range quotas {
+			if !quotaApplies(quota, claim.Namespace) {
+				continue
+			}
+			for model, count := range countClaimDevices(claim, states...) {
+				used[i][model] += count
+			}
+		}
+	}
+
+	sort.SliceStable(preparing, func(i, j int) bool {
+		a, b := preparing[i], preparing[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	rejections := make(map[k8stypes.NamespacedName]types.QuotaRejection)
+	for _, claim := range preparing {
+		requested := countClaimDevices(claim, "Preparing")
+		if rejection, rejected := checkQuotas(quotas, used, claim, requested); rejected {
+			rejections[rejection.Claim] = rejection
+			continue
+		}
+
+		for i, quota := range quotas {
+			if quotaApplies(quota, claim.Namespace) {
+				for model, count := range requested {
+					used[i][model] += count
+				}
+			}
+		}
+		admittedUIDs = append(admittedUIDs, claim.UID)
+	}
+
+	var usage []types.DeviceQuotaUsage
+	for i, quota := range quotas {
+		for _, limit := range sortedLimits(quota) {
+			usage = append(usage, types.DeviceQuotaUsage{Quota: quota.Name, Model: limit.Model, Used: used[i][limit.Model]})
+		}
+	}
+
+	return usage, rejections, admittedUIDs
+}
+
+// QuotaAdmissions remembers the ResourceClaims admitted in the DeviceQuotas
+// across the nodes reconciled in parallel. A claim admitted by a node keeps
+// its devices when another node later sees an older claim, so that two nodes
+// do not both take the last devices of a quota. The zero value is ready to
+// use.
+type QuotaAdmissions struct {
+	mu       sync.Mutex
+	admitted map[k8stypes.UID]bool
+	// version counts the changes of admitted.
+	version uint64
+}
+
+// Admit makes the plan of a node with plan from the claims admitted so far,
+// and records the claims admitted by the plan in their place. The nodes are
+// planned in parallel: a plan made while another node changed the admitted
+// claims is made again from the new ones. plan must read the ResourceClaims
+// after it is called, so that it sees the claims admitted before. The usage
+// of the quotas is recorded once the plan is kept; a usage written by an
+// older plan is corrected by the next one.
+func (a *QuotaAdmissions) Admit(ctx context.Context, kubeClient client.Client, plan func(admitted map[k8stypes.UID]bool) (*types.Plan, error)) (*types.Plan, error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	for {
+		admitted, version := a.load()
+		p, err := plan(admitted)
+		if err != nil {
+			return nil, err
+		}
+
+		if a.commit(version, p.AdmittedClaims) {
+			if err := applyQuotaUsage(ctx, kubeClient, p.QuotaUsage); err != nil {
+				return nil, err
+			}
+			return p, nil
+		}
+		logger.V(1).Info("Claims admitted by another node while planning, planning again")
+	}
+}
+
+// load returns the admitted claims, which must not be modified, and their
+// version.
+func (a *QuotaAdmissions) load() (map[k8stypes.UID]bool, uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.admitted, a.version
+}
+
+// commit replaces the admitted claims, unless they changed since version.
+func (a *QuotaAdmissions) commit(version uint64, uids []k8stypes.UID) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if version != a.version {
+		return false
+	}
+
+	admitted := make(map[k8stypes.UID]bool, len(uids))
+	for _, uid := range uids {
+		admitted[uid] = true
+	}
+	if !maps.Equal(admitted, a.admitted) {
+		a.admitted = admitted
+		a.version++
+	}
+
+	return true
+}
+
+// checkQuotas returns the first limit that the requested devices of a claim
+// would exceed, in the order of the quotas and of their models.
+func checkQuotas(quotas []ddsv1alpha1.DeviceQuota, used []map[string]int, claim types.ResourceClaimInfo, requested map[string]int) (types.QuotaRejection, bool) {
+	for i, quota := range quotas {
+		if !quotaApplies(quota, claim.Namespace) {
+			continue
+		}
+		for _, limit := range sortedLimits(quota) {
+			count, ok := requested[limit.Model]
+			if !ok || used[i][limit.Model]+count <= limit.MaxDevices {
+				continue
+			}
+			return types.QuotaRejection{
+				Claim:     k8stypes.NamespacedName{Namespace: claim.Namespace, Name: claim.Name},
+				Quota:     quota.Name,
+				Model:     limit.Model,
+				Requested: count,
+				Used:      used[i][limit.Model],
+				Limit:     limit.MaxDevices,
+			}, true
+		}
+	}
+
+	return types.QuotaRejection{}, false
+}
+
+// quotaApplies reports whether a DeviceQuota limits the claims of a
+// namespace.
+func quotaApplies(quota ddsv1alpha1.DeviceQuota, namespace string) bool {
+	return quota.Spec.Namespace == "" || quota.Spec.Namespace == namespace
+}
+
+func sortedLimits(quota ddsv1alpha1.DeviceQuota) []ddsv1alpha1.DeviceQuotaLimit {
+	limits := slices.Clone(quota.Spec.Limits)
+	slices.SortFunc(limits, func(a, b ddsv1alpha1.DeviceQuotaLimit) int {
+		return strings.Compare(a.Model, b.Model)
+	})
+
+	return limits
+}
+
+// countClaimDevices counts the devices of a claim in the given states by
+// model. Devices whose model is not resolved are not counted.
+func countClaimDevices(claim types.ResourceClaimInfo, states ...string) map[string]int {
+	counts := make(map[string]int)
+	for _, device := range claim.Devices {
+		if device.Model != "" && slices.Contains(states, device.State) {
+			counts[device.Model]++
+		}
+	}
+
+	return counts
+}
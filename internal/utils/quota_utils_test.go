@@ -0,0 +1,473 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func deviceQuota(name, namespace string, limits ...ddsv1alpha1.DeviceQuotaLimit) *ddsv1alpha1.DeviceQuota {
+	return &ddsv1alpha1.DeviceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Generation: 1,
+		},
+		Spec: ddsv1alpha1.DeviceQuotaSpec{
+			Namespace: namespace,
+			Limits:    limits,
+		},
+	}
+}
+
+func newQuotaClient(t *testing.T, quotas ...*ddsv1alpha1.DeviceQuota) client.Client {
+	s := scheme.Scheme
+	if err := ddsv1alpha1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add scheme: %v", err)
+	}
+
+	builder := fake.NewClientBuilder().WithScheme(s).WithStatusSubresource(&ddsv1alpha1.DeviceQuota{})
+	for _, quota := range quotas {
+		builder = builder.WithObjects(quota)
+	}
+
+	return builder.Build()
+}
+
+func quotaClaim(namespace, name string, created time.Time, model string, states ...string) types.ResourceClaimInfo {
+	claim := types.ResourceClaimInfo{
+		Name:              name,
+		Namespace:         namespace,
+		UID:               k8stypes.UID(namespace + "/" + name),
+		NodeName:          "node1",
+		CreationTimestamp: metav1.Time{Time: created},
+	}
+	for _, state := range states {
+		claim.Devices = append(claim.Devices, types.ResourceClaimDevice{Model: model, State: state})
+	}
+
+	return claim
+}
+
+func TestValidateDeviceQuota(t *testing.T) {
+	testCases := []struct {
+		name           string
+		spec           ddsv1alpha1.DeviceQuotaSpec
+		expectedErrMsg string
+	}{
+		{
+			name: "valid quota",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Namespace: "team-a",
+				Limits:    []ddsv1alpha1.DeviceQuotaLimit{{Model: "A100 80G", MaxDevices: 2}, {Model: "H100", MaxDevices: 0}},
+			},
+		},
+		{
+			name: "cluster-wide quota",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Limits: []ddsv1alpha1.DeviceQuotaLimit{{Model: "H100", MaxDevices: 8}},
+			},
+		},
+		{
+			name: "invalid namespace",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Namespace: "Team_A",
+				Limits:    []ddsv1alpha1.DeviceQuotaLimit{{Model: "H100", MaxDevices: 1}},
+			},
+			expectedErrMsg: `invalid namespace "Team_A"`,
+		},
+		{
+			name: "unknown model",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Limits: []ddsv1alpha1.DeviceQuotaLimit{{Model: "V100", MaxDevices: 1}},
+			},
+			expectedErrMsg: "limits: unknown model: V100",
+		},
+		{
+			name: "duplicate model",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Limits: []ddsv1alpha1.DeviceQuotaLimit{{Model: "H100", MaxDevices: 1}, {Model: "H100", MaxDevices: 2}},
+			},
+			expectedErrMsg: `limits: duplicate model "H100"`,
+		},
+		{
+			name: "negative limit",
+			spec: ddsv1alpha1.DeviceQuotaSpec{
+				Limits: []ddsv1alpha1.DeviceQuotaLimit{{Model: "H100", MaxDevices: -1}},
+			},
+			expectedErrMsg: `limits "H100": maxDevices must not be negative`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDeviceQuota(ddsv1alpha1.DeviceQuota{Spec: tc.spec}, policyTestSpec)
+
+			if tc.expectedErrMsg == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tc.expectedErrMsg)
+			}
+			if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+				t.Errorf("expected error containing %q, got %q", tc.expectedErrMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestGetDeviceQuotas(t *testing.T) {
+	kubeClient := newQuotaClient(t,
+		deviceQuota("team-b", "team-b", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 1}),
+		deviceQuota("broken", "", ddsv1alpha1.DeviceQuotaLimit{Model: "V100", MaxDevices: 1}),
+		deviceQuota("cluster", "", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 4}),
+	)
+
+	quotas, err := GetDeviceQuotas(context.Background(), kubeClient, policyTestSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, quota := range quotas {
+		names = append(names, quota.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"cluster", "team-b"}) {
+		t.Errorf("unexpected quotas: %v", names)
+	}
+
+	for name, status := range map[string]metav1.ConditionStatus{"team-b": metav1.ConditionTrue, "broken": metav1.ConditionFalse, "cluster": metav1.ConditionTrue} {
+		quota := &ddsv1alpha1.DeviceQuota{}
+		if err := kubeClient.Get(context.Background(), k8stypes.NamespacedName{Name: name}, quota); err != nil {
+			t.Fatalf("failed to get DeviceQuota: %v", err)
+		}
+		if !meta.IsStatusConditionPresentAndEqual(quota.Status.Conditions, ddsv1alpha1.ConditionTypeReady, status) {
+			t.Errorf("DeviceQuota %s: expected Ready %s, got %v", name, status, quota.Status.Conditions)
+		}
+		if quota.Status.ObservedGeneration != 1 {
+			t.Errorf("DeviceQuota %s: expected observedGeneration 1, got %d", name, quota.Status.ObservedGeneration)
+		}
+	}
+}
+
+func TestAdmitClaims(t *testing.T) {
+	now := time.Now()
+	teamA := deviceQuota("team-a", "team-a", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 2})
+	cluster := deviceQuota("cluster", "", ddsv1alpha1.DeviceQuotaLimit{Model: "A100 80G", MaxDevices: 1}, ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 3})
+
+	testCases := []struct {
+		name               string
+		quotas             []ddsv1alpha1.DeviceQuota
+		claims             []types.ResourceClaimInfo
+		admitted           map[k8stypes.UID]bool
+		expectedUsage      []types.DeviceQuotaUsage
+		expectedRejections map[k8stypes.NamespacedName]types.QuotaRejection
+		expectedAdmitted   []k8stypes.UID
+	}{
+		{
+			name:   "claims within the quota",
+			quotas: []ddsv1alpha1.DeviceQuota{*teamA},
+			claims: []types.ResourceClaimInfo{
+				quotaClaim("team-a", "held", now.Add(-time.Hour), "H100", ""),
+				quotaClaim("team-a", "new", now, "H100", "Preparing"),
+				quotaClaim("team-b", "other", now, "H100", "Preparing", "Preparing"),
+			},
+			expectedUsage:      []types.DeviceQuotaUsage{{Quota: "team-a", Model: "H100", Used: 2}},
+			expectedRejections: map[k8stypes.NamespacedName]types.QuotaRejection{},
+			expectedAdmitted:   []k8stypes.UID{"team-a/new", "team-b/other"},
+		},
+		{
+			name:   "newest claim over the quota",
+			quotas: []ddsv1alpha1.DeviceQuota{*teamA},
+			claims: []types.ResourceClaimInfo{
+				quotaClaim("team-a", "newer", now, "H100", "Preparing"),
+				quotaClaim("team-a", "older", now.Add(-time.Minute), "H100", "Preparing"),
+				quotaClaim("team-a", "rescheduled", now.Add(-time.Hour), "H100", "Reschedule"),
+				quotaClaim("team-a", "failed", now.Add(-time.Hour), "H100", "Failed", "Failed"),
+			},
+			expectedUsage: []types.DeviceQuotaUsage{{Quota: "team-a", Model: "H100", Used: 2}},
+			expectedRejections: map[k8stypes.NamespacedName]types.QuotaRejection{
+				{Namespace: "team-a", Name: "newer"}: {
+					Claim:     k8stypes.NamespacedName{Namespace: "team-a", Name: "newer"},
+					Quota:     "team-a",
+					Model:     "H100",
+					Requested: 1,
+					Used:      2,
+					Limit:     2,
+				},
+			},
+			expectedAdmitted: []k8stypes.UID{"team-a/older"},
+		},
+		{
+			name:   "cluster-wide quota counts every namespace",
+			quotas: []ddsv1alpha1.DeviceQuota{*cluster, *teamA},
+			claims: []types.ResourceClaimInfo{
+				quotaClaim("team-a", "a", now.Add(-2*time.Minute), "H100", "Preparing", "Preparing"),
+				quotaClaim("team-b", "b", now.Add(-time.Minute), "H100", "Preparing", "Preparing"),
+				quotaClaim("team-b", "c", now, "H100", "Preparing"),
+				quotaClaim("team-c", "d", now, "A100 80G", "Preparing", "Preparing"),
+			},
+			expectedUsage: []types.DeviceQuotaUsage{
+				{Quota: "cluster", Model: "A100 80G", Used: 0},
+				{Quota: "cluster", Model: "H100", Used: 3},
+				{Quota: "team-a", Model: "H100", Used: 2},
+			},
+			expectedRejections: map[k8stypes.NamespacedName]types.QuotaRejection{
+				{Namespace: "team-b", Name: "b"}: {
+					Claim:     k8stypes.NamespacedName{Namespace: "team-b", Name: "b"},
+					Quota:     "cluster",
+					Model:     "H100",
+					Requested: 2,
+					Used:      2,
+					Limit:     3,
+				},
+				{Namespace: "team-c", Name: "d"}: {
+					Claim:     k8stypes.NamespacedName{Namespace: "team-c", Name: "d"},
+					Quota:     "cluster",
+					Model:     "A100 80G",
+					Requested: 2,
+					Used:      0,
+					Limit:     1,
+				},
+			},
+			expectedAdmitted: []k8stypes.UID{"team-a/a", "team-b/c"},
+		},
+		{
+			name:   "claims of the same age are admitted by namespace and name",
+			quotas: []ddsv1alpha1.DeviceQuota{*teamA},
+			claims: []types.ResourceClaimInfo{
+				quotaClaim("team-a", "b", now, "H100", "Preparing", "Preparing"),
+				quotaClaim("team-a", "a", now, "H100", "Preparing", "Preparing"),
+			},
+			expectedUsage: []types.DeviceQuotaUsage{{Quota: "team-a", Model: "H100", Used: 2}},
+			expectedRejections: map[k8stypes.NamespacedName]types.QuotaRejection{
+				{Namespace: "team-a", Name: "b"}: {
+					Claim:     k8stypes.NamespacedName{Namespace: "team-a", Name: "b"},
+					Quota:     "team-a",
+					Model:     "H100",
+					Requested: 2,
+					Used:      2,
+					Limit:     2,
+				},
+			},
+			expectedAdmitted: []k8stypes.UID{"team-a/a"},
+		},
+		{
+			name:   "claim admitted before keeps its devices",
+			quotas: []ddsv1alpha1.DeviceQuota{*teamA},
+			claims: []types.ResourceClaimInfo{
+				quotaClaim("team-a", "older", now.Add(-time.Minute), "H100", "Preparing"),
+				quotaClaim("team-a", "newer", now, "H100", "Preparing", "Preparing"),
+			},
+			admitted:      map[k8stypes.UID]bool{"team-a/newer": true},
+			expectedUsage: []types.DeviceQuotaUsage{{Quota: "team-a", Model: "H100", Used: 2}},
+			expectedRejections: map[k8stypes.NamespacedName]types.QuotaRejection{
+				{Namespace: "team-a", Name: "older"}: {
+					Claim:     k8stypes.NamespacedName{Namespace: "team-a", Name: "older"},
+					Quota:     "team-a",
+					Model:     "H100",
+					Requested: 1,
+					Used:      2,
+					Limit:     2,
+				},
+			},
+			expectedAdmitted: []k8stypes.UID{"team-a/newer"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			usage, rejections, admitted := AdmitClaims(tc.quotas, tc.claims, tc.admitted)
+
+			if !reflect.DeepEqual(usage, tc.expectedUsage) {
+				t.Errorf("unexpected usage. Got: %v, Want: %v", usage, tc.expectedUsage)
+			}
+			if !reflect.DeepEqual(rejections, tc.expectedRejections) {
+				t.Errorf("unexpected rejections. Got: %v, Want: %v", rejections, tc.expectedRejections)
+			}
+			if !reflect.DeepEqual(admitted, tc.expectedAdmitted) {
+				t.Errorf("unexpected admitted claims. Got: %v, Want: %v", admitted, tc.expectedAdmitted)
+			}
+		})
+	}
+}
+
+func TestQuotaAdmissions(t *testing.T) {
+	now := time.Now()
+	quota := deviceQuota("team-a", "team-a", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 1})
+	kubeClient := newQuotaClient(t, quota.DeepCopy())
+
+	// node1 takes the last device of the quota for its claim before the
+	// older claim of node2 is allocated; node2 then sees both claims.
+	newer := quotaClaim("team-a", "newer", now, "H100", "Preparing")
+	older := quotaClaim("team-a", "older", now.Add(-time.Minute), "H100", "Preparing")
+	older.NodeName = "node2"
+
+	var admissions QuotaAdmissions
+	planNode := func(nodeName string, claims ...types.ResourceClaimInfo) map[k8stypes.NamespacedName]types.QuotaRejection {
+		var rejections map[k8stypes.NamespacedName]types.QuotaRejection
+		_, err := admissions.Admit(context.Background(), kubeClient, func(admitted map[k8stypes.UID]bool) (*types.Plan, error) {
+			plan := &types.Plan{NodeName: nodeName}
+			plan.QuotaUsage, rejections, plan.AdmittedClaims = AdmitClaims([]ddsv1alpha1.DeviceQuota{*quota}, claims, admitted)
+			return plan, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rejections
+	}
+
+	if rejections := planNode("node1", newer); len(rejections) != 0 {
+		t.Errorf("claim of node1 was rejected: %v", rejections)
+	}
+
+	rejections := planNode("node2", newer, older)
+	if _, ok := rejections[k8stypes.NamespacedName{Namespace: "team-a", Name: "older"}]; !ok || len(rejections) != 1 {
+		t.Errorf("only the claim of node2 should be rejected. Got: %v", rejections)
+	}
+
+	got := &ddsv1alpha1.DeviceQuota{}
+	if err := kubeClient.Get(context.Background(), k8stypes.NamespacedName{Name: "team-a"}, got); err != nil {
+		t.Fatalf("failed to get DeviceQuota: %v", err)
+	}
+	if expected := []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 1}}; !reflect.DeepEqual(got.Status.Used, expected) {
+		t.Errorf("unexpected usage. Got: %v, Want: %v", got.Status.Used, expected)
+	}
+
+	// Once the claim of node1 is attached, it is counted by its devices.
+	attached := quotaClaim("team-a", "newer", now, "H100", "")
+	if rejections := planNode("node2", attached, older); len(rejections) != 1 {
+		t.Errorf("claim of node2 should still be rejected. Got: %v", rejections)
+	}
+}
+
+func TestQuotaAdmissionsRace(t *testing.T) {
+	now := time.Now()
+	quota := deviceQuota("team-a", "team-a", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 1})
+	kubeClient := newQuotaClient(t, quota.DeepCopy())
+
+	first := quotaClaim("team-a", "first", now, "H100", "Preparing")
+	second := quotaClaim("team-a", "second", now.Add(-time.Minute), "H100", "Preparing")
+	second.NodeName = "node2"
+
+	var admissions QuotaAdmissions
+	admit := func(claims []types.ResourceClaimInfo, admitted map[k8stypes.UID]bool) (*types.Plan, map[k8stypes.NamespacedName]types.QuotaRejection) {
+		plan := &types.Plan{}
+		var rejections map[k8stypes.NamespacedName]types.QuotaRejection
+		plan.QuotaUsage, rejections, plan.AdmittedClaims = AdmitClaims([]ddsv1alpha1.DeviceQuota{*quota}, claims, admitted)
+		return plan, rejections
+	}
+
+	// node2 admits its claim while node1 is planned from the claims it read
+	// before, so node1 plans again and sees the claim of node2.
+	calls := 0
+	var rejections map[k8stypes.NamespacedName]types.QuotaRejection
+	_, err := admissions.Admit(context.Background(), kubeClient, func(admitted map[k8stypes.UID]bool) (*types.Plan, error) {
+		calls++
+		if calls == 1 {
+			var stale *types.Plan
+			stale, rejections = admit([]types.ResourceClaimInfo{first}, admitted)
+			if _, err := admissions.Admit(context.Background(), kubeClient, func(admitted map[k8stypes.UID]bool) (*types.Plan, error) {
+				plan, _ := admit([]types.ResourceClaimInfo{second}, admitted)
+				return plan, nil
+			}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return stale, nil
+		}
+		var plan *types.Plan
+		plan, rejections = admit([]types.ResourceClaimInfo{first, second}, admitted)
+		return plan, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("node1 should be planned twice. Got: %d", calls)
+	}
+	if _, ok := rejections[k8stypes.NamespacedName{Namespace: "team-a", Name: "first"}]; !ok || len(rejections) != 1 {
+		t.Errorf("only the claim of node1 should be rejected. Got: %v", rejections)
+	}
+
+	// A plan that admits the same claims does not make the others plan again.
+	calls = 0
+	if _, err := admissions.Admit(context.Background(), kubeClient, func(admitted map[k8stypes.UID]bool) (*types.Plan, error) {
+		calls++
+		plan, _ := admit([]types.ResourceClaimInfo{first, second}, admitted)
+		return plan, nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("node1 should be planned once. Got: %d", calls)
+	}
+}
+
+func TestPatchDeviceQuotaUsage(t *testing.T) {
+	quota := deviceQuota("team-a", "team-a", ddsv1alpha1.DeviceQuotaLimit{Model: "H100", MaxDevices: 2})
+	quota.Status.Used = []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 1}}
+
+	testCases := []struct {
+		name         string
+		dryRun       bool
+		used         []ddsv1alpha1.DeviceQuotaUsage
+		expectedUsed []ddsv1alpha1.DeviceQuotaUsage
+	}{
+		{
+			name:         "usage changed",
+			used:         []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 2}},
+			expectedUsed: []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 2}},
+		},
+		{
+			name:         "usage unchanged",
+			used:         []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 1}},
+			expectedUsed: []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 1}},
+		},
+		{
+			name:         "dry run",
+			dryRun:       true,
+			used:         []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 2}},
+			expectedUsed: []ddsv1alpha1.DeviceQuotaUsage{{Model: "H100", Used: 1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kubeClient := newQuotaClient(t, quota.DeepCopy())
+			ctx := WithDryRun(context.Background(), tc.dryRun)
+
+			if err := PatchDeviceQuotaUsage(ctx, kubeClient, "team-a", tc.used); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := &ddsv1alpha1.DeviceQuota{}
+			if err := kubeClient.Get(ctx, k8stypes.NamespacedName{Name: "team-a"}, got); err != nil {
+				t.Fatalf("failed to get DeviceQuota: %v", err)
+			}
+			if !reflect.DeepEqual(got.Status.Used, tc.expectedUsed) {
+				t.Errorf("unexpected usage. Got: %v, Want: %v", got.Status.Used, tc.expectedUsed)
+			}
+		})
+	}
+
+	t.Run("deleted quota", func(t *testing.T) {
+		kubeClient := newQuotaClient(t)
+		if err := PatchDeviceQuotaUsage(context.Background(), kubeClient, "team-a", nil); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
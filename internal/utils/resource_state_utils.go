@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/metrics"
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// States of a ComposableResource, as set by the Composable Resource Operator.
+const (
+	ResourceStateNone      = "None"
+	ResourceStateAttaching = "Attaching"
+	ResourceStateOnline    = "Online"
+	ResourceStateDetaching = "Detaching"
+	ResourceStateDeleting  = "Deleting"
+)
+
+// ReasonComposableResourceCycling is the reason of the events emitted for
+// ComposableResources that keep failing or re-attaching.
+const ReasonComposableResourceCycling = "ComposableResourceCycling"
+
+// IsResourceFailed reports whether the Composable Resource Operator reported
+// an error for a ComposableResource.
+func IsResourceFailed(resource cdioperator.ComposableResource) bool {
+	return resource.Status.Error != ""
+}
+
+// IsResourceOnline reports whether a ComposableResource is attached and
+// usable: Online, without error and not being deleted.
+func IsResourceOnline(resource cdioperator.ComposableResource) bool {
+	return resource.Status.State == ResourceStateOnline && !IsResourceFailed(resource) && resource.DeletionTimestamp == nil
+}
+
+// IsResourceAttaching reports whether a ComposableResource is still being
+// attached without error.
+func IsResourceAttaching(resource cdioperator.ComposableResource) bool {
+	switch resource.Status.State {
+	case "", ResourceStateNone, ResourceStateAttaching:
+		return !IsResourceFailed(resource) && resource.DeletionTimestamp == nil
+	default:
+		return false
+	}
+}
+
+// IsResourceDetaching reports whether a ComposableResource is on its way out.
+// Its capacity must not be counted as headroom.
+func IsResourceDetaching(resource cdioperator.ComposableResource) bool {
+	return resource.Status.State == ResourceStateDetaching || resource.Status.State == ResourceStateDeleting || resource.DeletionTimestamp != nil
+}
+
+// getResourceState returns the state of a ComposableResource as recorded in
+// the metrics, with "Failed" for resources reporting an error.
+func getResourceState(resource cdioperator.ComposableResource) string {
+	if IsResourceFailed(resource) {
+		return "Failed"
+	}
+	if resource.Status.State == "" {
+		return ResourceStateNone
+	}
+
+	return resource.Status.State
+}
+
+// GetLastUsedTime returns the last-used-time annotation of a ComposableResource.
+// The boolean is false when the resource has not been annotated yet.
+func GetLastUsedTime(resource cdioperator.ComposableResource, labelPrefix string) (time.Time, bool, error) {
+	lastUsedStr, exists := resource.GetAnnotations()[labelPrefix+"/last-used-time"]
+	if !exists {
+		return time.Time{}, false, nil
+	}
+
+	lastUsedTime, err := time.Parse(time.RFC3339, lastUsedStr)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse time: %v", err)
+	}
+
+	return lastUsedTime, true, nil
+}
+
+type trackedResourceState struct {
+	state  string
+	failed bool
+	cycles int
+}
+
+// ResourceStateTracker remembers the last observed state of the
+// ComposableResources of every node, to count the resources that cycle: that
+// fail, or that fall back from Online to attaching. The zero value is ready
+// to use.
+type ResourceStateTracker struct {
+	mu    sync.Mutex
+	nodes map[string]map[k8stypes.UID]trackedResourceState
+}
+
+// Observe records the states of the ComposableResources of a node and
+// returns the cycle count of the resources that cycled since the last call.
+// Resources that are gone are forgotten.
+func (t *ResourceStateTracker) Observe(nodeName string, resources []cdioperator.ComposableResource) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.nodes == nil {
+		t.nodes = make(map[string]map[k8stypes.UID]trackedResourceState)
+	}
+
+	previous := t.nodes[nodeName]
+	current := make(map[k8stypes.UID]trackedResourceState, len(resources))
+	cycled := make(map[string]int)
+
+	for _, resource := range resources {
+		next := trackedResourceState{
+			state:  resource.Status.State,
+			failed: IsResourceFailed(resource),
+		}
+
+		if last, ok := previous[resource.UID]; ok {
+			next.cycles = last.cycles
+			if (next.failed && !last.failed) ||
+				(last.state == ResourceStateOnline && next.state == ResourceStateAttaching) {
+				next.cycles++
+				cycled[resource.Name] = next.cycles
+			}
+		}
+
+		current[resource.UID] = next
+	}
+
+	t.nodes[nodeName] = current
+
+	return cycled
+}
+
+// Forget drops the states of a node that no longer exists.
+func (t *ResourceStateTracker) Forget(nodeName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.nodes, nodeName)
+}
+
+// NotifyComposableResourceStates records the state of the ComposableResources
+// of the snapshot in the metrics, and reports the resources that cycled with
+// an event on the resource and its node.
+func NotifyComposableResourceStates(ctx context.Context, recorder record.EventRecorder, tracker *ResourceStateTracker, snapshot *NodeSnapshot) {
+	metrics.ResetResourceStates(snapshot.NodeName)
+	for _, resource := range snapshot.ComposableResources {
+		metrics.RecordResourceState(snapshot.NodeName, resource.Spec.Model, getResourceState(resource))
+	}
+
+	cycled := tracker.Observe(snapshot.NodeName, snapshot.ComposableResources)
+	for i := range snapshot.ComposableResources {
+		resource := &snapshot.ComposableResources[i]
+		cycles, ok := cycled[resource.Name]
+		if !ok {
+			continue
+		}
+
+		metrics.RecordResourceCycle(snapshot.NodeName, resource.Spec.Model)
+
+		if recorder == nil {
+			continue
+		}
+		message := fmt.Sprintf("ComposableResource %s of model %s on node %s cycled %d times, state %s",
+			resource.Name, resource.Spec.Model, snapshot.NodeName, cycles, resource.Status.State)
+		if IsResourceFailed(*resource) {
+			message += ", error: " + resource.Status.Error
+		}
+		reason := eventReason(ctx, ReasonComposableResourceCycling)
+		recorder.Event(resource, corev1.EventTypeWarning, reason, message)
+		recorder.Event(nodeReference(snapshot.NodeName), corev1.EventTypeWarning, reason, message)
+	}
+}
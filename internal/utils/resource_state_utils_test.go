@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestResourceLifecycle(t *testing.T) {
+	testCases := []struct {
+		name              string
+		state             string
+		errMsg            string
+		deleted           bool
+		expectedOnline    bool
+		expectedAttaching bool
+		expectedDetaching bool
+		expectedFailed    bool
+	}{
+		{
+			name:           "online",
+			state:          "Online",
+			expectedOnline: true,
+		},
+		{
+			name:           "online with error",
+			state:          "Online",
+			errMsg:         "device lost",
+			expectedFailed: true,
+		},
+		{
+			name:              "online and deleted",
+			state:             "Online",
+			deleted:           true,
+			expectedDetaching: true,
+		},
+		{
+			name:              "not started",
+			state:             "",
+			expectedAttaching: true,
+		},
+		{
+			name:              "attaching",
+			state:             "Attaching",
+			expectedAttaching: true,
+		},
+		{
+			name:           "attaching with error",
+			state:          "Attaching",
+			errMsg:         "no free device in the fabric",
+			expectedFailed: true,
+		},
+		{
+			name:              "detaching",
+			state:             "Detaching",
+			expectedDetaching: true,
+		},
+		{
+			name:              "deleting",
+			state:             "Deleting",
+			expectedDetaching: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resource := cdioperator.ComposableResource{
+				Status: cdioperator.ComposableResourceStatus{
+					State: tc.state,
+					Error: tc.errMsg,
+				},
+			}
+			if tc.deleted {
+				resource.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			}
+
+			if got := IsResourceOnline(resource); got != tc.expectedOnline {
+				t.Errorf("IsResourceOnline is incorrect. Got: %v, Want: %v", got, tc.expectedOnline)
+			}
+			if got := IsResourceAttaching(resource); got != tc.expectedAttaching {
+				t.Errorf("IsResourceAttaching is incorrect. Got: %v, Want: %v", got, tc.expectedAttaching)
+			}
+			if got := IsResourceDetaching(resource); got != tc.expectedDetaching {
+				t.Errorf("IsResourceDetaching is incorrect. Got: %v, Want: %v", got, tc.expectedDetaching)
+			}
+			if got := IsResourceFailed(resource); got != tc.expectedFailed {
+				t.Errorf("IsResourceFailed is incorrect. Got: %v, Want: %v", got, tc.expectedFailed)
+			}
+		})
+	}
+}
+
+func TestResourceStateTracker(t *testing.T) {
+	resource := func(state, errMsg string) cdioperator.ComposableResource {
+		return cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "res0", UID: k8stypes.UID("uid0")},
+			Status: cdioperator.ComposableResourceStatus{
+				State: state,
+				Error: errMsg,
+			},
+		}
+	}
+
+	observations := []struct {
+		resources []cdioperator.ComposableResource
+		expected  map[string]int
+	}{
+		{
+			resources: []cdioperator.ComposableResource{resource("Attaching", "")},
+			expected:  map[string]int{},
+		},
+		{
+			resources: []cdioperator.ComposableResource{resource("Online", "")},
+			expected:  map[string]int{},
+		},
+		{
+			resources: []cdioperator.ComposableResource{resource("Attaching", "")},
+			expected:  map[string]int{"res0": 1},
+		},
+		{
+			resources: []cdioperator.ComposableResource{resource("Attaching", "attach failed")},
+			expected:  map[string]int{"res0": 2},
+		},
+		{
+			resources: []cdioperator.ComposableResource{resource("Attaching", "attach failed")},
+			expected:  map[string]int{},
+		},
+		{
+			resources: nil,
+			expected:  map[string]int{},
+		},
+		{
+			resources: []cdioperator.ComposableResource{resource("Attaching", "attach failed")},
+			expected:  map[string]int{},
+		},
+	}
+
+	var tracker ResourceStateTracker
+	for i, observation := range observations {
+		cycled := tracker.Observe("node1", observation.resources)
+		if !reflect.DeepEqual(cycled, observation.expected) {
+			t.Errorf("observation %d: cycled resources are incorrect. Got: %v, Want: %v", i, cycled, observation.expected)
+		}
+	}
+
+	tracker.Forget("node1")
+	if _, ok := tracker.nodes["node1"]; ok {
+		t.Errorf("Expected node1 to be forgotten")
+	}
+}
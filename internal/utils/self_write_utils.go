@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// selfWriteTTL is how long a write is remembered when its watch event is not
+// seen, for example because the informer relisted in between.
+const selfWriteTTL = 10 * time.Minute
+
+// selfWrite identifies the state of an object produced by a write of DDS.
+// Every write gets a new resource version, so a watch event carrying it shows
+// that write and nothing else.
+type selfWrite struct {
+	uid             k8stypes.UID
+	resourceVersion string
+}
+
+// SelfWriteTracker remembers the writes DDS made, so that the watch events
+// they cause can be told apart from changes made by others. The zero value
+// is ready to use.
+type SelfWriteTracker struct {
+	mu     sync.Mutex
+	writes map[selfWrite]time.Time
+	// now returns the current time; tests replace it.
+	now func() time.Time
+}
+
+// Record remembers the object as written by DDS. Writes older than
+// selfWriteTTL are forgotten.
+func (t *SelfWriteTracker) Record(obj metav1.Object) {
+	if obj == nil || obj.GetUID() == "" || obj.GetResourceVersion() == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock()
+	if t.writes == nil {
+		t.writes = make(map[selfWrite]time.Time)
+	}
+	for write, recorded := range t.writes {
+		if now.Sub(recorded) > selfWriteTTL {
+			delete(t.writes, write)
+		}
+	}
+
+	t.writes[selfWrite{uid: obj.GetUID(), resourceVersion: obj.GetResourceVersion()}] = now
+}
+
+// IsSelfWrite reports whether the object is in the state a write of DDS left
+// it in. The write is forgotten once its event is seen.
+func (t *SelfWriteTracker) IsSelfWrite(obj metav1.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	write := selfWrite{uid: obj.GetUID(), resourceVersion: obj.GetResourceVersion()}
+	if _, ok := t.writes[write]; !ok {
+		return false
+	}
+	delete(t.writes, write)
+
+	return true
+}
+
+func (t *SelfWriteTracker) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+
+	return time.Now()
+}
+
+type selfWriteKey struct{}
+
+// WithSelfWriteTracker returns a context in which the writes of DDS are
+// recorded in tracker.
+func WithSelfWriteTracker(ctx context.Context, tracker *SelfWriteTracker) context.Context {
+	return context.WithValue(ctx, selfWriteKey{}, tracker)
+}
+
+// recordSelfWrite records a written object in the tracker of ctx, if any.
+func recordSelfWrite(ctx context.Context, obj metav1.Object) {
+	if tracker, ok := ctx.Value(selfWriteKey{}).(*SelfWriteTracker); ok && tracker != nil {
+		tracker.Record(obj)
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSelfWriteTracker(t *testing.T) {
+	now := time.Now()
+	tracker := &SelfWriteTracker{now: func() time.Time { return now }}
+	object := func(uid, resourceVersion string) metav1.Object {
+		return &metav1.ObjectMeta{UID: k8stypes.UID(uid), ResourceVersion: resourceVersion}
+	}
+
+	tracker.Record(object("uid1", "10"))
+	tracker.Record(object("", "11"))
+
+	if tracker.IsSelfWrite(object("uid1", "12")) {
+		t.Errorf("a later version is not a self write")
+	}
+	if tracker.IsSelfWrite(object("uid2", "10")) {
+		t.Errorf("another object is not a self write")
+	}
+	if !tracker.IsSelfWrite(object("uid1", "10")) {
+		t.Errorf("the recorded version is a self write")
+	}
+	if tracker.IsSelfWrite(object("uid1", "10")) {
+		t.Errorf("a self write is only reported once")
+	}
+	if tracker.IsSelfWrite(nil) {
+		t.Errorf("nil is not a self write")
+	}
+
+	tracker.Record(object("uid1", "20"))
+	now = now.Add(selfWriteTTL + time.Second)
+	tracker.Record(object("uid1", "30"))
+	if tracker.IsSelfWrite(object("uid1", "20")) {
+		t.Errorf("an expired write is forgotten")
+	}
+	if !tracker.IsSelfWrite(object("uid1", "30")) {
+		t.Errorf("the recorded version is a self write")
+	}
+}
+
+func TestRecordSelfWrite(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposabilityRequest{}, &cdioperator.ComposabilityRequestList{})
+
+	request := &cdioperator.ComposabilityRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "request1", UID: "uid1"},
+		Spec: cdioperator.ComposabilityRequestSpec{
+			Resource: cdioperator.ScalarResourceDetails{Model: "A100 40G", Size: 1},
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(request).Build()
+
+	tracker := &SelfWriteTracker{}
+	ctx := WithSelfWriteTracker(context.Background(), tracker)
+	if err := PatchComposabilityRequestSize(ctx, fakeClient, "request1", 2, "composable.test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	patched := &cdioperator.ComposabilityRequest{}
+	if err := fakeClient.Get(ctx, k8stypes.NamespacedName{Name: "request1"}, patched); err != nil {
+		t.Fatalf("failed to get ComposabilityRequest: %v", err)
+	}
+	if !tracker.IsSelfWrite(patched) {
+		t.Errorf("the resize is not recorded as a self write")
+	}
+
+	// Writes outside a tracking context are not recorded.
+	if err := PatchComposabilityRequestSize(context.Background(), fakeClient, "request1", 3, "composable.test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fakeClient.Get(ctx, k8stypes.NamespacedName{Name: "request1"}, patched); err != nil {
+		t.Fatalf("failed to get ComposabilityRequest: %v", err)
+	}
+	if tracker.IsSelfWrite(patched) {
+		t.Errorf("an untracked write is recorded as a self write")
+	}
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ResourceClaimDeviceIndex indexes ResourceClaims by the key of every
+	// allocated device, see DeviceKey.
+	ResourceClaimDeviceIndex = "status.allocation.devices"
+	// ResourceClaimNodeIndex indexes ResourceClaims by the node they are allocated on.
+	ResourceClaimNodeIndex = "status.allocation.nodeName"
+	// ResourceSliceNodeIndex indexes ResourceSlices by the node they are published for.
+	ResourceSliceNodeIndex = "spec.nodeName"
+	// ResourceSlicePoolIndex indexes ResourceSlices by the key of their pool,
+	// see PoolKey.
+	ResourceSlicePoolIndex = "spec.pool"
+	// ComposableResourceNodeIndex indexes ComposableResources by their target node.
+	ComposableResourceNodeIndex = "spec.targetNode"
+	// ComposabilityRequestNodeIndex indexes ComposabilityRequests by their target node.
+	ComposabilityRequestNodeIndex = "spec.resource.targetNode"
+)
+
+// SetupFieldIndexers registers the field indexes used for the lookups of
+// NewNodeSnapshot. The ResourceClaim and ResourceSlice indexes are registered
+// for the version selected with SetDRAVersion.
+func SetupFieldIndexers(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, NewResourceClaim(), ResourceClaimDeviceIndex, func(obj client.Object) []string {
+		rc, err := ToResourceClaim(obj)
+		if err != nil || rc.Status.Allocation == nil {
+			return nil
+		}
+
+		var keys []string
+		for _, device := range rc.Status.Allocation.Devices.Results {
+			keys = append(keys, DeviceKey(device.Driver, device.Pool, device.Device))
+		}
+		return keys
+	}); err != nil {
+		return fmt.Errorf("failed to index ResourceClaim %s: %v", ResourceClaimDeviceIndex, err)
+	}
+
+	if err := indexer.IndexField(ctx, NewResourceClaim(), ResourceClaimNodeIndex, func(obj client.Object) []string {
+		rc, err := ToResourceClaim(obj)
+		if err != nil {
+			return nil
+		}
+		return indexValue(GetResourceClaimNodeName(*rc))
+	}); err != nil {
+		return fmt.Errorf("failed to index ResourceClaim %s: %v", ResourceClaimNodeIndex, err)
+	}
+
+	if err := indexer.IndexField(ctx, NewResourceSlice(), ResourceSliceNodeIndex, func(obj client.Object) []string {
+		return indexValue(GetResourceSliceNodeName(obj))
+	}); err != nil {
+		return fmt.Errorf("failed to index ResourceSlice %s: %v", ResourceSliceNodeIndex, err)
+	}
+
+	if err := indexer.IndexField(ctx, NewResourceSlice(), ResourceSlicePoolIndex, func(obj client.Object) []string {
+		rs, err := ToResourceSlice(obj)
+		if err != nil {
+			return nil
+		}
+		return []string{PoolKey(rs.Spec.Driver, rs.Spec.Pool.Name)}
+	}); err != nil {
+		return fmt.Errorf("failed to index ResourceSlice %s: %v", ResourceSlicePoolIndex, err)
+	}
+
+	if err := indexer.IndexField(ctx, &cdioperator.ComposableResource{}, ComposableResourceNodeIndex, func(obj client.Object) []string {
+		return indexValue(obj.(*cdioperator.ComposableResource).Spec.TargetNode)
+	}); err != nil {
+		return fmt.Errorf("failed to index ComposableResource %s: %v", ComposableResourceNodeIndex, err)
+	}
+
+	if err := indexer.IndexField(ctx, &cdioperator.ComposabilityRequest{}, ComposabilityRequestNodeIndex, func(obj client.Object) []string {
+		return indexValue(obj.(*cdioperator.ComposabilityRequest).Spec.Resource.TargetNode)
+	}); err != nil {
+		return fmt.Errorf("failed to index ComposabilityRequest %s: %v", ComposabilityRequestNodeIndex, err)
+	}
+
+	return nil
+}
+
+// DeviceKey identifies a DRA device across ResourceSlices and ResourceClaim allocations.
+func DeviceKey(driver, pool, device string) string {
+	return PoolKey(driver, pool) + "/" + device
+}
+
+// PoolKey identifies the pool of a DRA driver, published in one or more
+// ResourceSlices.
+func PoolKey(driver, pool string) string {
+	return driver + "/" + pool
+}
+
+func indexValue(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	return []string{value}
+}
+
+// NodeSnapshot holds the objects of one node that the device accounting
+// needs. It is read once at the start of a reconcile through the field
+// indexes, so that the helpers do not list the whole cluster again.
+type NodeSnapshot struct {
+	NodeName              string
+	ResourceClaimInfos    []types.ResourceClaimInfo
+	ResourceSliceInfos    []types.ResourceSliceInfo
+	ComposableResources   []cdioperator.ComposableResource
+	ComposabilityRequests []cdioperator.ComposabilityRequest
+
+	usedDevices map[string]bool
+}
+
+// NewNodeSnapshot reads the ResourceClaims, ResourceSlices, ComposableResources
+// and ComposabilityRequests of a node.
+func NewNodeSnapshot(ctx context.Context, kubeClient client.Client, nodeName string, composableDRASpec types.ComposableDRASpec) (*NodeSnapshot, error) {
+	resourceClaimInfos, err := GetResourceClaimInfo(ctx, kubeClient, composableDRASpec, client.MatchingFields{ResourceClaimNodeIndex: nodeName})
+	if err != nil {
+		return nil, err
+	}
+
+	resourceSliceInfos, err := GetResourceSliceInfo(ctx, kubeClient, composableDRASpec, client.MatchingFields{ResourceSliceNodeIndex: nodeName})
+	if err != nil {
+		return nil, err
+	}
+
+	return NewNodeSnapshotFromInfos(ctx, kubeClient, nodeName, resourceClaimInfos, resourceSliceInfos)
+}
+
+// NewNodeSnapshotFromInfos completes a snapshot from already collected
+// ResourceClaim and ResourceSlice infos.
+func NewNodeSnapshotFromInfos(ctx context.Context, kubeClient client.Client, nodeName string, resourceClaimInfos []types.ResourceClaimInfo, resourceSliceInfos []types.ResourceSliceInfo) (*NodeSnapshot, error) {
+	logger := ctrl.LoggerFrom(ctx)
+	logger.V(1).Info("Start collecting node snapshot")
+
+	snapshot := &NodeSnapshot{
+		NodeName:           nodeName,
+		ResourceClaimInfos: resourceClaimInfos,
+		ResourceSliceInfos: resourceSliceInfos,
+		usedDevices:        make(map[string]bool),
+	}
+
+	resourceList := &cdioperator.ComposableResourceList{}
+	if err := kubeClient.List(ctx, resourceList, client.MatchingFields{ComposableResourceNodeIndex: nodeName}); err != nil {
+		return nil, fmt.Errorf("failed to list ComposableResourceList: %v", err)
+	}
+	snapshot.ComposableResources = resourceList.Items
+
+	composabilityRequestList := &cdioperator.ComposabilityRequestList{}
+	if err := kubeClient.List(ctx, composabilityRequestList, client.MatchingFields{ComposabilityRequestNodeIndex: nodeName}); err != nil {
+		return nil, fmt.Errorf("failed to list composabilityRequestList: %v", err)
+	}
+	snapshot.ComposabilityRequests = composabilityRequestList.Items
+
+	for _, resourceSliceInfo := range resourceSliceInfos {
+		for _, device := range resourceSliceInfo.Devices {
+			isUsed, err := IsDeviceUsedByPod(ctx, kubeClient, device.Name, resourceSliceInfo)
+			if err != nil {
+				return nil, err
+			}
+			if isUsed {
+				snapshot.usedDevices[DeviceKey(resourceSliceInfo.Driver, resourceSliceInfo.Pool, device.Name)] = true
+			}
+		}
+	}
+
+	logger.V(1).Info("Finish collecting node snapshot",
+		"composableResources", len(snapshot.ComposableResources),
+		"composabilityRequests", len(snapshot.ComposabilityRequests),
+		"usedDevices", len(snapshot.usedDevices))
+
+	return snapshot, nil
+}
+
+// IsDeviceUsedByPod reports whether a device of a ResourceSlice in the
+// snapshot is allocated to a ResourceClaim.
+func (s *NodeSnapshot) IsDeviceUsedByPod(deviceName string, resourceSliceInfo types.ResourceSliceInfo) bool {
+	return s.usedDevices[DeviceKey(resourceSliceInfo.Driver, resourceSliceInfo.Pool, deviceName)]
+}
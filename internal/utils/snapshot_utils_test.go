@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	cdioperator "github.com/IBM/composable-resource-operator/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+	corev1 "k8s.io/api/core/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeFieldIndexer registers field indexes on a fake client builder.
+type fakeFieldIndexer struct {
+	builder *fake.ClientBuilder
+}
+
+func (f fakeFieldIndexer) IndexField(_ context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	f.builder.WithIndex(obj, field, extractValue)
+	return nil
+}
+
+// newIndexedClientBuilder returns a fake client builder with the DDS field
+// indexes registered, as the manager cache has them.
+func newIndexedClientBuilder(tb testing.TB) *fake.ClientBuilder {
+	s := scheme.Scheme
+	s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposabilityRequest{}, &cdioperator.ComposabilityRequestList{})
+	s.AddKnownTypes(metav1.SchemeGroupVersion, &cdioperator.ComposableResource{}, &cdioperator.ComposableResourceList{})
+
+	builder := fake.NewClientBuilder().WithScheme(s)
+	if err := SetupFieldIndexers(context.Background(), fakeFieldIndexer{builder: builder}); err != nil {
+		tb.Fatalf("failed to set up field indexers: %v", err)
+	}
+
+	return builder
+}
+
+func allocatedResourceClaim(name, nodeName, driver, pool, device string) *resourceapi.ResourceClaim {
+	return &resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Status: resourceapi.ResourceClaimStatus{
+			ReservedFor: []resourceapi.ResourceClaimConsumerReference{
+				{
+					Name:     "pod-" + name,
+					Resource: "pods",
+				},
+			},
+			Allocation: &resourceapi.AllocationResult{
+				NodeSelector: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchFields: []corev1.NodeSelectorRequirement{
+								{
+									Key:      "metadata.name",
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   []string{nodeName},
+								},
+							},
+						},
+					},
+				},
+				Devices: resourceapi.DeviceAllocationResult{
+					Results: []resourceapi.DeviceRequestAllocationResult{
+						{
+							Driver: driver,
+							Pool:   pool,
+							Device: device,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewNodeSnapshot(t *testing.T) {
+	resourceSliceInfos := []types.ResourceSliceInfo{
+		{
+			Name:     "rs1",
+			NodeName: "node1",
+			Driver:   "gpu.nvidia.com",
+			Pool:     "node1",
+			Devices: []types.ResourceSliceDevice{
+				{
+					Name: "gpu0",
+					UUID: "123",
+				},
+				{
+					Name: "gpu1",
+					UUID: "456",
+				},
+			},
+		},
+	}
+
+	clientObjects := []runtime.Object{
+		allocatedResourceClaim("rc0", "node1", "gpu.nvidia.com", "node1", "gpu0"),
+		allocatedResourceClaim("rc1", "node2", "gpu.nvidia.com", "node2", "gpu1"),
+		&cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "res0"},
+			Spec:       cdioperator.ComposableResourceSpec{TargetNode: "node1"},
+		},
+		&cdioperator.ComposableResource{
+			ObjectMeta: metav1.ObjectMeta{Name: "res1"},
+			Spec:       cdioperator.ComposableResourceSpec{TargetNode: "node2"},
+		},
+		&cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "cr0"},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{TargetNode: "node1"},
+			},
+		},
+		&cdioperator.ComposabilityRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "cr1"},
+			Spec: cdioperator.ComposabilityRequestSpec{
+				Resource: cdioperator.ScalarResourceDetails{TargetNode: "node2"},
+			},
+		},
+	}
+
+	fakeClient := newIndexedClientBuilder(t).WithRuntimeObjects(clientObjects...).Build()
+
+	snapshot, err := NewNodeSnapshotFromInfos(context.Background(), fakeClient, "node1", nil, resourceSliceInfos)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resourceNames, requestNames []string
+	for _, resource := range snapshot.ComposableResources {
+		resourceNames = append(resourceNames, resource.Name)
+	}
+	for _, cr := range snapshot.ComposabilityRequests {
+		requestNames = append(requestNames, cr.Name)
+	}
+
+	if !reflect.DeepEqual(resourceNames, []string{"res0"}) {
+		t.Errorf("ComposableResources are incorrect. Got: %v, Want: [res0]", resourceNames)
+	}
+	if !reflect.DeepEqual(requestNames, []string{"cr0"}) {
+		t.Errorf("ComposabilityRequests are incorrect. Got: %v, Want: [cr0]", requestNames)
+	}
+
+	if !snapshot.IsDeviceUsedByPod("gpu0", resourceSliceInfos[0]) {
+		t.Errorf("Expected gpu0 to be used by a pod")
+	}
+	if snapshot.IsDeviceUsedByPod("gpu1", resourceSliceInfos[0]) {
+		t.Errorf("Expected gpu1 of pool node1 not to be used by a pod")
+	}
+}
+
+const (
+	benchmarkDevicesPerNode = 8
+	benchmarkModel          = "A100 40G"
+)
+
+// newBenchmarkCluster builds a cluster with claimCount allocated claims spread
+// over claimCount/10 nodes and returns the infos of the first node.
+func newBenchmarkCluster(b *testing.B, claimCount int) (client.Client, []types.ResourceClaimInfo, []types.ResourceSliceInfo) {
+	nodeCount := claimCount / 10
+
+	var clientObjects []runtime.Object
+	var resourceClaimInfos []types.ResourceClaimInfo
+	var resourceSliceInfos []types.ResourceSliceInfo
+
+	for i := range nodeCount {
+		nodeName := fmt.Sprintf("node-%d", i)
+		resourceSliceInfo := types.ResourceSliceInfo{
+			Name:     "slice-" + nodeName,
+			NodeName: nodeName,
+			Driver:   "gpu.nvidia.com",
+			Pool:     nodeName,
+		}
+
+		for j := range benchmarkDevicesPerNode {
+			uuid := fmt.Sprintf("%s-uuid-%d", nodeName, j)
+			resourceSliceInfo.Devices = append(resourceSliceInfo.Devices, types.ResourceSliceDevice{
+				Name: fmt.Sprintf("gpu%d", j),
+				UUID: uuid,
+			})
+			clientObjects = append(clientObjects, &cdioperator.ComposableResource{
+				ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-res-%d", nodeName, j)},
+				Spec: cdioperator.ComposableResourceSpec{
+					Model:      benchmarkModel,
+					TargetNode: nodeName,
+				},
+				Status: cdioperator.ComposableResourceStatus{
+					State:    "Online",
+					DeviceID: uuid,
+				},
+			})
+		}
+
+		if i == 0 {
+			resourceSliceInfos = append(resourceSliceInfos, resourceSliceInfo)
+		}
+	}
+
+	for i := range claimCount {
+		nodeName := fmt.Sprintf("node-%d", i%nodeCount)
+		claimName := fmt.Sprintf("claim-%d", i)
+		device := fmt.Sprintf("gpu%d", (i/nodeCount)%benchmarkDevicesPerNode)
+		clientObjects = append(clientObjects, allocatedResourceClaim(claimName, nodeName, "gpu.nvidia.com", nodeName, device))
+
+		if i%nodeCount == 0 {
+			resourceClaimInfos = append(resourceClaimInfos, types.ResourceClaimInfo{
+				Name:      claimName,
+				Namespace: "default",
+				NodeName:  nodeName,
+				Devices: []types.ResourceClaimDevice{
+					{
+						Name:  device,
+						Model: benchmarkModel,
+						State: "Preparing",
+					},
+				},
+			})
+		}
+	}
+
+	fakeClient := newIndexedClientBuilder(b).WithRuntimeObjects(clientObjects...).Build()
+
+	return fakeClient, resourceClaimInfos, resourceSliceInfos
+}
+
+// listBasedPodAllocatedDevicesCount mirrors the lookups done before the field
+// indexes: every ComposableResource is listed, and every ResourceClaim is
+// listed again for each attached device.
+func listBasedPodAllocatedDevicesCount(ctx context.Context, kubeClient client.Client, model, nodeName string, resourceSliceInfos []types.ResourceSliceInfo) (int64, error) {
+	var count int64
+
+	resourceList := &cdioperator.ComposableResourceList{}
+	if err := kubeClient.List(ctx, resourceList); err != nil {
+		return 0, err
+	}
+
+	for _, resource := range resourceList.Items {
+		if resource.Spec.TargetNode != nodeName || resource.Spec.Model != model || resource.Status.State != "Online" {
+			continue
+		}
+		isRed, resourceSliceInfo, deviceName := IsDeviceResourceSliceRed(resource.Status.DeviceID, resourceSliceInfos)
+		if !isRed {
+			continue
+		}
+
+		resourceClaimList := &resourceapi.ResourceClaimList{}
+		if err := kubeClient.List(ctx, resourceClaimList); err != nil {
+			return 0, err
+		}
+	ClaimLoop:
+		for _, resourceClaim := range resourceClaimList.Items {
+			for _, result := range resourceClaim.Status.Allocation.Devices.Results {
+				if result.Driver == resourceSliceInfo.Driver && result.Pool == resourceSliceInfo.Pool && result.Device == deviceName {
+					count++
+					break ClaimLoop
+				}
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// BenchmarkConfiguredDeviceCount computes the configured device count once
+// per claim of a node, as the planner does when it checks max_device.
+func BenchmarkConfiguredDeviceCount(b *testing.B) {
+	ctx := context.Background()
+
+	for _, claimCount := range []int{1000, 5000} {
+		fakeClient, resourceClaimInfos, resourceSliceInfos := newBenchmarkCluster(b, claimCount)
+		nodeName := resourceSliceInfos[0].NodeName
+
+		b.Run(fmt.Sprintf("ListPerLookup/claims=%d", claimCount), func(b *testing.B) {
+			for range b.N {
+				for range resourceClaimInfos {
+					if _, err := listBasedPodAllocatedDevicesCount(ctx, fakeClient, benchmarkModel, nodeName, resourceSliceInfos); err != nil {
+						b.Fatalf("unexpected error: %v", err)
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("NodeSnapshot/claims=%d", claimCount), func(b *testing.B) {
+			for range b.N {
+				snapshot, err := NewNodeSnapshotFromInfos(ctx, fakeClient, nodeName, resourceClaimInfos, resourceSliceInfos)
+				if err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				for range resourceClaimInfos {
+					GetConfiguredDeviceCount(ctx, snapshot, benchmarkModel, resourceClaimInfos)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+)
+
+// ValidateComposableDRASpec checks the device catalog for inconsistencies
+// that would otherwise only surface while reconciling. All problems found
+// are returned together.
+func ValidateComposableDRASpec(composableDRASpec types.ComposableDRASpec) error {
+	var errs []error
+
+	if len(composableDRASpec.DeviceInfos) == 0 {
+		errs = append(errs, fmt.Errorf("device-info must not be empty"))
+	}
+
+	if composableDRASpec.LabelPrefix == "" {
+		errs = append(errs, fmt.Errorf("label-prefix must not be empty"))
+	}
+
+	driverNames := make(map[string]struct{})
+	for _, driverInfo := range composableDRASpec.Drivers {
+		if driverInfo.Name == "" {
+			errs = append(errs, fmt.Errorf("drivers: name must not be empty"))
+		} else if _, exists := driverNames[driverInfo.Name]; exists {
+			errs = append(errs, fmt.Errorf("drivers: duplicate name %q", driverInfo.Name))
+		}
+		driverNames[driverInfo.Name] = struct{}{}
+	}
+
+	indexes := make(map[int]types.DeviceInfo)
+	modelNames := make(map[string]struct{})
+	deviceNames := make(map[string]struct{})
+
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		if deviceInfo.Index < 1 {
+			errs = append(errs, fmt.Errorf("device-info %q: index must be positive, got %d", deviceInfo.CDIModelName, deviceInfo.Index))
+		}
+		if _, exists := indexes[deviceInfo.Index]; exists {
+			errs = append(errs, fmt.Errorf("device-info %q: duplicate index %d", deviceInfo.CDIModelName, deviceInfo.Index))
+		}
+		indexes[deviceInfo.Index] = deviceInfo
+
+		if deviceInfo.CDIModelName == "" {
+			errs = append(errs, fmt.Errorf("device-info index %d: cdi-model-name must not be empty", deviceInfo.Index))
+		} else if _, exists := modelNames[deviceInfo.CDIModelName]; exists {
+			errs = append(errs, fmt.Errorf("device-info index %d: duplicate cdi-model-name %q", deviceInfo.Index, deviceInfo.CDIModelName))
+		}
+		modelNames[deviceInfo.CDIModelName] = struct{}{}
+
+		if deviceInfo.K8sDeviceName == "" {
+			errs = append(errs, fmt.Errorf("device-info index %d: k8s-device-name must not be empty", deviceInfo.Index))
+		} else if _, exists := deviceNames[deviceInfo.K8sDeviceName]; exists {
+			errs = append(errs, fmt.Errorf("device-info index %d: duplicate k8s-device-name %q", deviceInfo.Index, deviceInfo.K8sDeviceName))
+		}
+		deviceNames[deviceInfo.K8sDeviceName] = struct{}{}
+
+		for _, match := range deviceInfo.Match {
+			if err := validateAttributeMatch(match); err != nil {
+				errs = append(errs, fmt.Errorf("device-info index %d: match: %v", deviceInfo.Index, err))
+			}
+		}
+
+		if GetResourceType(composableDRASpec, deviceInfo) == "" {
+			errs = append(errs, fmt.Errorf("device-info index %d: no resource-type set and no resource-type in drivers for driver %q", deviceInfo.Index, deviceInfo.DriverName))
+		}
+	}
+
+	for _, deviceInfo := range composableDRASpec.DeviceInfos {
+		for _, otherIndex := range deviceInfo.CannotCoexistWith {
+			if otherIndex == deviceInfo.Index {
+				errs = append(errs, fmt.Errorf("device-info index %d: cannot-coexist-with refers to itself", deviceInfo.Index))
+				continue
+			}
+
+			other, exists := indexes[otherIndex]
+			if !exists {
+				errs = append(errs, fmt.Errorf("device-info index %d: cannot-coexist-with refers to unknown index %d", deviceInfo.Index, otherIndex))
+				continue
+			}
+
+			if notIn(deviceInfo.Index, other.CannotCoexistWith) {
+				errs = append(errs, fmt.Errorf("device-info index %d: cannot-coexist-with %d is not mirrored by index %d", deviceInfo.Index, otherIndex, otherIndex))
+			}
+		}
+	}
+
+	if err := validateFabricIDRange(composableDRASpec.FabricIDRange); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateFabricIDRange(fabricIDRange []int) error {
+	seen := make(map[int]struct{}, len(fabricIDRange))
+
+	for _, id := range fabricIDRange {
+		if id < 0 {
+			return fmt.Errorf("fabric-id-range: id must not be negative, got %d", id)
+		}
+		if _, exists := seen[id]; exists {
+			return fmt.Errorf("fabric-id-range: duplicate id %d", id)
+		}
+		seen[id] = struct{}{}
+	}
+
+	return nil
+}
+
+func notIn[T comparable](target T, slice []T) bool {
+	return !slices.Contains(slice, target)
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/InfraDDS/dynamic-device-scaler/internal/types"
+)
+
+func TestValidateComposableDRASpec(t *testing.T) {
+	validDevices := func() []types.DeviceInfo {
+		return []types.DeviceInfo{
+			{
+				Index:             1,
+				CDIModelName:      "A100 40G",
+				K8sDeviceName:     "nvidia-a100-40",
+				DriverName:        "gpu.nvidia.com",
+				CannotCoexistWith: []int{2},
+			},
+			{
+				Index:             2,
+				CDIModelName:      "H100",
+				K8sDeviceName:     "nvidia-h100",
+				DriverName:        "gpu.nvidia.com",
+				CannotCoexistWith: []int{1},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		modify         func(spec *types.ComposableDRASpec)
+		wantErr        bool
+		expectedErrMsg string
+	}{
+		{
+			name: "valid spec",
+		},
+		{
+			name: "empty device info",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos = nil
+			},
+			wantErr:        true,
+			expectedErrMsg: "device-info must not be empty",
+		},
+		{
+			name: "empty label prefix",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.LabelPrefix = ""
+			},
+			wantErr:        true,
+			expectedErrMsg: "label-prefix must not be empty",
+		},
+		{
+			name: "duplicate index",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].Index = 1
+				spec.DeviceInfos[1].CannotCoexistWith = nil
+				spec.DeviceInfos[0].CannotCoexistWith = nil
+			},
+			wantErr:        true,
+			expectedErrMsg: "duplicate index 1",
+		},
+		{
+			name: "dangling coexist index",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[0].CannotCoexistWith = []int{2, 7}
+			},
+			wantErr:        true,
+			expectedErrMsg: "refers to unknown index 7",
+		},
+		{
+			name: "asymmetric exclusion",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].CannotCoexistWith = nil
+			},
+			wantErr:        true,
+			expectedErrMsg: "cannot-coexist-with 2 is not mirrored by index 2",
+		},
+		{
+			name: "self exclusion",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[0].CannotCoexistWith = []int{1, 2}
+			},
+			wantErr:        true,
+			expectedErrMsg: "refers to itself",
+		},
+		{
+			name: "duplicate model name",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].CDIModelName = "A100 40G"
+			},
+			wantErr:        true,
+			expectedErrMsg: "duplicate cdi-model-name \"A100 40G\"",
+		},
+		{
+			name: "duplicate device name",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].K8sDeviceName = "nvidia-a100-40"
+			},
+			wantErr:        true,
+			expectedErrMsg: "duplicate k8s-device-name \"nvidia-a100-40\"",
+		},
+		{
+			name: "driver without resource type",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].DriverName = "fpga.example.com"
+			},
+			wantErr:        true,
+			expectedErrMsg: "no resource-type in drivers for driver \"fpga.example.com\"",
+		},
+		{
+			name: "driver with resource type in the catalog",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].DriverName = "fpga.example.com"
+				spec.DeviceInfos[1].ResourceType = "fpga"
+			},
+		},
+		{
+			name: "driver with resource type in drivers",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[1].DriverName = "fpga.example.com"
+				spec.Drivers = []types.DriverInfo{{Name: "fpga.example.com", ResourceType: "fpga"}}
+			},
+		},
+		{
+			name: "duplicate driver",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.Drivers = []types.DriverInfo{{Name: "fpga.example.com", ResourceType: "fpga"}, {Name: "fpga.example.com"}}
+			},
+			wantErr:        true,
+			expectedErrMsg: "drivers: duplicate name \"fpga.example.com\"",
+		},
+		{
+			name: "match rule with attribute and capacity",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[0].Match = []types.AttributeMatch{{Attribute: "memory", Capacity: "memory", Value: "80Gi"}}
+			},
+			wantErr:        true,
+			expectedErrMsg: "device-info index 1: match: exactly one of attribute and capacity must be set",
+		},
+		{
+			name: "match rule with invalid int",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.DeviceInfos[0].Match = []types.AttributeMatch{{Attribute: "cudaCores", Type: "int", Value: "many"}}
+			},
+			wantErr:        true,
+			expectedErrMsg: "attribute cudaCores: invalid int \"many\"",
+		},
+		{
+			name: "negative fabric id",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.FabricIDRange = []int{1, -1}
+			},
+			wantErr:        true,
+			expectedErrMsg: "fabric-id-range: id must not be negative",
+		},
+		{
+			name: "duplicate fabric id",
+			modify: func(spec *types.ComposableDRASpec) {
+				spec.FabricIDRange = []int{1, 2, 1}
+			},
+			wantErr:        true,
+			expectedErrMsg: "fabric-id-range: duplicate id 1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := types.ComposableDRASpec{
+				DeviceInfos:   validDevices(),
+				LabelPrefix:   "composable.fsastech.com",
+				FabricIDRange: []int{1, 2, 3},
+			}
+			if tc.modify != nil {
+				tc.modify(&spec)
+			}
+
+			err := ValidateComposableDRASpec(spec)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error but got nil")
+				}
+				if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+					t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNotIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   int
+		slice    []int
+		expected bool
+	}{
+		{"target not in slice", 5, []int{1, 2, 3, 4}, true},
+		{"target in slice", 3, []int{1, 2, 3, 4}, false},
+		{"empty slice", 1, []int{}, true},
+		{"single element slice, target not in", 2, []int{1}, true},
+		{"single element slice, target in", 1, []int{1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := notIn(tt.target, tt.slice)
+			if result != tt.expected {
+				t.Errorf("notIn(%v, %v) = %v; expected %v", tt.target, tt.slice, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+	"github.com/InfraDDS/dynamic-device-scaler/internal/utils"
+)
+
+var ddsconfiglog = logf.Log.WithName("ddsconfig-resource")
+
+// SetupDDSConfigWebhookWithManager registers the webhook for DDSConfig in the manager.
+func SetupDDSConfigWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&ddsv1alpha1.DDSConfig{}).
+		WithValidator(&DDSConfigCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-infra-dds-v1alpha1-ddsconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=infra.dds,resources=ddsconfigs,verbs=create;update,versions=v1alpha1,name=vddsconfig-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// DDSConfigCustomValidator rejects DDSConfigs whose device catalog is inconsistent.
+type DDSConfigCustomValidator struct{}
+
+var _ webhook.CustomValidator = &DDSConfigCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type DDSConfig.
+func (v *DDSConfigCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ddsConfig, ok := obj.(*ddsv1alpha1.DDSConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a DDSConfig object but got %T", obj)
+	}
+	ddsconfiglog.Info("Validation for DDSConfig upon creation", "name", ddsConfig.GetName())
+
+	return nil, validateDDSConfig(ddsConfig)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type DDSConfig.
+func (v *DDSConfigCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	ddsConfig, ok := newObj.(*ddsv1alpha1.DDSConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a DDSConfig object for the newObj but got %T", newObj)
+	}
+	ddsconfiglog.Info("Validation for DDSConfig upon update", "name", ddsConfig.GetName())
+
+	return nil, validateDDSConfig(ddsConfig)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type DDSConfig.
+func (v *DDSConfigCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func validateDDSConfig(ddsConfig *ddsv1alpha1.DDSConfig) error {
+	if err := utils.ValidateComposableDRASpec(utils.ConvertDDSConfigSpec(ddsConfig.Spec)); err != nil {
+		return fmt.Errorf("invalid DDSConfig %s: %v", ddsConfig.Name, err)
+	}
+
+	return nil
+}
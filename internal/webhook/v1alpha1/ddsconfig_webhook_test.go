@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ddsv1alpha1 "github.com/InfraDDS/dynamic-device-scaler/api/v1alpha1"
+)
+
+func TestDDSConfigCustomValidator(t *testing.T) {
+	tests := []struct {
+		name           string
+		deviceInfos    []ddsv1alpha1.DeviceInfo
+		labelPrefix    string
+		wantErr        bool
+		expectedErrMsg string
+	}{
+		{
+			name: "valid config",
+			deviceInfos: []ddsv1alpha1.DeviceInfo{
+				{Index: 1, CDIModelName: "A100 40G", DriverName: "gpu.nvidia.com", K8sDeviceName: "nvidia-a100-40", CannotCoexistWith: []int{2}},
+				{Index: 2, CDIModelName: "H100", DriverName: "gpu.nvidia.com", K8sDeviceName: "nvidia-h100", CannotCoexistWith: []int{1}},
+			},
+			labelPrefix: "composable.fsastech.com",
+		},
+		{
+			name: "asymmetric exclusion",
+			deviceInfos: []ddsv1alpha1.DeviceInfo{
+				{Index: 1, CDIModelName: "A100 40G", DriverName: "gpu.nvidia.com", K8sDeviceName: "nvidia-a100-40", CannotCoexistWith: []int{2}},
+				{Index: 2, CDIModelName: "H100", DriverName: "gpu.nvidia.com", K8sDeviceName: "nvidia-h100"},
+			},
+			labelPrefix:    "composable.fsastech.com",
+			wantErr:        true,
+			expectedErrMsg: "is not mirrored by index 2",
+		},
+		{
+			name: "empty label prefix",
+			deviceInfos: []ddsv1alpha1.DeviceInfo{
+				{Index: 1, CDIModelName: "A100 40G", DriverName: "gpu.nvidia.com", K8sDeviceName: "nvidia-a100-40"},
+			},
+			wantErr:        true,
+			expectedErrMsg: "label-prefix must not be empty",
+		},
+	}
+
+	validator := &DDSConfigCustomValidator{}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ddsConfig := &ddsv1alpha1.DDSConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "composable-dra-dds"},
+				Spec: ddsv1alpha1.DDSConfigSpec{
+					DeviceInfos: tc.deviceInfos,
+					LabelPrefix: tc.labelPrefix,
+				},
+			}
+
+			_, createErr := validator.ValidateCreate(context.Background(), ddsConfig)
+			_, updateErr := validator.ValidateUpdate(context.Background(), ddsConfig.DeepCopy(), ddsConfig)
+
+			for _, err := range []error{createErr, updateErr} {
+				if tc.wantErr {
+					if err == nil {
+						t.Fatal("expected error but got nil")
+					}
+					if !strings.Contains(err.Error(), tc.expectedErrMsg) {
+						t.Errorf("error message %q does not contain %q", err.Error(), tc.expectedErrMsg)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}